@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// inspects the Kafka topics and consumer groups of the pipeline and resets group offsets
+//
+//	admin [flags] topics [topic...]  list the topics (all by default) with the leader, replicas and ISR of their partitions
+//	admin [flags] groups [group...]  describe the consumer groups (the pipeline groups by default) and their members
+//	admin [flags] lag [group...]     show the lag of the consumer groups (the pipeline groups by default) per partition
+//	admin [flags] reset-offsets      reset the offsets of a group (-group) on a topic (-topic) to a target (-to)
+func main() {
+	group := flag.String("group", "", "consumer group whose offsets are reset")
+	topic := flag.String("topic", "", "topic whose offsets are reset")
+	to := flag.String("to", "", "target of the offset reset: earliest, latest, an RFC3339 time or offsets by partition (e.g. 0=120,3=42)")
+	dryRun := flag.Bool("dry-run", false, "print the offsets of the reset without changing them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: admin [flags] topics [topic...] | groups [group...] | lag [group...] | reset-offsets\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	var target kafkautils.ResetTarget
+	switch command {
+	case "topics", "groups", "lag":
+	case "reset-offsets":
+		if *group == "" || *topic == "" || *to == "" {
+			logger.ErrorLogger.Println("reset-offsets requires -group, -topic and -to")
+			os.Exit(2)
+		}
+		var err error
+		if target, err = parseResetTarget(*to); err != nil {
+			logger.ErrorLogger.Printf("invalid -to: %v\n", err)
+			os.Exit(2)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, config.KafkaAdminTimeout)
+	defer cancel()
+
+	admin := kafkautils.NewAdminClient(config.KafkaBrokers...)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	var err error
+	switch command {
+	case "topics":
+		err = printTopics(ctx, w, admin, args)
+	case "groups":
+		err = printGroups(ctx, w, admin, args)
+	case "lag":
+		err = printLag(ctx, w, admin, args)
+	case "reset-offsets":
+		err = resetOffsets(ctx, w, admin, *group, *topic, target, *dryRun)
+	}
+	w.Flush()
+	if err != nil {
+		logger.ErrorLogger.Println(err)
+		os.Exit(1)
+	}
+}
+
+// parseResetTarget parses the target of an offset reset: earliest, latest, an RFC3339 time or offsets by partition
+func parseResetTarget(s string) (kafkautils.ResetTarget, error) {
+	switch s {
+	case "earliest":
+		return kafkautils.ResetTarget{Earliest: true}, nil
+	case "latest":
+		return kafkautils.ResetTarget{Latest: true}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return kafkautils.ResetTarget{Time: t}, nil
+	}
+
+	offsets := make(map[int]int64)
+	for _, entry := range strings.Split(s, ",") {
+		partStr, offStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return kafkautils.ResetTarget{}, fmt.Errorf("'%s' is not earliest, latest, an RFC3339 time or <partition>=<offset>", entry)
+		}
+		partition, err := strconv.Atoi(partStr)
+		if err != nil || partition < 0 {
+			return kafkautils.ResetTarget{}, fmt.Errorf("invalid partition in '%s'", entry)
+		}
+		offset, err := strconv.ParseInt(offStr, 10, 64)
+		if err != nil || offset < 0 {
+			return kafkautils.ResetTarget{}, fmt.Errorf("invalid offset in '%s'", entry)
+		}
+		offsets[partition] = offset
+	}
+	return kafkautils.ResetTarget{Offsets: offsets}, nil
+}
+
+func printTopics(ctx context.Context, w *tabwriter.Writer, admin kafkautils.Admin, topics []string) error {
+	infos, err := kafkautils.DescribeTopics(ctx, admin, topics...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "TOPIC\tPARTITION\tLEADER\tREPLICAS\tISR\t")
+	for _, t := range infos {
+		for _, p := range t.Partitions {
+			note := ""
+			if p.UnderReplicated() {
+				note = "under-replicated"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", t.Name, p.ID, p.Leader, joinInts(p.Replicas), joinInts(p.Isr), note)
+		}
+	}
+	return nil
+}
+
+func printGroups(ctx context.Context, w *tabwriter.Writer, admin kafkautils.Admin, groupIDs []string) error {
+	groupIDs, err := groupsOrPipelineGroups(ctx, admin, groupIDs)
+	if err != nil {
+		return err
+	}
+	groups, err := kafkautils.DescribeGroups(ctx, admin, groupIDs...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "GROUP\tSTATE\tMEMBER\tCLIENT\tHOST\tPARTITIONS")
+	for _, g := range groups {
+		if len(g.Members) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\n", g.ID, g.State)
+		}
+		for _, m := range g.Members {
+			var assigned []string
+			for _, tp := range m.Assigned {
+				assigned = append(assigned, tp.String())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", g.ID, g.State, m.ID, m.ClientID, m.ClientHost, strings.Join(assigned, ","))
+		}
+	}
+	return nil
+}
+
+func printLag(ctx context.Context, w *tabwriter.Writer, admin kafkautils.Admin, groupIDs []string) error {
+	groupIDs, err := groupsOrPipelineGroups(ctx, admin, groupIDs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "GROUP\tTOPIC\tPARTITION\tCOMMITTED\tEND\tLAG")
+	for _, id := range groupIDs {
+		lags, err := kafkautils.GroupLag(ctx, admin, id)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for _, l := range lags {
+			committed := "-"
+			if l.Committed >= 0 {
+				committed = strconv.FormatInt(l.Committed, 10)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%d\n", id, l.Topic, l.Partition, committed, l.End, l.Lag)
+			total += l.Lag
+		}
+		fmt.Fprintf(w, "%s\t(total)\t\t\t\t%d\n", id, total)
+	}
+	return nil
+}
+
+func resetOffsets(ctx context.Context, w *tabwriter.Writer, admin kafkautils.Admin, group string, topic string, target kafkautils.ResetTarget, dryRun bool) error {
+	resets, err := kafkautils.PlanOffsetReset(ctx, admin, group, topic, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "GROUP\tTOPIC\tPARTITION\tCURRENT\tNEW")
+	for _, r := range resets {
+		current := "-"
+		if r.Current >= 0 {
+			current = strconv.FormatInt(r.Current, 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\n", group, r.Topic, r.Partition, current, r.Target)
+	}
+	w.Flush()
+
+	if dryRun {
+		logger.InfoLogger.Printf("%d offsets of group %s not reset (dry run)\n", len(resets), group)
+		return nil
+	}
+	if err := kafkautils.ResetOffsets(ctx, admin, group, resets); err != nil {
+		return err
+	}
+	logger.InfoLogger.Printf("%d offsets of group %s reset\n", len(resets), group)
+	return nil
+}
+
+// the given groups, or the pipeline groups if none are given
+func groupsOrPipelineGroups(ctx context.Context, admin kafkautils.Admin, groupIDs []string) ([]string, error) {
+	if len(groupIDs) > 0 {
+		return groupIDs, nil
+	}
+	return kafkautils.ListGroups(ctx, admin, config.KafkaPipelineGroups...)
+}
+
+func joinInts(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ",")
+}
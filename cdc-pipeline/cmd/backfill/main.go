@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/backfill"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+)
+
+// copies the users and orders tables from Postgres to Cassandra at a consistent snapshot
+// and records the snapshot for the CDC consumer (see its -snapshot flag)
+func main() {
+	chunks := flag.Int("chunks", config.BackfillChunks, "key ranges each table is split into")
+	workers := flag.Int("workers", config.BackfillWorkers, "chunks copied in parallel")
+	snapshotFile := flag.String("snapshot", config.BackfillSnapshotFile, "file the snapshot is recorded in")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := sink.DefaultCassandraClientConfig()
+	if err != nil {
+		logger.ErrorLogger.Printf("invalid cassandra client config: %v\n", err)
+		os.Exit(1)
+	}
+	cs, err := sink.NewCassandraClient(cfg)
+	if err != nil {
+		logger.ErrorLogger.Printf("failed to connect to cassandra: %v\n", err)
+		os.Exit(1)
+	}
+	defer cs.Close()
+
+	connString := fmt.Sprintf("postgres://%s:%s@%s/%s", config.PGUser, config.PGPassword, config.PGAddr, config.PGDBName)
+	b := backfill.NewBackfiller(connString, cs, backfill.Config{
+		Tables: []backfill.Table{
+			{Name: "users", Topic: config.DebeziumUsersTopic, Key: "id"},
+			{Name: "orders", Topic: config.DebeziumOrdersTopic, Key: "id"},
+		},
+		Chunks:  *chunks,
+		Workers: *workers,
+	})
+
+	snap, err := b.Run(ctx)
+	if err != nil {
+		logger.ErrorLogger.Printf("backfill failed: %v\n", err)
+		os.Exit(1)
+	}
+	// only a completed backfill is handed off
+	if err := backfill.SaveSnapshot(*snapshotFile, snap); err != nil {
+		logger.ErrorLogger.Printf("failed to record the snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	logger.InfoLogger.Printf("backfill completed at lsn %s, recorded in %s (run the CDC consumer with -snapshot %s)\n", snap.LSN, *snapshotFile, *snapshotFile)
+}
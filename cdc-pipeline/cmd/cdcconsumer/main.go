@@ -2,72 +2,246 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/backfill"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
 	"github.com/segmentio/kafka-go"
 )
 
 func main() {
+	sinkName := flag.String("sink", cassandraSinkName, "sink to apply change events to (cassandra, file, parquet, webhook, postgres-replica, sqlite, redis, search)")
+	snapshotFile := flag.String("snapshot", "", "snapshot of a backfill (skips the change events already backfilled)")
+	replayMode := flag.Bool("replay", false, "replay a range of the cdc topics (see -from-*, -to-*) instead of consuming new change events")
+	replayId := flag.String("replay-id", "", "id of the replay, naming its consumer group and dedup scope (default: the start time; reuse it to resume a replay)")
+	fromTime := flag.String("from-time", "", "replay from the first change event at or after this RFC3339 time (default: the first retained event)")
+	toTime := flag.String("to-time", "", "replay until the last change event before this RFC3339 time (default: the last event when the replay starts)")
+	fromOffsets := flag.String("from-offsets", "", "replay from these offsets, e.g. cdc.public.users:0=120,cdc.public.orders:3=42 (overrides -from-time)")
+	toOffsets := flag.String("to-offsets", "", "replay until these offsets (exclusive), e.g. cdc.public.users:0=500 (overrides -to-time)")
+	dedup := flag.String("dedup", dedupScoped, "dedup of replayed change events: scoped (once per replay) or bypass (apply all)")
+	adminAddr := flag.String("admin-addr", config.CdcConsumerAdminAddr, "address of the health, readiness, status and pause endpoints of the consumers (disabled if empty)")
+	flag.Parse()
+
 	// topics produced by Debezium
 	cdcTopics := []string{
 		config.DebeziumUsersTopic,
 		config.DebeziumOrdersTopic,
 	}
 
-	// create cassandra client
-	cs, err := sink.NewCassandraClient(config.CassandraHosts, config.CassandraKeyspace)
+	// events of transactions visible in the snapshot of a backfill are already applied
+	var snap *backfill.Snapshot
+	if *snapshotFile != "" {
+		var err error
+		if snap, err = backfill.LoadSnapshot(*snapshotFile); err != nil {
+			logger.ErrorLogger.Printf("failed to load backfill snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		logger.InfoLogger.Printf("skipping change events contained in the backfill snapshot at lsn %s\n", snap.LSN)
+	}
+
+	// create the change sink
+	cs, err := newChangeSink(*sinkName)
 	if err != nil {
-		logger.ErrorLogger.Printf("failed to init cassandra client: %v\n", err)
+		logger.ErrorLogger.Printf("failed to init %s sink: %v\n", *sinkName, err)
 		os.Exit(1)
 	}
 	defer cs.Close()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *replayMode {
+		if snap != nil {
+			logger.ErrorLogger.Println("-snapshot can't be used with -replay")
+			os.Exit(1)
+		}
+		opts, err := newReplayOptions(*replayId, *fromTime, *toTime, *fromOffsets, *toOffsets, *dedup)
+		if err != nil {
+			logger.ErrorLogger.Printf("invalid replay options: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := replay(ctx, cdcTopics, *sinkName, cs, opts); err != nil {
+			logger.ErrorLogger.Printf("replay failed: %v\n", err)
+			cs.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// serve the health of the consumers (and pause their topics) while they consume
+	status := adminserver.NewStatus(cdcTopics...)
+	if *adminAddr != "" {
+		srv := newAdminServer(*sinkName, cs, status)
+		srv.Start(*adminAddr)
+		defer srv.Stop(context.Background())
+	}
+
 	wg := sync.WaitGroup{}
 	for _, t := range cdcTopics {
 		wg.Add(1)
 		go func(topic string) {
 			defer wg.Done()
-			consumeTopic(topic, cs)
+			consumeTopic(ctx, topic, sinkGroupId(*sinkName), cs, snap, status)
 		}(t)
 	}
 	wg.Wait()
 	logger.InfoLogger.Println("cdc-consumer stopped")
 }
 
-func consumeTopic(topic string, cqlClient *sink.CassandraClient) {
+// newAdminServer returns the admin server of the consumers, ready once Kafka (and the sink, if it can be pinged) is
+// reachable and the consumers joined their group
+func newAdminServer(sinkName string, cs sink.ChangeSink, status *adminserver.Status) *adminserver.Server {
+	admin := kafkautils.NewAdminClient(config.KafkaBrokers...)
+	srv := adminserver.New("cdc-consumer", status)
+	srv.AddCheck("kafka", adminserver.KafkaCheck(admin))
+	if ps, ok := cs.(sink.PingableSink); ok {
+		srv.AddCheck(sinkName, func(ctx context.Context) error {
+			return ps.Ping()
+		})
+	}
+	srv.AddCheck("group "+sinkGroupId(sinkName), adminserver.GroupCheck(admin, sinkGroupId(sinkName)))
+	return srv
+}
+
+func newReplayOptions(id, fromTime, toTime, fromOffsets, toOffsets, dedup string) (replayOptions, error) {
+	opts := replayOptions{id: id, dedup: dedup}
+	if opts.id == "" {
+		opts.id = time.Now().UTC().Format("20060102T150405")
+	}
+	if dedup != dedupScoped && dedup != dedupBypass {
+		return opts, fmt.Errorf("unknown dedup mode: %s", dedup)
+	}
+
+	var err error
+	if fromTime != "" {
+		if opts.fromTime, err = time.Parse(time.RFC3339, fromTime); err != nil {
+			return opts, fmt.Errorf("-from-time: %w", err)
+		}
+	}
+	if toTime != "" {
+		if opts.toTime, err = time.Parse(time.RFC3339, toTime); err != nil {
+			return opts, fmt.Errorf("-to-time: %w", err)
+		}
+	}
+	if opts.fromOffsets, err = parseOffsets(fromOffsets); err != nil {
+		return opts, fmt.Errorf("-from-offsets: %w", err)
+	}
+	if opts.toOffsets, err = parseOffsets(toOffsets); err != nil {
+		return opts, fmt.Errorf("-to-offsets: %w", err)
+	}
+	return opts, nil
+}
+
+// settings of the consumers (variables so tests can shorten them)
+var (
+	idleTimeout  = 10 * time.Second // stop consuming a topic after no message is received for this duration
+	retryBackOff = 2 * time.Second  // wait between retries of fetches, applies and commits
+	maxRetries   = 3
+)
+
+func consumeTopic(ctx context.Context, topic string, groupId string, cs sink.ChangeSink, snap *backfill.Snapshot, status *adminserver.Status) {
 
 	// create a kafka reader
 	r := kafka.NewReader(kafka.ReaderConfig{
 		Brokers: config.KafkaBrokers,
 		Topic:   topic,
-		GroupID: "cdc-cassandra-sink",
+		GroupID: groupId,
 	})
 	defer r.Close()
 
 	logger.InfoLogger.Printf("starting consumer for topic=%s\n", topic)
+	consumeMessages(ctx, r, topic, cs, snap, status)
+}
 
-	// setup retry variables for retries
-	fetchRetry, commitRetry, maxRetries, retryBackOff := 0, 0, 3, 2 // retryBackOff in seconds
-	timeOutSecs := 10
+// consumeMessages applies the messages of the reader to the sink and commits their offsets, until no message is
+// received for the idle timeout, a message can't be applied (or committed) after retries or the context is done.
+// The applied and failed messages are reported to the status, and no messages are fetched while the topic is paused
+// in it (the pending messages are persisted and committed before).
+func consumeMessages(ctx context.Context, r kafkautils.MessageReader, topic string, cs sink.ChangeSink, snap *backfill.Snapshot, status *adminserver.Status) {
+	fetchRetry, commitRetry := 0, 0
+
+	// buffered sinks only persist events when flushed, so their offsets are committed in batches after a flush
+	bs, buffered := cs.(sink.BufferedSink)
+	var pending []kafka.Message // applied but not yet committed messages
+	lastFlush := time.Now()
+
+	// persist and commit the pending messages
+	flushAndCommit := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if buffered {
+			if err := bs.Flush(); err != nil {
+				pending = nil // nothing is known to be persisted
+				return fmt.Errorf("failed to flush sink for topic %s: %w", topic, err)
+			}
+			lastFlush = time.Now()
+		}
+		if err := r.CommitMessages(context.Background(), pending...); err != nil {
+			return fmt.Errorf("failed to commit offset: %w", err)
+		}
+		pending = pending[:0]
+		return nil
+	}
+
+	// persist and commit pending messages before stopping
+	defer func() {
+		if err := flushAndCommit(); err != nil {
+			logger.ErrorLogger.Println(err)
+		}
+	}()
+
+	// changes already loaded by a backfill are skipped
+	skipBackfilled := func(ev *model.ChangeEvent) bool {
+		if snap == nil || !snap.Contains(ev) {
+			return false
+		}
+		logger.DebugLogger.Printf("skipping backfilled change: topic=%s partition=%d offset=%d txId=%d\n", topic, ev.Partition, ev.Offset, ev.TxID)
+		return true
+	}
 
 	// message consumption loop
 	for {
+		// wait while the topic is paused, without leaving applied messages uncommitted for that long
+		if status.Paused(topic) {
+			if err := flushAndCommit(); err != nil {
+				logger.ErrorLogger.Println(err)
+				break
+			}
+			logger.InfoLogger.Printf("topic %s is paused\n", topic)
+			if err := status.WaitUntilResumed(ctx, topic); err != nil {
+				break
+			}
+			logger.InfoLogger.Printf("topic %s is resumed\n", topic)
+		}
+
 		// parent context cancellation
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutSecs)*time.Second)
-		msg, err := r.FetchMessage(ctx)
+		fetchCtx, cancel := context.WithTimeout(ctx, idleTimeout)
+		msg, err := r.FetchMessage(fetchCtx)
 		cancel()
 
 		// retry logic
 		if err != nil {
 
+			if ctx.Err() != nil {
+				logger.InfoLogger.Printf("Shutting down consumer for topic %s...\n", topic)
+				break
+			}
 			if err == context.DeadlineExceeded {
-				logger.InfoLogger.Printf("No new messages received in last %d seconds for topic %s. Shutting down consumer...\n", timeOutSecs, topic)
+				logger.InfoLogger.Printf("No new messages received in last %v for topic %s. Shutting down consumer...\n", idleTimeout, topic)
 				break
 			}
 
@@ -76,36 +250,84 @@ func consumeTopic(topic string, cqlClient *sink.CassandraClient) {
 				break
 			}
 			// small backoff then try fetching again
-			time.Sleep(time.Duration(retryBackOff) * time.Second)
+			time.Sleep(retryBackOff)
 			fetchRetry += 1
 			continue
 		}
 		fetchRetry = 0 // reset number of retries
 
-		// parse Debezium events
-		ev, err := parser.ParseDebeziumEvent(msg.Value)
-		if err != nil {
-			logger.ErrorLogger.Printf("message parsing error: topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
-			break
+		// tombstones (messages with a null value) follow delete events for log compaction, there's nothing to apply
+		if msg.Value != nil {
+			// apply to the sink (idempotent for Cassandra due to processed_events table), retrying transient errors
+			applyRetry := 0
+			for {
+				_, err = applyMessage(cs, msg, skipBackfilled)
+				if err == nil {
+					break
+				}
+				status.Failed(msg, err, applyRetry+1, applyRetry < maxRetries)
+				if applyRetry == maxRetries {
+					break
+				}
+				logger.ErrorLogger.Printf("apply change error (retrying): topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+				time.Sleep(retryBackOff)
+				applyRetry += 1
+			}
+			if err != nil {
+				logger.ErrorLogger.Printf("apply change error: topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+				break
+			}
 		}
 
-		// apply to Cassandra (idempotent due to processed_events table)
-		if err := cqlClient.ApplyChange(topic, ev); err != nil {
-			logger.ErrorLogger.Printf("apply change error: topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
-			break
+		status.Processed(msg)
+		pending = append(pending, msg)
+
+		if buffered {
+			if len(pending) < config.CdcFlushMaxEvents && time.Since(lastFlush) < config.CdcFlushInterval {
+				continue
+			}
+			if err := bs.Flush(); err != nil {
+				logger.ErrorLogger.Printf("failed to flush sink for topic %s: %v\n", topic, err)
+				pending = nil // nothing is known to be persisted
+				break
+			}
+			lastFlush = time.Now()
 		}
 
 		// commit kafka offset after successful persist
-		if err := r.CommitMessages(context.Background(), msg); err != nil {
+		if err := r.CommitMessages(context.Background(), pending...); err != nil {
 			logger.ErrorLogger.Printf("failed to commit offset: %v\n", err)
+			status.Failed(pending[len(pending)-1], fmt.Errorf("commit failed: %w", err), commitRetry+1, commitRetry < maxRetries)
 			if commitRetry == maxRetries {
 				break
 			}
 			// small backoff then try committing again
-			time.Sleep(time.Duration(retryBackOff) * time.Second)
+			time.Sleep(retryBackOff)
 			commitRetry += 1
 			continue
 		}
+		pending = pending[:0]
 		commitRetry = 0
 	}
 }
+
+// applyMessage parses a message and applies its change event to the sink, unless skip returns true (skip may be nil).
+// Poison messages (malformed events, which fail every time they're applied) are logged and skipped,
+// so the returned error is transient, i.e. applying the message may succeed when retried.
+func applyMessage(cs sink.ChangeSink, msg kafka.Message, skip func(ev *model.ChangeEvent) bool) (bool, error) {
+	ev, err := parser.ParseDebeziumEvent(msg.Value)
+	if err == nil {
+		ev.Partition = msg.Partition
+		ev.Offset = msg.Offset
+		if skip != nil && skip(ev) {
+			return false, nil
+		}
+		err = cs.ApplyChange(msg.Topic, ev)
+	}
+
+	if parser.IsPoison(err) {
+		logger.ErrorLogger.Printf("skipping poison message: topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/segmentio/kafka-go"
+)
+
+const testTopic = "cdc.public.users"
+
+// recordingSink records the names of the applied users, failing with the error of a name (if any)
+type recordingSink struct {
+	applied []string
+	errs    map[string]error
+}
+
+func (s *recordingSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	name, _ := ev.Row["name"].(string)
+	if err := s.errs[name]; err != nil {
+		return err
+	}
+	s.applied = append(s.applied, name)
+	return nil
+}
+
+func (s *recordingSink) Close() {}
+
+// bufferedRecordingSink is a recordingSink that must be flushed
+type bufferedRecordingSink struct {
+	recordingSink
+	flushes int
+}
+
+func (s *bufferedRecordingSink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+// pausingSink is a bufferedRecordingSink pausing the test topic once it applied a user
+type pausingSink struct {
+	bufferedRecordingSink
+	status     *adminserver.Status
+	pauseAfter string
+}
+
+func (s *pausingSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if err := s.bufferedRecordingSink.ApplyChange(topic, ev); err != nil {
+		return err
+	}
+	if ev.Row["name"] == s.pauseAfter {
+		return s.status.Pause(topic)
+	}
+	return nil
+}
+
+func setTestTimeouts(t *testing.T) {
+	idle, backOff := idleTimeout, retryBackOff
+	idleTimeout, retryBackOff = 50*time.Millisecond, time.Millisecond
+	t.Cleanup(func() { idleTimeout, retryBackOff = idle, backOff })
+}
+
+// write change events of users to the test topic (a single partition), "poison" is written as an invalid message
+func writeUserEvents(t *testing.T, b *kafkautils.MemoryBroker, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		value := []byte(fmt.Sprintf(`{"payload": {"op": "c", "after": {"name": %q}, "ts_ms": 1690000000000, "source": {"txId": 780, "lsn": 1000}}}`, name))
+		if name == "poison" {
+			value = []byte("{not json")
+		}
+		if err := b.Writer(testTopic).WriteMessages(context.Background(), kafka.Message{Value: value}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+}
+
+func TestConsumeMessages_SkipsPoisonMessages(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "poison", "bob")
+	if err := b.Writer(testTopic).WriteMessages(context.Background(), kafka.Message{Value: nil}); err != nil { // tombstone
+		t.Fatalf("WriteMessages failed: %v", err)
+	}
+
+	cs := &recordingSink{}
+	consumeMessages(context.Background(), b.Reader(testTopic, "g1"), testTopic, cs, nil, adminserver.NewStatus(testTopic))
+
+	if fmt.Sprint(cs.applied) != "[alice bob]" {
+		t.Errorf("unexpected applied users: %v", cs.applied)
+	}
+	if offset, _ := b.CommittedOffset("g1", testTopic, 0); offset != 4 {
+		t.Errorf("expected committed offset 4, got %d", offset)
+	}
+}
+
+func TestConsumeMessages_StopsAfterTransientErrors(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob", "carol")
+
+	cs := &recordingSink{errs: map[string]error{"bob": errors.New("cassandra unavailable")}}
+	status := adminserver.NewStatus(testTopic)
+	consumeMessages(context.Background(), b.Reader(testTopic, "g1"), testTopic, cs, nil, status)
+
+	if fmt.Sprint(cs.applied) != "[alice]" {
+		t.Errorf("unexpected applied users: %v", cs.applied)
+	}
+	// the status reports the failed message after the consumer gave up retrying it
+	workers := status.Workers()
+	if len(workers) != 1 || workers[0].LastOffset != 0 || workers[0].LastError != "offset 1: cassandra unavailable" ||
+		workers[0].Retrying || workers[0].Attempts != maxRetries+1 {
+		t.Errorf("unexpected worker status: %+v", workers)
+	}
+	if offset, _ := b.CommittedOffset("g1", testTopic, 0); offset != 1 {
+		t.Errorf("expected committed offset 1 (the failed message), got %d", offset)
+	}
+
+	// once the error is resolved, the consumer resumes at the failed message
+	cs.errs = nil
+	consumeMessages(context.Background(), b.Reader(testTopic, "g1"), testTopic, cs, nil, adminserver.NewStatus(testTopic))
+	if fmt.Sprint(cs.applied) != "[alice bob carol]" {
+		t.Errorf("unexpected applied users: %v", cs.applied)
+	}
+	if offset, _ := b.CommittedOffset("g1", testTopic, 0); offset != 3 {
+		t.Errorf("expected committed offset 3, got %d", offset)
+	}
+}
+
+func TestConsumeMessages_BufferedSinkCommitsAfterFlush(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob")
+
+	// the pending messages are flushed and committed together when the consumer stops
+	cs := &bufferedRecordingSink{}
+	consumeMessages(context.Background(), b.Reader(testTopic, "g1"), testTopic, cs, nil, adminserver.NewStatus(testTopic))
+
+	if cs.flushes != 1 {
+		t.Errorf("expected 1 flush, got %d", cs.flushes)
+	}
+	commits := b.Commits("g1")
+	if len(commits) != 2 || commits[1].Offset != 1 {
+		t.Errorf("unexpected commits: %v", commits)
+	}
+}
+
+func TestConsumeMessages_CommitsBeforePause(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob")
+
+	// the topic is paused while the consumer waits for more messages
+	status := adminserver.NewStatus(testTopic)
+	cs := &pausingSink{status: status, pauseAfter: "bob"}
+	idleTimeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumeMessages(ctx, b.Reader(testTopic, "g1"), testTopic, cs, nil, status)
+	}()
+
+	// the buffered messages are flushed and committed before the consumer blocks on the pause
+	deadline := time.Now().Add(5 * time.Second)
+	for commits := b.Commits("g1"); len(commits) != 2 || commits[1].Offset != 1; commits = b.Commits("g1") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pending messages to be committed while paused, got commits %v", commits)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// cancelling the context stops the paused consumer
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the paused consumer to stop")
+	}
+	if cs.flushes != 1 {
+		t.Errorf("expected 1 flush, got %d", cs.flushes)
+	}
+}
+
+func TestApplyMessage_Poison(t *testing.T) {
+	cs := &recordingSink{errs: map[string]error{"mallory": parser.Poison(errors.New("invalid row"))}}
+	value := []byte(`{"payload": {"op": "c", "after": {"name": "mallory"}, "source": {"txId": 780, "lsn": 1000}}}`)
+	applied, err := applyMessage(cs, kafka.Message{Topic: testTopic, Value: value}, nil)
+	if applied || err != nil {
+		t.Errorf("expected the poison change to be skipped, got %t, %v", applied, err)
+	}
+}
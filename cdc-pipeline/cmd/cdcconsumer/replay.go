@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+	"github.com/segmentio/kafka-go"
+)
+
+// dedup modes of a replay (selected with the -dedup flag)
+const (
+	dedupScoped = "scoped" // apply every event once per replay (even if it was processed before)
+	dedupBypass = "bypass" // apply every event, without recording it in processed_events
+)
+
+// replayOptions select the range of the cdc topics to replay
+type replayOptions struct {
+	id          string                              // names the replay's consumer group and dedup scope
+	fromTime    time.Time                           // start at the first event at or after this time (if not zero)
+	toTime      time.Time                           // stop before the first event at or after this time (if not zero)
+	fromOffsets map[kafkautils.TopicPartition]int64 // start offsets (overriding fromTime)
+	toOffsets   map[kafkautils.TopicPartition]int64 // end offsets, exclusive (overriding toTime)
+	dedup       string
+}
+
+// parseOffsets parses the offsets of partitions, e.g. "cdc.public.users:0=120,cdc.public.orders:3=42"
+func parseOffsets(s string) (map[kafkautils.TopicPartition]int64, error) {
+	offsets := make(map[kafkautils.TopicPartition]int64)
+	if s == "" {
+		return offsets, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		tpStr, offStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		i := strings.LastIndex(tpStr, ":")
+		if !ok || i < 0 {
+			return nil, fmt.Errorf("invalid partition offset '%s' (expected <topic>:<partition>=<offset>)", entry)
+		}
+		partition, err := strconv.Atoi(tpStr[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition in '%s': %w", entry, err)
+		}
+		offset, err := strconv.ParseInt(offStr, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset in '%s'", entry)
+		}
+		offsets[kafkautils.TopicPartition{Topic: tpStr[:i], Partition: partition}] = offset
+	}
+	return offsets, nil
+}
+
+// replayPartition is the range of offsets [start, end) of a partition to replay
+type replayPartition struct {
+	kafkautils.TopicPartition
+	start   int64
+	end     int64
+	next    atomic.Int64 // offset of the next message to replay
+	applied atomic.Int64 // number of applied change events
+}
+
+func (p *replayPartition) progress() string {
+	next := p.next.Load()
+	pct := 100.0
+	if p.end > p.start {
+		pct = float64(next-p.start) * 100 / float64(p.end-p.start)
+	}
+	return fmt.Sprintf("%s at offset %d of [%d, %d) (%.1f%%), %d events applied", p.TopicPartition, next, p.start, p.end, pct, p.applied.Load())
+}
+
+// replay applies the change events of a range of every partition of the topics to the sink.
+// The offsets of the applied events are committed to a transient consumer group (deleted once the replay completes),
+// so an interrupted replay resumes where it stopped when run again with the same id.
+func replay(ctx context.Context, topics []string, sinkName string, cs sink.ChangeSink, opts replayOptions) error {
+	client := &kafka.Client{
+		Addr:    kafka.TCP(config.KafkaBrokers...),
+		Timeout: 10 * time.Second,
+	}
+	groupId := fmt.Sprintf("cdc-%s-replay-%s", sinkName, opts.id)
+
+	partitions, err := planReplay(ctx, client, topics, groupId, opts)
+	if err != nil {
+		return err
+	}
+
+	// the events were processed before, so deduplicating them as usual would skip all of them
+	if ds, ok := cs.(sink.DedupSink); ok {
+		if opts.dedup == dedupBypass {
+			ds.SetDedupMode(sink.DedupMode{Bypass: true})
+		} else {
+			ds.SetDedupMode(sink.DedupMode{Scope: "replay-" + opts.id})
+		}
+	}
+	logger.InfoLogger.Printf("replay %s: replaying %d partitions into the %s sink (consumer group %s, %s dedup)\n", opts.id, len(partitions), sinkName, groupId, opts.dedup)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// report the progress of every partition
+	logProgress := func() {
+		for _, p := range partitions {
+			logger.InfoLogger.Printf("replay %s: %s\n", opts.id, p.progress())
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(config.ReplayProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logProgress()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	commit := func(p *replayPartition) error {
+		res, err := client.OffsetCommit(context.Background(), &kafka.OffsetCommitRequest{
+			GroupID:      groupId,
+			GenerationID: -1, // the group has no members, offsets are committed like by a standalone consumer
+			Topics:       map[string][]kafka.OffsetCommit{p.Topic: {{Partition: p.Partition, Offset: p.next.Load()}}},
+		})
+		if err != nil {
+			return err
+		}
+		for _, rp := range res.Topics[p.Topic] {
+			if rp.Error != nil {
+				return rp.Error
+			}
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	wg := sync.WaitGroup{}
+	for _, p := range partitions {
+		wg.Add(1)
+		go func(p *replayPartition) {
+			defer wg.Done()
+			if p.start >= p.end {
+				return
+			}
+			r, err := newPartitionReader(p)
+			if err == nil {
+				err = replayRange(ctx, p, cs, r, commit)
+				r.Close()
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel() // stop the other partitions
+				}
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(done)
+	logProgress()
+
+	if firstErr != nil {
+		return fmt.Errorf("%w (run again with -replay-id %s to resume)", firstErr, opts.id)
+	}
+
+	// the group only tracks the progress of the replay
+	if res, err := client.DeleteGroups(context.Background(), &kafka.DeleteGroupsRequest{GroupIDs: []string{groupId}}); err != nil {
+		logger.ErrorLogger.Printf("failed to delete consumer group %s: %v\n", groupId, err)
+	} else if err := res.Errors[groupId]; err != nil && !errors.Is(err, kafka.GroupIdNotFound) {
+		logger.ErrorLogger.Printf("failed to delete consumer group %s: %v\n", groupId, err)
+	}
+	logger.InfoLogger.Printf("replay %s completed\n", opts.id)
+	return nil
+}
+
+// planReplay resolves the range of offsets to replay of every partition of the topics
+func planReplay(ctx context.Context, client kafkautils.Admin, topics []string, groupId string, opts replayOptions) ([]*replayPartition, error) {
+	parts, err := kafkautils.TopicPartitions(ctx, client, topics)
+	if err != nil {
+		return nil, err
+	}
+	for tp := range opts.fromOffsets {
+		if !slices.Contains(parts, tp) {
+			return nil, fmt.Errorf("unknown partition %s", tp)
+		}
+	}
+	for tp := range opts.toOffsets {
+		if !slices.Contains(parts, tp) {
+			return nil, fmt.Errorf("unknown partition %s", tp)
+		}
+	}
+
+	first, err := kafkautils.ListOffsets(ctx, client, parts, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := kafkautils.ListOffsets(ctx, client, parts, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	offsetsAt := func(t time.Time) (map[kafkautils.TopicPartition]int64, error) {
+		if t.IsZero() {
+			return nil, nil
+		}
+		return kafkautils.ListOffsets(ctx, client, parts, func(partition int) kafka.OffsetRequest { return kafka.TimeOffsetOf(partition, t) })
+	}
+	fromTime, err := offsetsAt(opts.fromTime)
+	if err != nil {
+		return nil, err
+	}
+	toTime, err := offsetsAt(opts.toTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// offsets committed by a previous run of the replay
+	partitionIDs := make(map[string][]int)
+	for _, tp := range parts {
+		partitionIDs[tp.Topic] = append(partitionIDs[tp.Topic], tp.Partition)
+	}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupId, Topics: partitionIDs})
+	if err != nil {
+		return nil, fmt.Errorf("fetch offsets of group %s: %w", groupId, err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("fetch offsets of group %s: %w", groupId, committed.Error)
+	}
+	resume := make(map[kafkautils.TopicPartition]int64)
+	for topic, ps := range committed.Topics {
+		for _, p := range ps {
+			if p.Error == nil && p.CommittedOffset >= 0 {
+				resume[kafkautils.TopicPartition{Topic: topic, Partition: p.Partition}] = p.CommittedOffset
+			}
+		}
+	}
+
+	// the partitions are ordered by topic and partition
+	var partitions []*replayPartition
+	for _, tp := range parts {
+		partitions = append(partitions, resolveReplayRange(tp, partitionOffsets{
+			first:    first[tp],
+			last:     last[tp],
+			fromTime: offsetOf(fromTime, tp),
+			toTime:   offsetOf(toTime, tp),
+			resume:   offsetOf(resume, tp),
+		}, opts))
+	}
+	return partitions, nil
+}
+
+// partitionOffsets are the offsets of a partition its replay range is resolved from
+type partitionOffsets struct {
+	first, last      int64
+	fromTime, toTime *int64 // offsets of the times of the options (nil without a time, -1 if no event is at or after it)
+	resume           *int64 // offset committed by a previous run of the replay (nil if none)
+}
+
+func offsetOf(offsets map[kafkautils.TopicPartition]int64, tp kafkautils.TopicPartition) *int64 {
+	if off, ok := offsets[tp]; ok {
+		return &off
+	}
+	return nil
+}
+
+// resolveReplayRange resolves the range of a partition to replay: explicit offsets override times, the range is
+// clamped to the retained offsets of the partition, and a previous run of the replay is resumed within the range
+func resolveReplayRange(tp kafkautils.TopicPartition, offsets partitionOffsets, opts replayOptions) *replayPartition {
+	p := &replayPartition{TopicPartition: tp, start: offsets.first, end: offsets.last}
+
+	// a time after the last event resolves to no offset, i.e. the end of the partition
+	if offsets.fromTime != nil {
+		p.start = resolveTimeOffset(*offsets.fromTime, offsets.last)
+	}
+	if off, ok := opts.fromOffsets[tp]; ok {
+		p.start = off
+	}
+	if offsets.toTime != nil {
+		p.end = resolveTimeOffset(*offsets.toTime, offsets.last)
+	}
+	if off, ok := opts.toOffsets[tp]; ok {
+		p.end = off
+	}
+
+	if p.start < offsets.first {
+		logger.InfoLogger.Printf("replay %s: %s starts at offset %d, earlier offsets are not retained anymore\n", opts.id, tp, offsets.first)
+		p.start = offsets.first
+	}
+	if p.end > offsets.last {
+		logger.InfoLogger.Printf("replay %s: %s ends at offset %d (the end of the partition)\n", opts.id, tp, offsets.last)
+		p.end = offsets.last
+	}
+	if off := offsets.resume; off != nil && *off > p.start && *off <= p.end {
+		logger.InfoLogger.Printf("replay %s: resuming %s at offset %d\n", opts.id, tp, *off)
+		p.start = *off
+	}
+	p.end = max(p.end, p.start)
+	p.next.Store(p.start)
+	return p
+}
+
+func resolveTimeOffset(offset int64, last int64) int64 {
+	if offset < 0 {
+		return last
+	}
+	return offset
+}
+
+// newPartitionReader returns a reader of a partition (without consumer group) starting at the start of its range
+func newPartitionReader(p *replayPartition) (kafkautils.MessageReader, error) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   config.KafkaBrokers,
+		Topic:     p.Topic,
+		Partition: p.Partition,
+		MaxWait:   time.Second,
+	})
+	if err := r.SetOffset(p.start); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("seek %s to offset %d: %w", p.TopicPartition, p.start, err)
+	}
+	return r, nil
+}
+
+// replayRange applies the change events of a partition's range, fetched by the reader, to the sink (retrying transient
+// errors). The offsets of the applied events are committed (after flushing buffered sinks) periodically and at the end
+// of the range.
+func replayRange(ctx context.Context, p *replayPartition, cs sink.ChangeSink, r kafkautils.MessageReader, commit func(p *replayPartition) error) error {
+	if p.start >= p.end {
+		return nil
+	}
+
+	bs, buffered := cs.(sink.BufferedSink)
+	persist := func() error {
+		if buffered {
+			if err := bs.Flush(); err != nil {
+				return fmt.Errorf("flush sink: %w", err)
+			}
+		}
+		if err := commit(p); err != nil {
+			return fmt.Errorf("commit offset %d of %s: %w", p.next.Load(), p.TopicPartition, err)
+		}
+		return nil
+	}
+
+	lastCommit, uncommitted := time.Now(), 0
+	for p.next.Load() < p.end {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			// stopped by an interrupt (or a failure of another partition), keep the progress to resume from
+			if ctx.Err() != nil && uncommitted > 0 {
+				if err := persist(); err != nil {
+					logger.ErrorLogger.Printf("replay: %v\n", err)
+				}
+			}
+			return fmt.Errorf("fetch from %s: %w", p.TopicPartition, err)
+		}
+		// offsets removed by log compaction are skipped, so the range may end before its last offset
+		if msg.Offset >= p.end {
+			break
+		}
+
+		// tombstones (messages with a null value) follow delete events for log compaction, there's nothing to apply
+		if msg.Value != nil {
+			applyRetry := 0
+			var applied bool
+			for {
+				applied, err = applyMessage(cs, msg, nil)
+				if err == nil || applyRetry == maxRetries {
+					break
+				}
+				logger.ErrorLogger.Printf("replay: apply change error (retrying): topic=%s partition=%d offset=%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+				time.Sleep(retryBackOff)
+				applyRetry += 1
+			}
+			if err != nil {
+				return fmt.Errorf("apply change error: topic=%s partition=%d offset=%d: %w", msg.Topic, msg.Partition, msg.Offset, err)
+			}
+			if applied {
+				p.applied.Add(1)
+			}
+		}
+		p.next.Store(msg.Offset + 1)
+		uncommitted++
+
+		if uncommitted < config.CdcFlushMaxEvents && time.Since(lastCommit) < config.ReplayCommitInterval {
+			continue
+		}
+		if err := persist(); err != nil {
+			return err
+		}
+		lastCommit, uncommitted = time.Now(), 0
+	}
+
+	p.next.Store(p.end)
+	return persist()
+}
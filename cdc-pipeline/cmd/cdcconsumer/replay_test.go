@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// flakySink is a bufferedRecordingSink failing the first apply of the names in fails
+type flakySink struct {
+	bufferedRecordingSink
+	fails map[string]bool
+}
+
+func (s *flakySink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	name, _ := ev.Row["name"].(string)
+	if s.fails[name] {
+		delete(s.fails, name)
+		return errors.New("cassandra unavailable")
+	}
+	return s.bufferedRecordingSink.ApplyChange(topic, ev)
+}
+
+func TestParseOffsets(t *testing.T) {
+	offsets, err := parseOffsets("cdc.public.users:0=120, cdc.public.orders:3=42")
+	if err != nil {
+		t.Fatalf("parseOffsets failed: %v", err)
+	}
+	want := map[kafkautils.TopicPartition]int64{{Topic: "cdc.public.users", Partition: 0}: 120, {Topic: "cdc.public.orders", Partition: 3}: 42}
+	if fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Errorf("parseOffsets = %v, want %v", offsets, want)
+	}
+
+	if offsets, err := parseOffsets(""); err != nil || len(offsets) != 0 {
+		t.Errorf("expected no offsets for an empty string, got %v (err: %v)", offsets, err)
+	}
+	for _, s := range []string{"cdc.public.users=1", "cdc.public.users:0", "cdc.public.users:x=1", "cdc.public.users:0=-1", "cdc.public.users:0=x"} {
+		if _, err := parseOffsets(s); err == nil {
+			t.Errorf("expected an error for '%s'", s)
+		}
+	}
+}
+
+func TestResolveReplayRange(t *testing.T) {
+	tp := kafkautils.TopicPartition{Topic: testTopic, Partition: 0}
+	offset := func(off int64) *int64 { return &off }
+
+	tests := []struct {
+		name               string
+		offsets            partitionOffsets
+		from, to           map[kafkautils.TopicPartition]int64
+		wantStart, wantEnd int64
+	}{
+		{name: "whole partition", offsets: partitionOffsets{first: 10, last: 50}, wantStart: 10, wantEnd: 50},
+		{name: "times", offsets: partitionOffsets{first: 10, last: 50, fromTime: offset(20), toTime: offset(30)}, wantStart: 20, wantEnd: 30},
+		{name: "times after the last event", offsets: partitionOffsets{first: 10, last: 50, fromTime: offset(-1), toTime: offset(-1)}, wantStart: 50, wantEnd: 50},
+		{name: "offsets override times", offsets: partitionOffsets{first: 10, last: 50, fromTime: offset(20), toTime: offset(30)},
+			from: map[kafkautils.TopicPartition]int64{tp: 25}, to: map[kafkautils.TopicPartition]int64{tp: 40}, wantStart: 25, wantEnd: 40},
+		{name: "clamped to the retained offsets", offsets: partitionOffsets{first: 10, last: 50},
+			from: map[kafkautils.TopicPartition]int64{tp: 0}, to: map[kafkautils.TopicPartition]int64{tp: 100}, wantStart: 10, wantEnd: 50},
+		{name: "end before start", offsets: partitionOffsets{first: 10, last: 50},
+			from: map[kafkautils.TopicPartition]int64{tp: 30}, to: map[kafkautils.TopicPartition]int64{tp: 20}, wantStart: 30, wantEnd: 30},
+		{name: "resumed", offsets: partitionOffsets{first: 10, last: 50, resume: offset(35)}, wantStart: 35, wantEnd: 50},
+		{name: "resumed at the end", offsets: partitionOffsets{first: 10, last: 50, resume: offset(50)}, wantStart: 50, wantEnd: 50},
+		{name: "resume outside the range", offsets: partitionOffsets{first: 10, last: 50, fromTime: offset(20), resume: offset(15)}, wantStart: 20, wantEnd: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := resolveReplayRange(tp, tt.offsets, replayOptions{id: "test", fromOffsets: tt.from, toOffsets: tt.to})
+			if p.start != tt.wantStart || p.end != tt.wantEnd || p.next.Load() != tt.wantStart {
+				t.Errorf("expected range [%d, %d), got [%d, %d) (next %d)", tt.wantStart, tt.wantEnd, p.start, p.end, p.next.Load())
+			}
+		})
+	}
+}
+
+// replay the range of the test topic's partition, recording the committed offsets
+func replayTestRange(ctx context.Context, b *kafkautils.MemoryBroker, start, end int64, cs *flakySink) ([]int64, error) {
+	p := resolveReplayRange(kafkautils.TopicPartition{Topic: testTopic, Partition: 0}, partitionOffsets{first: 0, last: 100},
+		replayOptions{id: "test", fromOffsets: map[kafkautils.TopicPartition]int64{{Topic: testTopic, Partition: 0}: start},
+			toOffsets: map[kafkautils.TopicPartition]int64{{Topic: testTopic, Partition: 0}: end}})
+	r := b.PartitionReader(testTopic, 0, p.start)
+	defer r.Close()
+
+	var commits []int64
+	err := replayRange(ctx, p, cs, r, func(p *replayPartition) error {
+		commits = append(commits, p.next.Load())
+		return nil
+	})
+	return commits, err
+}
+
+func TestReplayRange_StopsAtEndOffset(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob", "carol", "dave")
+
+	// the apply of bob fails once
+	cs := &flakySink{fails: map[string]bool{"bob": true}}
+	commits, err := replayTestRange(context.Background(), b, 1, 3, cs)
+	if err != nil {
+		t.Fatalf("replayRange failed: %v", err)
+	}
+	if fmt.Sprint(cs.applied) != "[bob carol]" {
+		t.Errorf("unexpected applied users: %v", cs.applied)
+	}
+	// the end of the range is committed after the sink is flushed
+	if fmt.Sprint(commits) != "[3]" || cs.flushes != 1 {
+		t.Errorf("expected a single commit of offset 3 after a flush, got commits %v and %d flushes", commits, cs.flushes)
+	}
+}
+
+func TestReplayRange_FailsAfterRetries(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob")
+
+	cs := &flakySink{}
+	cs.errs = map[string]error{"bob": errors.New("cassandra unavailable")}
+	commits, err := replayTestRange(context.Background(), b, 0, 2, cs)
+	if err == nil {
+		t.Fatalf("expected the replay to fail")
+	}
+	if fmt.Sprint(cs.applied) != "[alice]" || len(commits) != 0 {
+		t.Errorf("expected alice to be applied without commits, got %v and commits %v", cs.applied, commits)
+	}
+}
+
+func TestReplayRange_InterruptedKeepsProgress(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic(testTopic, 1)
+	writeUserEvents(t, b, "alice", "bob")
+
+	// the range ends after the written messages, so the replay waits until it's interrupted
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cs := &flakySink{}
+	commits, err := replayTestRange(ctx, b, 0, 5, cs)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the interrupted replay to fail, got %v", err)
+	}
+	if fmt.Sprint(commits) != "[2]" || cs.flushes != 1 {
+		t.Errorf("expected the progress to be committed after a flush, got commits %v and %d flushes", commits, cs.flushes)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+)
+
+// names of the sinks supported by the cdc consumer (selected with the -sink flag)
+const (
+	cassandraSinkName = "cassandra"
+	fileSinkName      = "file"
+	parquetSinkName   = "parquet"
+	webhookSinkName   = "webhook"
+	replicaSinkName   = "postgres-replica"
+	sqliteSinkName    = "sqlite"
+	redisSinkName     = "redis"
+	searchSinkName    = "search"
+)
+
+// create the change sink with the given name
+func newChangeSink(name string) (sink.ChangeSink, error) {
+	var s sink.ChangeSink
+	var err error
+
+	switch name {
+	case cassandraSinkName:
+		var cfg sink.CassandraClientConfig
+		if cfg, err = sink.DefaultCassandraClientConfig(); err == nil {
+			s, err = sink.NewCassandraClient(cfg)
+		}
+	case fileSinkName:
+		s, err = sink.NewFileSink(sink.FileSinkConfig{
+			Dir:          config.FileSinkDir,
+			MaxFileBytes: config.FileSinkMaxFileBytes,
+			MaxFileAge:   config.FileSinkMaxFileAge,
+			Gzip:         config.FileSinkGzip,
+		})
+	case parquetSinkName:
+		s, err = sink.NewParquetSink(sink.ParquetSinkConfig{Dir: config.ParquetSinkDir})
+	case webhookSinkName:
+		s, err = newWebhookSink()
+	case replicaSinkName:
+		s, err = newPostgresReplicaSink()
+	case sqliteSinkName:
+		s, err = sink.NewSQLiteSink(config.SQLiteSinkPath)
+	case redisSinkName:
+		s, err = sink.NewRedisCacheSink(sink.RedisCacheSinkConfig{
+			Addr:       config.RedisAddr,
+			Password:   config.RedisPassword,
+			DB:         config.RedisDB,
+			TTL:        config.RedisCacheTTL,
+			DeletedTTL: config.RedisCacheDeletedTTL,
+		})
+	case searchSinkName:
+		s, err = sink.NewSearchIndexSink(sink.SearchIndexSinkConfig{
+			URL:             config.SearchIndexURL,
+			Username:        os.Getenv(config.SearchIndexUsernameEnv),
+			Password:        os.Getenv(config.SearchIndexPasswordEnv),
+			IndexPrefix:     config.SearchIndexPrefix,
+			PrimaryKeys:     config.SearchIndexPrimaryKeys,
+			MaxBatchActions: config.SearchIndexMaxBatchActions,
+			MaxBatchBytes:   config.SearchIndexMaxBatchBytes,
+			MaxBatchAge:     config.SearchIndexMaxBatchAge,
+			Timeout:         config.SearchIndexTimeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown sink: %s", name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// each sink tracks its own progress, hence uses its own consumer group
+func sinkGroupId(name string) string {
+	return fmt.Sprintf("cdc-%s-sink", name)
+}
+
+func newWebhookSink() (*sink.WebhookSink, error) {
+	var endpoints []sink.WebhookEndpoint
+	for _, e := range config.WebhookEndpoints {
+		secret := os.Getenv(e.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("missing signing secret for webhook '%s' (set %s)", e.Name, e.SecretEnv)
+		}
+		endpoints = append(endpoints, sink.WebhookEndpoint{
+			Name:   e.Name,
+			URL:    e.URL,
+			Secret: secret,
+			Tables: e.Tables,
+		})
+	}
+
+	return sink.NewWebhookSink(sink.WebhookSinkConfig{
+		Endpoints:        endpoints,
+		BatchSize:        config.WebhookBatchSize,
+		MaxAttempts:      config.WebhookMaxAttempts,
+		InitialBackOff:   config.WebhookInitialBackOff,
+		Timeout:          config.WebhookTimeout,
+		BreakerThreshold: config.WebhookBreakerThreshold,
+		BreakerCooldown:  config.WebhookBreakerCooldown,
+	})
+}
+
+func newPostgresReplicaSink() (*sink.PostgresReplicaSink, error) {
+	var tables []sink.ReplicaTable
+	for _, t := range config.ReplicaTables {
+		rt := sink.ReplicaTable{Source: t.Source, Target: t.Target, PrimaryKey: t.PrimaryKey}
+		for _, c := range t.Columns {
+			rt.Columns = append(rt.Columns, sink.ReplicaColumn{Source: c[0], Target: c[1]})
+		}
+		tables = append(tables, rt)
+	}
+
+	return sink.NewPostgresReplicaSink(config.PGReplicaAddr, config.PGReplicaUser, config.PGReplicaPassword, config.PGReplicaDBName, tables)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/connect"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+const usage = `usage: connectors [flags] <command> [connector...]
+
+commands:
+  apply    create or update the connectors of the config files and wait until they are running
+  status   show the state of the connectors and their tasks
+  pause    pause the connectors and wait until they are paused
+  resume   resume the connectors and wait until they are running
+  restart  restart the connectors and their tasks (only the failed ones with -failed-only)
+  delete   delete the connectors
+
+The commands apply to the given connectors or to all connectors of the config files.
+
+flags:
+`
+
+func main() {
+	url := flag.String("url", config.ConnectURL, "Kafka Connect REST API URL")
+	configPaths := flag.String("config", config.ConnectorConfigDir, "comma separated connector config files or directories")
+	failedOnly := flag.Bool("failed-only", false, "restart only failed tasks")
+	wait := flag.Bool("wait", true, "wait until the connectors are in the expected state")
+	timeout := flag.Duration("timeout", config.ConnectWaitTimeout, "max time to wait for the connectors")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, names := flag.Arg(0), flag.Args()[1:]
+
+	configs, err := connect.LoadConnectorConfigs(strings.Split(*configPaths, ",")...)
+	if err != nil {
+		logger.ErrorLogger.Printf("failed to load connector configs: %v\n", err)
+		os.Exit(1)
+	}
+	configs, err = selectConnectors(configs, names, cmd == "apply")
+	if err != nil {
+		logger.ErrorLogger.Println(err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := connect.NewClient(*url, config.ConnectTimeout, config.ConnectPollInterval)
+
+	failed := false
+	for _, cc := range configs {
+		if err := run(ctx, c, cmd, cc, *failedOnly, *wait); err != nil {
+			logger.ErrorLogger.Printf("%s %s: %v\n", cmd, cc.Name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// connectors to operate on (connectors without a config file are allowed, except for apply)
+func selectConnectors(configs []connect.ConnectorConfig, names []string, needConfig bool) ([]connect.ConnectorConfig, error) {
+	if len(names) == 0 {
+		return configs, nil
+	}
+
+	byName := make(map[string]connect.ConnectorConfig, len(configs))
+	for _, cc := range configs {
+		byName[cc.Name] = cc
+	}
+	selected := make([]connect.ConnectorConfig, 0, len(names))
+	for _, n := range names {
+		cc, ok := byName[n]
+		if !ok && needConfig {
+			return nil, fmt.Errorf("no config file for connector %s", n)
+		}
+		cc.Name = n
+		selected = append(selected, cc)
+	}
+	return selected, nil
+}
+
+func run(ctx context.Context, c *connect.Client, cmd string, cc connect.ConnectorConfig, failedOnly bool, wait bool) error {
+	var waitFor string
+	switch cmd {
+	case "apply":
+		res, err := c.CreateOrUpdate(ctx, cc)
+		if err != nil {
+			return err
+		}
+		logger.InfoLogger.Printf("connector %s %s\n", cc.Name, res)
+		waitFor = connect.StateRunning
+	case "status":
+		s, err := c.Status(ctx, cc.Name)
+		if errors.Is(err, connect.ErrNotFound) {
+			fmt.Printf("%s: not found\n", cc.Name)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		printStatus(s)
+	case "pause":
+		if err := c.Pause(ctx, cc.Name); err != nil {
+			return err
+		}
+		waitFor = connect.StatePaused
+	case "resume":
+		if err := c.Resume(ctx, cc.Name); err != nil {
+			return err
+		}
+		waitFor = connect.StateRunning
+	case "restart":
+		if err := c.Restart(ctx, cc.Name, failedOnly); err != nil {
+			return err
+		}
+		waitFor = connect.StateRunning
+	case "delete":
+		err := c.Delete(ctx, cc.Name)
+		if errors.Is(err, connect.ErrNotFound) {
+			logger.InfoLogger.Printf("connector %s doesn't exist\n", cc.Name)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.InfoLogger.Printf("connector %s deleted\n", cc.Name)
+	default:
+		return fmt.Errorf("unknown command (see -h)")
+	}
+
+	if !wait || waitFor == "" {
+		return nil
+	}
+	s, err := c.WaitForState(ctx, cc.Name, waitFor)
+	if s != nil {
+		printStatus(s)
+	}
+	return err
+}
+
+func printStatus(s *connect.ConnectorStatus) {
+	fmt.Printf("%s (%s): %s on %s\n", s.Name, s.Type, s.Connector.State, s.Connector.WorkerID)
+	for _, t := range s.Tasks {
+		fmt.Printf("  task %d: %s on %s\n", t.ID, t.State, t.WorkerID)
+		if t.Trace != "" {
+			line, _, _ := strings.Cut(t.Trace, "\n")
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"sync"
 	"time"
 
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
@@ -15,50 +18,91 @@ import (
 )
 
 const (
-	initialBackOffSeconds   = 1                // Initial backoff in seconds for retries
-	groupMaxAttempts        = 6                // Max attempts to check consumer group readiness
-	processEventMaxAttempts = 3                // Max attempts to process a message
-	idleTimeout             = 10 * time.Second // Consumer idle timeout duration
+	initialBackOffSeconds   = 1 // Initial backoff in seconds for retries
+	groupMaxAttempts        = 6 // Max attempts to check consumer group readiness
+	processEventMaxAttempts = 3 // Max attempts to process a message
+)
+
+// variables (rather than constants) so tests can shorten them
+var (
+	idleTimeout    = 10 * time.Second                                   // Consumer idle timeout duration
+	handlerBackOff = time.Duration(initialBackOffSeconds) * time.Second // Initial backoff of the event handler retries
 )
 
 type eventHandler func(db sink.DBClient, msg *kafka.Message) bool
 
 type consumerConfig struct {
-	topic         string
-	numPartitions int
-	groupId       string
-	handler       eventHandler
+	topic               string
+	numPartitions       int
+	groupId             string
+	handler             eventHandler
+	workersPerPartition int
 }
 
 func main() {
+	workersPerPartition := flag.Int("workers-per-partition", config.ConsumerWorkersPerPartition,
+		"workers per partition, messages are distributed to them by the hash of their key (so each key is processed in order)")
+	adminAddr := flag.String("admin-addr", config.ConsumerAdminAddr, "address of the health, readiness, status and pause endpoints (disabled if empty)")
+	flag.Parse()
 
 	consumerConfigs := []consumerConfig{
 		{
-			topic:         config.UsersTopic,
-			numPartitions: config.UsersNumPartitions,
-			groupId:       config.UsersConsumerGroupId,
-			handler:       handleUserEvent,
+			topic:               config.UsersTopic,
+			numPartitions:       config.UsersNumPartitions,
+			groupId:             config.UsersConsumerGroupId,
+			handler:             handleUserEvent,
+			workersPerPartition: *workersPerPartition,
 		},
 		{
-			topic:         config.OrdersTopic,
-			numPartitions: config.OrdersNumPartitions,
-			groupId:       config.OrdersConsumerGroupId,
-			handler:       handleOrderEvent,
+			topic:               config.OrdersTopic,
+			numPartitions:       config.OrdersNumPartitions,
+			groupId:             config.OrdersConsumerGroupId,
+			handler:             handleOrderEvent,
+			workersPerPartition: *workersPerPartition,
 		},
 	}
 
+	db, err := sink.NewDBClient()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	// serve the health of the consumers (and pause their topics) while they consume
+	status := adminserver.NewStatus(config.UsersTopic, config.OrdersTopic)
+	if *adminAddr != "" {
+		srv := newAdminServer(db, status, consumerConfigs)
+		srv.Start(*adminAddr)
+		defer srv.Stop(context.Background())
+	}
+
 	var wg sync.WaitGroup
 	for i := range consumerConfigs {
 		wg.Add(1)
 		go func(c *consumerConfig) {
 			defer wg.Done()
-			consumeEvents(c)
+			consumeEvents(c, db, status)
 		}(&consumerConfigs[i])
 	}
 
 	wg.Wait()
 }
 
+// newAdminServer returns the admin server of the consumers, ready once Kafka and Postgres are reachable and the
+// consumers joined their groups
+func newAdminServer(db sink.DBClient, status *adminserver.Status, consumerConfigs []consumerConfig) *adminserver.Server {
+	admin := kafkautils.NewAdminClient(config.KafkaBrokers...)
+	srv := adminserver.New("consumer", status)
+	srv.AddCheck("kafka", adminserver.KafkaCheck(admin))
+	srv.AddCheck("postgres", func(ctx context.Context) error {
+		return db.Exec("SELECT 1")
+	})
+	for _, c := range consumerConfigs {
+		srv.AddCheck("group "+c.groupId, adminserver.GroupCheck(admin, c.groupId))
+	}
+	return srv
+}
+
 func handleUserEvent(db sink.DBClient, msg *kafka.Message) bool {
 	// de-serialise event and put it into DB...
 	var event model.UserEvent
@@ -74,108 +118,71 @@ func handleOrderEvent(db sink.DBClient, msg *kafka.Message) bool {
 }
 
 // consume events - blocks until new message arrives or time out reached
-func consumeEvents(c *consumerConfig) {
+func consumeEvents(c *consumerConfig, db sink.DBClient, status *adminserver.Status) {
 
-	// create the topic if it doesn't exist
-	if !kafkautils.TopicExists(c.topic, config.KafkaBrokers...) {
-		logger.InfoLogger.Printf("Topic '%s' not found. Creating the topic...\n", c.topic)
-		err := kafkautils.CreateTopic(config.KafkaBrokers[0], c.topic, c.numPartitions, config.KafkaReplicationFactor)
-		if err != nil {
-			panic(err)
-		}
+	// create the topic if it doesn't exist (and update its partitions and configs to the spec)
+	admin := kafkautils.NewAdminClient(config.KafkaBrokers...)
+	if _, err := kafkautils.EnsureTopics(context.Background(), admin, kafkautils.DefaultTopicSpec(c.topic, c.numPartitions)); err != nil {
+		logger.ErrorLogger.Printf("Failed to provision '%s' topic: %v\n", c.topic, err)
+		return
 	}
 
-	// create a new reader (this will cause rebalancing of partition in Kafka)
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: config.KafkaBrokers,
-		Topic:   c.topic,
-		GroupID: c.groupId,
-	})
-
-	// check consumer group state and wait for it to be ready before start reading
-	err := kafkautils.WaitForGroupReady(config.KafkaBrokers, c.groupId, groupMaxAttempts, initialBackOffSeconds)
+	// join the consumer group (this will cause rebalancing of partitions in Kafka), the workers of the partitions
+	// assigned to the consumer are started and stopped in the rebalances
+	pool := newWorkerPool(db, c.handler, c.workersPerPartition, status)
+	r, err := kafkautils.NewGroupReader(config.KafkaBrokers, c.topic, c.groupId, pool)
 	if err != nil {
 		panic(err)
 	}
+	defer r.Close()
 
-	db, err := sink.NewDBClient()
+	// check consumer group state and wait for it to be ready before start reading
+	err = kafkautils.WaitForGroupReady(config.KafkaBrokers, c.groupId, groupMaxAttempts, initialBackOffSeconds)
 	if err != nil {
 		panic(err)
 	}
-	defer db.Close()
 
-	// create a channel per partition
-	chPerPartition := make(map[int]chan *kafka.Message)
-	for i := range c.numPartitions {
-		chPerPartition[i] = make(chan *kafka.Message, 100) // buffered channel
-	}
+	runConsumer(r, pool, c.topic)
+}
 
-	// dispatch message processing to workers (one per partition)
-	logger.InfoLogger.Printf("Starting a worker per partition for '%s' topic...\n", c.topic)
-	wg := &sync.WaitGroup{}
-	for i := range c.numPartitions {
-		wg.Add(1)
-		startWorker(chPerPartition[i], r, db, c.handler, wg, context.Background())
-	}
+// runConsumer dispatches the messages of the reader to the worker of their partition until no message is received for
+// the idle timeout or a message fails to be processed (then the offsets of the following messages aren't committed).
+// While the topic is paused no messages are fetched (and the idle timeout doesn't apply).
+func runConsumer(r kafkautils.MessageReader, pool *workerPool, topic string) {
+	pool.reader = r
+	logger.InfoLogger.Printf("Starting a worker per assigned partition of '%s' topic...\n", topic)
 
-	lastMsgSeen := time.Now()
 	// start consuming messages with an idle timeout...
-	// (if the time passed since last message received is greater than the idleTimeout seconds then stop the consumer)
+	// (if no message is received within the idleTimeout then stop the consumer)
 	for {
-		ctx, cancel := context.WithTimeout(context.Background(), idleTimeout)
-		msg, err := r.FetchMessage(ctx)
-		cancel()
+		if err := pool.status.WaitUntilResumed(pool.ctx, topic); err != nil {
+			logger.ErrorLogger.Printf("Stopping consumer of '%s' topic after a failed message\n", topic)
+			break
+		}
+
+		fetchCtx, cancelFetch := context.WithTimeout(pool.ctx, idleTimeout)
+		msg, err := r.FetchMessage(fetchCtx)
+		cancelFetch()
 
 		if err != nil {
-			if err == context.DeadlineExceeded {
-				if time.Since(lastMsgSeen) >= idleTimeout {
-					logger.InfoLogger.Printf("No new messages received for %v, shutting down consumer\n", idleTimeout)
-					break
-				}
+			switch {
+			case pool.ctx.Err() != nil:
+				logger.ErrorLogger.Printf("Stopping consumer of '%s' topic after a failed message\n", topic)
+			case errors.Is(err, context.DeadlineExceeded):
+				logger.InfoLogger.Printf("No new messages received for %v, shutting down consumer\n", idleTimeout)
+			default:
+				logger.ErrorLogger.Printf("Error while reading events from '%s' topic: %v\n", topic, err)
 			}
-			logger.ErrorLogger.Printf("Error while reading events from '%s' topic: %v\n", c.topic, err)
 			break
 		}
 
-		lastMsgSeen = time.Now()
-		// dispatch the message to channel based on its partition
-		chPerPartition[msg.Partition] <- &msg
-	}
-
-	// closing worker channels
-	for i := range c.numPartitions {
-		close(chPerPartition[i])
+		// dispatch the message to the worker of its partition
+		// (unless a worker failed, as its channel isn't drained anymore)
+		if !pool.dispatch(&msg) {
+			logger.ErrorLogger.Printf("Stopping consumer of '%s' topic after a failed message\n", topic)
+			break
+		}
 	}
-	wg.Wait() // Wait for all workers to finish
-}
 
-func startWorker(ch <-chan *kafka.Message, r *kafka.Reader, db sink.DBClient, dbHandler eventHandler, wg *sync.WaitGroup, ctx context.Context) {
-	go func() {
-		defer wg.Done()
-		for msg := range ch {
-			logger.DebugLogger.Printf("Topic: %s, Partition: %v, Offset: %v\nKey: %s, Message: %s\n",
-				msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
-
-			var success bool
-
-			// process message with a backoff retry strategy...
-			for i := 0; i < processEventMaxAttempts; i++ {
-				success = dbHandler(db, msg)
-				if success {
-					break
-				}
-				delay := initialBackOffSeconds << i
-				logger.DebugLogger.Printf("[Attempt %d/%d] DB event handler failed for '%s', trying again in %d seconds...\n", i+1, processEventMaxAttempts, msg.Topic, delay)
-				time.Sleep(time.Duration(delay) * time.Second)
-			}
-
-			// stop consuming further if the current message was failed to process
-			if !success {
-				break
-			}
-
-			// commit the offset
-			r.CommitMessages(ctx, *msg)
-		}
-	}()
+	pool.stop() // Wait for all workers to finish
 }
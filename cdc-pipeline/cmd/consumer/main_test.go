@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeDB is a DBClient not connected to a database (the test handlers don't use it)
+type fakeDB struct{}
+
+func (fakeDB) Exec(query string, args ...any) error { return nil }
+func (fakeDB) Close() error                         { return nil }
+
+// recordingHandler records the processed messages, failing for the messages with the value "bad"
+type recordingHandler struct {
+	mu        sync.Mutex
+	processed []kafka.Message
+	attempts  int
+	delay     time.Duration // of each message
+}
+
+func (h *recordingHandler) handle(db sink.DBClient, msg *kafka.Message) bool {
+	time.Sleep(h.delay)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts++
+	if string(msg.Value) == "bad" {
+		return false
+	}
+	h.processed = append(h.processed, *msg)
+	return true
+}
+
+func setTestTimeouts(t *testing.T) {
+	idle, backOff := idleTimeout, handlerBackOff
+	idleTimeout, handlerBackOff = 50*time.Millisecond, time.Millisecond
+	t.Cleanup(func() { idleTimeout, handlerBackOff = idle, backOff })
+}
+
+// write messages to a topic (the values are also used as keys)
+func writeTestMessages(t *testing.T, b *kafkautils.MemoryBroker, topic string, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		if err := b.Writer(topic).WriteMessages(context.Background(), kafka.Message{Key: []byte(v), Value: []byte(v)}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+}
+
+// run a consumer of the topic until it stops, fails the test if it doesn't stop in time
+func runTestConsumer(t *testing.T, b *kafkautils.MemoryBroker, c *consumerConfig) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runConsumer(b.Reader(c.topic, c.groupId), newWorkerPool(fakeDB{}, c.handler, c.workersPerPartition, adminserver.NewStatus(c.topic)), c.topic)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("consumer of '%s' didn't stop", c.topic)
+	}
+}
+
+func TestRunConsumer_CommitsInOrder(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("users", 3)
+	var values []string
+	for i := range 30 {
+		values = append(values, fmt.Sprintf("user-%d", i))
+	}
+	writeTestMessages(t, b, "users", values...)
+
+	h := &recordingHandler{}
+	c := &consumerConfig{topic: "users", numPartitions: 3, groupId: "users-group", handler: h.handle}
+	runTestConsumer(t, b, c) // stops after the idle timeout
+
+	if len(h.processed) != len(values) {
+		t.Errorf("expected %d processed messages, got %d", len(values), len(h.processed))
+	}
+
+	// offsets of each partition are committed in order, up to the last message
+	next := make(map[int]int64)
+	for _, msg := range b.Commits("users-group") {
+		if msg.Offset != next[msg.Partition] {
+			t.Fatalf("expected offset %d of partition %d to be committed next, got %d", next[msg.Partition], msg.Partition, msg.Offset)
+		}
+		next[msg.Partition]++
+	}
+	for p := range 3 {
+		offset, _ := b.CommittedOffset("users-group", "users", p)
+		if n := int64(len(b.Messages("users", p))); offset != n {
+			t.Errorf("expected committed offset %d of partition %d, got %d", n, p, offset)
+		}
+	}
+}
+
+func TestRunConsumer_StopsAfterFailedMessage(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("orders", 1)
+
+	// more messages follow the failed one than a worker channel buffers, so the dispatcher must not block on it
+	values := []string{"o0", "o1", "bad"}
+	for i := range 150 {
+		values = append(values, fmt.Sprintf("o%d", i+3))
+	}
+	writeTestMessages(t, b, "orders", values...)
+
+	h := &recordingHandler{}
+	c := &consumerConfig{topic: "orders", numPartitions: 1, groupId: "orders-group", handler: h.handle}
+	runTestConsumer(t, b, c)
+
+	if h.attempts != 2+processEventMaxAttempts {
+		t.Errorf("expected the failed message to be tried %d times, got %d handler calls", processEventMaxAttempts, h.attempts)
+	}
+	if offset, _ := b.CommittedOffset("orders-group", "orders", 0); offset != 2 {
+		t.Errorf("expected committed offset 2 (the failed message), got %d", offset)
+	}
+
+	// a new consumer of the group resumes at the failed message
+	h = &recordingHandler{}
+	c.handler = h.handle
+	runTestConsumer(t, b, c)
+	if h.attempts != processEventMaxAttempts || len(h.processed) != 0 {
+		t.Errorf("expected the consumer to resume at the failed message, got %d handler calls", h.attempts)
+	}
+}
+
+func TestRunConsumer_StopsAfterFailedCommit(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("users", 1)
+	writeTestMessages(t, b, "users", "u0", "u1", "u2")
+
+	b.FailCommit = func(group string, msgs []kafka.Message) error {
+		if msgs[0].Offset == 1 {
+			return kafka.NotCoordinatorForGroup
+		}
+		return nil
+	}
+	h := &recordingHandler{}
+	c := &consumerConfig{topic: "users", numPartitions: 1, groupId: "users-group", handler: h.handle}
+	runTestConsumer(t, b, c)
+
+	if offset, _ := b.CommittedOffset("users-group", "users", 0); offset != 1 {
+		t.Errorf("expected committed offset 1, got %d", offset)
+	}
+	if len(h.processed) != 2 {
+		t.Errorf("expected no messages to be processed after the failed commit, got %d", len(h.processed))
+	}
+}
+
+// processedByPartition returns the number of processed messages by partition
+func (h *recordingHandler) processedByPartition() map[int]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := make(map[int]int)
+	for _, msg := range h.processed {
+		counts[msg.Partition]++
+	}
+	return counts
+}
+
+func TestRunConsumer_DiscoversPartitions(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("users", 2)
+	var values []string
+	for i := range 40 {
+		values = append(values, fmt.Sprintf("user-%d", i))
+	}
+	writeTestMessages(t, b, "users", values[:20]...)
+	b.AddPartitions("users", 5) // more partitions than configured
+	writeTestMessages(t, b, "users", values[20:]...)
+
+	h := &recordingHandler{}
+	c := &consumerConfig{topic: "users", numPartitions: 2, groupId: "users-group", handler: h.handle}
+	runTestConsumer(t, b, c)
+
+	if len(h.processed) != len(values) {
+		t.Errorf("expected %d processed messages, got %d", len(values), len(h.processed))
+	}
+	for p := range 5 {
+		offset, _ := b.CommittedOffset("users-group", "users", p)
+		if n := int64(len(b.Messages("users", p))); offset != n {
+			t.Errorf("expected committed offset %d of partition %d, got %d", n, p, offset)
+		}
+	}
+}
+
+func TestRunConsumer_DrainsRevokedPartitions(t *testing.T) {
+	setTestTimeouts(t)
+	idleTimeout = 500 * time.Millisecond // keeps the first consumer running while the second joins
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("orders", 4)
+	var values []string
+	for i := range 80 {
+		values = append(values, fmt.Sprintf("order-%d", i))
+	}
+	writeTestMessages(t, b, "orders", values...)
+
+	// the first consumer is assigned all partitions
+	h1 := &recordingHandler{delay: 2 * time.Millisecond}
+	pool1 := newWorkerPool(fakeDB{}, h1.handle, 1, adminserver.NewStatus("orders"))
+	done1 := make(chan struct{})
+	r1 := b.GroupReader("orders", "orders-group", pool1)
+	go func() {
+		defer close(done1)
+		runConsumer(r1, pool1, "orders")
+	}()
+	for deadline := time.Now().Add(5 * time.Second); len(h1.processedByPartition()) < 4; {
+		if time.Now().After(deadline) {
+			t.Fatalf("the first consumer didn't process messages of all partitions")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a second consumer joins: partitions 1 and 3 are revoked from the first one, which processed and committed the
+	// messages dispatched to their workers before they're assigned to the second one
+	h2 := &recordingHandler{}
+	pool2 := newWorkerPool(fakeDB{}, h2.handle, 1, adminserver.NewStatus("orders"))
+	r2 := b.GroupReader("orders", "orders-group", pool2)
+	revoked := h1.processedByPartition()
+	for _, p := range []int{1, 3} {
+		if offset, _ := b.CommittedOffset("orders-group", "orders", p); offset != int64(revoked[p]) {
+			t.Errorf("expected committed offset %d of revoked partition %d, got %d", revoked[p], p, offset)
+		}
+	}
+	runConsumer(r2, pool2, "orders")
+	<-done1
+
+	// no message of the revoked partitions was processed by the first consumer afterwards, nor twice
+	processed1, processed2 := h1.processedByPartition(), h2.processedByPartition()
+	for _, p := range []int{1, 3} {
+		if processed1[p] != revoked[p] {
+			t.Errorf("expected the first consumer to process %d messages of revoked partition %d, got %d", revoked[p], p, processed1[p])
+		}
+		if n := len(b.Messages("orders", p)); processed1[p]+processed2[p] != n {
+			t.Errorf("expected %d processed messages of partition %d, got %d + %d", n, p, processed1[p], processed2[p])
+		}
+	}
+	for _, p := range []int{0, 2} {
+		if processed2[p] != 0 {
+			t.Errorf("expected partition %d to stay with the first consumer, got %d messages processed by the second", p, processed2[p])
+		}
+	}
+	for p := range 4 {
+		offset, _ := b.CommittedOffset("orders-group", "orders", p)
+		if n := int64(len(b.Messages("orders", p))); offset != n {
+			t.Errorf("expected committed offset %d of partition %d, got %d", n, p, offset)
+		}
+	}
+}
+
+func TestRunConsumer_WorkersPerPartitionKeepKeyOrder(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("orders", 2)
+	w := b.Writer("orders")
+	for i := range 100 {
+		key := fmt.Sprintf("order-%d", i%10)
+		if err := w.WriteMessages(context.Background(), kafka.Message{Key: []byte(key), Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+
+	h := &recordingHandler{delay: time.Millisecond}
+	c := &consumerConfig{topic: "orders", numPartitions: 2, groupId: "orders-group", handler: h.handle, workersPerPartition: 4}
+	runTestConsumer(t, b, c)
+
+	if len(h.processed) != 100 {
+		t.Errorf("expected 100 processed messages, got %d", len(h.processed))
+	}
+
+	// the messages of a key are processed in order
+	last := make(map[string]int64)
+	for _, msg := range h.processed {
+		if prev, ok := last[string(msg.Key)]; ok && msg.Offset <= prev {
+			t.Errorf("message at offset %d of key %s processed after offset %d", msg.Offset, msg.Key, prev)
+		}
+		last[string(msg.Key)] = msg.Offset
+	}
+
+	// the committed offsets of a partition only move forward, up to the last message
+	committed := make(map[int]int64)
+	for _, msg := range b.Commits("orders-group") {
+		if msg.Offset < committed[msg.Partition] {
+			t.Errorf("offset %d of partition %d committed after offset %d", msg.Offset, msg.Partition, committed[msg.Partition])
+		}
+		committed[msg.Partition] = msg.Offset
+	}
+	for p := range 2 {
+		offset, _ := b.CommittedOffset("orders-group", "orders", p)
+		if n := int64(len(b.Messages("orders", p))); offset != n {
+			t.Errorf("expected committed offset %d of partition %d, got %d", n, p, offset)
+		}
+	}
+}
+
+func TestRunConsumer_WorkersPerPartitionDontCommitPastFailedMessage(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("users", 1)
+	w := b.Writer("users")
+	for i := range 40 {
+		value := fmt.Sprint(i)
+		if i == 5 {
+			value = "bad"
+		}
+		if err := w.WriteMessages(context.Background(), kafka.Message{Key: []byte(fmt.Sprintf("user-%d", i)), Value: []byte(value)}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+
+	// the other workers process later messages, but their offsets aren't committed past the failed message
+	h := &recordingHandler{}
+	c := &consumerConfig{topic: "users", numPartitions: 1, groupId: "users-group", handler: h.handle, workersPerPartition: 4}
+	runTestConsumer(t, b, c)
+
+	if offset, _ := b.CommittedOffset("users-group", "users", 0); offset > 5 {
+		t.Errorf("expected a committed offset up to 5 (the failed message), got %d", offset)
+	}
+}
+
+func TestRunConsumer_PausesTopic(t *testing.T) {
+	setTestTimeouts(t)
+	b := kafkautils.NewMemoryBroker()
+	b.CreateTopic("users", 2)
+	writeTestMessages(t, b, "users", "u0", "u1", "u2", "u3")
+
+	status := adminserver.NewStatus("users")
+	if err := status.Pause("users"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	h := &recordingHandler{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runConsumer(b.Reader("users", "users-group"), newWorkerPool(fakeDB{}, h.handle, 1, status), "users")
+	}()
+
+	// a paused consumer neither fetches messages nor stops after the idle timeout
+	select {
+	case <-done:
+		t.Fatalf("paused consumer stopped")
+	case <-time.After(4 * idleTimeout):
+	}
+	if n := len(h.processedByPartition()); n != 0 {
+		t.Fatalf("expected no processed messages while paused, got messages of %d partitions", n)
+	}
+
+	if err := status.Resume("users"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("consumer didn't stop after resuming")
+	}
+	if len(h.processed) != 4 {
+		t.Errorf("expected 4 processed messages, got %d", len(h.processed))
+	}
+
+	// the workers report their last processed offsets
+	for _, w := range status.Workers() {
+		if n := int64(len(b.Messages("users", w.Partition))); w.LastOffset != n-1 || w.Retrying {
+			t.Errorf("unexpected status of partition %d: %+v", w.Partition, w)
+		}
+	}
+}
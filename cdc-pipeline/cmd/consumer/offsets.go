@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker tracks the messages of a partition dispatched to its workers, which may complete out of order. Only
+// the messages dispatched before all unfinished ones are committed, so a restart never skips an unfinished message.
+type offsetTracker struct {
+	mu        sync.Mutex
+	pending   []int64                  // offsets of the dispatched messages not committed yet, in dispatch order
+	completed map[int64]*kafka.Message // the completed pending messages, by offset
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{completed: make(map[int64]*kafka.Message)}
+}
+
+// add records a dispatched message (messages are dispatched in the order of their offsets)
+func (t *offsetTracker) add(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, offset)
+}
+
+// complete marks a message as processed and returns the last message of the completed messages dispatched before all
+// unfinished ones (whose offset is to be committed), nil if the first pending message isn't completed yet
+func (t *offsetTracker) complete(msg *kafka.Message) *kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.completed[msg.Offset] = msg
+
+	var last *kafka.Message
+	for len(t.pending) > 0 {
+		m, ok := t.completed[t.pending[0]]
+		if !ok {
+			break
+		}
+		last = m
+		delete(t.completed, t.pending[0])
+		t.pending = t.pending[1:]
+	}
+	return last
+}
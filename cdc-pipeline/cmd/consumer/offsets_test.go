@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTracker_CommitsContiguousOffsets(t *testing.T) {
+	tracker := newOffsetTracker()
+	for _, offset := range []int64{3, 4, 5, 7, 8} { // offsets may have gaps (e.g. after compaction)
+		tracker.add(offset)
+	}
+
+	steps := []struct {
+		completed int64
+		want      int64 // offset of the message to commit (-1 for none)
+	}{
+		{4, -1}, // 3 is unfinished
+		{7, -1},
+		{3, 4}, // 3 and 4 are completed, 5 is unfinished
+		{5, 7}, // up to 7, 8 is unfinished
+		{8, 8},
+	}
+	for _, s := range steps {
+		got := int64(-1)
+		if msg := tracker.complete(&kafka.Message{Offset: s.completed}); msg != nil {
+			got = msg.Offset
+		}
+		if got != s.want {
+			t.Errorf("completing offset %d: expected to commit %d, got %d", s.completed, s.want, got)
+		}
+	}
+	if len(tracker.pending) != 0 || len(tracker.completed) != 0 {
+		t.Errorf("expected no tracked offsets, got %v pending and %d completed", tracker.pending, len(tracker.completed))
+	}
+}
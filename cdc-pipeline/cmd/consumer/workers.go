@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+	"github.com/segmentio/kafka-go"
+)
+
+const workerBufferSize = 100 // messages buffered per worker
+
+var errHandlerFailed = errors.New("event handler failed")
+
+// workerPool processes the messages of a consumer with workers per partition. The messages of a partition are
+// distributed to its workers by the hash of their key, so the messages of a key are processed in order. Workers are
+// started when the first message of their partition is dispatched, and stopped when the partition is revoked in a
+// rebalance (after the messages dispatched to them are processed and committed). When a message fails to be processed
+// (or committed) the pool's context is cancelled, so no further offsets are committed. The processed and failed
+// messages are reported to the status of the admin server.
+type workerPool struct {
+	db                  sink.DBClient
+	handler             eventHandler
+	workersPerPartition int
+	status              *adminserver.Status
+	reader              kafkautils.MessageReader // commits the offsets of the processed messages
+	ctx                 context.Context
+	cancel              context.CancelFunc
+	wg                  sync.WaitGroup
+
+	mu       sync.Mutex
+	workers  map[kafkautils.TopicPartition]*partitionWorker
+	assigned map[kafkautils.TopicPartition]bool // nil until the reader assigns partitions (then all partitions are consumed)
+}
+
+// partitionWorker is the group of workers of a partition (one per key hash)
+type partitionWorker struct {
+	lanes    []chan *kafka.Message // messages of each worker
+	offsets  *offsetTracker
+	commitMu sync.Mutex    // commits of the partition are in offset order
+	revoked  chan struct{} // closed when the partition is revoked
+	done     chan struct{} // closed when all workers of the partition stopped
+}
+
+var _ kafkautils.RebalanceListener = (*workerPool)(nil)
+
+// newWorkerPool creates a pool with a number of workers per partition (at least one), reporting to the status
+func newWorkerPool(db sink.DBClient, handler eventHandler, workersPerPartition int, status *adminserver.Status) *workerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerPool{
+		db:                  db,
+		handler:             handler,
+		workersPerPartition: max(workersPerPartition, 1),
+		status:              status,
+		ctx:                 ctx,
+		cancel:              cancel,
+		workers:             make(map[kafkautils.TopicPartition]*partitionWorker),
+	}
+}
+
+// PartitionsAssigned records the partitions assigned in a new generation, their workers start with their first message
+func (p *workerPool) PartitionsAssigned(partitions []kafkautils.TopicPartition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.assigned == nil {
+		p.assigned = make(map[kafkautils.TopicPartition]bool)
+	}
+	for _, tp := range partitions {
+		p.assigned[tp] = true
+	}
+}
+
+// PartitionsRevoked stops the workers of the revoked partitions, returning once they processed (and committed) the
+// messages dispatched to them
+func (p *workerPool) PartitionsRevoked(partitions []kafkautils.TopicPartition) {
+	p.mu.Lock()
+	var stopping []*partitionWorker
+	for _, tp := range partitions {
+		if p.assigned != nil {
+			delete(p.assigned, tp)
+		}
+		p.status.Removed(tp.Topic, tp.Partition)
+		if w, ok := p.workers[tp]; ok {
+			close(w.revoked)
+			delete(p.workers, tp)
+			stopping = append(stopping, w)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range stopping {
+		<-w.done
+	}
+	logger.InfoLogger.Printf("Workers of revoked partitions %v stopped\n", partitions)
+}
+
+// dispatch sends a message to the worker of its key in its partition (starting the workers of the partition with its
+// first message), false if the pool's context is cancelled after a failed message. Messages of revoked partitions are dropped, as they
+// are fetched again by the consumer the partitions are assigned to.
+func (p *workerPool) dispatch(msg *kafka.Message) bool {
+	tp := kafkautils.TopicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	p.mu.Lock()
+	if p.assigned != nil && !p.assigned[tp] {
+		p.mu.Unlock()
+		logger.DebugLogger.Printf("Dropping message of revoked partition: topic=%s partition=%d offset=%d\n", msg.Topic, msg.Partition, msg.Offset)
+		return true
+	}
+	w, ok := p.workers[tp]
+	if !ok {
+		logger.InfoLogger.Printf("Starting %d worker(s) for partition %d of '%s' topic\n", p.workersPerPartition, msg.Partition, msg.Topic)
+		w = p.startWorkers()
+		p.workers[tp] = w
+	}
+	p.mu.Unlock()
+
+	lane := 0
+	if len(w.lanes) > 1 {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		lane = int(h.Sum32() % uint32(len(w.lanes)))
+	}
+	w.offsets.add(msg.Offset)
+	select {
+	case w.lanes[lane] <- msg:
+	case <-w.revoked:
+	case <-p.ctx.Done():
+		return false
+	}
+	return true
+}
+
+// stop stops all workers after they processed the dispatched messages
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	for tp, w := range p.workers {
+		for _, lane := range w.lanes {
+			close(lane)
+		}
+		delete(p.workers, tp)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	p.cancel()
+}
+
+// startWorkers starts the workers of a partition, each processing its messages in order until its channel is closed or
+// the partition is revoked
+func (p *workerPool) startWorkers() *partitionWorker {
+	w := &partitionWorker{offsets: newOffsetTracker(), revoked: make(chan struct{}), done: make(chan struct{})}
+	var lanesWg sync.WaitGroup
+	for range p.workersPerPartition {
+		lane := make(chan *kafka.Message, workerBufferSize)
+		w.lanes = append(w.lanes, lane)
+		lanesWg.Add(1)
+		go func() {
+			defer lanesWg.Done()
+			p.runWorker(w, lane)
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		lanesWg.Wait()
+		close(w.done)
+	}()
+	return w
+}
+
+func (p *workerPool) runWorker(w *partitionWorker, lane <-chan *kafka.Message) {
+	for {
+		select {
+		case msg, ok := <-lane:
+			if !ok {
+				return
+			}
+			p.process(w, msg)
+		case <-w.revoked:
+			// drain the messages dispatched before the revocation
+			for {
+				select {
+				case msg, ok := <-lane:
+					if !ok {
+						return
+					}
+					p.process(w, msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// process handles a message with retries and commits the offsets completed with it, cancelling the pool's context if
+// either fails
+func (p *workerPool) process(w *partitionWorker, msg *kafka.Message) {
+	if p.ctx.Err() != nil {
+		return // drain the channel after a failure
+	}
+
+	logger.DebugLogger.Printf("Topic: %s, Partition: %v, Offset: %v\nKey: %s, Message: %s\n",
+		msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
+
+	var success bool
+
+	// process message with a backoff retry strategy...
+	for i := 0; i < processEventMaxAttempts; i++ {
+		success = p.handler(p.db, msg)
+		if success {
+			break
+		}
+		p.status.Failed(*msg, errHandlerFailed, i+1, i+1 < processEventMaxAttempts)
+		delay := handlerBackOff << i
+		logger.DebugLogger.Printf("[Attempt %d/%d] DB event handler failed for '%s', trying again in %v...\n", i+1, processEventMaxAttempts, msg.Topic, delay)
+		time.Sleep(delay)
+	}
+
+	// stop consuming further if the current message was failed to process
+	if !success {
+		logger.ErrorLogger.Printf("Failed to process message: topic=%s partition=%d offset=%d\n", msg.Topic, msg.Partition, msg.Offset)
+		p.cancel()
+		return
+	}
+
+	p.status.Processed(*msg)
+
+	// commit the offset (once the messages dispatched before it are processed too)
+	w.commitMu.Lock()
+	defer w.commitMu.Unlock()
+	last := w.offsets.complete(msg)
+	if last == nil {
+		return
+	}
+	if err := p.reader.CommitMessages(context.Background(), *last); err != nil {
+		logger.ErrorLogger.Printf("Failed to commit offset: topic=%s partition=%d offset=%d: %v\n", last.Topic, last.Partition, last.Offset, err)
+		p.status.Failed(*last, fmt.Errorf("commit failed: %w", err), 1, false)
+		p.cancel()
+	}
+}
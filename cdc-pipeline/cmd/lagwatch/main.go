@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/lagwatch"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// monitors the lag of the consumer groups (the pipeline groups by default), serving it as Prometheus metrics (/metrics)
+// and JSON (/lag) next to its health (/healthz, /readyz), and alerts when the lag or its growth rate crosses a threshold for a sustained period
+//
+//	lagwatch [flags] [group pattern...]
+func main() {
+	listen := flag.String("listen", config.LagWatchListenAddr, "address of the metrics and JSON endpoints")
+	interval := flag.Duration("interval", config.LagWatchInterval, "time between lag computations")
+	maxLag := flag.Int64("max-lag", config.LagWatchMaxLag, "messages a group may be behind before alerting (0 disables the alert)")
+	maxGrowth := flag.Float64("max-growth", config.LagWatchMaxGrowthRate, "messages per second the lag of a group may grow before alerting (0 disables the alert)")
+	sustainedFor := flag.Duration("for", config.LagWatchSustainedFor, "time a threshold must be crossed to fire an alert")
+	webhook := flag.String("webhook", config.LagWatchWebhookURL, "URL the alerts are POSTed to (in addition to the log)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lagwatch [flags] [group pattern...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *interval <= 0 {
+		logger.ErrorLogger.Println("-interval must be positive")
+		os.Exit(2)
+	}
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = config.KafkaPipelineGroups
+	}
+
+	hooks := []lagwatch.AlertHook{lagwatch.LogHook{}}
+	if *webhook != "" {
+		hooks = append(hooks, lagwatch.NewWebhookHook(*webhook, config.LagWatchWebhookTimeout))
+	}
+	admin := kafkautils.NewAdminClient(config.KafkaBrokers...)
+	source := lagwatch.NewAdminSource(admin, patterns...)
+	w := lagwatch.NewWatcher(source, lagwatch.Config{MaxLag: *maxLag, MaxGrowthRate: *maxGrowth, SustainedFor: *sustainedFor}, hooks...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the lag endpoints are served by the admin server, ready while Kafka is reachable
+	adminSrv := adminserver.New("lagwatch", nil)
+	adminSrv.AddCheck("kafka", adminserver.KafkaCheck(admin))
+	adminSrv.Handle("GET /metrics", w.Handler())
+	adminSrv.Handle("GET /lag", w.Handler())
+
+	srv := &http.Server{Addr: *listen, Handler: adminSrv.Handler()}
+	go func() {
+		logger.InfoLogger.Printf("Serving the lag of %v on %s (/metrics, /lag, /healthz, /readyz)\n", patterns, *listen)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorLogger.Printf("Failed to serve the lag endpoints: %v\n", err)
+			stop()
+		}
+	}()
+
+	w.Run(ctx, *interval)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(shutdownCtx)
+	logger.InfoLogger.Println("Lag watcher stopped")
+}
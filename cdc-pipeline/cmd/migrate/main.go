@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/migrate"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+	"github.com/jackc/pgx/v5"
+)
+
+// names of the stores (selected with the -store flag), also the directories of their migrations
+const (
+	postgresStore  = "postgres"
+	cassandraStore = "cassandra"
+)
+
+// applies and rolls back the schema migrations of Postgres and Cassandra
+//
+//	migrate [flags] up [version]   apply the pending migrations (up to the version)
+//	migrate [flags] down <version> roll back the migrations newer than the version (0 rolls back all)
+//	migrate [flags] status         list the migrations and if they're applied
+//	migrate [flags] verify         check that the applied migrations weren't changed since
+func main() {
+	store := flag.String("store", "all", "store to migrate: postgres, cassandra or all")
+	dir := flag.String("dir", config.MigrationsDir, "directory of the migrations (with a postgres/ and cassandra/ directory)")
+	dryRun := flag.Bool("dry-run", false, "print the statements of the migrations instead of running them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [flags] up [version] | down <version> | status | verify\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command := flag.Arg(0)
+	target := 0
+	switch {
+	case command == "down" && flag.NArg() != 2:
+		logger.ErrorLogger.Println("down requires the version to roll back to (0 rolls back all migrations)")
+		os.Exit(2)
+	case (command == "up" || command == "down") && flag.NArg() == 2:
+		var err error
+		if target, err = strconv.Atoi(flag.Arg(1)); err != nil || target < 0 {
+			logger.ErrorLogger.Printf("invalid version %q\n", flag.Arg(1))
+			os.Exit(2)
+		}
+	case command != "up" && command != "down" && command != "status" && command != "verify":
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var stores []string
+	switch *store {
+	case "all":
+		stores = []string{postgresStore, cassandraStore}
+	case postgresStore, cassandraStore:
+		stores = []string{*store}
+	default:
+		logger.ErrorLogger.Printf("unknown store: %s\n", *store)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, config.MigrateTimeout)
+	defer cancel()
+
+	for _, name := range stores {
+		if err := run(ctx, name, filepath.Join(*dir, name), *dryRun, command, target); err != nil {
+			logger.ErrorLogger.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(ctx context.Context, name string, dir string, dryRun bool, command string, target int) error {
+	ext := "sql"
+	if name == cassandraStore {
+		ext = "cql"
+	}
+	migrations, err := migrate.LoadMigrations(dir, ext)
+	if err != nil {
+		return fmt.Errorf("%s: load migrations: %w", name, err)
+	}
+
+	store, closeStore, err := openStore(ctx, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer closeStore()
+
+	m := migrate.NewMigrator(store, migrations, dryRun, os.Stdout)
+	switch command {
+	case "up":
+		n, err := m.Up(ctx, target)
+		if err != nil {
+			return err
+		}
+		logger.InfoLogger.Printf("%s: %d migrations applied%s\n", name, n, dryRunNote(dryRun))
+	case "down":
+		n, err := m.Down(ctx, target)
+		if err != nil {
+			return err
+		}
+		logger.InfoLogger.Printf("%s: %d migrations rolled back%s\n", name, n, dryRunNote(dryRun))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(name, statuses)
+	case "verify":
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+		logger.InfoLogger.Printf("%s: applied migrations verified\n", name)
+	}
+	return nil
+}
+
+func openStore(ctx context.Context, name string) (migrate.Store, func(), error) {
+	if name == postgresStore {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%s@%s/%s", config.PGUser, config.PGPassword, config.PGAddr, config.PGDBName))
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect to postgres: %w", err)
+		}
+		return migrate.NewPostgresStore(conn), func() { conn.Close(context.Background()) }, nil
+	}
+
+	cfg, err := sink.DefaultCassandraClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := migrate.NewCassandraStore(cfg.ClusterConfig(), cfg.Keyspace, config.CassandraReplication, config.MigrateLockTTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to cassandra: %w", err)
+	}
+	return s, s.Close, nil
+}
+
+func printStatus(name string, statuses []migrate.Status) {
+	fmt.Printf("%s:\n", name)
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "applied " + s.AppliedAt.Format(time.RFC3339) + " (migration files missing)"
+		case s.Changed:
+			state = "applied " + s.AppliedAt.Format(time.RFC3339) + " (changed since)"
+		case s.Applied:
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("  %04d_%-30s %s\n", s.Version, s.Name, state)
+	}
+}
+
+func dryRunNote(dryRun bool) string {
+	if dryRun {
+		return " (dry run)"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/adminserver"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/source"
+	"github.com/segmentio/kafka-go"
+)
+
+// streams changes from Postgres' logical replication to the cdc.public.* topics (instead of Debezium)
+func main() {
+	adminAddr := flag.String("admin-addr", config.PGSourceAdminAddr, "address of the health and readiness endpoints (disabled if empty)")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the topic is set on every message, writes return after all in-sync replicas have the messages
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(config.KafkaBrokers...),
+		Balancer:               &kafka.Hash{}, // partition by the row's key (like Debezium)
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+	defer writer.Close()
+
+	src := source.NewPostgresSource(source.PostgresSourceConfig{
+		Addr:               config.PGAddr,
+		User:               config.PGUser,
+		Password:           config.PGPassword,
+		Database:           config.PGDBName,
+		Publication:        config.PGSourcePublication,
+		Tables:             config.PGSourceTables,
+		SlotName:           config.PGSourceSlotName,
+		TopicPrefix:        config.PGSourceTopicPrefix,
+		StandbyTimeout:     config.PGSourceStandbyTimeout,
+		MaxPendingMessages: config.PGSourceMaxPendingMessages,
+		Tombstones:         config.PGSourceTombstones,
+	}, writer)
+
+	// ready while Kafka is reachable and changes are streamed from Postgres
+	if *adminAddr != "" {
+		srv := adminserver.New("pgsource", nil)
+		srv.AddCheck("kafka", adminserver.KafkaCheck(kafkautils.NewAdminClient(config.KafkaBrokers...)))
+		srv.AddCheck("postgres", func(ctx context.Context) error {
+			if !src.Streaming() {
+				return errors.New("replication not streaming")
+			}
+			return nil
+		})
+		srv.Start(*adminAddr)
+		defer srv.Stop(context.Background())
+	}
+
+	if err := src.Run(ctx); err != nil {
+		logger.ErrorLogger.Printf("postgres source failed: %v\n", err)
+		os.Exit(1)
+	}
+	logger.InfoLogger.Printf("postgres source stopped (acknowledged lsn %s)\n", src.AckedLSN())
+}
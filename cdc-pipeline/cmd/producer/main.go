@@ -29,12 +29,9 @@ func main() {
 func produceUserEvents(batchSize int, numBatches int) []model.UUID {
 
 	// create the topic if it doesn't exist
-	if !kafkautils.TopicExists(config.UsersTopic, config.KafkaBrokers...) {
-		logger.InfoLogger.Printf("Topic '%s' not found. Creating the topic...\n", config.UsersTopic)
-		err := kafkautils.CreateTopic(config.KafkaBrokers[0], config.UsersTopic, config.UsersNumPartitions, config.KafkaReplicationFactor)
-		if err != nil {
-			panic(err)
-		}
+	if err := ensureTopic(config.UsersTopic, config.UsersNumPartitions); err != nil {
+		logger.ErrorLogger.Printf("❌ Failed to provision '%s' topic: %v\n", config.UsersTopic, err)
+		os.Exit(1)
 	}
 
 	writer := &kafka.Writer{
@@ -86,12 +83,9 @@ func produceUserEvents(batchSize int, numBatches int) []model.UUID {
 
 func produceOrderEvents(userIds []model.UUID, batchSize int, numBatches int) {
 
-	if !kafkautils.TopicExists(config.OrdersTopic, config.KafkaBrokers...) {
-		logger.InfoLogger.Printf("Topic '%s' not found. Creating the topic...\n", config.OrdersTopic)
-		err := kafkautils.CreateTopic(config.KafkaBrokers[0], config.OrdersTopic, config.OrdersNumPartitions, config.KafkaReplicationFactor)
-		if err != nil {
-			panic(err)
-		}
+	if err := ensureTopic(config.OrdersTopic, config.OrdersNumPartitions); err != nil {
+		logger.ErrorLogger.Printf("❌ Failed to provision '%s' topic: %v\n", config.OrdersTopic, err)
+		os.Exit(1)
 	}
 
 	writer := &kafka.Writer{
@@ -143,3 +137,9 @@ func produceOrderEvents(userIds []model.UUID, batchSize int, numBatches int) {
 	logger.DebugLogger.Println("Number of orders created: ", numOrders)
 	logger.DebugLogger.Println("Number of unique users used for new orders: ", len(myUserIDs))
 }
+
+// create the topic if it doesn't exist (and update its partitions and configs to the spec)
+func ensureTopic(topic string, partitions int) error {
+	_, err := kafkautils.EnsureTopics(context.Background(), kafkautils.NewAdminClient(config.KafkaBrokers...), kafkautils.DefaultTopicSpec(topic, partitions))
+	return err
+}
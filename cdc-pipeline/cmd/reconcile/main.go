@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/reconcile"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/sink"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// compares the Postgres tables with the Cassandra tables written by the cassandra sink
+// (exits with status 1 if they differ and weren't repaired)
+func main() {
+	tables := flag.String("tables", "users,orders,orders_by_user", "comma separated cassandra tables to reconcile")
+	repair := flag.String("repair", "", "repair the differences: 'print' the CQL statements or 'apply' them")
+	ranges := flag.Int("ranges", config.ReconcileTokenRanges, "number of token ranges each table is scanned in")
+	pageSize := flag.Int("page-size", config.ReconcilePageSize, "rows per cassandra page")
+	maxDiffs := flag.Int("max-diffs", config.ReconcileMaxDiffs, "differences printed per table")
+	flag.Parse()
+
+	if *repair != "" && *repair != "print" && *repair != "apply" {
+		logger.ErrorLogger.Printf("invalid -repair value %q (expected 'print' or 'apply')\n", *repair)
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("pgx", fmt.Sprintf("postgres://%s:%s@%s/%s", config.PGUser, config.PGPassword, config.PGAddr, config.PGDBName))
+	if err != nil {
+		logger.ErrorLogger.Printf("failed to connect to postgres: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	cfg, err := sink.DefaultCassandraClientConfig()
+	if err != nil {
+		logger.ErrorLogger.Printf("invalid cassandra config: %v\n", err)
+		os.Exit(2)
+	}
+	sess, err := cfg.ClusterConfig().CreateSession()
+	if err != nil {
+		logger.ErrorLogger.Printf("failed to connect to cassandra: %v\n", err)
+		os.Exit(1)
+	}
+	defer sess.Close()
+
+	r, err := reconcile.NewReconciler(reconcile.NewPostgresReader(db), reconcile.NewCassandraStore(sess, *pageSize), reconcile.Config{
+		Tables:      strings.Split(*tables, ","),
+		TokenRanges: *ranges,
+	})
+	if err != nil {
+		logger.ErrorLogger.Println(err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	report, err := r.Run(ctx)
+	if err != nil {
+		logger.ErrorLogger.Printf("reconciliation failed: %v\n", err)
+		os.Exit(1)
+	}
+	printReport(report, *maxDiffs)
+
+	if report.InSync() {
+		return
+	}
+	writes := reconcile.RepairWrites(report)
+	switch *repair {
+	case "print":
+		for _, w := range writes {
+			fmt.Println(w.CQL())
+		}
+	case "apply":
+		if err := r.Repair(ctx, writes); err != nil {
+			logger.ErrorLogger.Printf("repair failed: %v\n", err)
+			os.Exit(1)
+		}
+		logger.InfoLogger.Printf("applied %d repair writes\n", len(writes))
+		return
+	}
+	os.Exit(1)
+}
+
+func printReport(report *reconcile.Report, maxDiffs int) {
+	printed := make(map[string]int)
+	for _, d := range report.Diffs {
+		if printed[d.Table] < maxDiffs {
+			fmt.Println(d)
+		}
+		printed[d.Table]++
+	}
+
+	fmt.Printf("\n%-16s %9s %9s %8s %8s %9s %10s\n", "TABLE", "EXPECTED", "SCANNED", "MISSING", "EXTRA", "MISMATCH", "PRECISION")
+	for _, tr := range report.Tables {
+		fmt.Printf("%-16s %9d %9d %8d %8d %9d %10d\n", tr.Table, tr.Expected, tr.Scanned,
+			tr.Counts[reconcile.Missing], tr.Counts[reconcile.Extra], tr.Counts[reconcile.Mismatch], tr.Counts[reconcile.Precision])
+	}
+}
@@ -0,0 +1,37 @@
+package adminserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaCheck checks that the Kafka brokers respond to metadata requests
+func KafkaCheck(admin kafkautils.TopicAdmin) Check {
+	return func(ctx context.Context) error {
+		if _, err := admin.Metadata(ctx, &kafka.MetadataRequest{}); err != nil {
+			return fmt.Errorf("kafka unreachable: %w", err)
+		}
+		return nil
+	}
+}
+
+// GroupCheck checks that a consumer group is stable with members (i.e. the command joined it)
+func GroupCheck(admin kafkautils.Admin, groupID string) Check {
+	return func(ctx context.Context) error {
+		groups, err := kafkautils.DescribeGroups(ctx, admin, groupID)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			return fmt.Errorf("consumer group '%s' not found", groupID)
+		}
+		g := groups[0]
+		if g.State != "Stable" || len(g.Members) == 0 {
+			return fmt.Errorf("consumer group '%s' not joined (state %s, %d members)", groupID, g.State, len(g.Members))
+		}
+		return nil
+	}
+}
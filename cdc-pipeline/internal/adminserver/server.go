@@ -0,0 +1,182 @@
+package adminserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// Check returns an error if a dependency of the command isn't ready (e.g. Kafka isn't reachable)
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// CheckResult is the result of a readiness check
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Server is the admin HTTP server of a command:
+//
+//	GET  /healthz                the process is up
+//	GET  /readyz                 all readiness checks pass (503 otherwise)
+//	GET  /status                 the workers and topics of the command
+//	POST /topics/{topic}/pause   pause fetching the messages of a topic
+//	POST /topics/{topic}/resume  resume fetching the messages of a topic
+type Server struct {
+	name         string
+	status       *Status
+	checkTimeout time.Duration
+	mux          *http.ServeMux
+	started      time.Time
+
+	mu     sync.Mutex
+	checks []namedCheck
+	srv    *http.Server
+}
+
+// New returns the admin server of a command, reporting the status (which can be nil if the command has no workers)
+func New(name string, status *Status) *Server {
+	if status == nil {
+		status = NewStatus()
+	}
+	s := &Server{name: name, status: status, checkTimeout: config.AdminCheckTimeout, mux: http.NewServeMux(), started: time.Now()}
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("POST /topics/{topic}/pause", s.handlePause)
+	s.mux.HandleFunc("POST /topics/{topic}/resume", s.handleResume)
+	return s
+}
+
+// AddCheck adds a readiness check
+func (s *Server) AddCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name, check})
+}
+
+// Handle serves another endpoint of the command (e.g. its metrics)
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the handler of the endpoints
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start serves the endpoints on an address in the background until the server is stopped
+func (s *Server) Start(addr string) {
+	srv := &http.Server{Addr: addr, Handler: s.mux}
+	s.mu.Lock()
+	s.srv = srv
+	s.mu.Unlock()
+
+	go func() {
+		logger.InfoLogger.Printf("Admin endpoints of %s listening on %s\n", s.name, addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorLogger.Printf("Admin server of %s failed: %v\n", s.name, err)
+		}
+	}()
+}
+
+// Stop stops serving the endpoints, waiting for the active requests until the context is done
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// Ready runs the readiness checks concurrently, returning their results in the order they were added and whether all
+// of them passed
+func (s *Server) Ready(ctx context.Context) ([]CheckResult, bool) {
+	s.mu.Lock()
+	checks := append([]namedCheck(nil), s.checks...)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, s.checkTimeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = CheckResult{Name: c.name, OK: true}
+			if err := c.check(ctx); err != nil {
+				results[i].OK, results[i].Error = false, err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		ready = ready && r.OK
+	}
+	return results, ready
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "command": s.name, "uptime_seconds": int64(time.Since(s.started).Seconds())})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	results, ready := s.Ready(r.Context())
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"ready": ready, "checks": results})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"command": s.name, "topics": s.status.Topics(), "workers": s.status.Workers()})
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.changeTopic(w, r, s.status.Pause, "paused")
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.changeTopic(w, r, s.status.Resume, "resumed")
+}
+
+func (s *Server) changeTopic(w http.ResponseWriter, r *http.Request, change func(topic string) error, action string) {
+	topic := r.PathValue("topic")
+	if err := change(topic); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrUnknownTopic) {
+			code = http.StatusNotFound
+		}
+		writeJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+	logger.InfoLogger.Printf("Topic '%s' of %s %s\n", topic, s.name, action)
+	writeJSON(w, http.StatusOK, map[string]any{"topic": topic, "paused": s.status.Topics()[topic]})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.ErrorLogger.Printf("Failed to write admin response: %v\n", err)
+	}
+}
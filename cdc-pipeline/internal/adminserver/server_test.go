@@ -0,0 +1,126 @@
+package adminserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/segmentio/kafka-go"
+)
+
+// serve a request to the server, returning the response code and body
+func serve(t *testing.T, s *Server, method string, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestServer_Health(t *testing.T) {
+	s := New("consumer", nil)
+	code, body := serve(t, s, "GET", "/healthz")
+	if code != http.StatusOK || !strings.Contains(body, `"status":"ok"`) || !strings.Contains(body, `"command":"consumer"`) {
+		t.Errorf("unexpected health response %d: %s", code, body)
+	}
+}
+
+func TestServer_Ready(t *testing.T) {
+	s := New("consumer", nil)
+	s.AddCheck("kafka", func(ctx context.Context) error { return nil })
+	code, body := serve(t, s, "GET", "/readyz")
+	if code != http.StatusOK || !strings.Contains(body, `"ready":true`) {
+		t.Errorf("unexpected readiness response %d: %s", code, body)
+	}
+
+	// a failed or hanging check makes the server unready (the checks time out)
+	s.checkTimeout = 20 * time.Millisecond
+	s.AddCheck("postgres", func(ctx context.Context) error { return errors.New("connection refused") })
+	s.AddCheck("group", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	code, body = serve(t, s, "GET", "/readyz")
+	var res struct {
+		Ready  bool          `json:"ready"`
+		Checks []CheckResult `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if code != http.StatusServiceUnavailable || res.Ready || len(res.Checks) != 3 {
+		t.Fatalf("unexpected readiness response %d: %s", code, body)
+	}
+	if !res.Checks[0].OK || res.Checks[1].Error != "connection refused" || res.Checks[2].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("unexpected check results: %+v", res.Checks)
+	}
+}
+
+func TestServer_StatusAndPause(t *testing.T) {
+	status := NewStatus("users")
+	status.Processed(kafka.Message{Topic: "users", Partition: 2, Offset: 41})
+	s := New("consumer", status)
+
+	if code, body := serve(t, s, "POST", "/topics/users/pause"); code != http.StatusOK || !strings.Contains(body, `"paused":true`) {
+		t.Errorf("unexpected pause response %d: %s", code, body)
+	}
+	if code, _ := serve(t, s, "POST", "/topics/orders/pause"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown topic, got %d", code)
+	}
+	if code, _ := serve(t, s, "GET", "/topics/users/pause"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a GET, got %d", code)
+	}
+
+	code, body := serve(t, s, "GET", "/status")
+	var res struct {
+		Topics  map[string]bool `json:"topics"`
+		Workers []WorkerStatus  `json:"workers"`
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if code != http.StatusOK || !res.Topics["users"] || len(res.Workers) != 1 || res.Workers[0].Partition != 2 || res.Workers[0].LastOffset != 41 {
+		t.Errorf("unexpected status response %d: %s", code, body)
+	}
+
+	if code, body := serve(t, s, "POST", "/topics/users/resume"); code != http.StatusOK || !strings.Contains(body, `"paused":false`) {
+		t.Errorf("unexpected resume response %d: %s", code, body)
+	}
+}
+
+// fakeGroupAdmin is an Admin describing consumer groups (its other requests aren't implemented)
+type fakeGroupAdmin struct {
+	kafkautils.Admin
+	groups map[string]kafka.DescribeGroupsResponseGroup
+}
+
+func (a *fakeGroupAdmin) DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error) {
+	res := &kafka.DescribeGroupsResponse{}
+	for _, id := range req.GroupIDs {
+		if g, ok := a.groups[id]; ok {
+			res.Groups = append(res.Groups, g)
+		}
+	}
+	return res, nil
+}
+
+func TestGroupCheck(t *testing.T) {
+	admin := &fakeGroupAdmin{groups: map[string]kafka.DescribeGroupsResponseGroup{
+		"joined":      {GroupID: "joined", GroupState: "Stable", Members: []kafka.DescribeGroupsResponseMember{{MemberID: "m1"}}},
+		"rebalancing": {GroupID: "rebalancing", GroupState: "PreparingRebalance", Members: []kafka.DescribeGroupsResponseMember{{MemberID: "m1"}}},
+		"empty":       {GroupID: "empty", GroupState: "Empty"},
+	}}
+	if err := GroupCheck(admin, "joined")(context.Background()); err != nil {
+		t.Errorf("expected the joined group to be ready, got %v", err)
+	}
+	for _, id := range []string{"rebalancing", "empty", "missing"} {
+		if err := GroupCheck(admin, id)(context.Background()); err == nil {
+			t.Errorf("expected group %s not to be ready", id)
+		}
+	}
+}
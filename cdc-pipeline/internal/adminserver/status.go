@@ -0,0 +1,178 @@
+package adminserver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrUnknownTopic is returned when pausing or resuming a topic the command doesn't consume
+var ErrUnknownTopic = errors.New("unknown topic")
+
+// WorkerStatus is the state of the worker(s) of a topic partition
+type WorkerStatus struct {
+	Topic       string    `json:"topic"`
+	Partition   int       `json:"partition"`
+	LastOffset  int64     `json:"last_offset"` // of the last processed message (-1 if none)
+	LastError   string    `json:"last_error,omitempty"`
+	Retrying    bool      `json:"retrying"` // the message at RetryOffset failed and is retried
+	RetryOffset int64     `json:"retry_offset,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"` // failed attempts of the retried (or given up) message
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Status tracks the workers of a command and pauses the topics it consumes. Workers report the messages they
+// processed and failed, the fetch loops of the topics wait while they're paused.
+type Status struct {
+	mu      sync.Mutex
+	workers map[kafkautils.TopicPartition]*WorkerStatus
+	paused  map[string]chan struct{} // of the consumed topics, closed while the topic isn't paused
+	now     func() time.Time
+}
+
+// NewStatus returns the Status of a command consuming the topics (which can be paused)
+func NewStatus(topics ...string) *Status {
+	s := &Status{workers: make(map[kafkautils.TopicPartition]*WorkerStatus), paused: make(map[string]chan struct{}), now: time.Now}
+	for _, t := range topics {
+		s.paused[t] = closedChan()
+	}
+	return s
+}
+
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+// workerLocked returns the status of the worker of a partition, adding it if needed (s.mu must be held)
+func (s *Status) workerLocked(topic string, partition int) *WorkerStatus {
+	tp := kafkautils.TopicPartition{Topic: topic, Partition: partition}
+	w, ok := s.workers[tp]
+	if !ok {
+		w = &WorkerStatus{Topic: topic, Partition: partition, LastOffset: -1}
+		s.workers[tp] = w
+	}
+	w.UpdatedAt = s.now()
+	return w
+}
+
+// Processed records a processed message of a worker (ending the retries of the message)
+func (s *Status) Processed(msg kafka.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.workerLocked(msg.Topic, msg.Partition)
+	w.LastOffset = max(w.LastOffset, msg.Offset)
+	if w.Retrying && w.RetryOffset <= msg.Offset {
+		w.Retrying, w.RetryOffset, w.Attempts = false, 0, 0
+	}
+}
+
+// Failed records a failed attempt of processing a message, which is retried unless the worker gave up
+func (s *Status) Failed(msg kafka.Message, err error, attempt int, retrying bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.workerLocked(msg.Topic, msg.Partition)
+	w.LastError = fmt.Sprintf("offset %d: %v", msg.Offset, err)
+	w.Retrying, w.RetryOffset, w.Attempts = retrying, msg.Offset, attempt
+}
+
+// Removed removes the worker of a partition (e.g. after the partition was revoked)
+func (s *Status) Removed(topic string, partition int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.workers, kafkautils.TopicPartition{Topic: topic, Partition: partition})
+}
+
+// Workers returns the status of the workers, ordered by topic and partition
+func (s *Status) Workers() []WorkerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	workers := make([]WorkerStatus, 0, len(s.workers))
+	for _, w := range s.workers {
+		workers = append(workers, *w)
+	}
+	slices.SortFunc(workers, func(a, b WorkerStatus) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	return workers
+}
+
+// Topics returns the consumed topics and whether they're paused
+func (s *Status) Topics() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topics := make(map[string]bool, len(s.paused))
+	for t, resumed := range s.paused {
+		topics[t] = !isClosed(resumed)
+	}
+	return topics
+}
+
+// Pause pauses fetching the messages of a topic (the messages already fetched are still processed)
+func (s *Status) Pause(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resumed, ok := s.paused[topic]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+	if isClosed(resumed) {
+		s.paused[topic] = make(chan struct{})
+	}
+	return nil
+}
+
+// Resume resumes fetching the messages of a paused topic
+func (s *Status) Resume(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resumed, ok := s.paused[topic]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+	if !isClosed(resumed) {
+		close(resumed)
+	}
+	return nil
+}
+
+// Paused reports whether a topic is paused
+func (s *Status) Paused(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resumed, ok := s.paused[topic]
+	return ok && !isClosed(resumed)
+}
+
+// WaitUntilResumed returns once a topic isn't paused (immediately if it isn't), or the context's error when it's done
+func (s *Status) WaitUntilResumed(ctx context.Context, topic string) error {
+	s.mu.Lock()
+	resumed, ok := s.paused[topic]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package adminserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestStatus_Workers(t *testing.T) {
+	s := NewStatus("users", "orders")
+	s.Processed(kafka.Message{Topic: "users", Partition: 1, Offset: 4})
+	s.Processed(kafka.Message{Topic: "orders", Partition: 0, Offset: 7})
+	s.Failed(kafka.Message{Topic: "users", Partition: 0, Offset: 2}, errors.New("db down"), 1, true)
+	s.Failed(kafka.Message{Topic: "users", Partition: 0, Offset: 2}, errors.New("db down"), 2, true)
+
+	var got []string
+	for _, w := range s.Workers() {
+		got = append(got, fmt.Sprintf("%s/%d last=%d retrying=%t attempts=%d error=%q", w.Topic, w.Partition, w.LastOffset, w.Retrying, w.Attempts, w.LastError))
+	}
+	want := `[orders/0 last=7 retrying=false attempts=0 error="" users/0 last=-1 retrying=true attempts=2 error="offset 2: db down" users/1 last=4 retrying=false attempts=0 error=""]`
+	if fmt.Sprint(got) != want {
+		t.Errorf("unexpected workers:\n%v\nwant\n%v", got, want)
+	}
+
+	// processing the retried message ends its retries (the last error is kept)
+	s.Processed(kafka.Message{Topic: "users", Partition: 0, Offset: 2})
+	if w := s.Workers()[1]; w.Retrying || w.Attempts != 0 || w.LastOffset != 2 || w.LastError == "" {
+		t.Errorf("unexpected worker after the retry: %+v", w)
+	}
+
+	s.Removed("users", 0)
+	if n := len(s.Workers()); n != 2 {
+		t.Errorf("expected 2 workers after removing one, got %d", n)
+	}
+}
+
+func TestStatus_PauseAndResume(t *testing.T) {
+	s := NewStatus("users")
+	if err := s.Pause("orders"); !errors.Is(err, ErrUnknownTopic) {
+		t.Errorf("expected ErrUnknownTopic, got %v", err)
+	}
+	if err := s.WaitUntilResumed(context.Background(), "users"); err != nil {
+		t.Fatalf("expected no wait for a topic that isn't paused, got %v", err)
+	}
+
+	if err := s.Pause("users"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	s.Pause("users") // pausing twice doesn't change anything
+	if !s.Topics()["users"] {
+		t.Errorf("expected the topic to be paused")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.WaitUntilResumed(ctx, "users"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait of a paused topic to time out, got %v", err)
+	}
+
+	resumed := make(chan error)
+	go func() { resumed <- s.WaitUntilResumed(context.Background(), "users") }()
+	if err := s.Resume("users"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	s.Resume("users") // resuming twice doesn't change anything
+	select {
+	case err := <-resumed:
+		if err != nil {
+			t.Errorf("unexpected wait error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wait didn't return after resuming")
+	}
+	if s.Topics()["users"] {
+		t.Errorf("expected the topic to be resumed")
+	}
+}
@@ -0,0 +1,163 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// Table is a Postgres table to backfill
+type Table struct {
+	Name  string // e.g. "users"
+	Topic string // topic of the table's change events (selects the mapping of the rows)
+	Key   string // uuid primary key column the table is split into chunks by
+}
+
+// RowWriter writes a row of a table (implemented by *sink.CassandraClient)
+type RowWriter interface {
+	ApplySnapshotRow(topic string, row model.JsonMap) error
+}
+
+// KeyRange is a range of uuid keys from Start (inclusive) to End (exclusive, unbounded if empty)
+type KeyRange struct {
+	Start string
+	End   string
+}
+
+// KeyRanges splits the uuid key space into n ranges (by the first 32 bits)
+func KeyRanges(n int) []KeyRange {
+	if n < 1 {
+		n = 1
+	}
+	bound := func(i int) string {
+		return fmt.Sprintf("%08x-0000-0000-0000-000000000000", uint64(i)*(1<<32)/uint64(n))
+	}
+	ranges := make([]KeyRange, n)
+	for i := range ranges {
+		ranges[i] = KeyRange{Start: bound(i), End: bound(i + 1)}
+	}
+	ranges[n-1].End = ""
+	return ranges
+}
+
+// snapshotReader reads chunks of tables at a consistent snapshot (ReadChunk is called concurrently)
+type snapshotReader interface {
+	Snapshot() *Snapshot
+	ReadChunk(ctx context.Context, t Table, r KeyRange, fn func(model.JsonMap) error) error
+	Close()
+}
+
+// Config configures a Backfiller
+type Config struct {
+	Tables  []Table
+	Chunks  int // key ranges each table is split into
+	Workers int // chunks read (and written) in parallel
+}
+
+// Backfiller copies Postgres tables to a RowWriter in parallel chunks, all read at the same snapshot
+type Backfiller struct {
+	cfg    Config
+	writer RowWriter
+	open   func(ctx context.Context, workers int) (snapshotReader, error)
+}
+
+// NewBackfiller returns a Backfiller reading from the Postgres database of the given connection string
+func NewBackfiller(connString string, writer RowWriter, cfg Config) *Backfiller {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	return &Backfiller{
+		cfg:    cfg,
+		writer: writer,
+		open: func(ctx context.Context, workers int) (snapshotReader, error) {
+			return openPostgresSnapshot(ctx, connString, workers)
+		},
+	}
+}
+
+type chunk struct {
+	table Table
+	keys  KeyRange
+}
+
+// Run backfills the tables and returns the snapshot they were read at (with the number of written rows)
+func (b *Backfiller) Run(ctx context.Context) (*Snapshot, error) {
+	reader, err := b.open(ctx, b.cfg.Workers)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	snap := reader.Snapshot()
+	snap.Rows = make(map[string]int)
+	logger.InfoLogger.Printf("backfill: snapshot taken at lsn %s (xmin=%d, xmax=%d, %d in progress)\n", snap.LSN, snap.Xmin, snap.Xmax, len(snap.Xip))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		for _, t := range b.cfg.Tables {
+			for _, r := range KeyRanges(b.cfg.Chunks) {
+				select {
+				case chunks <- chunk{t, r}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var firstErr error
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < b.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				n, err := b.copyChunk(ctx, reader, c)
+				mu.Lock()
+				snap.Rows[c.table.Name] += n
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel() // stop the other workers
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	snap.CompletedAt = time.Now().UTC()
+	logger.InfoLogger.Printf("backfill: copied %v rows in %v\n", snap.Rows, time.Since(start).Round(time.Millisecond))
+	return snap, nil
+}
+
+func (b *Backfiller) copyChunk(ctx context.Context, reader snapshotReader, c chunk) (int, error) {
+	n := 0
+	err := reader.ReadChunk(ctx, c.table, c.keys, func(row model.JsonMap) error {
+		if err := b.writer.ApplySnapshotRow(c.table.Topic, row); err != nil {
+			return fmt.Errorf("write %s row %v: %w", c.table.Name, row[c.table.Key], err)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("backfill %s [%s, %s): %w", c.table.Name, c.keys.Start, c.keys.End, err)
+	}
+	logger.DebugLogger.Printf("backfill: copied %d %s rows [%s, %s)\n", n, c.table.Name, c.keys.Start, c.keys.End)
+	return n, nil
+}
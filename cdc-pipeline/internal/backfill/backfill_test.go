@@ -0,0 +1,166 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// fakeSnapshot returns the rows of a table whose key is in the requested range
+type fakeSnapshot struct {
+	rows   map[string][]model.JsonMap
+	failOn string // key of a row whose chunk fails
+	closed bool
+}
+
+func (f *fakeSnapshot) Snapshot() *Snapshot {
+	return &Snapshot{LSN: "0/1A2B3C8", Xmin: 780, Xmax: 790, Xip: []uint64{785}}
+}
+
+func (f *fakeSnapshot) ReadChunk(ctx context.Context, t Table, r KeyRange, fn func(model.JsonMap) error) error {
+	for _, row := range f.rows[t.Name] {
+		key := row[t.Key].(string)
+		if key < r.Start || (r.End != "" && key >= r.End) {
+			continue
+		}
+		if key == f.failOn {
+			return errors.New("connection reset")
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSnapshot) Close() { f.closed = true }
+
+// recordingWriter records the written rows by topic
+type recordingWriter struct {
+	mu   sync.Mutex
+	rows map[string][]model.JsonMap
+}
+
+func (w *recordingWriter) ApplySnapshotRow(topic string, row model.JsonMap) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.rows == nil {
+		w.rows = make(map[string][]model.JsonMap)
+	}
+	w.rows[topic] = append(w.rows[topic], row)
+	return nil
+}
+
+var testTables = []Table{
+	{Name: "users", Topic: "cdc.public.users", Key: "id"},
+	{Name: "orders", Topic: "cdc.public.orders", Key: "id"},
+}
+
+func newTestBackfiller(snap *fakeSnapshot, w RowWriter) *Backfiller {
+	b := NewBackfiller("", w, Config{Tables: testTables, Chunks: 8, Workers: 3})
+	b.open = func(ctx context.Context, workers int) (snapshotReader, error) { return snap, nil }
+	return b
+}
+
+func testRows() map[string][]model.JsonMap {
+	return map[string][]model.JsonMap{
+		"users": {
+			{"id": "0859fb00-8eeb-44cd-97f5-df0db4f7a2c3", "name": "Alice"},
+			{"id": "da0859fb-8eeb-44cd-97f5-df0db4f7a2c3", "name": "Bob"},
+			{"id": "ffffffff-ffff-ffff-ffff-ffffffffffff", "name": "Carol"},
+		},
+		"orders": {
+			{"id": "00000000-0000-0000-0000-000000000000", "status": "PLACED"},
+			{"id": "7fffffff-fea3-46b4-9536-89a3b1cba1f8", "status": "SHIPPED"},
+		},
+	}
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	snap, w := &fakeSnapshot{rows: testRows()}, &recordingWriter{}
+
+	s, err := newTestBackfiller(snap, w).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(w.rows["cdc.public.users"]) != 3 || len(w.rows["cdc.public.orders"]) != 2 {
+		t.Errorf("expected every row to be written once, got %v", w.rows)
+	}
+	if !reflect.DeepEqual(s.Rows, map[string]int{"users": 3, "orders": 2}) || s.LSN != "0/1A2B3C8" || s.CompletedAt.IsZero() {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+	if !snap.closed {
+		t.Errorf("expected the snapshot to be closed")
+	}
+}
+
+func TestBackfiller_RunFailure(t *testing.T) {
+	snap := &fakeSnapshot{rows: testRows(), failOn: "da0859fb-8eeb-44cd-97f5-df0db4f7a2c3"}
+
+	if _, err := newTestBackfiller(snap, &recordingWriter{}).Run(context.Background()); err == nil {
+		t.Fatalf("expected an error when a chunk fails")
+	}
+	if !snap.closed {
+		t.Errorf("expected the snapshot to be closed")
+	}
+}
+
+func TestKeyRanges(t *testing.T) {
+	ranges := KeyRanges(4)
+	want := []KeyRange{
+		{"00000000-0000-0000-0000-000000000000", "40000000-0000-0000-0000-000000000000"},
+		{"40000000-0000-0000-0000-000000000000", "80000000-0000-0000-0000-000000000000"},
+		{"80000000-0000-0000-0000-000000000000", "c0000000-0000-0000-0000-000000000000"},
+		{"c0000000-0000-0000-0000-000000000000", ""},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("unexpected key ranges: %v", ranges)
+	}
+}
+
+func TestSnapshot_Contains(t *testing.T) {
+	s := &Snapshot{LSN: "0/1000", Xmin: 1<<32 + 100, Xmax: 1<<32 + 110, Xip: []uint64{1<<32 + 105}}
+
+	tests := []struct {
+		txID int64
+		lsn  int64
+		want bool
+	}{
+		{99, 0, true},         // committed before the snapshot
+		{104, 0, true},        // committed before the snapshot (between xmin and xmax)
+		{105, 0, false},       // in progress
+		{110, 0, false},       // started after the snapshot
+		{4000000000, 0, true}, // before the wraparound of the 32 bit ids, i.e. before the snapshot
+		{0, 0x0fff, true},     // no transaction id, before the snapshot's LSN
+		{0, 0x1000, false},
+	}
+	for _, tt := range tests {
+		if got := s.Contains(&model.ChangeEvent{TxID: tt.txID, LSN: tt.lsn}); got != tt.want {
+			t.Errorf("Contains(txId=%d, lsn=%d) = %t, want %t", tt.txID, tt.lsn, got, tt.want)
+		}
+	}
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	s := &Snapshot{LSN: "0/1A2B3C8", Xmin: 780, Xmax: 790, Xip: []uint64{785}, Rows: map[string]int{"users": 3}}
+
+	if err := SaveSnapshot(path, s); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("expected %+v, got %+v", s, loaded)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Errorf("expected no temporary files, got %v", matches)
+	}
+}
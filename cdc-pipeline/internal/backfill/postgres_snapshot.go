@@ -0,0 +1,136 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/source"
+	"github.com/jackc/pgx/v5"
+)
+
+// postgresSnapshot exports the snapshot of a (held open) transaction, which the workers' transactions import
+type postgresSnapshot struct {
+	coordinator *pgx.Conn
+	conns       chan *pgx.Conn // connections of the workers
+	snapshotID  string
+	snapshot    Snapshot
+}
+
+func openPostgresSnapshot(ctx context.Context, connString string, workers int) (_ *postgresSnapshot, err error) {
+	coordinator, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	s := &postgresSnapshot{coordinator: coordinator, conns: make(chan *pgx.Conn, workers)}
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+
+	if _, err := coordinator.Exec(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		return nil, err
+	}
+	var xmin, xmax string
+	var xip []string
+	err = coordinator.QueryRow(ctx, `SELECT pg_export_snapshot(), pg_current_wal_lsn()::text,
+		pg_snapshot_xmin(pg_current_snapshot())::text, pg_snapshot_xmax(pg_current_snapshot())::text,
+		ARRAY(SELECT pg_snapshot_xip(pg_current_snapshot())::text)`).Scan(&s.snapshotID, &s.snapshot.LSN, &xmin, &xmax, &xip)
+	if err != nil {
+		return nil, fmt.Errorf("export snapshot: %w", err)
+	}
+	if s.snapshot.Xmin, err = strconv.ParseUint(xmin, 10, 64); err != nil {
+		return nil, err
+	}
+	if s.snapshot.Xmax, err = strconv.ParseUint(xmax, 10, 64); err != nil {
+		return nil, err
+	}
+	for _, x := range xip {
+		id, err := strconv.ParseUint(x, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		s.snapshot.Xip = append(s.snapshot.Xip, id)
+	}
+
+	for i := 0; i < workers; i++ {
+		conn, err := pgx.Connect(ctx, connString)
+		if err != nil {
+			return nil, fmt.Errorf("connect to postgres: %w", err)
+		}
+		s.conns <- conn
+	}
+	return s, nil
+}
+
+func (s *postgresSnapshot) Snapshot() *Snapshot {
+	snap := s.snapshot
+	return &snap
+}
+
+// ReadChunk reads the rows of a key range of a table (as Debezium would encode them) in a transaction importing the snapshot
+func (s *postgresSnapshot) ReadChunk(ctx context.Context, t Table, r KeyRange, fn func(model.JsonMap) error) error {
+	conn := <-s.conns
+	defer func() { s.conns <- conn }()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", s.snapshotID)); err != nil {
+		return fmt.Errorf("import snapshot: %w", err)
+	}
+
+	key := pgx.Identifier{t.Key}.Sanitize()
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s >= $1", pgx.Identifier{t.Name}.Sanitize(), key)
+	args := []any{r.Start}
+	if r.End != "" {
+		query += fmt.Sprintf(" AND %s < $2", key)
+		args = append(args, r.End)
+	}
+
+	// the simple protocol returns all values in their text representation
+	rows, err := tx.Query(ctx, query, append([]any{pgx.QueryExecModeSimpleProtocol}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	columns := make([]model.Column, len(fields))
+	for i, f := range fields {
+		columns[i] = source.ColumnSchema(f.Name, f.DataTypeOID, f.TypeModifier)
+	}
+
+	for rows.Next() {
+		row := make(model.JsonMap, len(columns))
+		for i, v := range rows.RawValues() {
+			if v == nil {
+				row[columns[i].Name] = nil
+				continue
+			}
+			if row[columns[i].Name], err = source.DecodeTextValue(&columns[i], string(v)); err != nil {
+				return fmt.Errorf("column '%s': %w", columns[i].Name, err)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *postgresSnapshot) Close() {
+	close(s.conns)
+	for conn := range s.conns {
+		conn.Close(context.Background())
+	}
+	s.coordinator.Close(context.Background()) // ends the exporting transaction
+}
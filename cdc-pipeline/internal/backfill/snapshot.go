@@ -0,0 +1,87 @@
+package backfill
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/jackc/pglogrepl"
+)
+
+// Snapshot is the Postgres snapshot a backfill read the tables at.
+// It's handed off to the CDC consumer, which skips the change events of transactions visible in the snapshot
+// (they are already backfilled) and applies all others (committed after the snapshot was taken).
+type Snapshot struct {
+	LSN         string         `json:"lsn"`  // WAL position when the snapshot was taken (e.g. "0/1A2B3C8")
+	Xmin        uint64         `json:"xmin"` // all transactions before xmin are visible
+	Xmax        uint64         `json:"xmax"` // no transaction from xmax on is visible
+	Xip         []uint64       `json:"xip"`  // transactions in progress when the snapshot was taken (not visible)
+	Rows        map[string]int `json:"rows"` // rows written per table
+	CompletedAt time.Time      `json:"completed_at"`
+}
+
+// Contains reports if a change event is contained in the snapshot, i.e. its transaction is visible in the snapshot.
+// Events without a transaction id are compared by LSN.
+func (s *Snapshot) Contains(ev *model.ChangeEvent) bool {
+	if ev.TxID == 0 {
+		lsn, err := pglogrepl.ParseLSN(s.LSN)
+		return err == nil && ev.LSN != 0 && ev.LSN < int64(lsn)
+	}
+
+	// Debezium sends 32 bit transaction ids (without epoch), so ids are compared modulo 2^32 (like Postgres does)
+	xid := uint32(ev.TxID)
+	if xidBefore(xid, uint32(s.Xmin)) {
+		return true
+	}
+	if !xidBefore(xid, uint32(s.Xmax)) {
+		return false
+	}
+	return !slices.ContainsFunc(s.Xip, func(x uint64) bool { return uint32(x) == xid })
+}
+
+// circular comparison of 32 bit transaction ids
+func xidBefore(a uint32, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+// SaveSnapshot writes a snapshot to a file (atomically, so the file is either complete or not there)
+func SaveSnapshot(path string, s *Snapshot) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot reads a snapshot from a file
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := pglogrepl.ParseLSN(s.LSN); err != nil {
+		return nil, fmt.Errorf("%s: invalid lsn: %w", path, err)
+	}
+	return &s, nil
+}
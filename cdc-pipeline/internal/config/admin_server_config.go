@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// admin HTTP servers of the long-running commands (health, readiness, worker status and topic pausing)
+const (
+	ConsumerAdminAddr    string        = ":9301"         // of the event consumer
+	CdcConsumerAdminAddr string        = ":9302"         // of the CDC consumer
+	PGSourceAdminAddr    string        = ":9303"         // of the Postgres source
+	AdminCheckTimeout    time.Duration = 3 * time.Second // of each readiness check
+)
@@ -0,0 +1,8 @@
+package config
+
+// direct backfill of Cassandra from Postgres
+const (
+	BackfillChunks       int    = 16 // key ranges each table is split into
+	BackfillWorkers      int    = 4  // chunks copied in parallel
+	BackfillSnapshotFile string = "cdc-backfill-snapshot.json"
+)
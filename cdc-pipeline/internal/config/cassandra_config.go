@@ -1,5 +1,36 @@
 package config
 
+import "time"
+
 var CassandraHosts = []string{"cassandra1:9042", "cassandra2:9042", "cassandra3:9042"}
 
 const CassandraKeyspace = "cdc_keyspace"
+
+// replication of the keyspace (when it's created by the migrate command)
+const CassandraReplication = "{'class': 'SimpleStrategy', 'replication_factor': '3'}"
+
+// Cassandra client settings of the sink (see sink.CassandraClientConfig)
+const (
+	CassandraWriteConsistency  string        = "QUORUM"
+	CassandraReadConsistency   string        = "QUORUM"
+	CassandraSerialConsistency string        = "SERIAL" // lightweight transactions (deduplication of change events)
+	CassandraLocalDC           string        = ""       // datacenter whose hosts are preferred (all hosts if empty)
+	CassandraConnectTimeout    time.Duration = 5 * time.Second
+	CassandraTimeout           time.Duration = 10 * time.Second
+	CassandraMaxRetries        int           = 3
+	CassandraRetryMinBackOff   time.Duration = 100 * time.Millisecond
+	CassandraRetryMaxBackOff   time.Duration = 2 * time.Second
+	CassandraSpeculativeTries  int           = 0 // extra attempts of slow idempotent queries on other hosts (0 = disabled)
+	CassandraSpeculativeDelay  time.Duration = 200 * time.Millisecond
+	CassandraCompression       bool          = true // snappy
+	CassandraMaxPreparedStmts  int           = 1000 // prepared statements cached per session
+
+	// environment variables with the credentials (not needed without authentication)
+	CassandraUsernameEnv string = "CDC_CASSANDRA_USERNAME"
+	CassandraPasswordEnv string = "CDC_CASSANDRA_PASSWORD"
+
+	// environment variables with the paths of the TLS CA certificate (TLS is enabled if set) and client certificate and key
+	CassandraTLSCAFileEnv   string = "CDC_CASSANDRA_TLS_CA"
+	CassandraTLSCertFileEnv string = "CDC_CASSANDRA_TLS_CERT"
+	CassandraTLSKeyFileEnv  string = "CDC_CASSANDRA_TLS_KEY"
+)
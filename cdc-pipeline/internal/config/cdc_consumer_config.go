@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// offsets of events applied to a buffered sink (e.g. file or parquet sink) are committed after the sink is flushed,
+// which happens when either of below limits is reached (or the consumer stops)
+const (
+	CdcFlushMaxEvents int           = 1000
+	CdcFlushInterval  time.Duration = 30 * time.Second
+)
+
+// a replay (-replay) logs its progress per partition at this interval,
+// and commits the offsets of applied events to its transient consumer group (cdc-<sink>-replay-<id>) at least this often
+const (
+	ReplayProgressInterval time.Duration = 10 * time.Second
+	ReplayCommitInterval   time.Duration = 5 * time.Second
+)
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// Kafka Connect (Debezium) REST API and connector management
+const (
+	ConnectURL          string        = "http://debezium:8083"
+	ConnectorConfigDir  string        = "connectors" // declarative connector configs (*.json)
+	ConnectTimeout      time.Duration = 10 * time.Second
+	ConnectPollInterval time.Duration = 2 * time.Second
+	ConnectWaitTimeout  time.Duration = 2 * time.Minute // max time to wait for a connector state
+)
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// file (JSON lines) sink settings
+const (
+	FileSinkDir          string        = "cdc-files"
+	FileSinkMaxFileBytes int64         = 64 << 20 // 64 MiB
+	FileSinkMaxFileAge   time.Duration = 5 * time.Minute
+	FileSinkGzip         bool          = false
+)
@@ -1,9 +1,19 @@
 package config
 
+import "time"
+
 var KafkaBrokers = []string{"kafka1:9092", "kafka2:9092", "kafka3:9092"}
 
 const KafkaReplicationFactor int = 3
 
+// configs of the pipeline topics, applied by kafkautils.EnsureTopics (zero values keep the broker defaults)
+const (
+	KafkaTopicRetentionMs       int64  = 7 * 24 * 60 * 60 * 1000 // 7 days
+	KafkaTopicCleanupPolicy     string = "delete"
+	KafkaTopicMinInsyncReplicas int    = 2
+	KafkaTopicCompressionType   string = "producer" // keep the compression of the producers
+)
+
 // topics and partitions
 const (
 	UsersTopic           string = "users"
@@ -17,6 +27,16 @@ const (
 	OrdersConsumerGroupId string = "go-consumer-group-orders"
 )
 
+// workers per partition of the consumer (messages are distributed to them by the hash of their key, so the messages
+// of a key are processed in order)
+const ConsumerWorkersPerPartition int = 1
+
+// consumer groups of the pipeline, inspected by the admin command (patterns of path.Match)
+var KafkaPipelineGroups = []string{"go-consumer-group-*", "cdc-*-sink"}
+
+// max duration of a command of the admin tool
+const KafkaAdminTimeout time.Duration = 30 * time.Second
+
 const TestMsgKey string = "test"
 
 // debezium topics
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// consumer lag monitor (lagwatch)
+const (
+	LagWatchListenAddr     string        = ":9308"          // address of the metrics and JSON endpoints
+	LagWatchInterval       time.Duration = 15 * time.Second // between lag computations
+	LagWatchMaxLag         int64         = 10000            // messages a group may be behind (over all its partitions)
+	LagWatchMaxGrowthRate  float64       = 50               // messages per second the lag of a group may grow
+	LagWatchSustainedFor   time.Duration = 2 * time.Minute  // a threshold must be crossed this long to fire an alert
+	LagWatchWebhookURL     string        = ""               // alerts are POSTed to it (only logged if empty)
+	LagWatchWebhookTimeout time.Duration = 5 * time.Second
+)
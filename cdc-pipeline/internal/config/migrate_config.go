@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+// schema migrations (see cmd/migrate)
+const (
+	MigrationsDir  string        = "../sql/migrations" // holds a postgres/ and a cassandra/ directory of migrations
+	MigrateLockTTL time.Duration = 15 * time.Minute    // the Cassandra lock of a crashed run expires after this time
+	MigrateTimeout time.Duration = 10 * time.Minute
+)
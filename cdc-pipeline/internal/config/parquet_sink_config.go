@@ -0,0 +1,6 @@
+package config
+
+// parquet (data lake) sink settings
+const (
+	ParquetSinkDir string = "cdc-lake"
+)
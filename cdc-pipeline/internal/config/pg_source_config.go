@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// native Postgres logical replication source (alternative to the Debezium connector)
+const (
+	PGSourceSlotName           string        = "cdc_go_slot" // separate from Debezium's `cdc_slot`
+	PGSourcePublication        string        = "cdc_pub"     // same publication as Debezium uses
+	PGSourceTopicPrefix        string        = "cdc"
+	PGSourceStandbyTimeout     time.Duration = 10 * time.Second
+	PGSourceMaxPendingMessages int           = 1000
+	PGSourceTombstones         bool          = true
+)
+
+// tables of the publication (created by the source if it doesn't exist)
+var PGSourceTables = []string{"public.users", "public.orders"}
@@ -0,0 +1,48 @@
+package config
+
+// replica (reporting) database the postgres replica sink writes to
+const (
+	PGReplicaAddr     string = "postgres-replica:5432"
+	PGReplicaUser     string = "postgres"
+	PGReplicaPassword string = "postgres"
+	PGReplicaDBName   string = "cdc_replica_db"
+)
+
+// ReplicaTable maps a source table to a table of the replica database
+type ReplicaTable struct {
+	Source     string
+	Target     string
+	PrimaryKey []string    // source column names
+	Columns    [][2]string // pairs of source and target column names
+}
+
+var ReplicaTables = []ReplicaTable{
+	{
+		Source:     "users",
+		Target:     "users",
+		PrimaryKey: []string{"id"},
+		Columns: [][2]string{
+			{"id", "user_id"},
+			{"name", "full_name"},
+			{"dob", "date_of_birth"},
+			{"created_at", "created_at"},
+			{"modified_at", "updated_at"},
+			{"is_deleted", "is_deleted"},
+		},
+	},
+	{
+		Source:     "orders",
+		Target:     "orders",
+		PrimaryKey: []string{"id"},
+		Columns: [][2]string{
+			{"id", "order_id"},
+			{"user_id", "user_id"},
+			{"status", "order_status"},
+			{"quantity", "quantity"},
+			{"total_amount", "total_amount"},
+			{"placed_at", "placed_at"},
+			{"modified_at", "updated_at"},
+			{"is_deleted", "is_deleted"},
+		},
+	},
+}
@@ -0,0 +1,8 @@
+package config
+
+// Postgres vs Cassandra reconciliation
+const (
+	ReconcileTokenRanges int = 256  // token ranges each Cassandra table is scanned in
+	ReconcilePageSize    int = 1000 // rows per Cassandra page
+	ReconcileMaxDiffs    int = 100  // differences printed per table (all are counted and repaired)
+)
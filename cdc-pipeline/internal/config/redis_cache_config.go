@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// Redis cache sink settings
+const (
+	RedisAddr            string        = "redis:6379"
+	RedisPassword        string        = ""
+	RedisDB              int           = 0
+	RedisCacheTTL        time.Duration = 24 * time.Hour // expiry of the cached users and orders
+	RedisCacheDeletedTTL time.Duration = time.Hour      // expiry of soft deleted users and orders
+)
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// search index (OpenSearch/Elasticsearch) sink settings
+const (
+	SearchIndexURL             string        = "http://opensearch:9200"
+	SearchIndexPrefix          string        = "cdc-" // indices: cdc-users and cdc-orders
+	SearchIndexMaxBatchActions int           = 500
+	SearchIndexMaxBatchBytes   int           = 5 << 20 // 5 MiB
+	SearchIndexMaxBatchAge     time.Duration = 5 * time.Second
+	SearchIndexTimeout         time.Duration = 10 * time.Second
+
+	// environment variables with the basic auth credentials (not needed if the cluster's security is disabled)
+	SearchIndexUsernameEnv string = "CDC_SEARCH_USERNAME"
+	SearchIndexPasswordEnv string = "CDC_SEARCH_PASSWORD"
+)
+
+// primary key columns of the indexed tables (document ids)
+var SearchIndexPrimaryKeys = map[string][]string{
+	"users":  {"id"},
+	"orders": {"id"},
+}
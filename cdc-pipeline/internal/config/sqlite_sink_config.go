@@ -0,0 +1,6 @@
+package config
+
+// SQLite sink settings
+const (
+	SQLiteSinkPath string = "cdc-replica.db" // database file (":memory:" for an in-memory database)
+)
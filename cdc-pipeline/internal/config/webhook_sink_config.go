@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+// WebhookEndpoint is a webhook receiver of change events
+type WebhookEndpoint struct {
+	Name      string
+	URL       string
+	SecretEnv string   // name of the environment variable holding the signing secret
+	Tables    []string // only changes of these tables are delivered (all tables if empty)
+}
+
+var WebhookEndpoints = []WebhookEndpoint{
+	{
+		Name:      "user-service",
+		URL:       "http://user-service:8000/hooks/cdc",
+		SecretEnv: "CDC_WEBHOOK_USER_SERVICE_SECRET",
+		Tables:    []string{"users"},
+	},
+	{
+		Name:      "order-service",
+		URL:       "http://order-service:8000/hooks/cdc",
+		SecretEnv: "CDC_WEBHOOK_ORDER_SERVICE_SECRET",
+		Tables:    []string{"users", "orders"},
+	},
+}
+
+// webhook delivery settings
+const (
+	WebhookBatchSize        int           = 1 // a request per event
+	WebhookMaxAttempts      int           = 5
+	WebhookInitialBackOff   time.Duration = 500 * time.Millisecond
+	WebhookTimeout          time.Duration = 5 * time.Second
+	WebhookBreakerThreshold int           = 3
+	WebhookBreakerCooldown  time.Duration = 30 * time.Second
+)
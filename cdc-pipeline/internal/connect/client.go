@@ -0,0 +1,277 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// connector and task states reported by Kafka Connect
+const (
+	StateRunning    = "RUNNING"
+	StatePaused     = "PAUSED"
+	StateFailed     = "FAILED"
+	StateUnassigned = "UNASSIGNED"
+)
+
+// ErrNotFound is returned for connectors that don't exist
+var ErrNotFound = errors.New("connector not found")
+
+// APIError is an error response of the Connect REST API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("connect api error %d: %s", e.StatusCode, e.Message)
+}
+
+// ApplyResult is the outcome of CreateOrUpdate
+type ApplyResult string
+
+const (
+	Created   ApplyResult = "created"
+	Updated   ApplyResult = "updated"
+	Unchanged ApplyResult = "unchanged"
+)
+
+// ConnectorStatus is the status of a connector and its tasks
+type ConnectorStatus struct {
+	Name      string       `json:"name"`
+	Connector WorkerStatus `json:"connector"`
+	Tasks     []TaskStatus `json:"tasks"`
+	Type      string       `json:"type"`
+}
+
+// WorkerStatus is the state of a connector (or task) on a worker
+type WorkerStatus struct {
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+// TaskStatus is the status of a connector's task
+type TaskStatus struct {
+	ID int `json:"id"`
+	WorkerStatus
+}
+
+// Running reports if the connector and all its tasks (at least one) are running
+func (s *ConnectorStatus) Running() bool {
+	return s.inState(StateRunning)
+}
+
+func (s *ConnectorStatus) inState(state string) bool {
+	if s.Connector.State != state || len(s.Tasks) == 0 {
+		return false
+	}
+	for _, t := range s.Tasks {
+		if t.State != state {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedTasks returns the failed tasks of the connector
+func (s *ConnectorStatus) FailedTasks() []TaskStatus {
+	var failed []TaskStatus
+	for _, t := range s.Tasks {
+		if t.State == StateFailed {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Client is a client of the Kafka Connect REST API
+type Client struct {
+	baseURL      string
+	http         *http.Client
+	pollInterval time.Duration // interval of status requests while waiting for a state
+}
+
+// NewClient returns a new client of the Connect REST API at the given URL (e.g. "http://debezium:8083")
+func NewClient(baseURL string, timeout time.Duration, pollInterval time.Duration) *Client {
+	return &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		http:         &http.Client{Timeout: timeout},
+		pollInterval: pollInterval,
+	}
+}
+
+// List returns the names of all connectors
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	var names []string
+	err := c.do(ctx, http.MethodGet, "/connectors", nil, &names)
+	return names, err
+}
+
+// GetConfig returns the config of a connector (ErrNotFound if it doesn't exist)
+func (c *Client) GetConfig(ctx context.Context, name string) (map[string]string, error) {
+	var cfg map[string]string
+	err := c.do(ctx, http.MethodGet, connectorPath(name, "config"), nil, &cfg)
+	return cfg, err
+}
+
+// CreateOrUpdate creates the connector or updates its config if it differs from the given one
+func (c *Client) CreateOrUpdate(ctx context.Context, cc ConnectorConfig) (ApplyResult, error) {
+	current, err := c.GetConfig(ctx, cc.Name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	result := Updated
+	if errors.Is(err, ErrNotFound) {
+		result = Created
+	} else if reflect.DeepEqual(current, cc.withName()) {
+		return Unchanged, nil
+	}
+
+	// PUT creates the connector if it doesn't exist
+	if err := c.do(ctx, http.MethodPut, connectorPath(cc.Name, "config"), cc.withName(), nil); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// Status returns the status of a connector (ErrNotFound if it doesn't exist)
+func (c *Client) Status(ctx context.Context, name string) (*ConnectorStatus, error) {
+	var s ConnectorStatus
+	if err := c.do(ctx, http.MethodGet, connectorPath(name, "status"), nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Pause pauses a connector and its tasks
+func (c *Client) Pause(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPut, connectorPath(name, "pause"), nil, nil)
+}
+
+// Resume resumes a paused connector and its tasks
+func (c *Client) Resume(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPut, connectorPath(name, "resume"), nil, nil)
+}
+
+// Restart restarts a connector and its tasks (only the failed ones if onlyFailed is set)
+func (c *Client) Restart(ctx context.Context, name string, onlyFailed bool) error {
+	path := connectorPath(name, "restart") + fmt.Sprintf("?includeTasks=true&onlyFailed=%t", onlyFailed)
+	return c.do(ctx, http.MethodPost, path, nil, nil)
+}
+
+// RestartTask restarts a single task of a connector
+func (c *Client) RestartTask(ctx context.Context, name string, task int) error {
+	return c.do(ctx, http.MethodPost, connectorPath(name, fmt.Sprintf("tasks/%d/restart", task)), nil, nil)
+}
+
+// Delete deletes a connector (ErrNotFound if it doesn't exist)
+func (c *Client) Delete(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, connectorPath(name, ""), nil, nil)
+}
+
+// WaitForState polls the status of a connector until it and all its tasks are in the given state.
+// Waiting for RUNNING fails as soon as the connector or one of its tasks failed.
+func (c *Client) WaitForState(ctx context.Context, name string, state string) (*ConnectorStatus, error) {
+	for {
+		s, err := c.Status(ctx, name)
+		// a new connector has no status until a worker started it
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if s != nil {
+			if s.inState(state) {
+				return s, nil
+			}
+			if state == StateRunning {
+				if s.Connector.State == StateFailed {
+					return s, fmt.Errorf("connector %s failed: %s", name, firstLine(s.Connector.Trace))
+				}
+				if failed := s.FailedTasks(); len(failed) > 0 {
+					return s, fmt.Errorf("task %d of connector %s failed: %s", failed[0].ID, name, firstLine(failed[0].Trace))
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return s, fmt.Errorf("waiting for connector %s to be %s: %w", name, state, ctx.Err())
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, path, ErrNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &e) == nil && e.Message != "" {
+			apiErr.Message = e.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(respBody))
+		}
+		return apiErr
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+func connectorPath(name string, sub string) string {
+	p := "/connectors/" + url.PathEscape(name)
+	if sub != "" {
+		p += "/" + sub
+	}
+	return p
+}
+
+// first line of a (Java) stack trace
+func firstLine(trace string) string {
+	line, _, _ := strings.Cut(trace, "\n")
+	return line
+}
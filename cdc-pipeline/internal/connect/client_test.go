@@ -0,0 +1,298 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConnect is a httptest stand-in for the Kafka Connect REST API
+type fakeConnect struct {
+	mu         sync.Mutex
+	connectors map[string]*fakeConnector
+	requests   []string // "<method> <path>" of all requests
+}
+
+type fakeConnector struct {
+	config      map[string]string
+	state       string
+	taskStates  []string
+	startPolls  int // status requests until a new (or restarted) connector is running
+	failOnStart bool
+}
+
+func newFakeConnect() *fakeConnect {
+	return &fakeConnect{connectors: make(map[string]*fakeConnector)}
+}
+
+func (f *fakeConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/") // connectors/<name>/<sub>...
+	if len(parts) == 1 {
+		names := []string{}
+		for n := range f.connectors {
+			names = append(names, n)
+		}
+		json.NewEncoder(w).Encode(names)
+		return
+	}
+
+	name, sub := parts[1], strings.Join(parts[2:], "/")
+	c, exists := f.connectors[name]
+	if !exists && !(r.Method == http.MethodPut && sub == "config") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error_code": 404, "message": "Connector " + name + " not found"})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut && sub == "config":
+		var cfg map[string]string
+		json.NewDecoder(r.Body).Decode(&cfg)
+		if !exists {
+			c = &fakeConnector{state: StateUnassigned, taskStates: []string{StateUnassigned}, startPolls: 2}
+			f.connectors[name] = c
+			w.WriteHeader(http.StatusCreated)
+		}
+		c.config = cfg
+		json.NewEncoder(w).Encode(map[string]any{"name": name, "config": cfg})
+	case r.Method == http.MethodGet && sub == "config":
+		json.NewEncoder(w).Encode(c.config)
+	case r.Method == http.MethodGet && sub == "status":
+		if c.state == StateUnassigned || (c.state == StateRunning && c.startPolls > 0) {
+			c.startPolls--
+			if c.startPolls <= 0 {
+				c.setState(StateRunning)
+				if c.failOnStart {
+					c.taskStates[0] = StateFailed
+				}
+			}
+		}
+		s := ConnectorStatus{Name: name, Connector: WorkerStatus{State: c.state, WorkerID: "debezium:8083"}, Type: "source"}
+		for i, ts := range c.taskStates {
+			t := TaskStatus{ID: i, WorkerStatus: WorkerStatus{State: ts, WorkerID: "debezium:8083"}}
+			if ts == StateFailed {
+				t.Trace = "org.apache.kafka.connect.errors.ConnectException: slot in use\n\tat ..."
+			}
+			s.Tasks = append(s.Tasks, t)
+		}
+		json.NewEncoder(w).Encode(s)
+	case r.Method == http.MethodPut && sub == "pause":
+		c.setState(StatePaused)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && sub == "resume":
+		c.setState(StateRunning)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && sub == "restart":
+		onlyFailed := r.URL.Query().Get("onlyFailed") == "true"
+		for i, ts := range c.taskStates {
+			if !onlyFailed || ts == StateFailed {
+				c.taskStates[i] = StateRunning
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && strings.HasPrefix(sub, "tasks/"):
+		id, _ := strconv.Atoi(parts[3])
+		c.taskStates[id] = StateRunning
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && sub == "":
+		delete(f.connectors, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]any{"error_code": 405, "message": "HTTP 405 Method Not Allowed"})
+	}
+}
+
+func (c *fakeConnector) setState(state string) {
+	c.state = state
+	for i := range c.taskStates {
+		c.taskStates[i] = state
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeConnect) {
+	t.Helper()
+	f := newFakeConnect()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL, 5*time.Second, time.Millisecond), f
+}
+
+var testConnector = ConnectorConfig{
+	Name: "cdc-connector",
+	Config: map[string]string{
+		"connector.class": "io.debezium.connector.postgresql.PostgresConnector",
+		"topic.prefix":    "cdc",
+	},
+}
+
+func TestClient_CreateOrUpdate(t *testing.T) {
+	c, f := newTestClient(t)
+	ctx := context.Background()
+
+	if res, err := c.CreateOrUpdate(ctx, testConnector); err != nil || res != Created {
+		t.Fatalf("expected the connector to be created, got %q, %v", res, err)
+	}
+	if res, err := c.CreateOrUpdate(ctx, testConnector); err != nil || res != Unchanged {
+		t.Fatalf("expected the connector to be unchanged, got %q, %v", res, err)
+	}
+
+	updated := ConnectorConfig{Name: testConnector.Name, Config: map[string]string{"connector.class": "x", "topic.prefix": "cdc2"}}
+	if res, err := c.CreateOrUpdate(ctx, updated); err != nil || res != Updated {
+		t.Fatalf("expected the connector to be updated, got %q, %v", res, err)
+	}
+	if cfg := f.connectors["cdc-connector"].config; cfg["topic.prefix"] != "cdc2" || cfg["name"] != "cdc-connector" {
+		t.Errorf("unexpected config: %v", cfg)
+	}
+
+	puts := 0
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, "PUT") {
+			puts++
+		}
+	}
+	if puts != 2 {
+		t.Errorf("expected 2 config updates (create and update), got %d", puts)
+	}
+}
+
+func TestClient_WaitForRunning(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.CreateOrUpdate(ctx, testConnector); err != nil {
+		t.Fatalf("CreateOrUpdate failed: %v", err)
+	}
+	s, err := c.WaitForState(ctx, testConnector.Name, StateRunning)
+	if err != nil {
+		t.Fatalf("WaitForState failed: %v", err)
+	}
+	if !s.Running() || s.Tasks[0].WorkerID != "debezium:8083" {
+		t.Errorf("unexpected status: %+v", s)
+	}
+}
+
+func TestClient_WaitForRunningFailedTask(t *testing.T) {
+	c, f := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.CreateOrUpdate(ctx, testConnector); err != nil {
+		t.Fatalf("CreateOrUpdate failed: %v", err)
+	}
+	f.connectors[testConnector.Name].failOnStart = true
+
+	_, err := c.WaitForState(ctx, testConnector.Name, StateRunning)
+	if err == nil || !strings.Contains(err.Error(), "task 0 of connector cdc-connector failed: org.apache.kafka.connect.errors.ConnectException: slot in use") {
+		t.Fatalf("expected the task's failure, got %v", err)
+	}
+
+	// restart only the failed task
+	if err := c.Restart(ctx, testConnector.Name, true); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	if _, err := c.WaitForState(ctx, testConnector.Name, StateRunning); err != nil {
+		t.Fatalf("expected the connector to be running after the restart, got %v", err)
+	}
+	if last := f.requests[len(f.requests)-2]; last != "POST /connectors/cdc-connector/restart" {
+		t.Errorf("unexpected restart request: %s", last)
+	}
+}
+
+func TestClient_WaitForStateTimeout(t *testing.T) {
+	c, f := newTestClient(t)
+	if _, err := c.CreateOrUpdate(context.Background(), testConnector); err != nil {
+		t.Fatalf("CreateOrUpdate failed: %v", err)
+	}
+	f.connectors[testConnector.Name].startPolls = 1 << 30 // never starts
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForState(ctx, testConnector.Name, StateRunning); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+}
+
+func TestClient_PauseResumeDelete(t *testing.T) {
+	c, f := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.CreateOrUpdate(ctx, testConnector); err != nil {
+		t.Fatalf("CreateOrUpdate failed: %v", err)
+	}
+	if err := c.Pause(ctx, testConnector.Name); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if _, err := c.WaitForState(ctx, testConnector.Name, StatePaused); err != nil {
+		t.Fatalf("expected the connector to be paused, got %v", err)
+	}
+	if err := c.Resume(ctx, testConnector.Name); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if _, err := c.WaitForState(ctx, testConnector.Name, StateRunning); err != nil {
+		t.Fatalf("expected the connector to be running, got %v", err)
+	}
+
+	if err := c.Delete(ctx, testConnector.Name); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(f.connectors) != 0 {
+		t.Errorf("expected the connector to be deleted")
+	}
+	if err := c.Delete(ctx, testConnector.Name); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted connector, got %v", err)
+	}
+	if _, err := c.Status(ctx, testConnector.Name); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the status of a deleted connector, got %v", err)
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	c, _ := newTestClient(t)
+	if _, err := c.CreateOrUpdate(context.Background(), testConnector); err != nil {
+		t.Fatalf("CreateOrUpdate failed: %v", err)
+	}
+
+	err := c.do(context.Background(), http.MethodPatch, connectorPath(testConnector.Name, "config"), nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusMethodNotAllowed || apiErr.Message != "HTTP 405 Method Not Allowed" {
+		t.Errorf("expected an API error, got %v", err)
+	}
+}
+
+func TestLoadConnectorConfigs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEST_PG_PASSWORD", "s3cr3t")
+
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"name": "b", "config": {"database.password": "${TEST_PG_PASSWORD}", "transforms.route.replacement": "$1"}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"name": "a", "config": {"topic.prefix": "cdc"}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(`not a connector`), 0o644)
+
+	configs, err := LoadConnectorConfigs(dir)
+	if err != nil {
+		t.Fatalf("LoadConnectorConfigs failed: %v", err)
+	}
+	if len(configs) != 2 || configs[0].Name != "a" || configs[1].Name != "b" {
+		t.Fatalf("unexpected configs: %+v", configs)
+	}
+	if configs[1].Config["database.password"] != "s3cr3t" || configs[1].Config["transforms.route.replacement"] != "$1" {
+		t.Errorf("unexpected expanded config: %v", configs[1].Config)
+	}
+
+	// the same connector must not be defined twice
+	if _, err := LoadConnectorConfigs(dir, filepath.Join(dir, "a.json")); err == nil {
+		t.Errorf("expected an error for a duplicate connector")
+	}
+}
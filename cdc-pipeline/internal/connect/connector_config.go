@@ -0,0 +1,79 @@
+package connect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// `${VAR}` references (other `$` are kept, e.g. in regex replacements of transforms)
+var envRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ConnectorConfig is the declarative config of a connector (same format as the body of `POST /connectors`)
+type ConnectorConfig struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+// config as returned by Connect (which adds the connector's name)
+func (cc ConnectorConfig) withName() map[string]string {
+	cfg := make(map[string]string, len(cc.Config)+1)
+	for k, v := range cc.Config {
+		cfg[k] = v
+	}
+	cfg["name"] = cc.Name
+	return cfg
+}
+
+// LoadConnectorConfigs reads connector configs from JSON files and directories (all `*.json` files in them).
+// `${VAR}` references in the files are replaced with the values of environment variables (e.g. for passwords).
+func LoadConnectorConfigs(paths ...string) ([]ConnectorConfig, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	var configs []ConnectorConfig
+	names := make(map[string]string) // connector name -> file
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var cc ConnectorConfig
+		if err := json.Unmarshal(expandEnv(b), &cc); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		if cc.Name == "" || len(cc.Config) == 0 {
+			return nil, fmt.Errorf("%s: name and config are required", f)
+		}
+		if prev, ok := names[cc.Name]; ok {
+			return nil, fmt.Errorf("%s: connector %s is already defined in %s", f, cc.Name, prev)
+		}
+		names[cc.Name] = f
+		configs = append(configs, cc)
+	}
+	return configs, nil
+}
+
+func expandEnv(b []byte) []byte {
+	return envRefRegex.ReplaceAllFunc(b, func(ref []byte) []byte {
+		return []byte(os.Getenv(string(ref[2 : len(ref)-1])))
+	})
+}
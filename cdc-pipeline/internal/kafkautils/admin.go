@@ -0,0 +1,424 @@
+package kafkautils
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Admin is the Kafka admin API used to inspect topics and consumer groups and to reset group offsets
+// (implemented by *kafka.Client)
+type Admin interface {
+	TopicAdmin
+	ListGroups(ctx context.Context, req *kafka.ListGroupsRequest) (*kafka.ListGroupsResponse, error)
+	DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error)
+	OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error)
+	ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+var _ Admin = (*kafka.Client)(nil)
+
+// TopicInfo describes a topic and its partitions
+type TopicInfo struct {
+	Name       string
+	Partitions []PartitionInfo
+}
+
+// PartitionInfo describes the replicas of a partition (by broker id)
+type PartitionInfo struct {
+	ID       int
+	Leader   int
+	Replicas []int
+	Isr      []int
+}
+
+// UnderReplicated reports whether replicas of the partition are out of sync
+func (p PartitionInfo) UnderReplicated() bool {
+	return len(p.Isr) < len(p.Replicas)
+}
+
+// DescribeTopics describes the topics (all non internal topics if none are given), ordered by name
+func DescribeTopics(ctx context.Context, admin Admin, topics ...string) ([]TopicInfo, error) {
+	meta, err := admin.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of the Kafka cluster: %w", err)
+	}
+
+	var infos []TopicInfo
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("topic '%s': %w", t.Name, t.Error)
+		}
+		if t.Internal && len(topics) == 0 {
+			continue
+		}
+		info := TopicInfo{Name: t.Name}
+		for _, p := range t.Partitions {
+			info.Partitions = append(info.Partitions, PartitionInfo{ID: p.ID, Leader: p.Leader.ID, Replicas: brokerIDs(p.Replicas), Isr: brokerIDs(p.Isr)})
+		}
+		slices.SortFunc(info.Partitions, func(a, b PartitionInfo) int { return cmp.Compare(a.ID, b.ID) })
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b TopicInfo) int { return cmp.Compare(a.Name, b.Name) })
+	return infos, nil
+}
+
+func brokerIDs(brokers []kafka.Broker) []int {
+	ids := make([]int, len(brokers))
+	for i, b := range brokers {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// GroupInfo describes a consumer group and its members
+type GroupInfo struct {
+	ID      string
+	State   string // e.g. "Stable", "Empty", "PreparingRebalance" or "Dead"
+	Members []GroupMember
+}
+
+// GroupMember is a member of a consumer group with its assigned partitions
+type GroupMember struct {
+	ID         string
+	ClientID   string
+	ClientHost string
+	Assigned   []TopicPartition
+}
+
+// ListGroups returns the ids of the consumer groups matching any of the patterns (see path.Match), ordered by id
+func ListGroups(ctx context.Context, admin Admin, patterns ...string) ([]string, error) {
+	res, err := admin.ListGroups(ctx, &kafka.ListGroupsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("failed to list consumer groups: %w", res.Error)
+	}
+
+	var ids []string
+	for _, g := range res.Groups {
+		for _, pattern := range patterns {
+			if ok, err := path.Match(pattern, g.GroupID); err != nil {
+				return nil, fmt.Errorf("invalid group pattern '%s': %w", pattern, err)
+			} else if ok {
+				ids = append(ids, g.GroupID)
+				break
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// DescribeGroups describes consumer groups, in the given order
+func DescribeGroups(ctx context.Context, admin Admin, groupIDs ...string) ([]GroupInfo, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	res, err := admin.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: groupIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe consumer groups: %w", err)
+	}
+
+	byID := make(map[string]kafka.DescribeGroupsResponseGroup)
+	for _, g := range res.Groups {
+		byID[g.GroupID] = g
+	}
+	infos := make([]GroupInfo, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		g, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("consumer group '%s' not described", id)
+		}
+		if g.Error != nil {
+			return nil, fmt.Errorf("consumer group '%s': %w", id, g.Error)
+		}
+		info := GroupInfo{ID: id, State: g.GroupState}
+		for _, m := range g.Members {
+			member := GroupMember{ID: m.MemberID, ClientID: m.ClientID, ClientHost: m.ClientHost}
+			for _, t := range m.MemberAssignments.Topics {
+				for _, p := range t.Partitions {
+					member.Assigned = append(member.Assigned, TopicPartition{t.Topic, p})
+				}
+			}
+			slices.SortFunc(member.Assigned, compareTopicPartitions)
+			info.Members = append(info.Members, member)
+		}
+		slices.SortFunc(info.Members, func(a, b GroupMember) int { return cmp.Compare(a.ID, b.ID) })
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func compareTopicPartitions(a, b TopicPartition) int {
+	return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+}
+
+// PartitionLag is the lag of a consumer group on a partition
+type PartitionLag struct {
+	TopicPartition
+	Committed int64 // committed offset of the group (-1 if the group didn't commit an offset)
+	End       int64 // offset of the next message written to the partition
+	Lag       int64 // messages not consumed yet (from the first retained offset if nothing was committed)
+}
+
+// GroupLag returns the lag of a consumer group on every partition of the topics (the topics the group committed offsets
+// of if none are given), ordered by topic and partition
+func GroupLag(ctx context.Context, admin Admin, groupID string, topics ...string) ([]PartitionLag, error) {
+	committed, err := admin.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group '%s': %w", groupID, err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group '%s': %w", groupID, committed.Error)
+	}
+	offsets := make(map[TopicPartition]int64)
+	groupTopics := len(topics) == 0
+	for topic, ps := range committed.Topics {
+		if groupTopics {
+			topics = append(topics, topic)
+		}
+		for _, p := range ps {
+			if p.Error == nil && p.CommittedOffset >= 0 {
+				offsets[TopicPartition{topic, p.Partition}] = p.CommittedOffset
+			}
+		}
+	}
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	parts, err := TopicPartitions(ctx, admin, topics)
+	if err != nil {
+		return nil, err
+	}
+	first, err := ListOffsets(ctx, admin, parts, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := ListOffsets(ctx, admin, parts, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+
+	var lags []PartitionLag
+	for _, tp := range parts {
+		lag := PartitionLag{TopicPartition: tp, Committed: -1, End: last[tp]}
+		if off, ok := offsets[tp]; ok {
+			lag.Committed = off
+			lag.Lag = max(lag.End-off, 0)
+		} else {
+			lag.Lag = lag.End - first[tp]
+		}
+		lags = append(lags, lag)
+	}
+	return lags, nil
+}
+
+// TopicPartitions returns the partitions of the topics, ordered by topic and partition
+func TopicPartitions(ctx context.Context, admin Admin, topics []string) ([]TopicPartition, error) {
+	meta, err := admin.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of %v: %w", topics, err)
+	}
+	var parts []TopicPartition
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("topic '%s': %w", t.Name, t.Error)
+		}
+		for _, p := range t.Partitions {
+			parts = append(parts, TopicPartition{t.Name, p.ID})
+		}
+	}
+	slices.SortFunc(parts, compareTopicPartitions)
+	return parts, nil
+}
+
+// ListOffsets lists an offset of each partition (the first, last or the one of a time, as selected by the request),
+// -1 if there's no message at or after the time
+func ListOffsets(ctx context.Context, admin Admin, parts []TopicPartition, request func(partition int) kafka.OffsetRequest) (map[TopicPartition]int64, error) {
+	req := &kafka.ListOffsetsRequest{Topics: make(map[string][]kafka.OffsetRequest)}
+	for _, tp := range parts {
+		req.Topics[tp.Topic] = append(req.Topics[tp.Topic], request(tp.Partition))
+	}
+	res, err := admin.ListOffsets(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	offsets := make(map[TopicPartition]int64)
+	for topic, ps := range res.Topics {
+		for _, p := range ps {
+			tp := TopicPartition{topic, p.Partition}
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %v: %w", tp, p.Error)
+			}
+			switch {
+			case p.FirstOffset >= 0:
+				offsets[tp] = p.FirstOffset
+			case p.LastOffset >= 0:
+				offsets[tp] = p.LastOffset
+			default:
+				offsets[tp] = -1
+				for off := range p.Offsets {
+					offsets[tp] = off
+				}
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// ResetTarget selects the offsets a consumer group is reset to
+type ResetTarget struct {
+	Earliest bool          // the first retained offsets
+	Latest   bool          // the end of the partitions (skipping all messages)
+	Time     time.Time     // the first messages at or after the time (the end of partitions without such messages)
+	Offsets  map[int]int64 // explicit offsets by partition (other partitions keep their offsets)
+}
+
+func (t ResetTarget) validate() error {
+	n := 0
+	for _, set := range []bool{t.Earliest, t.Latest, !t.Time.IsZero(), t.Offsets != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of earliest, latest, a time or offsets must be selected")
+	}
+	return nil
+}
+
+// OffsetReset is the planned reset of a group's offset on a partition
+type OffsetReset struct {
+	TopicPartition
+	Current int64 // committed offset (-1 if none)
+	Target  int64
+}
+
+// ErrGroupActive is returned when resetting the offsets of a consumer group with members
+var ErrGroupActive = errors.New("consumer group has active members")
+
+// PlanOffsetReset plans the reset of a group's offsets on the partitions of a topic, without changing them. Targets
+// outside the retained offsets are clamped to the first or end offsets of the partitions.
+func PlanOffsetReset(ctx context.Context, admin Admin, groupID string, topic string, target ResetTarget) ([]OffsetReset, error) {
+	if err := target.validate(); err != nil {
+		return nil, err
+	}
+	parts, err := TopicPartitions(ctx, admin, []string{topic})
+	if err != nil {
+		return nil, err
+	}
+	for p := range target.Offsets {
+		if !slices.Contains(parts, TopicPartition{topic, p}) {
+			return nil, fmt.Errorf("unknown partition %v", TopicPartition{topic, p})
+		}
+	}
+
+	first, err := ListOffsets(ctx, admin, parts, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := ListOffsets(ctx, admin, parts, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	var atTime map[TopicPartition]int64
+	if !target.Time.IsZero() {
+		if atTime, err = ListOffsets(ctx, admin, parts, func(p int) kafka.OffsetRequest { return kafka.TimeOffsetOf(p, target.Time) }); err != nil {
+			return nil, err
+		}
+	}
+
+	committed, err := admin.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupID, Topics: map[string][]int{topic: partitionIDs(parts)}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group '%s': %w", groupID, err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group '%s': %w", groupID, committed.Error)
+	}
+	current := make(map[TopicPartition]int64)
+	for _, p := range committed.Topics[topic] {
+		if p.Error == nil {
+			current[TopicPartition{topic, p.Partition}] = p.CommittedOffset
+		}
+	}
+
+	var resets []OffsetReset
+	for _, tp := range parts {
+		reset := OffsetReset{TopicPartition: tp, Current: -1}
+		if off, ok := current[tp]; ok && off >= 0 {
+			reset.Current = off
+		}
+		switch {
+		case target.Earliest:
+			reset.Target = first[tp]
+		case target.Latest:
+			reset.Target = last[tp]
+		case atTime != nil:
+			reset.Target = atTime[tp]
+			if reset.Target < 0 {
+				reset.Target = last[tp]
+			}
+		default:
+			off, ok := target.Offsets[tp.Partition]
+			if !ok {
+				continue
+			}
+			reset.Target = min(max(off, first[tp]), last[tp])
+		}
+		resets = append(resets, reset)
+	}
+	return resets, nil
+}
+
+func partitionIDs(parts []TopicPartition) []int {
+	ids := make([]int, len(parts))
+	for i, tp := range parts {
+		ids[i] = tp.Partition
+	}
+	return ids
+}
+
+// ResetOffsets commits the planned offsets of a consumer group. The group must not have members, as they'd overwrite
+// the offsets with their next commits (ErrGroupActive is returned).
+func ResetOffsets(ctx context.Context, admin Admin, groupID string, resets []OffsetReset) error {
+	groups, err := DescribeGroups(ctx, admin, groupID)
+	if err != nil {
+		return err
+	}
+	if n := len(groups[0].Members); n > 0 {
+		return fmt.Errorf("%w: '%s' has %d members (stop its consumers first)", ErrGroupActive, groupID, n)
+	}
+
+	req := &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1, // the group has no members, offsets are committed like by a standalone consumer
+		Topics:       make(map[string][]kafka.OffsetCommit),
+	}
+	for _, r := range resets {
+		req.Topics[r.Topic] = append(req.Topics[r.Topic], kafka.OffsetCommit{Partition: r.Partition, Offset: r.Target})
+	}
+	res, err := admin.OffsetCommit(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets of group '%s': %w", groupID, err)
+	}
+	var errs []error
+	for topic, ps := range res.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				errs = append(errs, fmt.Errorf("failed to commit offset of %v: %w", TopicPartition{topic, p.Partition}, p.Error))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
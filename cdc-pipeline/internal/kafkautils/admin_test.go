@@ -0,0 +1,256 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeAdmin is an Admin of an in-memory cluster with consumer groups and partition offsets
+type fakeAdmin struct {
+	*fakeTopicAdmin
+	groups    map[string]kafka.DescribeGroupsResponseGroup
+	committed map[string]map[TopicPartition]int64 // committed offsets by group
+	first     map[TopicPartition]int64
+	last      map[TopicPartition]int64
+	atTime    map[TopicPartition]int64 // offsets of the first messages at or after any time (-1 if none)
+}
+
+func newFakeAdmin() *fakeAdmin {
+	return &fakeAdmin{
+		fakeTopicAdmin: newFakeTopicAdmin(),
+		groups:         make(map[string]kafka.DescribeGroupsResponseGroup),
+		committed:      make(map[string]map[TopicPartition]int64),
+		first:          make(map[TopicPartition]int64),
+		last:           make(map[TopicPartition]int64),
+		atTime:         make(map[TopicPartition]int64),
+	}
+}
+
+// add a topic whose partitions retain the offsets [first, last)
+func (a *fakeAdmin) addTopicWithOffsets(name string, first []int64, last []int64) {
+	a.addTopic(name, len(first), 3, nil)
+	for p := range first {
+		a.first[TopicPartition{name, p}] = first[p]
+		a.last[TopicPartition{name, p}] = last[p]
+	}
+}
+
+func (a *fakeAdmin) addGroup(id string, state string, committed map[TopicPartition]int64, members ...kafka.DescribeGroupsResponseMember) {
+	a.groups[id] = kafka.DescribeGroupsResponseGroup{GroupID: id, GroupState: state, Members: members}
+	a.committed[id] = committed
+}
+
+func (a *fakeAdmin) ListGroups(ctx context.Context, req *kafka.ListGroupsRequest) (*kafka.ListGroupsResponse, error) {
+	res := &kafka.ListGroupsResponse{}
+	for id := range a.groups {
+		res.Groups = append(res.Groups, kafka.ListGroupsResponseGroup{GroupID: id})
+	}
+	return res, nil
+}
+
+func (a *fakeAdmin) DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error) {
+	res := &kafka.DescribeGroupsResponse{}
+	for _, id := range req.GroupIDs {
+		g, ok := a.groups[id]
+		if !ok {
+			g = kafka.DescribeGroupsResponseGroup{GroupID: id, GroupState: "Dead"}
+		}
+		res.Groups = append(res.Groups, g)
+	}
+	return res, nil
+}
+
+func (a *fakeAdmin) OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error) {
+	res := &kafka.OffsetFetchResponse{Topics: make(map[string][]kafka.OffsetFetchPartition)}
+	for tp, off := range a.committed[req.GroupID] {
+		if req.Topics == nil || req.Topics[tp.Topic] != nil {
+			res.Topics[tp.Topic] = append(res.Topics[tp.Topic], kafka.OffsetFetchPartition{Partition: tp.Partition, CommittedOffset: off})
+		}
+	}
+	return res, nil
+}
+
+func (a *fakeAdmin) ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error) {
+	res := &kafka.ListOffsetsResponse{Topics: make(map[string][]kafka.PartitionOffsets)}
+	for topic, reqs := range req.Topics {
+		for _, r := range reqs {
+			tp := TopicPartition{topic, r.Partition}
+			po := kafka.PartitionOffsets{Partition: r.Partition, FirstOffset: -1, LastOffset: -1, Offsets: make(map[int64]time.Time)}
+			switch r.Timestamp {
+			case kafka.FirstOffset:
+				po.FirstOffset = a.first[tp]
+			case kafka.LastOffset:
+				po.LastOffset = a.last[tp]
+			default:
+				if off := a.atTime[tp]; off >= 0 {
+					po.Offsets[off] = time.UnixMilli(r.Timestamp)
+				}
+			}
+			res.Topics[topic] = append(res.Topics[topic], po)
+		}
+	}
+	return res, nil
+}
+
+func (a *fakeAdmin) OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error) {
+	res := &kafka.OffsetCommitResponse{Topics: make(map[string][]kafka.OffsetCommitPartition)}
+	if a.committed[req.GroupID] == nil {
+		a.committed[req.GroupID] = make(map[TopicPartition]int64)
+	}
+	for topic, commits := range req.Topics {
+		for _, c := range commits {
+			a.committed[req.GroupID][TopicPartition{topic, c.Partition}] = c.Offset
+			res.Topics[topic] = append(res.Topics[topic], kafka.OffsetCommitPartition{Partition: c.Partition})
+		}
+	}
+	return res, nil
+}
+
+func formatLags(lags []PartitionLag) string {
+	var s []string
+	for _, l := range lags {
+		s = append(s, fmt.Sprintf("%v %d %d %d", l.TopicPartition, l.Committed, l.End, l.Lag))
+	}
+	return fmt.Sprint(s)
+}
+
+func formatResets(resets []OffsetReset) string {
+	var s []string
+	for _, r := range resets {
+		s = append(s, fmt.Sprintf("%v %d -> %d", r.TopicPartition, r.Current, r.Target))
+	}
+	return fmt.Sprint(s)
+}
+
+func TestDescribeTopics(t *testing.T) {
+	admin := newFakeAdmin()
+	admin.addTopic("users", 2, 3, nil)
+	admin.addTopic("orders", 1, 3, nil)
+	admin.topics["__consumer_offsets"] = kafka.Topic{Name: "__consumer_offsets", Internal: true}
+	users := admin.topics["users"]
+	users.Partitions[1].Leader = kafka.Broker{ID: 2}
+	users.Partitions[1].Replicas = []kafka.Broker{{ID: 2}, {ID: 1}, {ID: 3}}
+	users.Partitions[1].Isr = []kafka.Broker{{ID: 2}}
+
+	topics, err := DescribeTopics(context.Background(), admin)
+	if err != nil {
+		t.Fatalf("DescribeTopics failed: %v", err)
+	}
+	if len(topics) != 2 || topics[0].Name != "orders" || topics[1].Name != "users" {
+		t.Fatalf("expected the non internal topics ordered by name, got %+v", topics)
+	}
+	p := topics[1].Partitions[1]
+	if p.Leader != 2 || fmt.Sprint(p.Replicas) != "[2 1 3]" || fmt.Sprint(p.Isr) != "[2]" || !p.UnderReplicated() {
+		t.Errorf("unexpected partition: %+v", p)
+	}
+
+	if _, err := DescribeTopics(context.Background(), admin, "products"); !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		t.Errorf("expected kafka.UnknownTopicOrPartition, got %v", err)
+	}
+}
+
+func TestListAndDescribeGroups(t *testing.T) {
+	admin := newFakeAdmin()
+	admin.addGroup("go-consumer-group-users", "Stable", nil, kafka.DescribeGroupsResponseMember{
+		MemberID: "m1", ClientID: "consumer", ClientHost: "/10.0.0.1",
+		MemberAssignments: kafka.DescribeGroupsResponseAssignments{Topics: []kafka.GroupMemberTopic{{Topic: "users", Partitions: []int{2, 0}}}},
+	})
+	admin.addGroup("cdc-cassandra-sink", "Empty", nil)
+	admin.addGroup("cdc-file-sink", "Empty", nil)
+
+	ids, err := ListGroups(context.Background(), admin, "go-consumer-group-*", "cdc-cassandra-sink")
+	if err != nil || fmt.Sprint(ids) != "[cdc-cassandra-sink go-consumer-group-users]" {
+		t.Fatalf("unexpected groups: %v, %v", ids, err)
+	}
+	if _, err := ListGroups(context.Background(), admin, "["); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+
+	groups, err := DescribeGroups(context.Background(), admin, ids...)
+	if err != nil {
+		t.Fatalf("DescribeGroups failed: %v", err)
+	}
+	if groups[0].State != "Empty" || len(groups[0].Members) != 0 {
+		t.Errorf("unexpected group: %+v", groups[0])
+	}
+	m := groups[1].Members[0]
+	if groups[1].State != "Stable" || m.ClientHost != "/10.0.0.1" || fmt.Sprint(m.Assigned) != "[users/0 users/2]" {
+		t.Errorf("unexpected group: %+v", groups[1])
+	}
+}
+
+func TestGroupLag(t *testing.T) {
+	admin := newFakeAdmin()
+	admin.addTopicWithOffsets("users", []int64{0, 10, 0}, []int64{100, 50, 0})
+	admin.addGroup("go-consumer-group-users", "Stable", map[TopicPartition]int64{{"users", 0}: 80, {"users", 2}: 0})
+
+	lags, err := GroupLag(context.Background(), admin, "go-consumer-group-users")
+	if err != nil {
+		t.Fatalf("GroupLag failed: %v", err)
+	}
+	// partition 1 has no committed offset, its lag counts from the first retained offset
+	want := "[users/0 80 100 20 users/1 -1 50 40 users/2 0 0 0]"
+	if formatLags(lags) != want {
+		t.Errorf("expected %s, got %v", want, lags)
+	}
+
+	if lags, err := GroupLag(context.Background(), admin, "unknown-group"); err != nil || lags != nil {
+		t.Errorf("expected no lag of a group without offsets, got %v, %v", lags, err)
+	}
+}
+
+func TestOffsetReset(t *testing.T) {
+	admin := newFakeAdmin()
+	admin.addTopicWithOffsets("orders", []int64{5, 0}, []int64{100, 40})
+	admin.atTime[TopicPartition{"orders", 0}] = 60
+	admin.atTime[TopicPartition{"orders", 1}] = -1
+	admin.addGroup("go-consumer-group-orders", "Empty", map[TopicPartition]int64{{"orders", 0}: 90})
+
+	tests := []struct {
+		name   string
+		target ResetTarget
+		want   string
+	}{
+		{"earliest", ResetTarget{Earliest: true}, "[orders/0 90 -> 5 orders/1 -1 -> 0]"},
+		{"latest", ResetTarget{Latest: true}, "[orders/0 90 -> 100 orders/1 -1 -> 40]"},
+		{"time", ResetTarget{Time: time.Now()}, "[orders/0 90 -> 60 orders/1 -1 -> 40]"},
+		{"offsets", ResetTarget{Offsets: map[int]int64{0: 2}}, "[orders/0 90 -> 5]"}, // clamped to the first offset
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resets, err := PlanOffsetReset(context.Background(), admin, "go-consumer-group-orders", "orders", tt.target)
+			if err != nil || formatResets(resets) != tt.want {
+				t.Errorf("expected %s, got %v, %v", tt.want, resets, err)
+			}
+		})
+	}
+
+	// planning doesn't change the offsets, resetting does
+	resets, _ := PlanOffsetReset(context.Background(), admin, "go-consumer-group-orders", "orders", ResetTarget{Earliest: true})
+	if admin.committed["go-consumer-group-orders"][TopicPartition{"orders", 0}] != 90 {
+		t.Fatalf("expected the plan not to change offsets")
+	}
+	if err := ResetOffsets(context.Background(), admin, "go-consumer-group-orders", resets); err != nil {
+		t.Fatalf("ResetOffsets failed: %v", err)
+	}
+	if got := admin.committed["go-consumer-group-orders"]; got[TopicPartition{"orders", 0}] != 5 || got[TopicPartition{"orders", 1}] != 0 {
+		t.Errorf("unexpected committed offsets: %v", got)
+	}
+
+	// invalid targets and active groups
+	if _, err := PlanOffsetReset(context.Background(), admin, "go-consumer-group-orders", "orders", ResetTarget{Earliest: true, Latest: true}); err == nil {
+		t.Errorf("expected an error for several targets")
+	}
+	if _, err := PlanOffsetReset(context.Background(), admin, "go-consumer-group-orders", "orders", ResetTarget{Offsets: map[int]int64{7: 0}}); err == nil {
+		t.Errorf("expected an error for an unknown partition")
+	}
+	admin.addGroup("go-consumer-group-orders", "Stable", nil, kafka.DescribeGroupsResponseMember{MemberID: "m1"})
+	if err := ResetOffsets(context.Background(), admin, "go-consumer-group-orders", resets); !errors.Is(err, ErrGroupActive) {
+		t.Errorf("expected ErrGroupActive, got %v", err)
+	}
+}
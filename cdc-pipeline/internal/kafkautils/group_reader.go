@@ -0,0 +1,182 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+// RebalanceListener is notified of the partitions assigned to (and revoked from) a consumer of a group
+type RebalanceListener interface {
+	// PartitionsAssigned is called with the partitions of a new generation of the group, before their messages are fetched
+	PartitionsAssigned(partitions []TopicPartition)
+
+	// PartitionsRevoked is called when a rebalance ends the generation. It must return once the fetched messages of the
+	// partitions are processed and their offsets committed, as the partitions are released to the group afterwards.
+	PartitionsRevoked(partitions []TopicPartition)
+}
+
+// GroupReader is a MessageReader of a topic for a consumer group, reading the partitions assigned to it in each
+// generation of the group. Unlike *kafka.Reader it notifies a RebalanceListener of the assigned and revoked partitions,
+// and the group rebalances when partitions are added to the topic.
+type GroupReader struct {
+	cg       *kafka.ConsumerGroup
+	brokers  []string
+	topic    string
+	listener RebalanceListener
+
+	msgs      chan generationMessage
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	mu  sync.Mutex
+	gen *kafka.Generation // the current generation, its offsets are committed
+}
+
+var _ MessageReader = (*GroupReader)(nil)
+
+// generationMessage is a message read in a generation (ctx is done when the generation ends)
+type generationMessage struct {
+	ctx context.Context
+	msg kafka.Message
+}
+
+// NewGroupReader joins a consumer group to read a topic, partitions without a committed offset are read from the first
+// offset
+func NewGroupReader(brokers []string, topic string, groupID string, listener RebalanceListener) (*GroupReader, error) {
+	cg, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                    groupID,
+		Brokers:               brokers,
+		Topics:                []string{topic},
+		WatchPartitionChanges: true,
+		StartOffset:           kafka.FirstOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &GroupReader{cg: cg, brokers: brokers, topic: topic, listener: listener, msgs: make(chan generationMessage), closed: make(chan struct{})}
+	go r.run()
+	return r, nil
+}
+
+// run reads the assigned partitions of each generation until the group is closed
+func (r *GroupReader) run() {
+	for {
+		gen, err := r.cg.Next(context.Background())
+		if errors.Is(err, kafka.ErrGroupClosed) {
+			return
+		}
+		if err != nil {
+			// the consumer group retries to join by itself
+			logger.ErrorLogger.Printf("Failed to join a generation of the consumer group of '%s' topic: %v\n", r.topic, err)
+			continue
+		}
+
+		assignments := gen.Assignments[r.topic]
+		partitions := make([]TopicPartition, len(assignments))
+		for i, a := range assignments {
+			partitions[i] = TopicPartition{r.topic, a.ID}
+		}
+		logger.InfoLogger.Printf("Generation %d of group '%s' assigned partitions %v\n", gen.ID, gen.GroupID, partitions)
+
+		r.mu.Lock()
+		r.gen = gen
+		r.mu.Unlock()
+		r.listener.PartitionsAssigned(partitions)
+
+		// the generation ends (and the group rebalances) once all its functions return,
+		// so the revoked partitions are released after the listener returns
+		gen.Start(func(ctx context.Context) {
+			<-ctx.Done()
+			logger.InfoLogger.Printf("Generation %d of group '%s' ended, revoking partitions %v\n", gen.ID, gen.GroupID, partitions)
+			r.listener.PartitionsRevoked(partitions)
+		})
+		for _, a := range assignments {
+			gen.Start(func(ctx context.Context) {
+				r.readPartition(ctx, a)
+			})
+		}
+	}
+}
+
+// readPartition sends the messages of an assigned partition to the fetches until the generation ends (or reading fails,
+// which ends the generation too)
+func (r *GroupReader) readPartition(ctx context.Context, a kafka.PartitionAssignment) {
+	pr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.brokers,
+		Topic:     r.topic,
+		Partition: a.ID,
+	})
+	defer pr.Close()
+
+	if err := pr.SetOffset(a.Offset); err != nil {
+		logger.ErrorLogger.Printf("Failed to set offset %d of partition %d of '%s' topic: %v\n", a.Offset, a.ID, r.topic, err)
+		return
+	}
+	for {
+		msg, err := pr.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.ErrorLogger.Printf("Error while reading partition %d of '%s' topic: %v\n", a.ID, r.topic, err)
+			}
+			return
+		}
+		select {
+		case r.msgs <- generationMessage{ctx, msg}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FetchMessage returns the next message of the assigned partitions, waiting until one is read if there is none.
+// Messages of an ended generation aren't returned, as the listener may have been notified of their revocation.
+func (r *GroupReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		select {
+		case m := <-r.msgs:
+			if m.ctx.Err() != nil {
+				continue
+			}
+			return m.msg, nil
+		case <-r.closed:
+			return kafka.Message{}, ErrReaderClosed
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		}
+	}
+}
+
+// CommitMessages commits the offsets following the messages in the current generation, which fails if the generation
+// ended (the partitions may be assigned to another consumer then)
+func (r *GroupReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	gen := r.gen
+	r.mu.Unlock()
+	if gen == nil {
+		return errors.New("no partitions assigned to the consumer")
+	}
+
+	offsets := make(map[string]map[int]int64)
+	for _, msg := range msgs {
+		if offsets[msg.Topic] == nil {
+			offsets[msg.Topic] = make(map[int]int64)
+		}
+		offsets[msg.Topic][msg.Partition] = msg.Offset + 1
+	}
+	return gen.CommitOffsets(offsets)
+}
+
+// Close leaves the group (after the listener is notified of the revoked partitions)
+func (r *GroupReader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		err = r.cg.Close()
+	})
+	return err
+}
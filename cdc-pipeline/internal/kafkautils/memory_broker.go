@@ -0,0 +1,383 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrReaderClosed is returned by the fetches of a closed GroupReader or MemoryBroker reader
+var ErrReaderClosed = errors.New("reader closed")
+
+// TopicPartition identifies a partition of a topic
+type TopicPartition struct {
+	Topic     string
+	Partition int
+}
+
+func (tp TopicPartition) String() string {
+	return fmt.Sprintf("%s/%d", tp.Topic, tp.Partition)
+}
+
+// MemoryBroker is an in-memory Kafka broker for tests of consumers and producers. It keeps the messages of
+// multi-partition topics and the committed offsets of consumer groups, so tests can check what was committed (and in
+// which order) after a consumer stopped. Messages with a key are written to the partition of the key's hash (like
+// kafka.Hash), others round robin.
+type MemoryBroker struct {
+	mu      sync.Mutex
+	topics  map[string][][]kafka.Message
+	next    map[string]int                      // partition of the next message without key, by topic
+	offsets map[string]map[TopicPartition]int64 // committed offsets (of the next message to consume) by group
+	commits map[string][]kafka.Message          // committed messages by group, in commit order
+	members map[string][]*memoryReader          // group readers by group, in join order
+	written chan struct{}                       // closed (and replaced) when messages are written or partitions assigned
+
+	rebalanceMu sync.Mutex // serializes the rebalances of groups
+
+	// inject errors of writes and commits (if set)
+	FailWrite  func(msgs []kafka.Message) error
+	FailCommit func(group string, msgs []kafka.Message) error
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics:  make(map[string][][]kafka.Message),
+		next:    make(map[string]int),
+		offsets: make(map[string]map[TopicPartition]int64),
+		commits: make(map[string][]kafka.Message),
+		members: make(map[string][]*memoryReader),
+		written: make(chan struct{}),
+	}
+}
+
+// CreateTopic creates a topic with the number of partitions (if it doesn't exist)
+func (b *MemoryBroker) CreateTopic(topic string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.topics[topic]; !ok {
+		b.topics[topic] = make([][]kafka.Message, partitions)
+	}
+}
+
+// AddPartitions increases the partitions of a topic to a number, rebalancing the groups with members reading the topic
+func (b *MemoryBroker) AddPartitions(topic string, partitions int) {
+	b.mu.Lock()
+	if n := len(b.topics[topic]); partitions > n {
+		b.topics[topic] = append(b.topics[topic], make([][]kafka.Message, partitions-n)...)
+	}
+	var groups []string
+	for group, members := range b.members {
+		for _, m := range members {
+			if m.topic == topic {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, group := range groups {
+		b.rebalance(group)
+	}
+}
+
+// Partitions returns the number of partitions of a topic (0 if it doesn't exist)
+func (b *MemoryBroker) Partitions(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.topics[topic])
+}
+
+// WriteMessages appends messages to the partitions of their topics, which must exist
+func (b *MemoryBroker) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if b.FailWrite != nil {
+		if err := b.FailWrite(msgs); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		if _, ok := b.topics[msg.Topic]; !ok {
+			return fmt.Errorf("%w: %s", kafka.UnknownTopicOrPartition, msg.Topic)
+		}
+	}
+	for _, msg := range msgs {
+		partitions := b.topics[msg.Topic]
+		if msg.Key != nil {
+			h := fnv.New32a()
+			h.Write(msg.Key)
+			msg.Partition = int(h.Sum32() % uint32(len(partitions)))
+		} else {
+			msg.Partition = b.next[msg.Topic]
+			b.next[msg.Topic] = (msg.Partition + 1) % len(partitions)
+		}
+		msg.Offset = int64(len(partitions[msg.Partition]))
+		if msg.Time.IsZero() {
+			msg.Time = time.Now()
+		}
+		partitions[msg.Partition] = append(partitions[msg.Partition], msg)
+	}
+
+	b.wakeLocked()
+	return nil
+}
+
+// wakeLocked wakes up the waiting fetches (b.mu must be held)
+func (b *MemoryBroker) wakeLocked() {
+	close(b.written)
+	b.written = make(chan struct{})
+}
+
+// Writer returns a MessageWriter of a topic (the topic of the written messages is set to it)
+func (b *MemoryBroker) Writer(topic string) MessageWriter {
+	return &memoryWriter{broker: b, topic: topic}
+}
+
+type memoryWriter struct {
+	broker *MemoryBroker
+	topic  string
+}
+
+func (w *memoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	withTopic := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		msg.Topic = w.topic
+		withTopic[i] = msg
+	}
+	return w.broker.WriteMessages(ctx, withTopic...)
+}
+
+// Messages returns the messages of a partition
+func (b *MemoryBroker) Messages(topic string, partition int) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if partition >= len(b.topics[topic]) {
+		return nil
+	}
+	return append([]kafka.Message(nil), b.topics[topic][partition]...)
+}
+
+// CommittedOffset returns the committed offset of a group's partition (the offset of the next message to consume),
+// false if the group didn't commit an offset of the partition
+func (b *MemoryBroker) CommittedOffset(group string, topic string, partition int) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	offset, ok := b.offsets[group][TopicPartition{topic, partition}]
+	return offset, ok
+}
+
+// Commits returns the messages committed by a group, in commit order
+func (b *MemoryBroker) Commits(group string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafka.Message(nil), b.commits[group]...)
+}
+
+// Reader returns a MessageReader of a topic for a consumer group. The reader is assigned all partitions of the topic
+// (including the ones added later) and starts at their committed offsets (or the first offset), fetching from the
+// partitions round robin.
+func (b *MemoryBroker) Reader(topic string, group string) MessageReader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := &memoryReader{broker: b, topic: topic, group: group, positions: make(map[int]int64), closed: make(chan struct{})}
+	for p := range b.topics[topic] {
+		r.positions[p] = b.offsets[group][TopicPartition{topic, p}]
+	}
+	return r
+}
+
+// PartitionReader returns a MessageReader of a single partition starting at an offset, without a consumer group
+// (like a kafka.Reader of a partition, so it can't commit offsets)
+func (b *MemoryBroker) PartitionReader(topic string, partition int, offset int64) MessageReader {
+	return &memoryReader{broker: b, topic: topic, member: true, assigned: []int{partition},
+		positions: map[int]int64{partition: offset}, closed: make(chan struct{})}
+}
+
+// GroupReader returns a MessageReader of a topic for a member of a consumer group, sharing the partitions of the topic
+// with the other members (round robin in join order). Like with Kafka, the group rebalances when a member joins or is
+// closed, or partitions are added to the topic: the partitions of all members are revoked before they are assigned
+// again, starting at their committed offsets. The listener (if not nil) is notified of both, the rebalance waits for it.
+func (b *MemoryBroker) GroupReader(topic string, group string, listener RebalanceListener) MessageReader {
+	r := &memoryReader{broker: b, topic: topic, group: group, member: true, listener: listener, positions: make(map[int]int64), closed: make(chan struct{})}
+	b.mu.Lock()
+	b.members[group] = append(b.members[group], r)
+	b.mu.Unlock()
+
+	b.rebalance(group)
+	return r
+}
+
+// rebalance revokes the partitions of the members of a group and assigns the partitions of their topics to the members
+// that aren't closed
+func (b *MemoryBroker) rebalance(group string) {
+	b.rebalanceMu.Lock()
+	defer b.rebalanceMu.Unlock()
+
+	b.mu.Lock()
+	members := b.members[group]
+	revoked := make([][]TopicPartition, len(members))
+	for i, m := range members {
+		for _, p := range m.assigned {
+			revoked[i] = append(revoked[i], TopicPartition{m.topic, p})
+		}
+		m.assigned = nil
+	}
+	b.mu.Unlock()
+	for i, m := range members {
+		if m.listener != nil && len(revoked[i]) > 0 {
+			m.listener.PartitionsRevoked(revoked[i])
+		}
+	}
+
+	// the members that joined during the revocation are included
+	b.mu.Lock()
+	var active []*memoryReader
+	for _, m := range b.members[group] {
+		if !m.isClosed() {
+			active = append(active, m)
+		}
+	}
+	b.members[group] = active
+	byTopic := make(map[string][]int) // indexes of the active members by topic
+	for i, m := range active {
+		byTopic[m.topic] = append(byTopic[m.topic], i)
+	}
+	assigned := make([][]TopicPartition, len(active))
+	for topic, idx := range byTopic {
+		for p := range b.topics[topic] {
+			i := idx[p%len(idx)]
+			assigned[i] = append(assigned[i], TopicPartition{topic, p})
+		}
+	}
+	b.mu.Unlock()
+
+	// the members fetch from their partitions after they're notified
+	for i, m := range active {
+		if m.listener != nil {
+			m.listener.PartitionsAssigned(assigned[i])
+		}
+	}
+	b.mu.Lock()
+	for i, m := range active {
+		m.positions = make(map[int]int64)
+		for _, tp := range assigned[i] {
+			m.assigned = append(m.assigned, tp.Partition)
+			m.positions[tp.Partition] = b.offsets[group][tp]
+		}
+		m.next = 0
+	}
+	b.wakeLocked()
+	b.mu.Unlock()
+}
+
+type memoryReader struct {
+	broker    *MemoryBroker
+	topic     string
+	group     string
+	member    bool              // a group reader, fetching from the assigned partitions only
+	listener  RebalanceListener // of a group reader (may be nil)
+	assigned  []int             // partitions assigned to a group reader
+	positions map[int]int64     // offsets of the next messages to fetch, by partition
+	next      int               // index of the partition to fetch from first
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// partitionsLocked returns the partitions the reader fetches from (b.mu must be held)
+func (r *memoryReader) partitionsLocked() []int {
+	if r.member {
+		return r.assigned
+	}
+	partitions := make([]int, len(r.broker.topics[r.topic]))
+	for p := range partitions {
+		partitions[p] = p
+		if _, ok := r.positions[p]; !ok {
+			r.positions[p] = r.broker.offsets[r.group][TopicPartition{r.topic, p}]
+		}
+	}
+	return partitions
+}
+
+// FetchMessage returns the next message, waiting until one is written if there is none
+func (r *memoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		b := r.broker
+		b.mu.Lock()
+		messages := b.topics[r.topic]
+		partitions := r.partitionsLocked()
+		for i := range partitions {
+			p := partitions[(r.next+i)%len(partitions)]
+			if r.positions[p] < int64(len(messages[p])) {
+				msg := messages[p][r.positions[p]]
+				r.positions[p]++
+				r.next = (r.next + i + 1) % len(partitions)
+				b.mu.Unlock()
+				return msg, nil
+			}
+		}
+		written := b.written
+		b.mu.Unlock()
+
+		select {
+		case <-written:
+		case <-r.closed:
+			return kafka.Message{}, ErrReaderClosed
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		}
+	}
+}
+
+// CommitMessages commits the offsets following the messages. Like with Kafka, committing an older message moves the
+// group's offset back.
+func (r *memoryReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	b := r.broker
+	if r.group == "" {
+		return errors.New("commits are unavailable without a consumer group")
+	}
+	if b.FailCommit != nil {
+		if err := b.FailCommit(r.group, msgs); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	offsets, ok := b.offsets[r.group]
+	if !ok {
+		offsets = make(map[TopicPartition]int64)
+		b.offsets[r.group] = offsets
+	}
+	for _, msg := range msgs {
+		offsets[TopicPartition{msg.Topic, msg.Partition}] = msg.Offset + 1
+	}
+	b.commits[r.group] = append(b.commits[r.group], msgs...)
+	return nil
+}
+
+func (r *memoryReader) isClosed() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the reader, a group reader leaves its group (after its partitions are revoked)
+func (r *memoryReader) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		if r.member {
+			r.broker.rebalance(r.group)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,242 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMemoryBroker_WriteAndFetch(t *testing.T) {
+	b := NewMemoryBroker()
+	b.CreateTopic("users", 3)
+	w := b.Writer("users")
+
+	// messages with the same key go to the same partition, others round robin
+	for _, key := range []string{"u1", "u2", "u1"} {
+		if err := w.WriteMessages(context.Background(), kafka.Message{Key: []byte(key), Value: []byte(key)}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+	if err := w.WriteMessages(context.Background(), kafka.Message{Value: []byte("a")}, kafka.Message{Value: []byte("b")}); err != nil {
+		t.Fatalf("WriteMessages failed: %v", err)
+	}
+
+	total := 0
+	var u1Partition []int
+	for p := range b.Partitions("users") {
+		for i, msg := range b.Messages("users", p) {
+			if msg.Offset != int64(i) || msg.Partition != p || msg.Topic != "users" {
+				t.Errorf("unexpected message at %d of partition %d: %+v", i, p, msg)
+			}
+			if string(msg.Key) == "u1" {
+				u1Partition = append(u1Partition, p)
+			}
+			total++
+		}
+	}
+	if total != 5 || len(u1Partition) != 2 || u1Partition[0] != u1Partition[1] {
+		t.Errorf("expected 5 messages (u1 twice in the same partition), got %d (u1 in %v)", total, u1Partition)
+	}
+
+	// a reader fetches every message once
+	r := b.Reader("users", "g1")
+	seen := make(map[TopicPartition]int64)
+	for range total {
+		msg, err := r.FetchMessage(context.Background())
+		if err != nil {
+			t.Fatalf("FetchMessage failed: %v", err)
+		}
+		tp := TopicPartition{msg.Topic, msg.Partition}
+		if next := seen[tp]; msg.Offset != next {
+			t.Errorf("expected offset %d of %v, got %d", next, tp, msg.Offset)
+		}
+		seen[tp] = msg.Offset + 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.FetchMessage(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded without messages, got %v", err)
+	}
+
+	if err := b.WriteMessages(context.Background(), kafka.Message{Topic: "orders"}); !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		t.Errorf("expected kafka.UnknownTopicOrPartition, got %v", err)
+	}
+}
+
+func TestMemoryBroker_FetchWaitsForWrites(t *testing.T) {
+	b := NewMemoryBroker()
+	b.CreateTopic("orders", 2)
+	r := b.Reader("orders", "g1")
+
+	fetched := make(chan kafka.Message)
+	go func() {
+		msg, err := r.FetchMessage(context.Background())
+		if err != nil {
+			t.Errorf("FetchMessage failed: %v", err)
+		}
+		fetched <- msg
+	}()
+
+	if err := b.Writer("orders").WriteMessages(context.Background(), kafka.Message{Value: []byte("o1")}); err != nil {
+		t.Fatalf("WriteMessages failed: %v", err)
+	}
+	if msg := <-fetched; string(msg.Value) != "o1" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	// closing the reader stops a waiting fetch
+	go func() { r.Close() }()
+	if _, err := r.FetchMessage(context.Background()); !errors.Is(err, ErrReaderClosed) {
+		t.Errorf("expected ErrReaderClosed, got %v", err)
+	}
+}
+
+func TestMemoryBroker_GroupOffsets(t *testing.T) {
+	b := NewMemoryBroker()
+	b.CreateTopic("users", 1)
+	for _, v := range []string{"m0", "m1", "m2"} {
+		if err := b.Writer("users").WriteMessages(context.Background(), kafka.Message{Value: []byte(v)}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+
+	r := b.Reader("users", "g1")
+	m0, _ := r.FetchMessage(context.Background())
+	m1, _ := r.FetchMessage(context.Background())
+	if _, ok := b.CommittedOffset("g1", "users", 0); ok {
+		t.Errorf("expected no committed offset before a commit")
+	}
+	if err := r.CommitMessages(context.Background(), m0); err != nil {
+		t.Fatalf("CommitMessages failed: %v", err)
+	}
+
+	// failed commits don't move the offset
+	b.FailCommit = func(group string, msgs []kafka.Message) error { return errors.New("not the coordinator") }
+	if err := r.CommitMessages(context.Background(), m1); err == nil {
+		t.Fatalf("expected the injected commit error")
+	}
+	b.FailCommit = nil
+	if offset, ok := b.CommittedOffset("g1", "users", 0); !ok || offset != 1 {
+		t.Errorf("expected committed offset 1, got %d, %t", offset, ok)
+	}
+
+	// a new reader of the group resumes at the committed offset, other groups start at the first offset
+	if msg, _ := b.Reader("users", "g1").FetchMessage(context.Background()); msg.Offset != 1 {
+		t.Errorf("expected the group to resume at offset 1, got %d", msg.Offset)
+	}
+	if msg, _ := b.Reader("users", "g2").FetchMessage(context.Background()); msg.Offset != 0 {
+		t.Errorf("expected a new group to start at offset 0, got %d", msg.Offset)
+	}
+
+	if commits := b.Commits("g1"); len(commits) != 1 || commits[0].Offset != 0 {
+		t.Errorf("unexpected commits: %v", commits)
+	}
+
+	b.FailWrite = func(msgs []kafka.Message) error { return kafka.LeaderNotAvailable }
+	if err := b.Writer("users").WriteMessages(context.Background(), kafka.Message{}); !errors.Is(err, kafka.LeaderNotAvailable) {
+		t.Errorf("expected the injected write error, got %v", err)
+	}
+	if n := len(b.Messages("users", 0)); n != 3 {
+		t.Errorf("expected 3 messages after a failed write, got %d", n)
+	}
+}
+
+// recordingListener records the partitions assigned to and revoked from a group reader
+type recordingListener struct {
+	events []string
+}
+
+func (l *recordingListener) PartitionsAssigned(partitions []TopicPartition) {
+	l.events = append(l.events, fmt.Sprint("assigned ", partitions))
+}
+
+func (l *recordingListener) PartitionsRevoked(partitions []TopicPartition) {
+	l.events = append(l.events, fmt.Sprint("revoked ", partitions))
+}
+
+func TestMemoryBroker_GroupRebalances(t *testing.T) {
+	b := NewMemoryBroker()
+	b.CreateTopic("orders", 2)
+	w := b.Writer("orders")
+	for _, v := range []string{"m0", "m1", "m2", "m3"} {
+		if err := w.WriteMessages(context.Background(), kafka.Message{Value: []byte(v)}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+
+	// the first member is assigned all partitions
+	l1 := &recordingListener{}
+	r1 := b.GroupReader("orders", "g1", l1)
+	msg, err := r1.FetchMessage(context.Background())
+	if err != nil {
+		t.Fatalf("FetchMessage failed: %v", err)
+	}
+	if err := r1.CommitMessages(context.Background(), msg); err != nil {
+		t.Fatalf("CommitMessages failed: %v", err)
+	}
+
+	// a second member joins, partition 1 is moved to it and the members resume at the committed offsets
+	l2 := &recordingListener{}
+	r2 := b.GroupReader("orders", "g1", l2)
+	if got := fmt.Sprint(l1.events); got != "[assigned [orders/0 orders/1] revoked [orders/0 orders/1] assigned [orders/0]]" {
+		t.Errorf("unexpected events of the first member: %s", got)
+	}
+	if got := fmt.Sprint(l2.events); got != "[assigned [orders/1]]" {
+		t.Errorf("unexpected events of the second member: %s", got)
+	}
+	if msg, _ := r1.FetchMessage(context.Background()); msg.Partition != 0 || msg.Offset != 1 {
+		t.Errorf("expected the first member to resume at offset 1 of partition 0, got %d/%d", msg.Partition, msg.Offset)
+	}
+	if msg, _ := r2.FetchMessage(context.Background()); msg.Partition != 1 || msg.Offset != 0 {
+		t.Errorf("expected the second member to start at offset 0 of partition 1, got %d/%d", msg.Partition, msg.Offset)
+	}
+
+	// partitions added to the topic are assigned in a rebalance
+	b.AddPartitions("orders", 3)
+	if got := fmt.Sprint(l2.events); got != "[assigned [orders/1] revoked [orders/1] assigned [orders/1]]" {
+		t.Errorf("unexpected events of the second member: %s", got)
+	}
+	if got := l1.events[len(l1.events)-1]; got != "assigned [orders/0 orders/2]" {
+		t.Errorf("unexpected assignment of the first member: %s", got)
+	}
+
+	// a closed member leaves the group
+	r1.Close()
+	if got := l1.events[len(l1.events)-1]; got != "revoked [orders/0 orders/2]" {
+		t.Errorf("expected the partitions of the closed member to be revoked, got %s", got)
+	}
+	if got := l2.events[len(l2.events)-1]; got != "assigned [orders/0 orders/1 orders/2]" {
+		t.Errorf("unexpected assignment of the second member: %s", got)
+	}
+}
+
+func TestMemoryBroker_PartitionReader(t *testing.T) {
+	b := NewMemoryBroker()
+	b.CreateTopic("users", 2)
+	for i := range 6 {
+		if err := b.WriteMessages(context.Background(), kafka.Message{Topic: "users", Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("WriteMessages failed: %v", err)
+		}
+	}
+
+	// only the messages of the partition are fetched, starting at the offset
+	r := b.PartitionReader("users", 1, 1)
+	defer r.Close()
+	for _, want := range []int64{1, 2} {
+		msg, err := r.FetchMessage(context.Background())
+		if err != nil {
+			t.Fatalf("FetchMessage failed: %v", err)
+		}
+		if msg.Partition != 1 || msg.Offset != want {
+			t.Errorf("expected offset %d of partition 1, got %d of partition %d", want, msg.Offset, msg.Partition)
+		}
+	}
+	if err := r.CommitMessages(context.Background(), kafka.Message{Topic: "users", Partition: 1, Offset: 2}); err == nil {
+		t.Errorf("expected commits to fail without a consumer group")
+	}
+}
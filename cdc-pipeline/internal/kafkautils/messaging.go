@@ -0,0 +1,26 @@
+package kafkautils
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageReader fetches messages of a consumer group and commits their offsets
+// (implemented by *kafka.Reader and MemoryBroker readers)
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// MessageWriter writes messages to Kafka (implemented by *kafka.Writer and MemoryBroker)
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// check that kafka-go's reader and writer satisfy the interfaces
+var (
+	_ MessageReader = (*kafka.Reader)(nil)
+	_ MessageWriter = (*kafka.Writer)(nil)
+)
@@ -0,0 +1,325 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+// TopicAdmin is the part of the Kafka admin API used to provision topics (implemented by *kafka.Client)
+type TopicAdmin interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error)
+	CreatePartitions(ctx context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error)
+	DescribeConfigs(ctx context.Context, req *kafka.DescribeConfigsRequest) (*kafka.DescribeConfigsResponse, error)
+	IncrementalAlterConfigs(ctx context.Context, req *kafka.IncrementalAlterConfigsRequest) (*kafka.IncrementalAlterConfigsResponse, error)
+}
+
+var _ TopicAdmin = (*kafka.Client)(nil)
+
+// NewAdminClient returns a Kafka client for admin requests to the brokers
+func NewAdminClient(brokers ...string) *kafka.Client {
+	return &kafka.Client{
+		Addr:    kafka.TCP(brokers...),
+		Timeout: 10 * time.Second,
+	}
+}
+
+// topic configs managed by a TopicSpec
+const (
+	retentionMsConfig       = "retention.ms"
+	cleanupPolicyConfig     = "cleanup.policy"
+	minInsyncReplicasConfig = "min.insync.replicas"
+	compressionTypeConfig   = "compression.type"
+)
+
+// TopicSpec is the desired state of a topic. Configs with a zero value aren't managed (they keep the broker defaults
+// or their current values).
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	RetentionMs       int64  // retention.ms (-1 for unlimited retention)
+	CleanupPolicy     string // cleanup.policy, e.g. "delete" or "compact"
+	MinInsyncReplicas int    // min.insync.replicas
+	CompressionType   string // compression.type, e.g. "producer", "snappy" or "zstd"
+}
+
+// DefaultTopicSpec returns the spec of a pipeline topic with the configured replication and topic configs
+func DefaultTopicSpec(topic string, partitions int) TopicSpec {
+	return TopicSpec{
+		Name:              topic,
+		Partitions:        partitions,
+		ReplicationFactor: config.KafkaReplicationFactor,
+		RetentionMs:       config.KafkaTopicRetentionMs,
+		CleanupPolicy:     config.KafkaTopicCleanupPolicy,
+		MinInsyncReplicas: config.KafkaTopicMinInsyncReplicas,
+		CompressionType:   config.KafkaTopicCompressionType,
+	}
+}
+
+// configs returns the managed topic configs of the spec
+func (s TopicSpec) configs() map[string]string {
+	configs := make(map[string]string)
+	if s.RetentionMs != 0 {
+		configs[retentionMsConfig] = strconv.FormatInt(s.RetentionMs, 10)
+	}
+	if s.CleanupPolicy != "" {
+		configs[cleanupPolicyConfig] = s.CleanupPolicy
+	}
+	if s.MinInsyncReplicas != 0 {
+		configs[minInsyncReplicasConfig] = strconv.Itoa(s.MinInsyncReplicas)
+	}
+	if s.CompressionType != "" {
+		configs[compressionTypeConfig] = s.CompressionType
+	}
+	return configs
+}
+
+// names of topic configs in sorted order
+func configNames(configs map[string]string) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (s TopicSpec) validate() error {
+	if s.Name == "" {
+		return errors.New("topic spec without a name")
+	}
+	if s.Partitions < 1 || s.ReplicationFactor < 1 {
+		return fmt.Errorf("topic '%s': partitions and replication factor must be positive", s.Name)
+	}
+	if s.MinInsyncReplicas > s.ReplicationFactor {
+		return fmt.Errorf("topic '%s': min.insync.replicas %d exceeds the replication factor %d", s.Name, s.MinInsyncReplicas, s.ReplicationFactor)
+	}
+	return nil
+}
+
+// TopicDiff is a difference of a topic setting from its spec
+type TopicDiff struct {
+	Topic   string
+	Setting string // "partitions", "replication.factor" or a topic config
+	Actual  string
+	Desired string
+}
+
+func (d TopicDiff) String() string {
+	return fmt.Sprintf("%s %s: %s -> %s", d.Topic, d.Setting, d.Actual, d.Desired)
+}
+
+// ProvisionReport reports the changes made by EnsureTopics and the drift it couldn't fix
+type ProvisionReport struct {
+	Created []string    // created topics
+	Changed []TopicDiff // increased partitions and altered configs
+	Drift   []TopicDiff // differences that need a manual fix (fewer partitions or another replication factor)
+}
+
+// HasDrift reports whether topics differ from their specs after provisioning
+func (r *ProvisionReport) HasDrift() bool {
+	return len(r.Drift) > 0
+}
+
+// EnsureTopics provisions topics to match their specs: missing topics are created, partitions increased and the managed
+// configs altered. Partitions can't be removed and the replication factor can't be changed without a reassignment, so
+// those differences are reported as drift. Errors of single topics don't stop the provisioning of the others, they're
+// joined in the returned error.
+func EnsureTopics(ctx context.Context, admin TopicAdmin, specs ...TopicSpec) (*ProvisionReport, error) {
+	report := &ProvisionReport{}
+	for _, s := range specs {
+		if err := s.validate(); err != nil {
+			return report, err
+		}
+	}
+
+	// metadata of all topics (requesting a missing topic may create it with the broker defaults)
+	meta, err := admin.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return report, fmt.Errorf("failed to get metadata of the Kafka cluster: %w", err)
+	}
+	existing := make(map[string]kafka.Topic)
+	for _, t := range meta.Topics {
+		existing[t.Name] = t
+	}
+
+	var errs []error
+	var missing, present []TopicSpec
+	for _, s := range specs {
+		if _, ok := existing[s.Name]; ok {
+			present = append(present, s)
+		} else {
+			missing = append(missing, s)
+		}
+	}
+
+	if err := createTopics(ctx, admin, missing, report); err != nil {
+		errs = append(errs, err)
+	}
+	if err := increasePartitions(ctx, admin, present, existing, report); err != nil {
+		errs = append(errs, err)
+	}
+	if err := alterTopicConfigs(ctx, admin, present, report); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, d := range report.Drift {
+		logger.ErrorLogger.Printf("topic drift (needs a manual fix): %v\n", d)
+	}
+	return report, errors.Join(errs...)
+}
+
+func createTopics(ctx context.Context, admin TopicAdmin, specs []TopicSpec, report *ProvisionReport) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	req := &kafka.CreateTopicsRequest{}
+	for _, s := range specs {
+		tc := kafka.TopicConfig{Topic: s.Name, NumPartitions: s.Partitions, ReplicationFactor: s.ReplicationFactor}
+		configs := s.configs()
+		for _, name := range configNames(configs) {
+			tc.ConfigEntries = append(tc.ConfigEntries, kafka.ConfigEntry{ConfigName: name, ConfigValue: configs[name]})
+		}
+		req.Topics = append(req.Topics, tc)
+	}
+	res, err := admin.CreateTopics(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+
+	var errs []error
+	for _, s := range specs {
+		if err := res.Errors[s.Name]; err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+			errs = append(errs, fmt.Errorf("failed to create topic '%s': %w", s.Name, err))
+			continue
+		}
+		logger.InfoLogger.Printf("Topic '%s' created with %d partitions\n", s.Name, s.Partitions)
+		report.Created = append(report.Created, s.Name)
+	}
+	return errors.Join(errs...)
+}
+
+func increasePartitions(ctx context.Context, admin TopicAdmin, specs []TopicSpec, existing map[string]kafka.Topic, report *ProvisionReport) error {
+	req := &kafka.CreatePartitionsRequest{}
+	var increased []TopicDiff
+	for _, s := range specs {
+		t := existing[s.Name]
+		partitions := len(t.Partitions)
+		diff := TopicDiff{Topic: s.Name, Setting: "partitions", Actual: strconv.Itoa(partitions), Desired: strconv.Itoa(s.Partitions)}
+		switch {
+		case partitions < s.Partitions:
+			req.Topics = append(req.Topics, kafka.TopicPartitionsConfig{Name: s.Name, Count: int32(s.Partitions)})
+			increased = append(increased, diff)
+		case partitions > s.Partitions:
+			report.Drift = append(report.Drift, diff)
+		}
+
+		if partitions > 0 {
+			if rf := len(t.Partitions[0].Replicas); rf != s.ReplicationFactor {
+				report.Drift = append(report.Drift, TopicDiff{Topic: s.Name, Setting: "replication.factor", Actual: strconv.Itoa(rf), Desired: strconv.Itoa(s.ReplicationFactor)})
+			}
+		}
+	}
+	if len(req.Topics) == 0 {
+		return nil
+	}
+
+	res, err := admin.CreatePartitions(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to increase partitions: %w", err)
+	}
+	var errs []error
+	for _, d := range increased {
+		if err := res.Errors[d.Topic]; err != nil {
+			errs = append(errs, fmt.Errorf("failed to increase partitions of topic '%s': %w", d.Topic, err))
+			continue
+		}
+		logger.InfoLogger.Printf("Partitions of topic '%s' increased from %s to %s\n", d.Topic, d.Actual, d.Desired)
+		report.Changed = append(report.Changed, d)
+	}
+	return errors.Join(errs...)
+}
+
+func alterTopicConfigs(ctx context.Context, admin TopicAdmin, specs []TopicSpec, report *ProvisionReport) error {
+	describeReq := &kafka.DescribeConfigsRequest{}
+	for _, s := range specs {
+		if names := configNames(s.configs()); len(names) > 0 {
+			describeReq.Resources = append(describeReq.Resources, kafka.DescribeConfigRequestResource{
+				ResourceType: kafka.ResourceTypeTopic, ResourceName: s.Name, ConfigNames: names,
+			})
+		}
+	}
+	if len(describeReq.Resources) == 0 {
+		return nil
+	}
+	described, err := admin.DescribeConfigs(ctx, describeReq)
+	if err != nil {
+		return fmt.Errorf("failed to describe topic configs: %w", err)
+	}
+
+	actual := make(map[string]map[string]string)
+	var errs []error
+	for _, r := range described.Resources {
+		if r.Error != nil {
+			errs = append(errs, fmt.Errorf("failed to describe configs of topic '%s': %w", r.ResourceName, r.Error))
+			continue
+		}
+		actual[r.ResourceName] = make(map[string]string)
+		for _, e := range r.ConfigEntries {
+			actual[r.ResourceName][e.ConfigName] = e.ConfigValue
+		}
+	}
+
+	alterReq := &kafka.IncrementalAlterConfigsRequest{}
+	altered := make(map[string][]TopicDiff)
+	for _, s := range specs {
+		current, ok := actual[s.Name]
+		if !ok {
+			continue
+		}
+		resource := kafka.IncrementalAlterConfigsRequestResource{ResourceType: kafka.ResourceTypeTopic, ResourceName: s.Name}
+		configs := s.configs()
+		for _, name := range configNames(configs) {
+			value := configs[name]
+			if current[name] == value {
+				continue
+			}
+			resource.Configs = append(resource.Configs, kafka.IncrementalAlterConfigsRequestConfig{
+				Name: name, Value: value, ConfigOperation: kafka.ConfigOperationSet,
+			})
+			altered[s.Name] = append(altered[s.Name], TopicDiff{Topic: s.Name, Setting: name, Actual: current[name], Desired: value})
+		}
+		if len(resource.Configs) > 0 {
+			alterReq.Resources = append(alterReq.Resources, resource)
+		}
+	}
+	if len(alterReq.Resources) == 0 {
+		return errors.Join(errs...)
+	}
+
+	res, err := admin.IncrementalAlterConfigs(ctx, alterReq)
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to alter topic configs: %w", err))...)
+	}
+	for _, r := range res.Resources {
+		if r.Error != nil {
+			errs = append(errs, fmt.Errorf("failed to alter configs of topic '%s': %w", r.ResourceName, r.Error))
+			continue
+		}
+		diffs := altered[r.ResourceName]
+		for _, d := range diffs {
+			logger.InfoLogger.Printf("Config %s of topic '%s' altered from '%s' to '%s'\n", d.Setting, d.Topic, d.Actual, d.Desired)
+		}
+		report.Changed = append(report.Changed, diffs...)
+	}
+	return errors.Join(errs...)
+}
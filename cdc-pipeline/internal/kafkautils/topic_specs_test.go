@@ -0,0 +1,198 @@
+package kafkautils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeTopicAdmin is a TopicAdmin of an in-memory cluster, recording the requests that change topics
+type fakeTopicAdmin struct {
+	topics  map[string]kafka.Topic
+	configs map[string]map[string]string
+	errs    map[string]error // errors of topics in the responses
+	created []kafka.TopicConfig
+	altered []kafka.IncrementalAlterConfigsRequestResource
+}
+
+func newFakeTopicAdmin() *fakeTopicAdmin {
+	return &fakeTopicAdmin{topics: make(map[string]kafka.Topic), configs: make(map[string]map[string]string), errs: make(map[string]error)}
+}
+
+// add a topic with a number of partitions and replicas
+func (a *fakeTopicAdmin) addTopic(name string, partitions int, replicas int, configs map[string]string) {
+	t := kafka.Topic{Name: name}
+	for p := range partitions {
+		t.Partitions = append(t.Partitions, kafka.Partition{Topic: name, ID: p, Replicas: make([]kafka.Broker, replicas)})
+	}
+	a.topics[name] = t
+	a.configs[name] = configs
+}
+
+func (a *fakeTopicAdmin) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	res := &kafka.MetadataResponse{}
+	if len(req.Topics) == 0 {
+		for _, t := range a.topics {
+			res.Topics = append(res.Topics, t)
+		}
+		return res, nil
+	}
+	for _, name := range req.Topics {
+		t, ok := a.topics[name]
+		if !ok {
+			t = kafka.Topic{Name: name, Error: kafka.UnknownTopicOrPartition}
+		}
+		res.Topics = append(res.Topics, t)
+	}
+	return res, nil
+}
+
+func (a *fakeTopicAdmin) CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error) {
+	res := &kafka.CreateTopicsResponse{Errors: make(map[string]error)}
+	for _, tc := range req.Topics {
+		if err := a.errs[tc.Topic]; err != nil {
+			res.Errors[tc.Topic] = err
+			continue
+		}
+		configs := make(map[string]string)
+		for _, e := range tc.ConfigEntries {
+			configs[e.ConfigName] = e.ConfigValue
+		}
+		a.addTopic(tc.Topic, tc.NumPartitions, tc.ReplicationFactor, configs)
+		a.created = append(a.created, tc)
+	}
+	return res, nil
+}
+
+func (a *fakeTopicAdmin) CreatePartitions(ctx context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error) {
+	res := &kafka.CreatePartitionsResponse{Errors: make(map[string]error)}
+	for _, tc := range req.Topics {
+		if err := a.errs[tc.Name]; err != nil {
+			res.Errors[tc.Name] = err
+			continue
+		}
+		t := a.topics[tc.Name]
+		a.addTopic(tc.Name, int(tc.Count), len(t.Partitions[0].Replicas), a.configs[tc.Name])
+	}
+	return res, nil
+}
+
+func (a *fakeTopicAdmin) DescribeConfigs(ctx context.Context, req *kafka.DescribeConfigsRequest) (*kafka.DescribeConfigsResponse, error) {
+	res := &kafka.DescribeConfigsResponse{}
+	for _, r := range req.Resources {
+		resource := kafka.DescribeConfigResponseResource{ResourceName: r.ResourceName}
+		for _, name := range r.ConfigNames {
+			resource.ConfigEntries = append(resource.ConfigEntries, kafka.DescribeConfigResponseConfigEntry{ConfigName: name, ConfigValue: a.configs[r.ResourceName][name]})
+		}
+		res.Resources = append(res.Resources, resource)
+	}
+	return res, nil
+}
+
+func (a *fakeTopicAdmin) IncrementalAlterConfigs(ctx context.Context, req *kafka.IncrementalAlterConfigsRequest) (*kafka.IncrementalAlterConfigsResponse, error) {
+	res := &kafka.IncrementalAlterConfigsResponse{}
+	for _, r := range req.Resources {
+		resource := kafka.IncrementalAlterConfigsResponseResource{ResourceName: r.ResourceName, Error: a.errs[r.ResourceName]}
+		if resource.Error == nil {
+			for _, c := range r.Configs {
+				a.configs[r.ResourceName][c.Name] = c.Value
+			}
+			a.altered = append(a.altered, r)
+		}
+		res.Resources = append(res.Resources, resource)
+	}
+	return res, nil
+}
+
+func testTopicSpec(name string, partitions int) TopicSpec {
+	return TopicSpec{Name: name, Partitions: partitions, ReplicationFactor: 3, RetentionMs: 86400000, CleanupPolicy: "delete", MinInsyncReplicas: 2}
+}
+
+func TestEnsureTopics_CreatesMissingTopics(t *testing.T) {
+	admin := newFakeTopicAdmin()
+	report, err := EnsureTopics(context.Background(), admin, testTopicSpec("users", 3), testTopicSpec("orders", 5))
+	if err != nil {
+		t.Fatalf("EnsureTopics failed: %v", err)
+	}
+
+	if fmt.Sprint(report.Created) != "[users orders]" || len(report.Changed) != 0 || report.HasDrift() {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	users := admin.created[0]
+	if users.NumPartitions != 3 || users.ReplicationFactor != 3 || fmt.Sprint(users.ConfigEntries) != "[{cleanup.policy delete} {min.insync.replicas 2} {retention.ms 86400000}]" {
+		t.Errorf("unexpected topic config: %+v", users)
+	}
+
+	// provisioning again changes nothing
+	if report, err := EnsureTopics(context.Background(), admin, testTopicSpec("users", 3), testTopicSpec("orders", 5)); err != nil || len(report.Created)+len(report.Changed) != 0 {
+		t.Errorf("expected no changes, got %+v, %v", report, err)
+	}
+	if len(admin.altered) != 0 {
+		t.Errorf("expected no altered configs, got %v", admin.altered)
+	}
+}
+
+func TestEnsureTopics_UpdatesExistingTopics(t *testing.T) {
+	admin := newFakeTopicAdmin()
+	admin.addTopic("users", 2, 3, map[string]string{"retention.ms": "604800000", "cleanup.policy": "delete", "min.insync.replicas": "1"})
+
+	report, err := EnsureTopics(context.Background(), admin, testTopicSpec("users", 3))
+	if err != nil {
+		t.Fatalf("EnsureTopics failed: %v", err)
+	}
+
+	want := "[users partitions: 2 -> 3 users min.insync.replicas: 1 -> 2 users retention.ms: 604800000 -> 86400000]"
+	if fmt.Sprint(report.Changed) != want || len(report.Created) != 0 || report.HasDrift() {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if n := len(admin.topics["users"].Partitions); n != 3 {
+		t.Errorf("expected 3 partitions, got %d", n)
+	}
+	if admin.configs["users"]["min.insync.replicas"] != "2" || admin.configs["users"]["retention.ms"] != "86400000" {
+		t.Errorf("unexpected configs: %v", admin.configs["users"])
+	}
+}
+
+func TestEnsureTopics_ReportsDrift(t *testing.T) {
+	admin := newFakeTopicAdmin()
+	admin.addTopic("orders", 8, 1, map[string]string{"retention.ms": "86400000", "cleanup.policy": "delete", "min.insync.replicas": "2"})
+
+	report, err := EnsureTopics(context.Background(), admin, testTopicSpec("orders", 5))
+	if err != nil {
+		t.Fatalf("EnsureTopics failed: %v", err)
+	}
+	if fmt.Sprint(report.Drift) != "[orders partitions: 8 -> 5 orders replication.factor: 1 -> 3]" || len(report.Changed) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if n := len(admin.topics["orders"].Partitions); n != 8 {
+		t.Errorf("expected the partitions to be unchanged, got %d", n)
+	}
+}
+
+func TestEnsureTopics_Errors(t *testing.T) {
+	admin := newFakeTopicAdmin()
+	admin.addTopic("orders", 5, 3, map[string]string{"retention.ms": "1"})
+	admin.errs["users"] = kafka.PolicyViolation
+	admin.errs["orders"] = kafka.TopicAuthorizationFailed
+
+	// errors of topics are returned (instead of panics) after provisioning the others
+	report, err := EnsureTopics(context.Background(), admin, testTopicSpec("users", 3), testTopicSpec("orders", 5), testTopicSpec("products", 1))
+	if !errors.Is(err, kafka.PolicyViolation) || !errors.Is(err, kafka.TopicAuthorizationFailed) {
+		t.Errorf("expected the errors of both topics, got %v", err)
+	}
+	if fmt.Sprint(report.Created) != "[products]" {
+		t.Errorf("unexpected created topics: %v", report.Created)
+	}
+
+	invalid := testTopicSpec("users", 3)
+	invalid.MinInsyncReplicas = 4
+	if _, err := EnsureTopics(context.Background(), admin, invalid); err == nil {
+		t.Errorf("expected an error for an invalid spec")
+	}
+	if _, err := EnsureTopics(context.Background(), admin, TopicSpec{Name: "users"}); err == nil {
+		t.Errorf("expected an error for a spec without partitions")
+	}
+}
@@ -12,7 +12,8 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-func TopicExists(topic string, brokers ...string) bool {
+// TopicExists checks if a (non internal) topic exists in the cluster
+func TopicExists(topic string, brokers ...string) (bool, error) {
 
 	kafkaClient := &kafka.Client{
 		Addr:    kafka.TCP(brokers...),
@@ -22,20 +23,17 @@ func TopicExists(topic string, brokers ...string) bool {
 	// Get cluster metadata
 	clusterInfo, err := kafkaClient.Metadata(context.Background(), &kafka.MetadataRequest{})
 	if err != nil {
-		panic(fmt.Sprintf("failed to connect to Kafka cluster: %v\n", err))
+		return false, fmt.Errorf("failed to connect to Kafka cluster: %w", err)
 	}
 
 	logger.DebugLogger.Printf("Kafka cluster controller found: %v\n", clusterInfo.Controller)
 
-	topics := make(map[string]bool)
 	for _, t := range clusterInfo.Topics {
-		if !t.Internal {
-			topics[t.Name] = true
+		if !t.Internal && t.Name == topic {
+			return true, nil
 		}
 	}
-
-	// Check if the topic exists in the map
-	return topics[topic]
+	return false, nil
 }
 
 func CreateTopic(broker string, topic string, partitions int, replicationFactor int) error {
@@ -74,7 +72,7 @@ func CreateTopic(broker string, topic string, partitions int, replicationFactor
 	return nil
 }
 
-func WriteWithRetry(writer *kafka.Writer, topic string, msgBatch []kafka.Message, maxAttempts int, backOffTimeout int) {
+func WriteWithRetry(writer MessageWriter, topic string, msgBatch []kafka.Message, maxAttempts int, backOffTimeout int) {
 	writeSuccess := false
 	var err error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
@@ -0,0 +1,82 @@
+package lagwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// Alert is fired when a rule of a consumer group is crossed for the sustained period, and again when it's resolved
+type Alert struct {
+	Group     string    `json:"group"`
+	Rule      string    `json:"rule"`  // RuleLag or RuleLagGrowth
+	Value     float64   `json:"value"` // lag (in messages) or growth rate (in messages per second)
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"` // when the threshold was first crossed
+	Time      time.Time `json:"time"`
+	Resolved  bool      `json:"resolved"`
+}
+
+func (a Alert) String() string {
+	if a.Resolved {
+		return fmt.Sprintf("%s of group '%s' resolved (%.1f <= %.1f)", a.Rule, a.Group, a.Value, a.Threshold)
+	}
+	return fmt.Sprintf("%s of group '%s' is %.1f > %.1f since %s", a.Rule, a.Group, a.Value, a.Threshold, a.Since.Format(time.RFC3339))
+}
+
+// AlertHook is notified of fired alerts
+type AlertHook interface {
+	Fire(a Alert) error
+}
+
+// LogHook logs the alerts (firing ones as errors)
+type LogHook struct{}
+
+func (LogHook) Fire(a Alert) error {
+	if a.Resolved {
+		logger.InfoLogger.Printf("ALERT %s\n", a)
+	} else {
+		logger.ErrorLogger.Printf("ALERT %s\n", a)
+	}
+	return nil
+}
+
+// WebhookHook POSTs the alerts as JSON to a URL
+type WebhookHook struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookHook(url string, timeout time.Duration) *WebhookHook {
+	return &WebhookHook{URL: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *WebhookHook) Fire(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // drain the body to reuse the connection
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status of webhook %s: %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package lagwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// Handler serves the lag of the last poll as Prometheus metrics (/metrics) and as JSON (/lag)
+func (w *Watcher) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", w.serveMetrics)
+	mux.HandleFunc("GET /lag", w.serveLag)
+	return mux
+}
+
+func (w *Watcher) serveLag(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(w.Snapshot()); err != nil {
+		logger.ErrorLogger.Printf("Failed to write the lag response: %v\n", err)
+	}
+}
+
+func (w *Watcher) serveMetrics(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	s, polls, pollErrors := w.snapshot, w.polls, w.pollErrors
+	w.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(rw, s, polls, pollErrors)
+}
+
+// writeMetrics writes a snapshot in the Prometheus text format
+func writeMetrics(out io.Writer, s Snapshot, polls int64, pollErrors int64) {
+	header := func(name, typ, help string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	partitionGauges := []struct {
+		name  string
+		help  string
+		value func(p PartitionStatus) int64
+	}{
+		{"cdc_consumer_group_lag", "Messages of a partition not consumed yet by a consumer group.", func(p PartitionStatus) int64 { return p.Lag }},
+		{"cdc_consumer_group_committed_offset", "Committed offset of a consumer group on a partition (-1 if none).", func(p PartitionStatus) int64 { return p.Committed }},
+		{"cdc_consumer_group_end_offset", "Offset of the next message written to a partition.", func(p PartitionStatus) int64 { return p.End }},
+	}
+	for _, m := range partitionGauges {
+		header(m.name, "gauge", m.help)
+		for _, g := range s.Groups {
+			for _, p := range g.Partitions {
+				fmt.Fprintf(out, "%s{group=\"%s\",topic=\"%s\",partition=\"%d\"} %d\n", m.name, escapeLabel(g.Group), escapeLabel(p.Topic), p.Partition, m.value(p))
+			}
+		}
+	}
+
+	header("cdc_consumer_group_lag_total", "gauge", "Messages not consumed yet by a consumer group over all partitions.")
+	for _, g := range s.Groups {
+		fmt.Fprintf(out, "cdc_consumer_group_lag_total{group=\"%s\"} %d\n", escapeLabel(g.Group), g.Lag)
+	}
+	header("cdc_consumer_group_lag_growth_rate", "gauge", "Messages per second the lag of a consumer group grew since the previous poll.")
+	for _, g := range s.Groups {
+		fmt.Fprintf(out, "cdc_consumer_group_lag_growth_rate{group=\"%s\"} %g\n", escapeLabel(g.Group), g.GrowthRate)
+	}
+	header("cdc_consumer_group_lag_alert", "gauge", "1 if an alert rule of a consumer group is firing.")
+	for _, g := range s.Groups {
+		for _, rule := range []string{RuleLag, RuleLagGrowth} {
+			firing := 0
+			for _, a := range g.Alerts {
+				if a == rule {
+					firing = 1
+				}
+			}
+			fmt.Fprintf(out, "cdc_consumer_group_lag_alert{group=\"%s\",rule=\"%s\"} %d\n", escapeLabel(g.Group), rule, firing)
+		}
+	}
+
+	header("cdc_lagwatch_polls_total", "counter", "Lag computations of the consumer groups.")
+	fmt.Fprintf(out, "cdc_lagwatch_polls_total %d\n", polls)
+	header("cdc_lagwatch_poll_errors_total", "counter", "Lag computations that failed (for some groups at least).")
+	fmt.Fprintf(out, "cdc_lagwatch_poll_errors_total %d\n", pollErrors)
+	if !s.Time.IsZero() {
+		header("cdc_lagwatch_last_poll_timestamp_seconds", "gauge", "Time of the last lag computation.")
+		fmt.Fprintf(out, "cdc_lagwatch_last_poll_timestamp_seconds %d\n", s.Time.Unix())
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,54 @@
+package lagwatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	source := newFakeSource()
+	source.lags["g1"] = 150
+	w, _ := newTestWatcher(source, Config{MaxLag: 100})
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := rec.Body.String()
+	for _, line := range []string{
+		`cdc_consumer_group_lag{group="g1",topic="users",partition="0"} 150`,
+		`cdc_consumer_group_committed_offset{group="g1",topic="users",partition="0"} 100`,
+		`cdc_consumer_group_end_offset{group="g1",topic="users",partition="0"} 250`,
+		`cdc_consumer_group_lag_total{group="g1"} 150`,
+		`cdc_consumer_group_lag_alert{group="g1",rule="lag"} 1`,
+		`cdc_consumer_group_lag_alert{group="g1",rule="lag_growth"} 0`,
+		`cdc_lagwatch_polls_total 1`,
+		`cdc_lagwatch_poll_errors_total 0`,
+		"# TYPE cdc_consumer_group_lag gauge",
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("expected metric line %q in:\n%s", line, metrics)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/lag", nil))
+	var s Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !s.Time.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || len(s.Groups) != 1 || s.Groups[0].Partitions[0].End != 250 || s.Groups[0].Alerts[0] != RuleLag {
+		t.Errorf("unexpected snapshot: %s", rec.Body.String())
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaped label: %s", got)
+	}
+}
@@ -0,0 +1,280 @@
+package lagwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// rules of the alerts
+const (
+	RuleLag       = "lag"        // the lag of a group is above Config.MaxLag
+	RuleLagGrowth = "lag_growth" // the lag of a group grows faster than Config.MaxGrowthRate
+)
+
+// LagSource lists consumer groups and computes their lag
+type LagSource interface {
+	Groups(ctx context.Context) ([]string, error)
+	GroupLag(ctx context.Context, group string) ([]kafkautils.PartitionLag, error)
+}
+
+type adminSource struct {
+	admin    kafkautils.Admin
+	patterns []string
+}
+
+// NewAdminSource returns a LagSource of the groups matching the patterns (path.Match), computing their lag from the
+// committed offsets (OffsetFetch) and the end offsets (ListOffsets) of their partitions
+func NewAdminSource(admin kafkautils.Admin, patterns ...string) LagSource {
+	return &adminSource{admin: admin, patterns: patterns}
+}
+
+func (s *adminSource) Groups(ctx context.Context) ([]string, error) {
+	return kafkautils.ListGroups(ctx, s.admin, s.patterns...)
+}
+
+func (s *adminSource) GroupLag(ctx context.Context, group string) ([]kafkautils.PartitionLag, error) {
+	return kafkautils.GroupLag(ctx, s.admin, group)
+}
+
+// Config configures the thresholds of the alerts
+type Config struct {
+	MaxLag        int64         // messages a group may be behind (0 = no alert)
+	MaxGrowthRate float64       // messages per second the lag of a group may grow (0 = no alert)
+	SustainedFor  time.Duration // a threshold must be crossed this long (in consecutive polls) to fire an alert
+}
+
+// Snapshot is the lag of the consumer groups computed by a poll
+type Snapshot struct {
+	Time   time.Time     `json:"time"`
+	Groups []GroupStatus `json:"groups"`
+	Error  string        `json:"error,omitempty"` // of the poll (the groups that failed are missing)
+}
+
+// GroupStatus is the lag of a consumer group
+type GroupStatus struct {
+	Group      string            `json:"group"`
+	Lag        int64             `json:"lag"`         // over all partitions
+	GrowthRate float64           `json:"growth_rate"` // messages per second since the previous poll
+	Alerts     []string          `json:"alerts"`      // rules of the firing alerts
+	Partitions []PartitionStatus `json:"partitions"`
+}
+
+// PartitionStatus is the lag of a consumer group on a partition
+type PartitionStatus struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Committed int64  `json:"committed"` // -1 if the group didn't commit an offset
+	End       int64  `json:"end"`
+	Lag       int64  `json:"lag"`
+}
+
+// Watcher periodically computes the lag of consumer groups, keeps the last snapshot for the HTTP endpoints and fires
+// alerts to the hooks when a threshold is crossed for a sustained period (and again when it's resolved)
+type Watcher struct {
+	source LagSource
+	cfg    Config
+	hooks  []AlertHook
+	now    func() time.Time
+
+	mu         sync.Mutex
+	snapshot   Snapshot
+	polls      int64
+	pollErrors int64
+	last       map[string]lagSample // previous lag of each group
+	alerts     map[alertKey]*alertState
+}
+
+type lagSample struct {
+	lag  int64
+	time time.Time
+}
+
+type alertKey struct {
+	group string
+	rule  string
+}
+
+type alertState struct {
+	since  time.Time // when the threshold was first crossed (in consecutive polls)
+	firing bool
+}
+
+func NewWatcher(source LagSource, cfg Config, hooks ...AlertHook) *Watcher {
+	return &Watcher{
+		source: source,
+		cfg:    cfg,
+		hooks:  hooks,
+		now:    time.Now,
+		last:   make(map[string]lagSample),
+		alerts: make(map[alertKey]*alertState),
+	}
+}
+
+// Run polls every interval until the context is done
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Poll(ctx); err != nil {
+			logger.ErrorLogger.Printf("Failed to compute the lag of the consumer groups: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll computes the lag of the groups and fires the alerts crossing (or no longer crossing) their thresholds. Errors
+// of single groups are joined and returned after the lag of the other groups is computed.
+func (w *Watcher) Poll(ctx context.Context) error {
+	now := w.now()
+	groups, err := w.source.Groups(ctx)
+	if err != nil {
+		w.failPoll(now, err)
+		return fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+
+	snapshot := Snapshot{Time: now, Groups: []GroupStatus{}}
+	var errs []error
+	for _, group := range groups {
+		lags, err := w.source.GroupLag(ctx, group)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		status := GroupStatus{Group: group, Alerts: []string{}, Partitions: []PartitionStatus{}}
+		for _, l := range lags {
+			status.Lag += l.Lag
+			status.Partitions = append(status.Partitions, PartitionStatus{Topic: l.Topic, Partition: l.Partition, Committed: l.Committed, End: l.End, Lag: l.Lag})
+		}
+		snapshot.Groups = append(snapshot.Groups, status)
+	}
+	err = errors.Join(errs...)
+	if err != nil {
+		snapshot.Error = err.Error()
+	}
+
+	w.mu.Lock()
+	fired := w.evaluateLocked(&snapshot)
+	w.snapshot = snapshot
+	w.polls++
+	if err != nil {
+		w.pollErrors++
+	}
+	w.mu.Unlock()
+
+	for _, a := range fired {
+		w.fire(a)
+	}
+	return err
+}
+
+// keep the previous snapshot (with the error) if the groups can't be listed
+func (w *Watcher) failPoll(now time.Time, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.polls++
+	w.pollErrors++
+	w.snapshot.Error = err.Error()
+}
+
+// evaluateLocked sets the growth rates and firing alerts of the groups of a new snapshot, returning the alerts to fire
+// (w.mu must be held)
+func (w *Watcher) evaluateLocked(s *Snapshot) []Alert {
+	var fired []Alert
+	seen := make(map[string]bool)
+	for i := range s.Groups {
+		g := &s.Groups[i]
+		seen[g.Group] = true
+		if prev, ok := w.last[g.Group]; ok && s.Time.After(prev.time) {
+			g.GrowthRate = float64(g.Lag-prev.lag) / s.Time.Sub(prev.time).Seconds()
+		}
+		w.last[g.Group] = lagSample{g.Lag, s.Time}
+
+		rules := []struct {
+			rule      string
+			value     float64
+			threshold float64
+		}{
+			{RuleLag, float64(g.Lag), float64(w.cfg.MaxLag)},
+			{RuleLagGrowth, g.GrowthRate, w.cfg.MaxGrowthRate},
+		}
+		for _, r := range rules {
+			if r.threshold <= 0 {
+				continue
+			}
+			if a, ok := w.evaluateRuleLocked(g.Group, r.rule, r.value, r.threshold, s.Time); ok {
+				fired = append(fired, a)
+			}
+			if w.alerts[alertKey{g.Group, r.rule}].firing {
+				g.Alerts = append(g.Alerts, r.rule)
+			}
+		}
+	}
+
+	// alerts of groups that no longer exist (or failed to be polled) are kept until the group is seen again
+	for key := range w.last {
+		if !seen[key] && s.Error == "" {
+			delete(w.last, key)
+			for _, rule := range []string{RuleLag, RuleLagGrowth} {
+				if st, ok := w.alerts[alertKey{key, rule}]; ok && st.firing {
+					fired = append(fired, Alert{Group: key, Rule: rule, Since: st.since, Time: s.Time, Resolved: true})
+				}
+				delete(w.alerts, alertKey{key, rule})
+			}
+		}
+	}
+	return fired
+}
+
+// evaluateRuleLocked updates the state of a rule of a group, returning the alert to fire if the rule started firing or
+// was resolved
+func (w *Watcher) evaluateRuleLocked(group string, rule string, value float64, threshold float64, now time.Time) (Alert, bool) {
+	key := alertKey{group, rule}
+	st, ok := w.alerts[key]
+	if !ok {
+		st = &alertState{}
+		w.alerts[key] = st
+	}
+	alert := Alert{Group: group, Rule: rule, Value: value, Threshold: threshold, Time: now}
+
+	if value <= threshold {
+		alert.Since, alert.Resolved = st.since, true
+		wasFiring := st.firing
+		*st = alertState{}
+		return alert, wasFiring
+	}
+	if st.since.IsZero() {
+		st.since = now
+	}
+	alert.Since = st.since
+	if !st.firing && now.Sub(st.since) >= w.cfg.SustainedFor {
+		st.firing = true
+		return alert, true
+	}
+	return alert, false
+}
+
+// fire sends an alert to all hooks (a failed hook doesn't stop the others)
+func (w *Watcher) fire(a Alert) {
+	for _, h := range w.hooks {
+		if err := h.Fire(a); err != nil {
+			logger.ErrorLogger.Printf("Failed to fire alert %s: %v\n", a, err)
+		}
+	}
+}
+
+// Snapshot returns the snapshot of the last poll
+func (w *Watcher) Snapshot() Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.snapshot
+}
@@ -0,0 +1,157 @@
+package lagwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+)
+
+// fakeSource is a LagSource returning the lag set by the tests (on a single partition per group)
+type fakeSource struct {
+	lags map[string]int64
+	errs map[string]error
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{lags: make(map[string]int64), errs: make(map[string]error)}
+}
+
+func (s *fakeSource) Groups(ctx context.Context) ([]string, error) {
+	var groups []string
+	for _, g := range []string{"g1", "g2"} {
+		if _, ok := s.lags[g]; ok {
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+func (s *fakeSource) GroupLag(ctx context.Context, group string) ([]kafkautils.PartitionLag, error) {
+	if err := s.errs[group]; err != nil {
+		return nil, err
+	}
+	lag := s.lags[group]
+	return []kafkautils.PartitionLag{{TopicPartition: kafkautils.TopicPartition{Topic: "users", Partition: 0}, Committed: 100, End: 100 + lag, Lag: lag}}, nil
+}
+
+// recordingHook records the fired alerts
+type recordingHook struct {
+	alerts []Alert
+}
+
+func (h *recordingHook) Fire(a Alert) error {
+	h.alerts = append(h.alerts, a)
+	return nil
+}
+
+// a watcher whose clock is advanced by the tests
+func newTestWatcher(source LagSource, cfg Config, hooks ...AlertHook) (*Watcher, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWatcher(source, cfg, hooks...)
+	w.now = func() time.Time { return now }
+	return w, &now
+}
+
+func TestWatcher_FiresSustainedLagAlerts(t *testing.T) {
+	source := newFakeSource()
+	hook := &recordingHook{}
+	w, now := newTestWatcher(source, Config{MaxLag: 100, SustainedFor: 2 * time.Minute}, hook)
+
+	// the lag must stay above the threshold for 2 minutes, the alert fires once and then resolves
+	for i, lag := range []int64{150, 160, 170, 180, 50, 60} {
+		source.lags["g1"] = lag
+		if err := w.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		if i == 3 && fmt.Sprint(w.Snapshot().Groups[0].Alerts) != "[lag]" {
+			t.Errorf("expected the lag alert to be firing, got %v", w.Snapshot().Groups[0].Alerts)
+		}
+		*now = now.Add(time.Minute)
+	}
+
+	if len(hook.alerts) != 2 {
+		t.Fatalf("expected a firing and a resolved alert, got %v", hook.alerts)
+	}
+	fired, resolved := hook.alerts[0], hook.alerts[1]
+	if fired.Rule != RuleLag || fired.Resolved || fired.Value != 170 || !fired.Since.Equal(w.Snapshot().Time.Add(-5*time.Minute)) {
+		t.Errorf("unexpected firing alert: %+v", fired)
+	}
+	if !resolved.Resolved || resolved.Value != 50 {
+		t.Errorf("unexpected resolved alert: %+v", resolved)
+	}
+}
+
+func TestWatcher_FiresLagGrowthAlerts(t *testing.T) {
+	source := newFakeSource()
+	hook := &recordingHook{}
+	w, now := newTestWatcher(source, Config{MaxGrowthRate: 5}, hook)
+
+	// a lag below the threshold growing fast for a short spike
+	source.lags["g1"], source.lags["g2"] = 0, 0
+	for _, lag := range []int64{600, 600} {
+		if err := w.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		*now = now.Add(time.Minute)
+		source.lags["g1"] = lag
+	}
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	if len(hook.alerts) != 2 || hook.alerts[0].Rule != RuleLagGrowth || hook.alerts[0].Value != 10 || !hook.alerts[1].Resolved {
+		t.Errorf("expected a firing and resolved lag growth alert of 10 messages/s, got %v", hook.alerts)
+	}
+	if g := w.Snapshot().Groups; g[0].GrowthRate != 0 || len(g[0].Alerts) != 0 || g[1].GrowthRate != 0 {
+		t.Errorf("unexpected status: %+v", g)
+	}
+}
+
+func TestWatcher_GroupErrors(t *testing.T) {
+	source := newFakeSource()
+	source.lags["g1"], source.lags["g2"] = 10, 20
+	source.errs["g1"] = errors.New("coordinator not available")
+	w, _ := newTestWatcher(source, Config{})
+
+	// the lag of the other groups is computed
+	if err := w.Poll(context.Background()); err == nil {
+		t.Errorf("expected the error of g1")
+	}
+	s := w.Snapshot()
+	if len(s.Groups) != 1 || s.Groups[0].Group != "g2" || s.Groups[0].Lag != 20 || s.Error != "coordinator not available" {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	var received Alert
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid alert body: %v", err)
+		}
+		rw.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	hook := NewWebhookHook(srv.URL, time.Second)
+	alert := Alert{Group: "g1", Rule: RuleLag, Value: 150, Threshold: 100, Since: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := hook.Fire(alert); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if received != alert {
+		t.Errorf("expected %+v, received %+v", alert, received)
+	}
+
+	status = http.StatusInternalServerError
+	if err := hook.Fire(alert); err == nil {
+		t.Errorf("expected an error for status 500")
+	}
+}
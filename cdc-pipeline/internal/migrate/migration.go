@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a numbered change of a store's schema, read from a pair of files
+// <version>_<name>.up.<ext> and <version>_<name>.down.<ext> (the down script is optional)
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // empty if the migration can't be rolled back
+	Checksum string // sha256 of the up script, recorded when the migration is applied
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.([a-z]+)$`)
+
+// LoadMigrations reads the migrations of a directory with the given file extension (e.g. "sql"), ordered by version
+func LoadMigrations(dir string, ext string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != "."+ext {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: invalid migration file name (expected <version>_<name>.up|down.%s)", e.Name(), ext)
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%s: invalid version", e.Name())
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is already used by migration '%s'", e.Name(), version, m.Name)
+		}
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SplitStatements splits a script into its statements (separated by semicolons),
+// ignoring semicolons in comments, quoted strings and $$ blocks
+func SplitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	flush := func() {
+		// skip statements consisting of comments only
+		if stmt := strings.TrimSpace(cur.String()); !onlyComments(stmt) {
+			stmts = append(stmts, stmt)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(script); {
+		rest := script[i:]
+		n := 1 // length of the token starting at i
+		switch {
+		case strings.HasPrefix(rest, "--"), strings.HasPrefix(rest, "//"):
+			n = tokenLen(rest, 2, "\n")
+		case strings.HasPrefix(rest, "/*"):
+			n = tokenLen(rest, 2, "*/")
+		case strings.HasPrefix(rest, "$$"):
+			n = tokenLen(rest, 2, "$$")
+		case rest[0] == '\'' || rest[0] == '"':
+			n = tokenLen(rest, 1, rest[:1])
+		case rest[0] == ';':
+			flush()
+			i++
+			continue
+		}
+		cur.WriteString(rest[:n])
+		i += n
+	}
+	flush()
+	return stmts
+}
+
+// length of a token from its opening delimiter (of length open) to its closing delimiter, or to the end of the script
+func tokenLen(s string, open int, close string) int {
+	end := strings.Index(s[open:], close)
+	if end < 0 {
+		return len(s)
+	}
+	return open + end + len(close)
+}
+
+func onlyComments(stmt string) bool {
+	for {
+		start := strings.Index(stmt, "/*")
+		if start < 0 {
+			break
+		}
+		stmt = stmt[:start] + stmt[start+tokenLen(stmt[start:], 2, "*/"):]
+	}
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "//") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// ErrLocked is returned when another run is migrating the store
+var ErrLocked = errors.New("migrations are locked by another run")
+
+// AppliedMigration is a migration recorded in the schema_version table of a store
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Store is a database whose schema is migrated (implemented for Postgres and Cassandra)
+type Store interface {
+	Name() string
+	// Lock prevents concurrent migrations of the store (failing with ErrLocked) and creates its schema_version table
+	Lock(ctx context.Context) (unlock func(), err error)
+	// Applied returns the applied migrations (none if the schema_version table doesn't exist yet)
+	Applied(ctx context.Context) ([]AppliedMigration, error)
+	// Apply runs the up (or down) script of a migration and records (or removes) the migration in the schema_version table
+	Apply(ctx context.Context, m Migration, up bool) error
+}
+
+// ChecksumError reports an applied migration whose up script was changed since
+type ChecksumError struct {
+	Version  int
+	Name     string
+	Applied  string // checksum recorded when the migration was applied
+	Expected string // checksum of the migration file
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("migration %d_%s was changed after it was applied (checksum %s, applied %s)", e.Version, e.Name, e.Expected, e.Applied)
+}
+
+// Status is the state of a migration of a store
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Changed   bool // the up script was changed after the migration was applied
+	Missing   bool // the migration was applied, but its files don't exist anymore
+}
+
+// Migrator applies and rolls back the migrations of a store
+type Migrator struct {
+	store      Store
+	migrations []Migration
+	dryRun     bool      // print the scripts instead of running them
+	out        io.Writer // output of a dry run
+}
+
+// NewMigrator returns a Migrator of a store's migrations (ordered by version, see LoadMigrations)
+func NewMigrator(store Store, migrations []Migration, dryRun bool, out io.Writer) *Migrator {
+	return &Migrator{store: store, migrations: migrations, dryRun: dryRun, out: out}
+}
+
+// Status returns the state of every migration, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.store.Applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.store.Name(), err)
+	}
+	byVersion := make(map[int]AppliedMigration)
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := byVersion[mig.Version]; ok {
+			s.Applied, s.AppliedAt, s.Changed = true, a.AppliedAt, a.Checksum != mig.Checksum
+			delete(byVersion, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			statuses = append(statuses, Status{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: a.AppliedAt, Missing: true})
+		}
+	}
+	sortStatuses(statuses)
+	return statuses, nil
+}
+
+// Verify checks that the applied migrations weren't changed (or removed) since they were applied
+func (m *Migrator) Verify(ctx context.Context) error {
+	applied, err := m.store.Applied(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.store.Name(), err)
+	}
+	return m.verify(applied)
+}
+
+func (m *Migrator) verify(applied []AppliedMigration) error {
+	var errs []error
+	for _, a := range applied {
+		mig, ok := m.find(a.Version)
+		if !ok {
+			errs = append(errs, fmt.Errorf("applied migration %d_%s doesn't exist", a.Version, a.Name))
+		} else if mig.Checksum != a.Checksum {
+			errs = append(errs, &ChecksumError{Version: a.Version, Name: a.Name, Applied: a.Checksum, Expected: mig.Checksum})
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", m.store.Name(), err)
+	}
+	return nil
+}
+
+// Up applies the pending migrations up to (and including) the target version (all if target is 0),
+// returning the number of applied migrations
+func (m *Migrator) Up(ctx context.Context, target int) (int, error) {
+	applied, unlock, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	current := 0
+	done := make(map[int]bool)
+	for _, a := range applied {
+		current = max(current, a.Version)
+		done[a.Version] = true
+	}
+
+	n := 0
+	for _, mig := range m.migrations {
+		if done[mig.Version] || (target > 0 && mig.Version > target) {
+			continue
+		}
+		// a migration older than the schema version was added after newer ones were applied
+		if mig.Version < current {
+			return n, fmt.Errorf("%s: migration %d_%s is older than the schema version %d, renumber it", m.store.Name(), mig.Version, mig.Name, current)
+		}
+		if err := m.apply(ctx, mig, true); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Down rolls back the applied migrations newer than the target version (0 rolls back all),
+// returning the number of rolled back migrations
+func (m *Migrator) Down(ctx context.Context, target int) (int, error) {
+	applied, unlock, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	n := 0
+	for i := len(applied) - 1; i >= 0 && applied[i].Version > target; i-- {
+		mig, _ := m.find(applied[i].Version) // verified to exist
+		if mig.Down == "" {
+			return n, fmt.Errorf("%s: migration %d_%s can't be rolled back (no down script)", m.store.Name(), mig.Version, mig.Name)
+		}
+		if err := m.apply(ctx, mig, false); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// lock the store (unless it's a dry run) and read and verify its applied migrations
+func (m *Migrator) begin(ctx context.Context) ([]AppliedMigration, func(), error) {
+	unlock := func() {}
+	if !m.dryRun {
+		var err error
+		if unlock, err = m.store.Lock(ctx); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", m.store.Name(), err)
+		}
+	}
+
+	applied, err := m.store.Applied(ctx)
+	if err == nil {
+		err = m.verify(applied)
+	} else {
+		err = fmt.Errorf("%s: %w", m.store.Name(), err)
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	sortApplied(applied)
+	return applied, unlock, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration, up bool) error {
+	direction, script := "up", mig.Up
+	if !up {
+		direction, script = "down", mig.Down
+	}
+
+	if m.dryRun {
+		fmt.Fprintf(m.out, "-- %s: %d_%s (%s)\n", m.store.Name(), mig.Version, mig.Name, direction)
+		for _, stmt := range SplitStatements(script) {
+			fmt.Fprintf(m.out, "%s;\n\n", stmt)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	if err := m.store.Apply(ctx, mig, up); err != nil {
+		return fmt.Errorf("%s: migration %d_%s (%s): %w", m.store.Name(), mig.Version, mig.Name, direction, err)
+	}
+	logger.InfoLogger.Printf("%s: migrated %d_%s (%s) in %v\n", m.store.Name(), mig.Version, mig.Name, direction, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func sortApplied(applied []AppliedMigration) {
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Version < applied[j].Version })
+}
+
+func sortStatuses(statuses []Status) {
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+}
@@ -0,0 +1,261 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStore records the applied migrations and the scripts it ran
+type fakeStore struct {
+	applied []AppliedMigration
+	scripts []string
+	locked  bool
+	failOn  int // version whose migration fails
+}
+
+func (s *fakeStore) Name() string { return "fake" }
+
+func (s *fakeStore) Lock(ctx context.Context) (func(), error) {
+	if s.locked {
+		return nil, ErrLocked
+	}
+	s.locked = true
+	return func() { s.locked = false }, nil
+}
+
+func (s *fakeStore) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	return append([]AppliedMigration(nil), s.applied...), nil
+}
+
+func (s *fakeStore) Apply(ctx context.Context, m Migration, up bool) error {
+	if m.Version == s.failOn {
+		return errors.New("syntax error")
+	}
+	if up {
+		s.scripts = append(s.scripts, m.Up)
+		s.applied = append(s.applied, AppliedMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()})
+		return nil
+	}
+	s.scripts = append(s.scripts, m.Down)
+	for i, a := range s.applied {
+		if a.Version == m.Version {
+			s.applied = append(s.applied[:i], s.applied[i+1:]...)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) versions() []int {
+	var versions []int
+	for _, a := range s.applied {
+		versions = append(versions, a.Version)
+	}
+	return versions
+}
+
+// write migration files to a temporary directory
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testMigrations(t *testing.T) []Migration {
+	t.Helper()
+	dir := writeMigrations(t, map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id UUID PRIMARY KEY);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email TEXT;",
+		"0002_add_email.down.sql":    "ALTER TABLE users DROP COLUMN email;",
+		"0003_backfill.up.sql":       "UPDATE users SET email = '';", // irreversible
+		"README.md":                  "ignored",
+	})
+	migrations, err := LoadMigrations(dir, "sql")
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+	return migrations
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations := testMigrations(t)
+
+	if len(migrations) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(migrations))
+	}
+	for i, want := range []string{"create_users", "add_email", "backfill"} {
+		if migrations[i].Version != i+1 || migrations[i].Name != want || len(migrations[i].Checksum) != 64 {
+			t.Errorf("unexpected migration %d: %+v", i, migrations[i])
+		}
+	}
+	if migrations[1].Down != "ALTER TABLE users DROP COLUMN email;" || migrations[2].Down != "" {
+		t.Errorf("unexpected down scripts: %q, %q", migrations[1].Down, migrations[2].Down)
+	}
+
+	invalid := []map[string]string{
+		{"create_users.up.sql": "CREATE TABLE users ();"},
+		{"0001_a.up.sql": "SELECT 1;", "0001_b.up.sql": "SELECT 2;"},
+		{"0001_a.down.sql": "SELECT 1;"},
+	}
+	for _, files := range invalid {
+		if _, err := LoadMigrations(writeMigrations(t, files), "sql"); err == nil {
+			t.Errorf("expected an error loading %v", files)
+		}
+	}
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	store := &fakeStore{}
+	m := NewMigrator(store, testMigrations(t), false, nil)
+	ctx := context.Background()
+
+	if n, err := m.Up(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("Up(2) = %d, %v", n, err)
+	}
+	if n, err := m.Up(ctx, 0); err != nil || n != 1 {
+		t.Fatalf("Up(0) = %d, %v", n, err)
+	}
+	if n, err := m.Up(ctx, 0); err != nil || n != 0 {
+		t.Fatalf("expected nothing to apply, got %d, %v", n, err)
+	}
+	if !reflect.DeepEqual(store.versions(), []int{1, 2, 3}) || store.locked {
+		t.Fatalf("unexpected applied versions %v (locked=%t)", store.versions(), store.locked)
+	}
+
+	// migration 3 has no down script
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "can't be rolled back") {
+		t.Fatalf("expected an irreversible migration error, got %v", err)
+	}
+
+	store.applied = store.applied[:2]
+	if n, err := m.Down(ctx, 0); err != nil || n != 2 {
+		t.Fatalf("Down(0) = %d, %v", n, err)
+	}
+	if len(store.applied) != 0 || store.scripts[len(store.scripts)-1] != "DROP TABLE users;" {
+		t.Errorf("expected the migrations to be rolled back in reverse order, got %v", store.scripts)
+	}
+}
+
+func TestMigrator_Failure(t *testing.T) {
+	store := &fakeStore{failOn: 2}
+	m := NewMigrator(store, testMigrations(t), false, nil)
+
+	n, err := m.Up(context.Background(), 0)
+	if err == nil || n != 1 {
+		t.Fatalf("expected the second migration to fail, got %d, %v", n, err)
+	}
+	if !reflect.DeepEqual(store.versions(), []int{1}) || store.locked {
+		t.Errorf("unexpected applied versions %v (locked=%t)", store.versions(), store.locked)
+	}
+}
+
+func TestMigrator_Locked(t *testing.T) {
+	store := &fakeStore{locked: true}
+	m := NewMigrator(store, testMigrations(t), false, nil)
+
+	if _, err := m.Up(context.Background(), 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(store.applied) != 0 {
+		t.Errorf("expected no migration to be applied, got %v", store.versions())
+	}
+}
+
+func TestMigrator_Checksums(t *testing.T) {
+	migrations := testMigrations(t)
+	store := &fakeStore{applied: []AppliedMigration{
+		{Version: 1, Name: "create_users", Checksum: migrations[0].Checksum},
+		{Version: 2, Name: "add_email", Checksum: "changed"},
+	}}
+	m := NewMigrator(store, migrations, false, nil)
+
+	err := m.Verify(context.Background())
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Version != 2 {
+		t.Fatalf("expected a checksum error of migration 2, got %v", err)
+	}
+	if _, err := m.Up(context.Background(), 0); !errors.As(err, &checksumErr) {
+		t.Fatalf("expected Up to fail verification, got %v", err)
+	}
+	if len(store.scripts) != 0 {
+		t.Errorf("expected no migration to run, got %v", store.scripts)
+	}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(statuses) != 3 || !statuses[0].Applied || statuses[0].Changed || !statuses[1].Changed || statuses[2].Applied {
+		t.Errorf("unexpected statuses: %+v", statuses)
+	}
+
+	// an applied migration without files
+	store.applied = append(store.applied, AppliedMigration{Version: 7, Name: "removed"})
+	if statuses, _ := m.Status(context.Background()); len(statuses) != 4 || !statuses[3].Missing {
+		t.Errorf("expected migration 7 to be missing, got %+v", statuses)
+	}
+}
+
+func TestMigrator_OutOfOrder(t *testing.T) {
+	migrations := testMigrations(t)
+	store := &fakeStore{applied: []AppliedMigration{
+		{Version: 1, Name: "create_users", Checksum: migrations[0].Checksum},
+		{Version: 3, Name: "backfill", Checksum: migrations[2].Checksum},
+	}}
+
+	if _, err := NewMigrator(store, migrations, false, nil).Up(context.Background(), 0); err == nil {
+		t.Fatalf("expected an error for migration 2 older than the schema version")
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	store := &fakeStore{locked: true} // a dry run doesn't lock
+	out := &bytes.Buffer{}
+	m := NewMigrator(store, testMigrations(t), true, out)
+
+	if n, err := m.Up(context.Background(), 2); err != nil || n != 2 {
+		t.Fatalf("Up(2) = %d, %v", n, err)
+	}
+	if len(store.applied) != 0 {
+		t.Errorf("expected a dry run not to apply migrations, got %v", store.versions())
+	}
+	want := "-- fake: 1_create_users (up)\nCREATE TABLE users (id UUID PRIMARY KEY);\n\n" +
+		"-- fake: 2_add_email (up)\nALTER TABLE users ADD COLUMN email TEXT;\n\n"
+	if out.String() != want {
+		t.Errorf("unexpected dry run output:\n%s", out.String())
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"single", "CREATE TABLE t (id int PRIMARY KEY)", []string{"CREATE TABLE t (id int PRIMARY KEY)"}},
+		{"multiple", "DROP TABLE a;\nDROP TABLE b;\n", []string{"DROP TABLE a", "DROP TABLE b"}},
+		{"comments", "-- drop a; and b\nDROP TABLE a; // done;\n/* ; */", []string{"-- drop a; and b\nDROP TABLE a"}},
+		{"strings", "INSERT INTO t (s) VALUES ('a;b');INSERT INTO t (s) VALUES ('it''s')", []string{"INSERT INTO t (s) VALUES ('a;b')", "INSERT INTO t (s) VALUES ('it''s')"}},
+		{"dollar quoted", "CREATE FUNCTION f() RETURNS void AS $$ BEGIN NULL; END; $$ LANGUAGE plpgsql;", []string{"CREATE FUNCTION f() RETURNS void AS $$ BEGIN NULL; END; $$ LANGUAGE plpgsql"}},
+		{"comment only", "-- nothing to do\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+)
+
+// check that the stores satisfy the interface
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*CassandraStore)(nil)
+)
+
+// key of the Postgres advisory lock held while migrating
+const postgresLockKey int64 = 0x6364636d6967 // "cdcmig"
+
+// PostgresStore is the Store of a Postgres database
+type PostgresStore struct {
+	conn *pgx.Conn
+}
+
+// NewPostgresStore returns the Store of the connection's database (the lock is held by the connection's session)
+func NewPostgresStore(conn *pgx.Conn) *PostgresStore {
+	return &PostgresStore{conn: conn}
+}
+
+func (s *PostgresStore) Name() string { return "postgres" }
+
+func (s *PostgresStore) Lock(ctx context.Context) (func(), error) {
+	var locked bool
+	if err := s.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", postgresLockKey).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrLocked
+	}
+	unlock := func() {
+		s.conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", postgresLockKey)
+	}
+
+	_, err := s.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("create schema_version table: %w", err)
+	}
+	return unlock, nil
+}
+
+func (s *PostgresStore) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	var exists bool
+	if err := s.conn.QueryRow(ctx, "SELECT to_regclass('schema_version') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := s.conn.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_version ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// Apply runs the script and records the migration in one transaction (Postgres DDL is transactional)
+func (s *PostgresStore) Apply(ctx context.Context, m Migration, up bool) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	// without arguments, the script is sent with the simple protocol, which allows multiple statements
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, "INSERT INTO schema_version (version, name, checksum) VALUES ($1, $2, $3)", m.Version, m.Name, m.Checksum)
+	} else {
+		_, err = tx.Exec(ctx, "DELETE FROM schema_version WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// CassandraStore is the Store of a Cassandra keyspace
+type CassandraStore struct {
+	cluster     gocql.ClusterConfig
+	keyspace    string
+	replication string // replication of the keyspace if it's created, e.g. "{'class': 'SimpleStrategy', 'replication_factor': '3'}"
+	lockTTL     time.Duration
+	owner       string         // identifies the run holding the lock
+	admin       *gocql.Session // not bound to the keyspace (which may not exist yet)
+	sess        *gocql.Session // bound to the keyspace, for the unqualified table names of the migrations
+}
+
+// NewCassandraStore connects to a cluster and returns the Store of a keyspace, which is created when the store is locked.
+// The lock expires after lockTTL, so the lock of a crashed run doesn't block later runs forever.
+func NewCassandraStore(cluster *gocql.ClusterConfig, keyspace string, replication string, lockTTL time.Duration) (*CassandraStore, error) {
+	s := &CassandraStore{cluster: *cluster, keyspace: keyspace, replication: replication, lockTTL: lockTTL}
+	s.cluster.Keyspace = ""
+
+	host, _ := os.Hostname()
+	s.owner = fmt.Sprintf("%s/%d", host, os.Getpid())
+
+	var err error
+	if s.admin, err = s.cluster.CreateSession(); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	return s, nil
+}
+
+func (s *CassandraStore) Close() {
+	if s.sess != nil {
+		s.sess.Close()
+	}
+	s.admin.Close()
+}
+
+func (s *CassandraStore) Name() string { return "cassandra" }
+
+func (s *CassandraStore) table(name string) string {
+	return s.keyspace + "." + name
+}
+
+func (s *CassandraStore) Lock(ctx context.Context) (func(), error) {
+	stmts := []string{
+		fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s", s.keyspace, s.replication),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (name text PRIMARY KEY, owner text, acquired_at timestamp)", s.table("schema_migration_lock")),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version int PRIMARY KEY, name text, checksum text, applied_at timestamp)", s.table("schema_version")),
+	}
+	for _, stmt := range stmts {
+		if err := s.admin.Query(stmt).WithContext(ctx).Exec(); err != nil {
+			return nil, err
+		}
+	}
+
+	// lightweight transaction, so only one run inserts the lock
+	holder := make(map[string]interface{})
+	applied, err := s.admin.Query(
+		fmt.Sprintf("INSERT INTO %s (name, owner, acquired_at) VALUES ('migrate', ?, ?) IF NOT EXISTS USING TTL ?", s.table("schema_migration_lock")),
+		s.owner, time.Now(), int(s.lockTTL.Seconds()),
+	).WithContext(ctx).Consistency(gocql.Quorum).MapScanCAS(holder)
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock: %w", err)
+	}
+	if !applied {
+		return nil, fmt.Errorf("%w (%v since %v)", ErrLocked, holder["owner"], holder["acquired_at"])
+	}
+
+	return func() {
+		s.admin.Query(fmt.Sprintf("DELETE FROM %s WHERE name = 'migrate' IF owner = ?", s.table("schema_migration_lock")), s.owner).
+			Consistency(gocql.Quorum).MapScanCAS(make(map[string]interface{}))
+	}, nil
+}
+
+func (s *CassandraStore) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	var n int
+	err := s.admin.Query("SELECT count(*) FROM system_schema.tables WHERE keyspace_name = ? AND table_name = 'schema_version'", s.keyspace).
+		WithContext(ctx).Scan(&n)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	var applied []AppliedMigration
+	var a AppliedMigration
+	iter := s.admin.Query(fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", s.table("schema_version"))).WithContext(ctx).Iter()
+	for iter.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt) {
+		applied = append(applied, a)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	sortApplied(applied)
+	return applied, nil
+}
+
+// Apply runs the statements of the script one by one. Cassandra has no transactions, so a failed migration may be
+// partially applied and is run again from its first statement (i.e. its statements should be idempotent, e.g. IF NOT EXISTS).
+func (s *CassandraStore) Apply(ctx context.Context, m Migration, up bool) error {
+	if s.sess == nil {
+		cluster := s.cluster
+		cluster.Keyspace = s.keyspace
+		sess, err := cluster.CreateSession()
+		if err != nil {
+			return fmt.Errorf("create session of keyspace %s: %w", s.keyspace, err)
+		}
+		s.sess = sess
+	}
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	for _, stmt := range SplitStatements(script) {
+		if err := s.sess.Query(stmt).WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("%s: %w", firstLine(stmt), err)
+		}
+	}
+
+	var err error
+	if up {
+		err = s.admin.Query(fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)", s.table("schema_version")),
+			m.Version, m.Name, m.Checksum, time.Now()).WithContext(ctx).Exec()
+	} else {
+		err = s.admin.Query(fmt.Sprintf("DELETE FROM %s WHERE version = ?", s.table("schema_version")), m.Version).WithContext(ctx).Exec()
+	}
+	if err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+	return nil
+}
+
+// first line of a statement (without comments), to identify it in errors
+func firstLine(stmt string) string {
+	for _, line := range strings.Split(stmt, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "//") {
+			return line
+		}
+	}
+	return stmt
+}
@@ -4,9 +4,28 @@ type JsonMap = map[string]any // a map of strings with value of any types (used
 
 // ChangeEvent is a small normalized representation of a Debezium change event
 type ChangeEvent struct {
-	Op   string  // operation: "c","u" "d","r" (read snapshot) - only "c" and "u" changes are relevant
-	Row  JsonMap // the data row after the change (nil for deletes)
-	TsMs int64   // event timestamp ms
+	Op     string  // operation: "c","u" "d","r" (read snapshot) - only "c" and "u" changes are relevant
+	Row    JsonMap // the data row after the change (nil for deletes)
+	Before JsonMap // the data row before the change (nil for creates; only the primary key for deletes with default replica identity)
+	TsMs   int64   // event timestamp ms
 	// EventID is a stable unique id for this change (derived from source metadata)
 	EventID string
+	LSN     int64 // postgres log sequence number of the change (0 if absent in the source metadata)
+	TxID    int64 // postgres transaction id of the change (0 if absent in the source metadata)
+
+	// Columns describes the columns of Row (derived from the Debezium schema, nil if the message has no schema)
+	Columns []Column
+
+	// kafka coordinates of the message carrying this event (set by the consumer, not the parser)
+	Partition int
+	Offset    int64
+}
+
+// Column describes a column of a change event row as declared in the Debezium (kafka connect) schema
+type Column struct {
+	Name     string
+	Type     string            // connect type: "int8", "int16", "int32", "int64", "float", "double", "boolean", "string" or "bytes"
+	Logical  string            // logical type name (e.g. "io.debezium.time.Date", "org.apache.kafka.connect.data.Decimal"), if any
+	Optional bool              // true if the column is nullable
+	Params   map[string]string // logical type parameters (e.g. "scale" for decimals)
 }
@@ -9,18 +9,19 @@ import (
 
 // ParseDebeziumEvent parses a Debezium JSON envelope (value) into model.ChangeEvent object.
 // The project uses JSON converter (no Avro/schema-registry).
+// Messages that can't be parsed are poison messages (i.e. the error matches ErrPoison).
 func ParseDebeziumEvent(value []byte) (*model.ChangeEvent, error) {
 	var envelope model.JsonMap
 	if err := json.Unmarshal(value, &envelope); err != nil {
-		return nil, err
+		return nil, Poison(err)
 	}
 	payloadRaw, ok := envelope["payload"]
 	if !ok || payloadRaw == nil {
-		return nil, fmt.Errorf("missing payload in debezium message")
+		return nil, Poison(fmt.Errorf("missing payload in debezium message"))
 	}
 	payload, ok := payloadRaw.(model.JsonMap) // check if payloadRaw is a valid json and load it as a map
 	if !ok {
-		return nil, fmt.Errorf("invalid payload format")
+		return nil, Poison(fmt.Errorf("invalid payload format"))
 	}
 
 	ev := &model.ChangeEvent{}
@@ -31,7 +32,15 @@ func ParseDebeziumEvent(value []byte) (*model.ChangeEvent, error) {
 	if after, ok := payload["after"].(model.JsonMap); ok {
 		ev.Row = after
 	}
-	ev.TsMs = ParseDebeziumNumber[int64](payload["ts_ms"])
+	if before, ok := payload["before"].(model.JsonMap); ok {
+		ev.Before = before
+	}
+	var err error
+	if !Row(payload).IsNull("ts_ms") {
+		if ev.TsMs, err = Row(payload).Int64("ts_ms"); err != nil {
+			return nil, err
+		}
+	}
 
 	eventID, err := constructEventID(payload, ev.TsMs)
 	if err != nil {
@@ -39,16 +48,66 @@ func ParseDebeziumEvent(value []byte) (*model.ChangeEvent, error) {
 	}
 	ev.EventID = eventID
 
+	// source is guaranteed to be present by constructEventID
+	source := Row(payload["source"].(model.JsonMap))
+	if !source.IsNull("lsn") {
+		if ev.LSN, err = source.Int64("lsn"); err != nil {
+			return nil, err
+		}
+	}
+	if txID, ok := source["txId"].(float64); ok { // (the id is only used as part of the EventID if it's not a number)
+		ev.TxID = int64(txID)
+	}
+
+	// schema is only present if the connector's JSON converter has schemas enabled
+	if schema, ok := envelope["schema"].(model.JsonMap); ok {
+		ev.Columns = parseRowColumns(schema)
+	}
+
 	return ev, nil
 }
 
+// extract the column definitions of the "after" row from the envelope schema
+func parseRowColumns(schema model.JsonMap) []model.Column {
+	fields, _ := schema["fields"].([]any)
+	for _, f := range fields {
+		field, ok := f.(model.JsonMap)
+		if !ok || field["field"] != "after" {
+			continue
+		}
+
+		rowFields, _ := field["fields"].([]any)
+		columns := make([]model.Column, 0, len(rowFields))
+		for _, rf := range rowFields {
+			rowField, ok := rf.(model.JsonMap)
+			if !ok {
+				continue
+			}
+			col := model.Column{}
+			col.Name, _ = rowField["field"].(string)
+			col.Type, _ = rowField["type"].(string)
+			col.Logical, _ = rowField["name"].(string)
+			col.Optional, _ = rowField["optional"].(bool)
+			if params, ok := rowField["parameters"].(model.JsonMap); ok {
+				col.Params = make(map[string]string, len(params))
+				for k, v := range params {
+					col.Params[k], _ = v.(string)
+				}
+			}
+			columns = append(columns, col)
+		}
+		return columns
+	}
+	return nil
+}
+
 // Build a stable EventID using common identifiers in the source fields (txId, lsn and ts_us)
 func constructEventID(payload model.JsonMap, tsMs int64) (string, error) {
 
 	// extract source field from the payload
 	source, ok := payload["source"].(model.JsonMap)
 	if !ok || source == nil {
-		return "", fmt.Errorf("missing 'source' field in the payload")
+		return "", Poison(fmt.Errorf("missing 'source' field in the payload"))
 	}
 
 	var idParts []any
@@ -72,20 +131,3 @@ func constructEventID(payload model.JsonMap, tsMs int64) (string, error) {
 	idBytes, _ := json.Marshal(idParts)
 	return string(idBytes), nil
 }
-
-// convert debezium number (i.e. an int value) based on its type and return as type T
-func ParseDebeziumNumber[T int | int32 | int64](numberStr interface{}) T {
-	// use type switch to infer correct type of the json value
-	switch v := numberStr.(type) {
-	case float64:
-		return T(v)
-	case int:
-		return T(v)
-	case int32:
-		return T(v)
-	case int64:
-		return T(v)
-	default:
-		panic("ParseDebeziumNumber: unsupported numeric type")
-	}
-}
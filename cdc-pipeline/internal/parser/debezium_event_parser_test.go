@@ -43,3 +43,65 @@ func TestParseDebeziumEvent_BasicParsing(t *testing.T) {
 		t.Errorf("EventID should not be empty")
 	}
 }
+
+func TestParseDebeziumEvent_SchemaAndLSN(t *testing.T) {
+	event := []byte(
+		`{
+			"schema": {
+				"type": "struct",
+				"fields": [
+					{"type": "struct", "optional": true, "field": "before", "fields": []},
+					{
+						"type": "struct", "optional": true, "field": "after",
+						"fields": [
+							{"type": "string", "optional": false, "name": "io.debezium.data.Uuid", "field": "id"},
+							{"type": "bytes", "optional": false, "name": "org.apache.kafka.connect.data.Decimal",
+							 "parameters": {"scale": "2", "connect.decimal.precision": "10"}, "field": "total_amount"},
+							{"type": "boolean", "optional": true, "field": "is_deleted"}
+						]
+					}
+				]
+			},
+			"payload": {
+				"op": "c",
+				"after": {"id": "28822318-1dde-4cf6-b9d3-62dec8def32c", "total_amount": "J0Q=", "is_deleted": false},
+				"ts_ms": 1690000000000,
+				"source": {"txId": 780, "lsn": 27034688, "ts_us": 1690000000000123}
+			}
+		}`)
+
+	ce, err := ParseDebeziumEvent(event)
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+
+	if ce.LSN != 27034688 || ce.TxID != 780 {
+		t.Errorf("expected LSN 27034688 and txId 780, got %d and %d", ce.LSN, ce.TxID)
+	}
+	if len(ce.Columns) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(ce.Columns))
+	}
+
+	dec := ce.Columns[1]
+	if dec.Name != "total_amount" || dec.Type != "bytes" || dec.Logical != "org.apache.kafka.connect.data.Decimal" || dec.Optional {
+		t.Errorf("unexpected decimal column: %+v", dec)
+	}
+	if dec.Params["scale"] != "2" {
+		t.Errorf("expected scale parameter 2, got %q", dec.Params["scale"])
+	}
+	if !ce.Columns[2].Optional {
+		t.Errorf("expected is_deleted column to be optional")
+	}
+}
+
+func TestParseDebeziumEvent_NoSchema(t *testing.T) {
+	event := []byte(`{"payload": {"op": "u", "after": {"id": "x"}, "ts_ms": 1, "source": {"ts_us": 1000}}}`)
+
+	ce, err := ParseDebeziumEvent(event)
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	if ce.Columns != nil || ce.LSN != 0 || ce.TxID != 0 {
+		t.Errorf("expected no columns, LSN and txId, got %+v, %d and %d", ce.Columns, ce.LSN, ce.TxID)
+	}
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+var (
+	uuidType = reflect.TypeOf(gocql.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+	decType  = reflect.TypeOf((*inf.Dec)(nil))
+)
+
+// DecodeRow decodes a change event row (e.g. ev.Row) into a struct, whose fields are mapped to the row's fields with
+// `debezium` tags (fields without a tag are ignored):
+//
+//	type OrderRow struct {
+//		ID         gocql.UUID `debezium:"id"`
+//		Total      *inf.Dec   `debezium:"total_amount,scale=2,required"`
+//		PlacedAt   time.Time  `debezium:"placed_at"`
+//		ModifiedAt *time.Time `debezium:"modified_at"`
+//	}
+//
+// The supported field types are strings, booleans, int, int32 and int64 (and types based on them), gocql.UUID,
+// time.Time (an RFC3339 timestamp, or a date with the `date` option) and *inf.Dec (a decimal with the `scale` option).
+// Pointer fields are nullable, i.e. nil if the row's field is null or missing, unless they have the `required` option.
+// A field that can't be read fails with a *FieldError (a poison message error, see Row).
+func DecodeRow[T any](row model.JsonMap) (T, error) {
+	var v T
+	fields, err := rowFieldsOf(reflect.TypeOf(v))
+	if err != nil {
+		return v, err
+	}
+
+	rv := reflect.ValueOf(&v).Elem()
+	r := Row(row)
+	for _, f := range fields {
+		if err := f.decode(r, rv.Field(f.index)); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// rowField is a struct field mapped to a row's field
+type rowField struct {
+	index    int
+	name     string // name of the row's field
+	date     bool   // time.Time of a date (days since the epoch)
+	scale    int    // scale of a decimal
+	required bool   // a pointer field must not be null
+}
+
+// decoded fields by struct type
+var rowFieldsCache sync.Map
+
+func rowFieldsOf(t reflect.Type) ([]rowField, error) {
+	if cached, ok := rowFieldsCache.Load(t); ok {
+		return cached.([]rowField), nil
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parser: can't decode a row into %v (not a struct)", t)
+	}
+
+	var fields []rowField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("debezium")
+		if !ok || tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("parser: field %s of %v is not exported", sf.Name, t)
+		}
+
+		parts := strings.Split(tag, ",")
+		f := rowField{index: i, name: parts[0]}
+		for _, opt := range parts[1:] {
+			switch {
+			case opt == "date":
+				f.date = true
+			case opt == "required":
+				f.required = true
+			case strings.HasPrefix(opt, "scale="):
+				scale, err := strconv.Atoi(strings.TrimPrefix(opt, "scale="))
+				if err != nil {
+					return nil, fmt.Errorf("parser: invalid scale of field %s of %v: %w", sf.Name, t, err)
+				}
+				f.scale = scale
+			default:
+				return nil, fmt.Errorf("parser: unknown option '%s' of field %s of %v", opt, sf.Name, t)
+			}
+		}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		if !supportedType(sf.Type) {
+			return nil, fmt.Errorf("parser: unsupported type %v of field %s of %v", sf.Type, sf.Name, t)
+		}
+		fields = append(fields, f)
+	}
+
+	rowFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func supportedType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer && t != decType {
+		t = t.Elem()
+	}
+	switch t {
+	case uuidType, timeType, decType:
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func (f *rowField) decode(r Row, dest reflect.Value) error {
+	t := dest.Type()
+	nullable := t.Kind() == reflect.Pointer
+	if nullable && !f.required && r.IsNull(f.name) {
+		return nil // leave nil
+	}
+	if nullable && t != decType {
+		ptr := reflect.New(t.Elem())
+		if err := f.decode(r, ptr.Elem()); err != nil {
+			return err
+		}
+		dest.Set(ptr)
+		return nil
+	}
+
+	var v any
+	var err error
+	switch t {
+	case uuidType:
+		v, err = r.UUID(f.name)
+	case timeType:
+		if f.date {
+			v, err = r.Date(f.name)
+		} else {
+			v, err = r.Timestamp(f.name)
+		}
+	case decType:
+		v, err = r.Decimal(f.name, f.scale)
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			v, err = r.String(f.name)
+		case reflect.Bool:
+			v, err = r.Bool(f.name)
+		case reflect.Int:
+			v, err = r.Int(f.name)
+		case reflect.Int32:
+			v, err = r.Int32(f.name)
+		case reflect.Int64:
+			v, err = r.Int64(f.name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	dest.Set(reflect.ValueOf(v).Convert(t))
+	return nil
+}
+
+// Required returns the value of a nullable field of a decoded row, failing with a *FieldError if it's nil
+func Required[T any](field string, v *T) (T, error) {
+	if v == nil {
+		var zero T
+		return zero, &FieldError{Field: field, Err: ErrNullField}
+	}
+	return *v, nil
+}
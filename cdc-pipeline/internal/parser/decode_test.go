@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+type testStatus string
+
+type testOrderRow struct {
+	ID         gocql.UUID  `debezium:"id"`
+	Status     testStatus  `debezium:"status"`
+	Quantity   int         `debezium:"quantity"`
+	LSN        int64       `debezium:"lsn"`
+	Total      *inf.Dec    `debezium:"total_amount,scale=2,required"`
+	Discount   *inf.Dec    `debezium:"discount,scale=2"`
+	DOB        time.Time   `debezium:"dob,date"`
+	PlacedAt   time.Time   `debezium:"placed_at"`
+	ModifiedAt *time.Time  `debezium:"modified_at"`
+	IsDeleted  *bool       `debezium:"is_deleted"`
+	Note       *string     `debezium:"note"`
+	UserID     *gocql.UUID `debezium:"user_id"`
+	Ignored    string
+}
+
+func testOrderJSONRow() model.JsonMap {
+	return model.JsonMap{
+		"id":           "eed38f7e-fea3-46b4-9536-89a3b1cba1f8",
+		"status":       "PLACED",
+		"quantity":     float64(2),
+		"lsn":          float64(1234),
+		"total_amount": "J0Q=",
+		"discount":     nil,
+		"dob":          float64(11172),
+		"placed_at":    "2025-08-28T16:02:58.281604Z",
+		"modified_at":  "2025-08-29T10:00:00Z",
+		"is_deleted":   true,
+		"user_id":      "da0859fb-8eeb-44cd-97f5-df0db4f7a2c3",
+		"Ignored":      "not decoded",
+	}
+}
+
+func TestDecodeRow(t *testing.T) {
+	o, err := DecodeRow[testOrderRow](testOrderJSONRow())
+	if err != nil {
+		t.Fatalf("DecodeRow failed: %v", err)
+	}
+
+	if o.ID.String() != "eed38f7e-fea3-46b4-9536-89a3b1cba1f8" || o.Status != "PLACED" || o.Quantity != 2 || o.LSN != 1234 {
+		t.Errorf("unexpected row: %+v", o)
+	}
+	if o.Total.Cmp(inf.NewDec(10052, 2)) != 0 || o.Discount != nil {
+		t.Errorf("unexpected decimals: %v, %v", o.Total, o.Discount)
+	}
+	if o.DOB.Format("2006-01-02") != "2000-08-03" || !o.PlacedAt.Equal(time.Date(2025, 8, 28, 16, 2, 58, 281604000, time.UTC)) {
+		t.Errorf("unexpected times: %v, %v", o.DOB, o.PlacedAt)
+	}
+	if o.ModifiedAt == nil || !o.ModifiedAt.Equal(time.Date(2025, 8, 29, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected modified_at: %v", o.ModifiedAt)
+	}
+	if o.IsDeleted == nil || !*o.IsDeleted || o.Note != nil || o.UserID == nil || o.UserID.String() != "da0859fb-8eeb-44cd-97f5-df0db4f7a2c3" {
+		t.Errorf("unexpected nullable fields: %v, %v, %v", o.IsDeleted, o.Note, o.UserID)
+	}
+	if o.Ignored != "" {
+		t.Errorf("expected the untagged field to be ignored, got %q", o.Ignored)
+	}
+}
+
+func TestDecodeRow_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(row model.JsonMap)
+		field  string
+		want   error
+	}{
+		{"missing field", func(row model.JsonMap) { delete(row, "status") }, "status", ErrMissingField},
+		{"null field", func(row model.JsonMap) { row["placed_at"] = nil }, "placed_at", ErrNullField},
+		{"null required pointer", func(row model.JsonMap) { row["total_amount"] = nil }, "total_amount", ErrNullField},
+		{"wrong type", func(row model.JsonMap) { row["quantity"] = "2" }, "quantity", ErrWrongType},
+		{"wrong type of pointer", func(row model.JsonMap) { row["is_deleted"] = "yes" }, "is_deleted", ErrWrongType},
+		{"invalid uuid", func(row model.JsonMap) { row["user_id"] = "user-1" }, "user_id", ErrInvalidValue},
+		{"invalid date", func(row model.JsonMap) { row["dob"] = 1.5 }, "dob", ErrInvalidValue},
+		{"invalid timestamp", func(row model.JsonMap) { row["modified_at"] = "yesterday" }, "modified_at", ErrInvalidValue},
+		{"invalid decimal", func(row model.JsonMap) { row["discount"] = "%%" }, "discount", ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := testOrderJSONRow()
+			tt.change(row)
+
+			_, err := DecodeRow[testOrderRow](row)
+			var fieldErr *FieldError
+			if !errors.Is(err, tt.want) || !IsPoison(err) || !errors.As(err, &fieldErr) || fieldErr.Field != tt.field {
+				t.Fatalf("expected a poison %v error of field '%s', got %v", tt.want, tt.field, err)
+			}
+		})
+	}
+}
+
+func TestDecodeRow_InvalidStructs(t *testing.T) {
+	type unsupported struct {
+		Amount float64 `debezium:"amount"`
+	}
+	type unknownOption struct {
+		Amount *inf.Dec `debezium:"amount,precision=10"`
+	}
+	type unexported struct {
+		amount int `debezium:"amount"`
+	}
+
+	row := model.JsonMap{"amount": float64(1)}
+	for _, decode := range []func() error{
+		func() error { _, err := DecodeRow[unsupported](row); return err },
+		func() error { _, err := DecodeRow[unknownOption](row); return err },
+		func() error { _, err := DecodeRow[unexported](row); return err },
+		func() error { _, err := DecodeRow[int](row); return err },
+	} {
+		// a struct that can't be decoded is a bug, not a poison message
+		if err := decode(); err == nil || IsPoison(err) {
+			t.Errorf("expected a (non poison) error, got %v", err)
+		}
+	}
+}
+
+func TestRequired(t *testing.T) {
+	b := true
+	if v, err := Required("is_deleted", &b); err != nil || !v {
+		t.Errorf("Required = %t, %v", v, err)
+	}
+	if _, err := Required[time.Time]("modified_at", nil); !errors.Is(err, ErrNullField) {
+		t.Errorf("expected ErrNullField, got %v", err)
+	}
+
+	// the cached fields of a type are reused
+	first, _ := rowFieldsOf(reflect.TypeOf(testOrderRow{}))
+	second, _ := rowFieldsOf(reflect.TypeOf(testOrderRow{}))
+	if len(first) != 12 || &first[0] != &second[0] {
+		t.Errorf("expected the cached fields to be reused, got %d fields", len(first))
+	}
+}
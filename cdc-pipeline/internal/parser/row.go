@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// ErrPoison marks the errors of malformed messages, which fail every time they are applied.
+// The consumer skips them (instead of retrying), all other errors of applying a change event are transient.
+var ErrPoison = errors.New("poison message")
+
+// errors of reading the fields of a row (see FieldError)
+var (
+	ErrMissingField = errors.New("missing field")
+	ErrNullField    = errors.New("null field")
+	ErrWrongType    = errors.New("wrong type")
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// FieldError reports a field of a change event row that can't be read.
+// It matches ErrPoison and (with errors.Is) one of ErrMissingField, ErrNullField, ErrWrongType or ErrInvalidValue.
+type FieldError struct {
+	Field string
+	Value any // value of the field (nil if it's missing or null)
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field '%s': %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() []error {
+	return []error{ErrPoison, e.Err}
+}
+
+// Poison marks an error as caused by a malformed message
+func Poison(err error) error {
+	if err == nil || errors.Is(err, ErrPoison) {
+		return err
+	}
+	return &poisonError{err}
+}
+
+type poisonError struct {
+	err error
+}
+
+func (e *poisonError) Error() string   { return e.err.Error() }
+func (e *poisonError) Unwrap() []error { return []error{ErrPoison, e.err} }
+
+// IsPoison reports if an error is caused by a malformed message (i.e. applying the message again fails again)
+func IsPoison(err error) bool {
+	return errors.Is(err, ErrPoison)
+}
+
+// Row reads typed values of the fields of a change event row (in Debezium's JSON format), e.g. Row(ev.Row).String("name").
+// The accessors fail with a *FieldError if the field is missing, null or of an unexpected type.
+type Row model.JsonMap
+
+// IsNull reports if a field is null (or missing)
+func (r Row) IsNull(field string) bool {
+	return r[field] == nil
+}
+
+// Get returns the value of a field, which must be present and not null
+func (r Row) Get(field string) (any, error) {
+	v, ok := r[field]
+	if !ok {
+		return nil, &FieldError{Field: field, Err: ErrMissingField}
+	}
+	if v == nil {
+		return nil, &FieldError{Field: field, Err: ErrNullField}
+	}
+	return v, nil
+}
+
+func (r Row) String(field string) (string, error) {
+	v, err := r.Get(field)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &FieldError{Field: field, Value: v, Err: fmt.Errorf("%w: expected a string, got %T", ErrWrongType, v)}
+	}
+	return s, nil
+}
+
+func (r Row) Bool(field string) (bool, error) {
+	v, err := r.Get(field)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &FieldError{Field: field, Value: v, Err: fmt.Errorf("%w: expected a boolean, got %T", ErrWrongType, v)}
+	}
+	return b, nil
+}
+
+func (r Row) Int(field string) (int, error)     { return rowNumber[int](r, field) }
+func (r Row) Int32(field string) (int32, error) { return rowNumber[int32](r, field) }
+func (r Row) Int64(field string) (int64, error) { return rowNumber[int64](r, field) }
+
+func rowNumber[T int | int32 | int64](r Row, field string) (T, error) {
+	v, err := r.Get(field)
+	if err != nil {
+		return 0, err
+	}
+	n, err := ParseDebeziumNumber[T](v)
+	if err != nil {
+		return 0, &FieldError{Field: field, Value: v, Err: err}
+	}
+	return n, nil
+}
+
+// UUID reads an io.debezium.data.Uuid field
+func (r Row) UUID(field string) (gocql.UUID, error) {
+	s, err := r.String(field)
+	if err != nil {
+		return gocql.UUID{}, err
+	}
+	id, err := gocql.ParseUUID(s)
+	if err != nil {
+		return gocql.UUID{}, &FieldError{Field: field, Value: s, Err: fmt.Errorf("%w: %w", ErrInvalidValue, err)}
+	}
+	return id, nil
+}
+
+// Date reads an io.debezium.time.Date field (days since the epoch) as midnight UTC of the day
+func (r Row) Date(field string) (time.Time, error) {
+	days, err := r.Int32(field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return DebeziumDate(days), nil
+}
+
+// Timestamp reads an io.debezium.time.ZonedTimestamp field (an RFC3339 string)
+func (r Row) Timestamp(field string) (time.Time, error) {
+	s, err := r.String(field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, &FieldError{Field: field, Value: s, Err: fmt.Errorf("%w: %w", ErrInvalidValue, err)}
+	}
+	return t, nil
+}
+
+// Decimal reads an org.apache.kafka.connect.data.Decimal field (a base64 encoded unscaled value) with the column's scale
+func (r Row) Decimal(field string, scale int) (*inf.Dec, error) {
+	s, err := r.String(field)
+	if err != nil {
+		return nil, err
+	}
+	unscaled, err := DebeziumDecimalUnscaled(s)
+	if err != nil {
+		return nil, &FieldError{Field: field, Value: s, Err: err}
+	}
+	return inf.NewDecBig(unscaled, inf.Scale(scale)), nil
+}
+
+// ParseDebeziumNumber converts a Debezium (JSON) integer value to type T,
+// failing with ErrWrongType if it's not a number or ErrInvalidValue if it's not an integer of T's range
+func ParseDebeziumNumber[T int | int32 | int64](v any) (T, error) {
+	var n int64
+	switch v := v.(type) {
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %v is not an integer", ErrInvalidValue, v)
+		}
+		n = int64(v)
+	case json.Number:
+		var err error
+		if n, err = v.Int64(); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+		}
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	default:
+		return 0, fmt.Errorf("%w: expected a number, got %T", ErrWrongType, v)
+	}
+
+	if int64(T(n)) != n {
+		return 0, fmt.Errorf("%w: %d overflows %T", ErrInvalidValue, n, T(0))
+	}
+	return T(n), nil
+}
+
+// DebeziumDate converts an io.debezium.time.Date value (days since the epoch) to midnight UTC of the day
+func DebeziumDate(days int32) time.Time {
+	return time.Unix(0, 0).UTC().AddDate(0, 0, int(days))
+}
+
+// DebeziumDecimalUnscaled decodes the unscaled value of a Debezium decimal
+// (the base64 encoded big-endian two's complement bytes of the value)
+func DebeziumDecimalUnscaled(b64 string) (*big.Int, error) {
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	i := new(big.Int)
+	if len(raw) > 0 && raw[0]&0x80 != 0 { // negative number
+		// two's complement conversion
+		tmp := make([]byte, len(raw))
+		for j := range raw {
+			tmp[j] = ^raw[j]
+		}
+		i.SetBytes(tmp)
+		i.Add(i, big.NewInt(1))
+		i.Neg(i)
+	} else {
+		i.SetBytes(raw)
+	}
+	return i, nil
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testRow() Row {
+	return Row{
+		"id":           "28822318-1dde-4cf6-b9d3-62dec8def32c",
+		"name":         "Alice",
+		"dob":          float64(11172),
+		"quantity":     float64(2),
+		"lsn":          json.Number("9007199254740993"),
+		"is_deleted":   false,
+		"created_at":   "2025-08-28T16:02:58.281604Z",
+		"total_amount": "J0Q=", // 100.52
+		"negative":     "2Lw=", // -100.52
+		"email":        nil,
+	}
+}
+
+func TestRow_Accessors(t *testing.T) {
+	r := testRow()
+
+	if id, err := r.UUID("id"); err != nil || id.String() != "28822318-1dde-4cf6-b9d3-62dec8def32c" {
+		t.Errorf("UUID = %v, %v", id, err)
+	}
+	if name, err := r.String("name"); err != nil || name != "Alice" {
+		t.Errorf("String = %q, %v", name, err)
+	}
+	if dob, err := r.Date("dob"); err != nil || dob.Format("2006-01-02") != "2000-08-03" {
+		t.Errorf("Date = %v, %v", dob, err)
+	}
+	if q, err := r.Int("quantity"); err != nil || q != 2 {
+		t.Errorf("Int = %d, %v", q, err)
+	}
+	if lsn, err := r.Int64("lsn"); err != nil || lsn != 9007199254740993 {
+		t.Errorf("Int64 = %d, %v", lsn, err)
+	}
+	if d, err := r.Bool("is_deleted"); err != nil || d {
+		t.Errorf("Bool = %t, %v", d, err)
+	}
+	if ts, err := r.Timestamp("created_at"); err != nil || !ts.Equal(time.Date(2025, 8, 28, 16, 2, 58, 281604000, time.UTC)) {
+		t.Errorf("Timestamp = %v, %v", ts, err)
+	}
+	if dec, err := r.Decimal("total_amount", 2); err != nil || dec.String() != "100.52" {
+		t.Errorf("Decimal = %v, %v", dec, err)
+	}
+	if dec, err := r.Decimal("negative", 2); err != nil || dec.String() != "-100.52" {
+		t.Errorf("Decimal = %v, %v", dec, err)
+	}
+	if !r.IsNull("email") || !r.IsNull("phone") || r.IsNull("name") {
+		t.Errorf("unexpected IsNull results")
+	}
+}
+
+func TestRow_Errors(t *testing.T) {
+	r := testRow()
+	r["ratio"] = 1.5
+	r["big"] = float64(1 << 40)
+	r["bad_id"] = "not-a-uuid"
+	r["bad_amount"] = "not base64!"
+
+	tests := []struct {
+		name  string
+		field string
+		read  func(field string) error
+		want  error
+	}{
+		{"missing", "phone", func(f string) error { _, err := r.String(f); return err }, ErrMissingField},
+		{"null", "email", func(f string) error { _, err := r.String(f); return err }, ErrNullField},
+		{"string of number", "quantity", func(f string) error { _, err := r.String(f); return err }, ErrWrongType},
+		{"number of string", "name", func(f string) error { _, err := r.Int(f); return err }, ErrWrongType},
+		{"bool of string", "name", func(f string) error { _, err := r.Bool(f); return err }, ErrWrongType},
+		{"not an integer", "ratio", func(f string) error { _, err := r.Int64(f); return err }, ErrInvalidValue},
+		{"overflow", "big", func(f string) error { _, err := r.Int32(f); return err }, ErrInvalidValue},
+		{"invalid uuid", "bad_id", func(f string) error { _, err := r.UUID(f); return err }, ErrInvalidValue},
+		{"invalid timestamp", "name", func(f string) error { _, err := r.Timestamp(f); return err }, ErrInvalidValue},
+		{"invalid decimal", "bad_amount", func(f string) error { _, err := r.Decimal(f, 2); return err }, ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.read(tt.field)
+			if !errors.Is(err, tt.want) || !IsPoison(err) {
+				t.Fatalf("expected a poison %v error, got %v", tt.want, err)
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.field {
+				t.Errorf("expected a FieldError of field '%s', got %#v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestParseDebeziumNumber(t *testing.T) {
+	if n, err := ParseDebeziumNumber[int32](float64(-5)); err != nil || n != -5 {
+		t.Errorf("ParseDebeziumNumber[int32](-5) = %d, %v", n, err)
+	}
+	if n, err := ParseDebeziumNumber[int](int64(7)); err != nil || n != 7 {
+		t.Errorf("ParseDebeziumNumber[int](7) = %d, %v", n, err)
+	}
+	if _, err := ParseDebeziumNumber[int64]("7"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected ErrWrongType, got %v", err)
+	}
+	if _, err := ParseDebeziumNumber[int32](int64(1) << 31); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestIsPoison(t *testing.T) {
+	if _, err := ParseDebeziumEvent([]byte(`{"payload": `)); !IsPoison(err) {
+		t.Errorf("expected invalid JSON to be a poison message, got %v", err)
+	}
+	if _, err := ParseDebeziumEvent([]byte(`{"schema": {}}`)); !IsPoison(err) {
+		t.Errorf("expected a message without payload to be a poison message, got %v", err)
+	}
+	if IsPoison(errors.New("connection refused")) || IsPoison(nil) {
+		t.Errorf("expected other errors not to be poison")
+	}
+	if err := Poison(errors.New("bad")); !IsPoison(err) || err.Error() != "bad" {
+		t.Errorf("unexpected Poison error: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+)
+
+// DiffKind is the kind of a difference between Postgres and Cassandra
+type DiffKind string
+
+const (
+	Missing   DiffKind = "missing"   // row in Postgres but not in Cassandra
+	Extra     DiffKind = "extra"     // row in Cassandra but not in Postgres
+	Mismatch  DiffKind = "mismatch"  // different value of a column
+	Precision DiffKind = "precision" // same value with a different precision (e.g. decimal scale or sub-millisecond timestamp)
+)
+
+// Diff is a difference of a row of a Cassandra table
+type Diff struct {
+	Table    string
+	Kind     DiffKind
+	Key      Row    // primary key values
+	Column   string // for mismatches and precision differences
+	Expected any    // postgres value
+	Actual   any    // cassandra value
+
+	row Row // expected row (nil for extra rows)
+}
+
+func (d Diff) String() string {
+	t := LookupTable(d.Table)
+	keys := make([]string, 0, len(t.Key))
+	for _, k := range t.Key {
+		keys = append(keys, fmt.Sprintf("%s=%v", k, d.Key[k]))
+	}
+	s := fmt.Sprintf("%s %s (%s)", d.Table, d.Kind, strings.Join(keys, ", "))
+	if d.Column != "" {
+		kind := t.column(d.Column).kind
+		s += fmt.Sprintf(": %s postgres=%s cassandra=%s", d.Column, formatValue(kind, d.Expected), formatValue(kind, d.Actual))
+	}
+	return s
+}
+
+// TableReport is the result of reconciling a table
+type TableReport struct {
+	Table    string
+	Expected int // rows derived from Postgres
+	Scanned  int // rows read from Cassandra
+	Counts   map[DiffKind]int
+}
+
+// Report is the result of a reconciliation
+type Report struct {
+	Tables []TableReport
+	Diffs  []Diff
+}
+
+// InSync reports if no differences were found
+func (r *Report) InSync() bool {
+	return len(r.Diffs) == 0
+}
+
+// Config configures a Reconciler
+type Config struct {
+	Tables      []string // cassandra tables to reconcile (all Tables if empty)
+	TokenRanges int      // number of token ranges each table is scanned in
+}
+
+// Reconciler compares the rows of the Postgres tables with the rows the cassandra sink derives from them
+type Reconciler struct {
+	pg   PostgresReader
+	cass CassandraStore
+	cfg  Config
+}
+
+// NewReconciler returns a new Reconciler
+func NewReconciler(pg PostgresReader, cass CassandraStore, cfg Config) (*Reconciler, error) {
+	if len(cfg.Tables) == 0 {
+		for _, t := range Tables {
+			cfg.Tables = append(cfg.Tables, t.Name)
+		}
+	}
+	for _, name := range cfg.Tables {
+		if LookupTable(name) == nil {
+			return nil, fmt.Errorf("unknown table %s", name)
+		}
+	}
+	return &Reconciler{pg: pg, cass: cass, cfg: cfg}, nil
+}
+
+// Run reconciles the tables.
+// The expected rows of a table are held in memory while its Cassandra rows are scanned by token range.
+func (r *Reconciler) Run(ctx context.Context) (*Report, error) {
+	report := &Report{}
+	sourceRows := make(map[string][]Row) // postgres table -> rows (read once for orders and orders_by_user)
+
+	for _, name := range r.cfg.Tables {
+		t := LookupTable(name)
+
+		rows, ok := sourceRows[t.Source]
+		if !ok {
+			err := r.pg.ScanTable(ctx, t.Source, func(row Row) error {
+				rows = append(rows, row)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sourceRows[t.Source] = rows
+		}
+
+		tr, diffs, err := r.reconcileTable(ctx, t, rows)
+		if err != nil {
+			return nil, err
+		}
+		report.Tables = append(report.Tables, tr)
+		report.Diffs = append(report.Diffs, diffs...)
+	}
+	return report, nil
+}
+
+func (r *Reconciler) reconcileTable(ctx context.Context, t *Table, sourceRows []Row) (TableReport, []Diff, error) {
+	tr := TableReport{Table: t.Name, Counts: make(map[DiffKind]int)}
+	var diffs []Diff
+
+	expected := make(map[string]Row, len(sourceRows))
+	for _, row := range sourceRows {
+		exp := t.expected(row)
+		expected[t.rowKey(exp)] = exp
+	}
+	tr.Expected = len(expected)
+
+	start := time.Now()
+	for i, tokens := range TokenRanges(r.cfg.TokenRanges) {
+		err := r.cass.ScanRange(ctx, t, tokens[0], tokens[1], func(actual Row) error {
+			tr.Scanned++
+			key := t.rowKey(actual)
+			exp, ok := expected[key]
+			if !ok {
+				diffs = append(diffs, Diff{Table: t.Name, Kind: Extra, Key: t.keyValues(actual)})
+				return nil
+			}
+			delete(expected, key)
+			diffs = append(diffs, t.compareRow(exp, actual)...)
+			return nil
+		})
+		if err != nil {
+			return tr, nil, err
+		}
+		if (i+1)%64 == 0 {
+			logger.DebugLogger.Printf("reconcile %s: scanned %d token ranges (%d rows)\n", t.Name, i+1, tr.Scanned)
+		}
+	}
+
+	// rows that weren't found in any token range
+	for _, exp := range expected {
+		diffs = append(diffs, Diff{Table: t.Name, Kind: Missing, Key: t.keyValues(exp), row: exp})
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return t.rowKey(diffs[i].Key) < t.rowKey(diffs[j].Key)
+	})
+	for _, d := range diffs {
+		tr.Counts[d.Kind]++
+	}
+	logger.InfoLogger.Printf("reconciled %s in %v: %d expected rows, %d cassandra rows, %d differences\n",
+		t.Name, time.Since(start).Round(time.Millisecond), tr.Expected, tr.Scanned, len(diffs))
+	return tr, diffs, nil
+}
+
+// compare the (non key) columns of a row
+func (t *Table) compareRow(expected Row, actual Row) []Diff {
+	var diffs []Diff
+	for _, col := range t.Columns {
+		a := actual[col.Name]
+		if other, ok := t.nullAs[col.Name]; ok && a == nil {
+			a = actual[other]
+		}
+		cmp := compareValues(col.kind, expected[col.Name], a)
+		if cmp == equal {
+			continue
+		}
+		kind := Mismatch
+		if cmp == precisionDiff {
+			kind = Precision
+		}
+		diffs = append(diffs, Diff{
+			Table:    t.Name,
+			Kind:     kind,
+			Key:      t.keyValues(expected),
+			Column:   col.Name,
+			Expected: expected[col.Name],
+			Actual:   actual[col.Name],
+			row:      expected,
+		})
+	}
+	return diffs
+}
+
+func (t *Table) column(name string) Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return Column{Name: name, kind: kindText}
+}
@@ -0,0 +1,253 @@
+package reconcile
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// fakePostgres holds the rows of the Postgres tables
+type fakePostgres map[string][]Row
+
+func (f fakePostgres) ScanTable(ctx context.Context, table string, fn func(Row) error) error {
+	for _, row := range f[table] {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeCassandra holds the rows of the Cassandra tables (by table and key) and applies repair writes
+type fakeCassandra struct {
+	tables map[string]map[string]Row
+	scans  int
+}
+
+func newFakeCassandra() *fakeCassandra {
+	return &fakeCassandra{tables: make(map[string]map[string]Row)}
+}
+
+func (f *fakeCassandra) put(table string, row Row) {
+	if f.tables[table] == nil {
+		f.tables[table] = make(map[string]Row)
+	}
+	f.tables[table][LookupTable(table).rowKey(row)] = row
+}
+
+// stand-in for the Murmur3 token of a partition key
+func fakeToken(t *Table, row Row) int64 {
+	h := fnv.New64a()
+	for _, k := range t.PartitionKey {
+		h.Write([]byte(row[k].(gocql.UUID).String()))
+	}
+	return int64(h.Sum64())
+}
+
+func (f *fakeCassandra) ScanRange(ctx context.Context, t *Table, start int64, end int64, fn func(Row) error) error {
+	f.scans++
+	for _, row := range f.tables[t.Name] {
+		if tok := fakeToken(t, row); tok >= start && tok <= end {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeCassandra) Exec(ctx context.Context, stmt string, values ...any) error {
+	fields := strings.Fields(stmt)
+	t := LookupTable(fields[2])
+	if fields[0] == "DELETE" {
+		key := make(Row)
+		for i, k := range t.Key {
+			key[k] = values[i]
+		}
+		delete(f.tables[t.Name], t.rowKey(key))
+		return nil
+	}
+	row := make(Row)
+	for i, col := range t.Columns {
+		row[col.Name] = values[i]
+	}
+	f.put(t.Name, row)
+	return nil
+}
+
+var (
+	testUserID  = gocql.MustRandomUUID()
+	testOrderID = gocql.MustRandomUUID()
+	testTime    = time.Date(2025, 8, 28, 16, 2, 58, 281604000, time.UTC)
+)
+
+func testPostgres() fakePostgres {
+	return fakePostgres{
+		"users": {{
+			"id": testUserID, "name": "Alice", "dob": time.Date(2000, 8, 3, 0, 0, 0, 0, time.UTC),
+			"created_at": testTime, "modified_at": testTime, "is_deleted": false,
+		}},
+		"orders": {{
+			"id": testOrderID, "user_id": testUserID, "status": "SHIPPED", "quantity": 2, "total_amount": inf.NewDec(10052, 2),
+			"placed_at": testTime, "modified_at": testTime.Add(time.Hour), "is_deleted": false,
+		}},
+	}
+}
+
+// cassandra rows as the sink writes them for testPostgres()
+func testCassandra() *fakeCassandra {
+	ms := testTime.Truncate(time.Millisecond)
+	c := newFakeCassandra()
+	c.put("users", Row{
+		"id": testUserID, "name": "Alice", "dob": time.Date(2000, 8, 3, 0, 0, 0, 0, time.UTC),
+		"created_at": ms, "modified_at": nil, "is_deleted": false, // not updated yet
+	})
+	order := Row{
+		"order_id": testOrderID, "user_id": testUserID, "status": "SHIPPED", "quantity": 2, "total_amount": inf.NewDec(10052, 2),
+		"placed_at": ms, "modified_at": ms.Add(time.Hour), "is_deleted": false,
+	}
+	c.put("orders", order)
+	c.put("orders_by_user", order)
+	return c
+}
+
+func runReconciler(t *testing.T, pg fakePostgres, cass *fakeCassandra) (*Reconciler, *Report) {
+	t.Helper()
+	r, err := NewReconciler(pg, cass, Config{TokenRanges: 16})
+	if err != nil {
+		t.Fatalf("NewReconciler failed: %v", err)
+	}
+	report, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return r, report
+}
+
+func TestReconciler_InSync(t *testing.T) {
+	cass := testCassandra()
+	_, report := runReconciler(t, testPostgres(), cass)
+
+	if !report.InSync() {
+		t.Fatalf("expected no differences, got %v", report.Diffs)
+	}
+	if cass.scans != 3*16 {
+		t.Errorf("expected every table to be scanned in 16 token ranges, got %d scans", cass.scans)
+	}
+	for _, tr := range report.Tables {
+		if tr.Expected != 1 || tr.Scanned != 1 {
+			t.Errorf("expected every row to be scanned once: %+v", tr)
+		}
+	}
+}
+
+func TestReconciler_Differences(t *testing.T) {
+	cass := testCassandra()
+	ms := testTime.Truncate(time.Millisecond)
+
+	// order missing in orders_by_user, different status and amount scale in orders
+	delete(cass.tables["orders_by_user"], LookupTable("orders_by_user").rowKey(Row{"user_id": testUserID, "order_id": testOrderID}))
+	cass.tables["orders"][LookupTable("orders").rowKey(Row{"order_id": testOrderID, "user_id": testUserID})]["status"] = "PLACED"
+	cass.tables["orders"][LookupTable("orders").rowKey(Row{"order_id": testOrderID, "user_id": testUserID})]["total_amount"] = inf.NewDec(100520, 3)
+
+	// row of the user's old name and rounded (instead of truncated) created_at
+	stale := gocql.MustRandomUUID()
+	cass.put("users", Row{"id": stale, "name": "Bob", "created_at": ms, "is_deleted": false})
+	cass.tables["users"][LookupTable("users").rowKey(Row{"id": testUserID, "name": "Alice"})]["created_at"] = testTime.Round(time.Millisecond)
+
+	_, report := runReconciler(t, testPostgres(), cass)
+
+	expected := map[string]bool{
+		"users extra (id=" + stale.String() + ", name=Bob)": true,
+		"users precision (id=" + testUserID.String() + ", name=Alice): created_at postgres='2025-08-28T16:02:58.281604Z' cassandra='2025-08-28T16:02:58.282Z'": true,
+		// modified_at isn't written until the first update, so it's compared with created_at
+		"users precision (id=" + testUserID.String() + ", name=Alice): modified_at postgres='2025-08-28T16:02:58.281604Z' cassandra=null":               true,
+		"orders mismatch (order_id=" + testOrderID.String() + ", user_id=" + testUserID.String() + "): status postgres='SHIPPED' cassandra='PLACED'":    true,
+		"orders precision (order_id=" + testOrderID.String() + ", user_id=" + testUserID.String() + "): total_amount postgres=100.52 cassandra=100.520": true,
+		"orders_by_user missing (user_id=" + testUserID.String() + ", order_id=" + testOrderID.String() + ")":                                           true,
+	}
+	if len(report.Diffs) != len(expected) {
+		t.Errorf("expected %d differences, got %d: %v", len(expected), len(report.Diffs), report.Diffs)
+	}
+	for _, d := range report.Diffs {
+		if !expected[d.String()] {
+			t.Errorf("unexpected difference: %s", d)
+		}
+	}
+	if report.Tables[1].Counts[Mismatch] != 1 || report.Tables[1].Counts[Precision] != 1 {
+		t.Errorf("unexpected counts of orders: %v", report.Tables[1].Counts)
+	}
+}
+
+func TestReconciler_Repair(t *testing.T) {
+	pg, cass := testPostgres(), testCassandra()
+	cass.put("users", Row{"id": gocql.MustRandomUUID(), "name": "Bob"})
+	cass.tables["orders"] = nil
+
+	r, report := runReconciler(t, pg, cass)
+	writes := RepairWrites(report)
+	if len(writes) != 2 {
+		t.Fatalf("expected 2 repair writes, got %d", len(writes))
+	}
+
+	insert := writes[1].CQL()
+	want := "INSERT INTO orders (order_id, user_id, status, quantity, total_amount, placed_at, modified_at, is_deleted) VALUES (" +
+		testOrderID.String() + ", " + testUserID.String() + ", 'SHIPPED', 2, 100.52, '2025-08-28T16:02:58.281Z', '2025-08-28T17:02:58.281Z', false);"
+	if insert != want {
+		t.Errorf("unexpected repair write:\n got %s\nwant %s", insert, want)
+	}
+	if !strings.HasPrefix(writes[0].CQL(), "DELETE FROM users WHERE id = ") || !strings.HasSuffix(writes[0].CQL(), " AND name = 'Bob';") {
+		t.Errorf("unexpected repair write: %s", writes[0].CQL())
+	}
+
+	if err := r.Repair(context.Background(), writes); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if _, report := runReconciler(t, pg, cass); !report.InSync() {
+		t.Errorf("expected no differences after the repair, got %v", report.Diffs)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		kind     columnKind
+		expected any
+		actual   any
+		want     comparison
+	}{
+		{kindTimestamp, testTime, testTime.Truncate(time.Millisecond), equal},
+		{kindTimestamp, testTime, testTime.Round(time.Millisecond), precisionDiff},
+		{kindTimestamp, testTime, testTime.Add(time.Second), valueDiff},
+		{kindTimestamp, testTime, nil, valueDiff},
+		{kindDecimal, inf.NewDec(10052, 2), inf.NewDec(10052, 2), equal},
+		{kindDecimal, inf.NewDec(10050, 2), inf.NewDec(1005, 1), precisionDiff},
+		{kindDecimal, inf.NewDec(10052, 2), inf.NewDec(100521, 3), precisionDiff},
+		{kindDecimal, inf.NewDec(10052, 2), inf.NewDec(10053, 2), valueDiff},
+		{kindDate, time.Date(2000, 8, 3, 0, 0, 0, 0, time.UTC), time.Date(2000, 8, 3, 0, 0, 0, 0, time.UTC), equal},
+		{kindText, "a", "b", valueDiff},
+		{kindBool, nil, nil, equal},
+	}
+	for i, tt := range tests {
+		if got := compareValues(tt.kind, tt.expected, tt.actual); got != tt.want {
+			t.Errorf("test %d: compareValues(%v, %v) = %d, want %d", i, tt.expected, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestTokenRanges(t *testing.T) {
+	ranges := TokenRanges(7)
+	if ranges[0][0] != math.MinInt64 || ranges[6][1] != math.MaxInt64 {
+		t.Errorf("expected the ranges to cover the token ring: %v", ranges)
+	}
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i][0] != ranges[i-1][1]+1 || ranges[i][0] > ranges[i][1] {
+			t.Errorf("ranges %d and %d aren't contiguous: %v", i-1, i, ranges)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RepairWrite is a CQL statement making a row of a Cassandra table match Postgres
+type RepairWrite struct {
+	Table  string
+	Stmt   string
+	Values []any
+	kinds  []columnKind
+}
+
+// CQL returns the statement with the values inlined (e.g. to review the writes or run them with cqlsh)
+func (w RepairWrite) CQL() string {
+	var b strings.Builder
+	i := 0
+	for _, r := range w.Stmt {
+		if r == '?' && i < len(w.Values) {
+			b.WriteString(formatValue(w.kinds[i], w.Values[i]))
+			i++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + ";"
+}
+
+// RepairWrites returns the writes repairing the differences of a report:
+// missing and different rows are (over)written with all columns, extra rows are deleted
+func RepairWrites(report *Report) []RepairWrite {
+	var writes []RepairWrite
+	done := make(map[string]bool) // table and key of the repaired rows (a row can have several different columns)
+
+	for _, d := range report.Diffs {
+		t := LookupTable(d.Table)
+		id := t.Name + "|" + t.rowKey(d.Key)
+		if done[id] {
+			continue
+		}
+		done[id] = true
+
+		if d.Kind == Extra {
+			writes = append(writes, deleteWrite(t, d.Key))
+		} else {
+			writes = append(writes, upsertWrite(t, d.row))
+		}
+	}
+	return writes
+}
+
+func upsertWrite(t *Table, row Row) RepairWrite {
+	w := RepairWrite{Table: t.Name}
+	names := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		names[i] = col.Name
+		v := row[col.Name]
+		if ts, ok := v.(time.Time); ok && col.kind == kindTimestamp {
+			v = ts.Truncate(time.Millisecond) // like the sink writes them
+		}
+		w.Values = append(w.Values, v)
+		w.kinds = append(w.kinds, col.kind)
+	}
+	w.Stmt = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+	return w
+}
+
+func deleteWrite(t *Table, key Row) RepairWrite {
+	w := RepairWrite{Table: t.Name}
+	conds := make([]string, len(t.Key))
+	for i, k := range t.Key {
+		conds[i] = k + " = ?"
+		w.Values = append(w.Values, key[k])
+		w.kinds = append(w.kinds, t.column(k).kind)
+	}
+	w.Stmt = fmt.Sprintf("DELETE FROM %s WHERE %s", t.Name, strings.Join(conds, " AND "))
+	return w
+}
+
+// Repair executes repair writes
+func (r *Reconciler) Repair(ctx context.Context, writes []RepairWrite) error {
+	for i, w := range writes {
+		if err := r.cass.Exec(ctx, w.Stmt, w.Values...); err != nil {
+			return fmt.Errorf("repair write %d (%s): %w", i+1, w.CQL(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// PostgresReader reads all rows of a Postgres table
+type PostgresReader interface {
+	ScanTable(ctx context.Context, table string, fn func(Row) error) error
+}
+
+// CassandraStore reads the rows of a token range of a Cassandra table and executes repair writes
+type CassandraStore interface {
+	ScanRange(ctx context.Context, t *Table, start int64, end int64, fn func(Row) error) error
+	Exec(ctx context.Context, stmt string, values ...any) error
+}
+
+// TokenRanges splits the Murmur3 token ring into n contiguous ranges (with inclusive bounds)
+func TokenRanges(n int) [][2]int64 {
+	if n < 1 {
+		n = 1
+	}
+	width := math.MaxUint64 / uint64(n)
+	ranges := make([][2]int64, n)
+	for i := range ranges {
+		// wrapping uint64 arithmetic starting at the minimum token
+		ranges[i][0] = int64(uint64(1<<63) + uint64(i)*width)
+		if i > 0 {
+			ranges[i-1][1] = ranges[i][0] - 1
+		}
+	}
+	ranges[n-1][1] = math.MaxInt64
+	return ranges
+}
+
+type postgresReader struct {
+	db *sql.DB
+}
+
+// NewPostgresReader returns a PostgresReader of the users and orders tables
+func NewPostgresReader(db *sql.DB) PostgresReader {
+	return &postgresReader{db: db}
+}
+
+func (p *postgresReader) ScanTable(ctx context.Context, table string, fn func(Row) error) error {
+	var query string
+	switch table {
+	case "users":
+		query = "SELECT id::text, name, dob, created_at, modified_at, is_deleted FROM users"
+	case "orders":
+		query = "SELECT id::text, user_id::text, status, quantity, total_amount::text, placed_at, modified_at, is_deleted FROM orders"
+	default:
+		return fmt.Errorf("unknown postgres table %s", table)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row Row
+		if table == "users" {
+			row, err = scanUser(rows)
+		} else {
+			row, err = scanOrder(rows)
+		}
+		if err != nil {
+			return fmt.Errorf("scan %s: %w", table, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanUser(rows *sql.Rows) (Row, error) {
+	var id, name string
+	var dob time.Time
+	var createdAt, modifiedAt sql.NullTime
+	var isDeleted sql.NullBool
+	if err := rows.Scan(&id, &name, &dob, &createdAt, &modifiedAt, &isDeleted); err != nil {
+		return nil, err
+	}
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, err
+	}
+	return Row{
+		"id":          uuid,
+		"name":        name,
+		"dob":         dob,
+		"created_at":  nullable(createdAt.Time, createdAt.Valid),
+		"modified_at": nullable(modifiedAt.Time, modifiedAt.Valid),
+		"is_deleted":  nullable(isDeleted.Bool, isDeleted.Valid),
+	}, nil
+}
+
+func scanOrder(rows *sql.Rows) (Row, error) {
+	var id, userID, status, total string
+	var quantity int
+	var placedAt, modifiedAt sql.NullTime
+	var isDeleted sql.NullBool
+	if err := rows.Scan(&id, &userID, &status, &quantity, &total, &placedAt, &modifiedAt, &isDeleted); err != nil {
+		return nil, err
+	}
+	orderUUID, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, err
+	}
+	userUUID, err := gocql.ParseUUID(userID)
+	if err != nil {
+		return nil, err
+	}
+	amount, ok := new(inf.Dec).SetString(total)
+	if !ok {
+		return nil, fmt.Errorf("invalid total_amount %s", total)
+	}
+	return Row{
+		"id":           orderUUID,
+		"user_id":      userUUID,
+		"status":       status,
+		"quantity":     quantity,
+		"total_amount": amount,
+		"placed_at":    nullable(placedAt.Time, placedAt.Valid),
+		"modified_at":  nullable(modifiedAt.Time, modifiedAt.Valid),
+		"is_deleted":   nullable(isDeleted.Bool, isDeleted.Valid),
+	}, nil
+}
+
+func nullable[T any](v T, valid bool) any {
+	if !valid {
+		return nil
+	}
+	return v
+}
+
+type cassandraStore struct {
+	sess     *gocql.Session
+	pageSize int
+}
+
+// NewCassandraStore returns a CassandraStore reading pages of the given size
+func NewCassandraStore(sess *gocql.Session, pageSize int) CassandraStore {
+	return &cassandraStore{sess: sess, pageSize: pageSize}
+}
+
+func (c *cassandraStore) ScanRange(ctx context.Context, t *Table, start int64, end int64, fn func(Row) error) error {
+	columns := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		columns[i] = col.Name
+	}
+	pk := strings.Join(t.PartitionKey, ", ")
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE token(%s) >= ? AND token(%s) <= ?", strings.Join(columns, ", "), t.Name, pk, pk)
+
+	iter := c.sess.Query(stmt, start, end).WithContext(ctx).PageSize(c.pageSize).Iter()
+	for {
+		row := map[string]any{}
+		if !iter.MapScan(row) {
+			break
+		}
+		if err := fn(normalizeCassandraRow(row)); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("scan %s [%d, %d]: %w", t.Name, start, end, err)
+	}
+	return nil
+}
+
+func (c *cassandraStore) Exec(ctx context.Context, stmt string, values ...any) error {
+	return c.sess.Query(stmt, values...).WithContext(ctx).Exec()
+}
+
+// gocql scans nulls as zero values of timestamps and nil decimals
+func normalizeCassandraRow(row map[string]any) Row {
+	for k, v := range row {
+		switch x := v.(type) {
+		case time.Time:
+			if x.IsZero() {
+				row[k] = nil
+			}
+		case *inf.Dec:
+			if x == nil {
+				row[k] = nil
+			}
+		}
+	}
+	return row
+}
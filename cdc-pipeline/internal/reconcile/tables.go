@@ -0,0 +1,213 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// Row is a row of a table (column name -> value, nil for null).
+// Values are normalized to gocql.UUID, string, int, bool, time.Time (dates and timestamps) and *inf.Dec.
+type Row map[string]any
+
+type columnKind int
+
+const (
+	kindUUID columnKind = iota
+	kindText
+	kindInt
+	kindBool
+	kindDate
+	kindTimestamp
+	kindDecimal
+)
+
+// Column is a column of a Cassandra table
+type Column struct {
+	Name string
+	kind columnKind
+}
+
+// Table is a Cassandra table and how its rows are derived from a Postgres table (the same way the cassandra sink writes them)
+type Table struct {
+	Name         string
+	Source       string   // Postgres table
+	PartitionKey []string // columns of the token function
+	Key          []string // primary key (partition and clustering columns)
+	Columns      []Column // all columns (including the key)
+
+	// columns whose null value means the value of another column
+	// (e.g. modified_at is only written by the sink for updates, so it's null until the first update)
+	nullAs map[string]string
+	// expected row of a Postgres row
+	expected func(Row) Row
+}
+
+// Tables are the Cassandra tables that are reconciled (see sql/migrations/cassandra)
+var Tables = []*Table{
+	{
+		Name:         "users",
+		Source:       "users",
+		PartitionKey: []string{"id"},
+		Key:          []string{"id", "name"},
+		Columns: []Column{
+			{"id", kindUUID}, {"name", kindText}, {"dob", kindDate},
+			{"created_at", kindTimestamp}, {"modified_at", kindTimestamp}, {"is_deleted", kindBool},
+		},
+		nullAs:   map[string]string{"modified_at": "created_at"},
+		expected: func(pg Row) Row { return pg },
+	},
+	{
+		Name:         "orders",
+		Source:       "orders",
+		PartitionKey: []string{"order_id"},
+		Key:          []string{"order_id", "user_id"},
+		Columns:      orderColumns,
+		nullAs:       map[string]string{"modified_at": "placed_at"},
+		expected:     expectedOrder,
+	},
+	{
+		Name:         "orders_by_user",
+		Source:       "orders",
+		PartitionKey: []string{"user_id"},
+		Key:          []string{"user_id", "order_id"},
+		Columns:      orderColumns,
+		nullAs:       map[string]string{"modified_at": "placed_at"},
+		expected:     expectedOrder,
+	},
+}
+
+var orderColumns = []Column{
+	{"order_id", kindUUID}, {"user_id", kindUUID}, {"status", kindText}, {"quantity", kindInt},
+	{"total_amount", kindDecimal}, {"placed_at", kindTimestamp}, {"modified_at", kindTimestamp}, {"is_deleted", kindBool},
+}
+
+func expectedOrder(pg Row) Row {
+	row := make(Row, len(pg))
+	for k, v := range pg {
+		row[k] = v
+	}
+	row["order_id"] = pg["id"]
+	delete(row, "id")
+	return row
+}
+
+// LookupTable returns the table with the given name (nil if it's not reconciled)
+func LookupTable(name string) *Table {
+	for _, t := range Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// key of a row (the primary key values joined with '|')
+func (t *Table) rowKey(row Row) string {
+	parts := make([]string, len(t.Key))
+	for i, k := range t.Key {
+		parts[i] = fmt.Sprint(row[k])
+	}
+	return strings.Join(parts, "|")
+}
+
+func (t *Table) keyValues(row Row) Row {
+	key := make(Row, len(t.Key))
+	for _, k := range t.Key {
+		key[k] = row[k]
+	}
+	return key
+}
+
+// result of comparing a column value
+type comparison int
+
+const (
+	equal comparison = iota
+	precisionDiff
+	valueDiff
+)
+
+// compare the expected (Postgres) and actual (Cassandra) value of a column
+func compareValues(kind columnKind, expected any, actual any) comparison {
+	if expected == nil || actual == nil {
+		if expected == nil && actual == nil {
+			return equal
+		}
+		return valueDiff
+	}
+
+	switch kind {
+	case kindDate:
+		e, eok := expected.(time.Time)
+		a, aok := actual.(time.Time)
+		if eok && aok && e.UTC().Format(time.DateOnly) == a.UTC().Format(time.DateOnly) {
+			return equal
+		}
+		return valueDiff
+	case kindTimestamp:
+		e, eok := expected.(time.Time)
+		a, aok := actual.(time.Time)
+		if !eok || !aok {
+			return valueDiff
+		}
+		// cassandra timestamps have millisecond precision (the sink truncates the microseconds of Postgres)
+		if e.Truncate(time.Millisecond).Equal(a) {
+			return equal
+		}
+		if d := e.Sub(a); d > -time.Millisecond && d < time.Millisecond {
+			return precisionDiff // e.g. rounded instead of truncated
+		}
+		return valueDiff
+	case kindDecimal:
+		e, eok := expected.(*inf.Dec)
+		a, aok := actual.(*inf.Dec)
+		if !eok || !aok {
+			return valueDiff
+		}
+		if e.Cmp(a) == 0 {
+			if e.Scale() == a.Scale() {
+				return equal
+			}
+			return precisionDiff // same number with a different scale (e.g. 100.5 and 100.50)
+		}
+		if new(inf.Dec).Round(a, e.Scale(), inf.RoundHalfEven).Cmp(e) == 0 {
+			return precisionDiff // more digits than the Postgres column's scale
+		}
+		return valueDiff
+	case kindUUID:
+		e, eok := expected.(gocql.UUID)
+		a, aok := actual.(gocql.UUID)
+		if eok && aok && e == a {
+			return equal
+		}
+		return valueDiff
+	default:
+		if expected == actual {
+			return equal
+		}
+		return valueDiff
+	}
+}
+
+// format a value of a column as CQL literal (also used in reports)
+func formatValue(kind columnKind, v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.ReplaceAll(x, "'", "''") + "'"
+	case time.Time:
+		if kind == kindDate {
+			return "'" + x.UTC().Format(time.DateOnly) + "'"
+		}
+		return "'" + x.UTC().Format("2006-01-02T15:04:05.999999Z07:00") + "'"
+	case *inf.Dec:
+		return x.String()
+	default:
+		return fmt.Sprint(x)
+	}
+}
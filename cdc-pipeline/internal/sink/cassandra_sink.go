@@ -1,9 +1,10 @@
 package sink
 
 import (
-	"encoding/base64"
 	"fmt"
-	"math/big"
+	"maps"
+	"os"
+	"regexp"
 	"time"
 
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
@@ -11,7 +12,6 @@ import (
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
 	"github.com/gocql/gocql"
-	"gopkg.in/inf.v0"
 )
 
 // define a Session and Query interface (for testability)
@@ -22,20 +22,164 @@ type CassandraSession interface {
 type CassandraQuery interface {
 	Exec() error
 	MapScan(map[string]interface{}) error
+	// MapScanCAS executes a lightweight transaction (e.g. `INSERT ... IF NOT EXISTS`), reports whether it was applied
+	// (if not, dest has the existing row)
+	MapScanCAS(dest map[string]interface{}) (bool, error)
+	Consistency(gocql.Consistency) CassandraQuery
+	SerialConsistency(gocql.SerialConsistency) CassandraQuery
 }
 
 // CassandraClient wraps a CassandraSession (interface)
 type CassandraClient struct {
-	session CassandraSession
+	session    CassandraSession
+	dedup      DedupMode
+	readCons   gocql.Consistency       // consistency of the reads (writes use the session's default)
+	serialCons gocql.SerialConsistency // serial consistency of the lightweight transactions
+}
+
+// DedupMode selects how change events are deduplicated (by their event id, in the processed_events table)
+type DedupMode struct {
+	Bypass bool   // apply every event, without checking or recording it
+	Scope  string // if set, only deduplicate among the events applied with the same scope (e.g. a replay)
+}
+
+// CassandraClientConfig configures a CassandraClient (see DefaultCassandraClientConfig)
+type CassandraClientConfig struct {
+	Hosts    []string
+	Keyspace string
+
+	// consistency by operation type
+	WriteConsistency  gocql.Consistency       // inserts, updates and deletes
+	ReadConsistency   gocql.Consistency       // selects
+	SerialConsistency gocql.SerialConsistency // paxos phase of lightweight transactions (deduplication of change events)
+
+	// queries are routed to the replicas of their partition (token aware),
+	// preferring the hosts of LocalDC if it's set (DC aware, other datacenters are only used if none is up)
+	LocalDC string
+
+	ConnectTimeout time.Duration
+	Timeout        time.Duration // timeout of a query
+
+	// failed queries are retried with exponential backoff (0 = no retries)
+	MaxRetries      int
+	RetryMinBackOff time.Duration
+	RetryMaxBackOff time.Duration
+
+	// slow idempotent queries (i.e. all but lightweight transactions) are sent to up to SpeculativeAttempts other hosts,
+	// one every SpeculativeDelay, and the first response is used (0 = disabled)
+	SpeculativeAttempts int
+	SpeculativeDelay    time.Duration
+
+	Compression bool // snappy compression of the frames
+
+	// password authentication (if Username is set)
+	Username string
+	Password string
+
+	// TLS (if TLSCAFile is set), with a client certificate if TLSCertFile and TLSKeyFile are set
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// prepared statements cached per session (gocql prepares every statement with values and reuses it)
+	MaxPreparedStmts int
+}
+
+// DefaultCassandraClientConfig returns the configuration of the sink (see internal/config),
+// with the credentials and TLS files read from the environment
+func DefaultCassandraClientConfig() (CassandraClientConfig, error) {
+	cfg := CassandraClientConfig{
+		Hosts:               config.CassandraHosts,
+		Keyspace:            config.CassandraKeyspace,
+		LocalDC:             config.CassandraLocalDC,
+		ConnectTimeout:      config.CassandraConnectTimeout,
+		Timeout:             config.CassandraTimeout,
+		MaxRetries:          config.CassandraMaxRetries,
+		RetryMinBackOff:     config.CassandraRetryMinBackOff,
+		RetryMaxBackOff:     config.CassandraRetryMaxBackOff,
+		SpeculativeAttempts: config.CassandraSpeculativeTries,
+		SpeculativeDelay:    config.CassandraSpeculativeDelay,
+		Compression:         config.CassandraCompression,
+		Username:            os.Getenv(config.CassandraUsernameEnv),
+		Password:            os.Getenv(config.CassandraPasswordEnv),
+		TLSCAFile:           os.Getenv(config.CassandraTLSCAFileEnv),
+		TLSCertFile:         os.Getenv(config.CassandraTLSCertFileEnv),
+		TLSKeyFile:          os.Getenv(config.CassandraTLSKeyFileEnv),
+		MaxPreparedStmts:    config.CassandraMaxPreparedStmts,
+	}
+
+	var err error
+	if cfg.WriteConsistency, err = gocql.ParseConsistencyWrapper(config.CassandraWriteConsistency); err != nil {
+		return cfg, fmt.Errorf("write consistency: %w", err)
+	}
+	if cfg.ReadConsistency, err = gocql.ParseConsistencyWrapper(config.CassandraReadConsistency); err != nil {
+		return cfg, fmt.Errorf("read consistency: %w", err)
+	}
+	if err := cfg.SerialConsistency.UnmarshalText([]byte(config.CassandraSerialConsistency)); err != nil {
+		return cfg, fmt.Errorf("serial consistency: %w", err)
+	}
+	return cfg, nil
+}
+
+// ClusterConfig returns the gocql cluster configuration of the client
+func (cfg *CassandraClientConfig) ClusterConfig() *gocql.ClusterConfig {
+	cluster := gocql.NewCluster(cfg.Hosts...)
+	cluster.Keyspace = cfg.Keyspace
+	cluster.Consistency = cfg.WriteConsistency
+	cluster.SerialConsistency = cfg.SerialConsistency
+	if cfg.ConnectTimeout > 0 {
+		cluster.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.Timeout > 0 {
+		cluster.Timeout = cfg.Timeout
+	}
+
+	fallback := gocql.RoundRobinHostPolicy()
+	if cfg.LocalDC != "" {
+		fallback = gocql.DCAwareRoundRobinPolicy(cfg.LocalDC)
+	}
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(fallback)
+
+	if cfg.MaxRetries > 0 {
+		cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{NumRetries: cfg.MaxRetries, Min: cfg.RetryMinBackOff, Max: cfg.RetryMaxBackOff}
+	}
+	if cfg.Compression {
+		cluster.Compressor = gocql.SnappyCompressor{}
+	}
+	if cfg.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: cfg.Username, Password: cfg.Password}
+	}
+	if cfg.TLSCAFile != "" {
+		cluster.SslOpts = &gocql.SslOptions{
+			CaPath:                 cfg.TLSCAFile,
+			CertPath:               cfg.TLSCertFile,
+			KeyPath:                cfg.TLSKeyFile,
+			EnableHostVerification: true,
+		}
+	}
+	if cfg.MaxPreparedStmts > 0 {
+		cluster.MaxPreparedStmts = cfg.MaxPreparedStmts
+	}
+	// the sink's writes set the same values when repeated, only lightweight transactions are marked as not idempotent
+	cluster.DefaultIdempotence = true
+	return cluster
 }
 
 // Adapter for real gocql.Session
 type realSession struct {
-	sess *gocql.Session
+	sess        *gocql.Session
+	speculative gocql.SpeculativeExecutionPolicy // nil if disabled
 }
 
+// Query sets the speculative execution of idempotent statements
 func (r *realSession) Query(stmt string, values ...interface{}) CassandraQuery {
-	return &realQuery{q: r.sess.Query(stmt, values...)}
+	q := r.sess.Query(stmt, values...)
+	if isLWT(stmt) {
+		q = q.Idempotent(false)
+	} else if r.speculative != nil {
+		q = q.SetSpeculativeExecutionPolicy(r.speculative)
+	}
+	return &realQuery{q: q}
 }
 
 func (r *realSession) Close() {
@@ -55,42 +199,59 @@ func (rq *realQuery) MapScan(dest map[string]interface{}) error {
 	return rq.q.MapScan(dest)
 }
 
-// add Consistency and MapScanCAS method to realQuery (as needed for some operations below)
-func (rq *realQuery) Consistency(c gocql.Consistency) *realQuery {
+func (rq *realQuery) MapScanCAS(dest map[string]interface{}) (bool, error) {
+	return rq.q.MapScanCAS(dest)
+}
+
+func (rq *realQuery) Consistency(c gocql.Consistency) CassandraQuery {
 	rq.q = rq.q.Consistency(c)
 	return rq
 }
 
-func (rq *realQuery) MapScanCAS(dest map[string]interface{}) (bool, error) {
-	return rq.q.MapScanCAS(dest)
+func (rq *realQuery) SerialConsistency(c gocql.SerialConsistency) CassandraQuery {
+	rq.q = rq.q.SerialConsistency(c)
+	return rq
 }
 
-// NewCassandraClient creates a new session
-func NewCassandraClient(hosts []string, keyspace string) (*CassandraClient, error) {
-	// create a new Cassandra cluster
-	cluster := gocql.NewCluster(hosts...)
-	cluster.Keyspace = keyspace
-	cluster.Consistency = gocql.Quorum
-	cluster.ConnectTimeout = 5 * time.Second
+var lwtStmtRegex = regexp.MustCompile(`(?i)\sIF\s+(NOT\s+EXISTS|EXISTS|\w+\s*[=<>!])`)
+
+// lightweight transactions (conditional statements, e.g. `INSERT ... IF NOT EXISTS`)
+func isLWT(stmt string) bool { return lwtStmtRegex.MatchString(stmt) }
 
-	// create a cluster session
-	sess, err := cluster.CreateSession()
+// NewCassandraClient connects to the cluster and returns a new CassandraClient
+func NewCassandraClient(cfg CassandraClientConfig) (*CassandraClient, error) {
+	sess, err := cfg.ClusterConfig().CreateSession()
 	if err != nil {
 		return nil, fmt.Errorf("create session: %w", err)
 	}
-	return &CassandraClient{session: &realSession{sess}}, nil
+
+	rs := &realSession{sess: sess}
+	if cfg.SpeculativeAttempts > 0 {
+		rs.speculative = &gocql.SimpleSpeculativeExecution{NumAttempts: cfg.SpeculativeAttempts, TimeoutDelay: cfg.SpeculativeDelay}
+	}
+	return &CassandraClient{session: rs, readCons: cfg.ReadConsistency, serialCons: cfg.SerialConsistency}, nil
+}
+
+// Ping checks that the cluster is reachable by reading the local node's version
+func (c *CassandraClient) Ping() error {
+	return c.session.Query("SELECT release_version FROM system.local").Consistency(gocql.One).Exec()
 }
 
 // close the cassandra session
 func (c *CassandraClient) Close() {
 	if c.session != nil {
-		// Only realSession needs Close method to be called
-		if rs, ok := c.session.(*realSession); ok {
-			rs.Close()
+		// Only sessions holding resources (e.g. realSession) need Close method to be called
+		if cs, ok := c.session.(interface{ Close() }); ok {
+			cs.Close()
 		}
 	}
 }
 
+// SetDedupMode changes how the change events applied from now on are deduplicated
+func (c *CassandraClient) SetDedupMode(m DedupMode) {
+	c.dedup = m
+}
+
 // ApplyChange applies idempotent sink of a Debezium change event to cassandra
 // for supported debezium topics.`topic` is the Kafka topic name (e.g. "cdc.public.users")
 func (c *CassandraClient) ApplyChange(topic string, ev *model.ChangeEvent) error {
@@ -100,16 +261,34 @@ func (c *CassandraClient) ApplyChange(topic string, ev *model.ChangeEvent) error
 	}
 
 	// deduplicate using processed_events table (INSERT IF NOT EXISTS)
-	applied, err := c.addEventIfNotProcessed(ev.EventID, topic, ev.TsMs)
-	if err != nil {
-		return fmt.Errorf("addEventIfNotProcessed: %w", err)
-	}
-	if !applied {
-		// already processed
+	if !c.dedup.Bypass {
+		eventID := ev.EventID
+		if c.dedup.Scope != "" {
+			eventID = c.dedup.Scope + "/" + eventID
+		}
+		applied, err := c.addEventIfNotProcessed(eventID, topic, ev.TsMs)
+		if err != nil {
+			return fmt.Errorf("addEventIfNotProcessed: %w", err)
+		}
+		if !applied {
+			// already processed
+			return nil
+		}
+
+		// the event isn't processed if applying it fails, so it's applied when retried (or redelivered)
+		if err := c.applyChange(topic, ev); err != nil {
+			if delErr := c.session.Query("DELETE FROM processed_events WHERE event_id = ?", eventID).Exec(); delErr != nil {
+				logger.ErrorLogger.Printf("failed to remove processed event %s: %v\n", eventID, delErr)
+			}
+			return err
+		}
 		return nil
 	}
+	return c.applyChange(topic, ev)
+}
 
-	// route by topic suffix (table name)
+// route by topic suffix (table name)
+func (c *CassandraClient) applyChange(topic string, ev *model.ChangeEvent) error {
 	switch topic {
 	case config.DebeziumUsersTopic:
 		return c.applyUserChange(ev)
@@ -120,6 +299,38 @@ func (c *CassandraClient) ApplyChange(topic string, ev *model.ChangeEvent) error
 	}
 }
 
+// ApplySnapshotRow writes a row of a snapshot of a Postgres table (in Debezium's JSON format) to cassandra,
+// with the same mapping as its change events: the row is created, and updated if it was modified (or soft deleted)
+// since it was created (a null `modified_at` means it wasn't modified). `topic` is the Kafka topic of the table's
+// change events (e.g. "cdc.public.users").
+func (c *CassandraClient) ApplySnapshotRow(topic string, row model.JsonMap) error {
+	var apply func(ev *model.ChangeEvent) error
+	var createdAtColumn string
+	switch topic {
+	case config.DebeziumUsersTopic:
+		apply, createdAtColumn = c.applyUserChange, "created_at"
+	case config.DebeziumOrdersTopic:
+		apply, createdAtColumn = c.applyOrderChange, "placed_at"
+	default:
+		return fmt.Errorf("unknown Debezium topic: %s", topic)
+	}
+
+	if err := apply(&model.ChangeEvent{Op: "c", Row: row}); err != nil {
+		return err
+	}
+	isDeleted, _ := row["is_deleted"].(bool)
+	modified := row["modified_at"] != nil && row["modified_at"] != row[createdAtColumn]
+	if !modified && !isDeleted {
+		return nil
+	}
+	if row["modified_at"] == nil {
+		// a soft deleted row without a modification time is deleted as of its creation
+		row = maps.Clone(row)
+		row["modified_at"] = row[createdAtColumn]
+	}
+	return apply(&model.ChangeEvent{Op: "u", Row: row})
+}
+
 // addEventIfNotProcessed returns true if the event was newly added (i.e., not seen before)
 func (c *CassandraClient) addEventIfNotProcessed(eventID string, topic string, tsMs int64) (bool, error) {
 
@@ -128,19 +339,15 @@ func (c *CassandraClient) addEventIfNotProcessed(eventID string, topic string, t
 	// LWT insert: INSERT ... IF NOT EXISTS
 	query := "INSERT INTO processed_events (event_id, topic, ts_ms, processed_at) VALUES (?, ?, ?, ?) IF NOT EXISTS"
 
-	// Type assertion to access Consistency and MapScanCAS for realSession
-	if rs, ok := c.session.(*realSession); ok {
-		q := rs.sess.Query(query, eventID, topic, tsMs, time.Now()).Consistency(gocql.Quorum)
-		applied, err := q.MapScanCAS(make(map[string]interface{})) // applied = true if the row insertion was successful
-		if err != nil {
-			return false, err
-		}
-
-		logger.DebugLogger.Printf("Row already existed? %v", !applied)
-		return applied, nil
+	applied, err := c.session.Query(query, eventID, topic, tsMs, time.Now()).
+		SerialConsistency(c.serialCons).
+		MapScanCAS(make(map[string]interface{})) // applied = true if the row insertion was successful
+	if err != nil {
+		return false, err
 	}
-	// For mocks, just return true
-	return true, nil
+
+	logger.DebugLogger.Printf("Row already existed? %v\n", !applied)
+	return applied, nil
 }
 
 func (c *CassandraClient) applyUserChange(ev *model.ChangeEvent) error {
@@ -157,20 +364,12 @@ func (c *CassandraClient) applyUserChange(ev *model.ChangeEvent) error {
 		return nil
 	}
 
-	id, err := convertJsonValueToCqlUUID(row["id"])
+	u, err := parser.DecodeRow[UserRow](row)
 	if err != nil {
 		return err
 	}
-
-	// extract necessary fields from the row
-	name := row["name"].(string)
-
-	dobInt := parser.ParseDebeziumNumber[int32](row["dob"])
-	dobTime := parseDebeziumDate(dobInt)
-	dob := dobTime.Format("2006-01-02") // extract date only as a string
-
-	// the timestamps in Debezium are in RFC3339 formatted string
-	createdAt, _ := time.Parse(time.RFC3339, row["created_at"].(string))
+	id, name, createdAt := u.ID, u.Name, u.CreatedAt
+	dob := u.DOB.Format("2006-01-02") // extract date only as a string
 
 	switch ev.Op {
 
@@ -179,8 +378,11 @@ func (c *CassandraClient) applyUserChange(ev *model.ChangeEvent) error {
 		return c.session.Query(stmt, id, name, dob, createdAt, false).Exec()
 
 	case "u":
-		modifiedAt, _ := time.Parse(time.RFC3339, row["modified_at"].(string))
-		isDeleted, _ := row["is_deleted"].(bool)
+		modifiedAt, err := parser.Required("modified_at", u.ModifiedAt)
+		if err != nil {
+			return err
+		}
+		isDeleted := u.IsDeleted != nil && *u.IsDeleted // is_deleted may be null
 
 		// if it's a deleting update, just set the deleted_ind (without updating the name)
 		if isDeleted {
@@ -192,18 +394,16 @@ func (c *CassandraClient) applyUserChange(ev *model.ChangeEvent) error {
 			// SELECT
 			var selectRow = map[string]interface{}{}
 			readStmt := "SELECT name from users where id = ? LIMIT 1"
-			err := c.session.Query(readStmt, id).MapScan(selectRow)
+			err := c.session.Query(readStmt, id).Consistency(c.readCons).MapScan(selectRow)
 			if err != nil {
 				return fmt.Errorf("applyUserChange: error when reading existing record with user id %v: %w", id, err)
 			}
 
 			var currName string
-			if _, ok := c.session.(*realSession); ok {
-				res, ok := selectRow["name"]
-				if !ok {
-					return fmt.Errorf("applyUserChange: error when reading existing record with user id %v: 'name' not found in the returned column set", id)
-				}
-				currName = res.(string)
+			if res, ok := selectRow["name"]; ok {
+				currName, _ = res.(string)
+			} else if _, ok := c.session.(*realSession); ok {
+				return fmt.Errorf("applyUserChange: error when reading existing record with user id %v: 'name' not found in the returned column set", id)
 			}
 
 			// INSERT
@@ -240,24 +440,14 @@ func (c *CassandraClient) applyOrderChange(ev *model.ChangeEvent) error {
 		return nil
 	}
 
-	orderID, err := convertJsonValueToCqlUUID(row["id"])
+	o, err := parser.DecodeRow[OrderRow](row)
 	if err != nil {
 		return err
 	}
-
-	userID, err := convertJsonValueToCqlUUID(row["user_id"])
-	if err != nil {
-		return err
-	}
-
-	status := row["status"].(string)
-	quantity := parser.ParseDebeziumNumber[int](row["quantity"])
-	total := debeziumDecimalToInfDec(row["total_amount"].(string), 2)
+	orderID, userID, status, quantity, total, placedAt := o.ID, o.UserID, o.Status, o.Quantity, o.TotalAmount, o.PlacedAt
 
 	switch ev.Op {
 	case "c":
-		// the timestamps are RFC3339 formatted string so just pass as is to cassandra
-		placedAt, _ := time.Parse(time.RFC3339, row["placed_at"].(string))
 		// insert into orders and orders_by_user
 		q1 := `INSERT INTO orders (order_id, user_id, status, quantity, total_amount, placed_at, is_deleted) VALUES (?, ?, ?, ?, ?, ?, ?)`
 		if err := c.session.Query(q1, orderID, userID, status, quantity, total, placedAt, false).Exec(); err != nil {
@@ -266,8 +456,14 @@ func (c *CassandraClient) applyOrderChange(ev *model.ChangeEvent) error {
 		q2 := `INSERT INTO orders_by_user (user_id, order_id, status, quantity, total_amount, placed_at, is_deleted) VALUES (?, ?, ?, ?, ?, ?, ?)`
 		return c.session.Query(q2, userID, orderID, status, quantity, total, placedAt, false).Exec()
 	case "u":
-		modifiedAt, _ := time.Parse(time.RFC3339, row["modified_at"].(string))
-		isDeleted := row["is_deleted"].(bool)
+		modifiedAt, err := parser.Required("modified_at", o.ModifiedAt)
+		if err != nil {
+			return err
+		}
+		isDeleted, err := parser.Required("is_deleted", o.IsDeleted)
+		if err != nil {
+			return err
+		}
 		q1 := `UPDATE orders SET status = ?, modified_at = ?, is_deleted = ? WHERE order_id = ? AND user_id = ?`
 		if err := c.session.Query(q1, status, modifiedAt, isDeleted, orderID, userID).Exec(); err != nil {
 			return err
@@ -278,50 +474,3 @@ func (c *CassandraClient) applyOrderChange(ev *model.ChangeEvent) error {
 
 	return nil
 }
-
-func parseDebeziumDate(days int32) time.Time {
-	// Debezium date = days since epoch (1970-01-01, UTC)
-	epoch := time.Unix(0, 0).UTC()
-	return epoch.AddDate(0, 0, int(days))
-}
-
-// helper function to convert an interface{} value to a valid CQL UUID
-func convertJsonValueToCqlUUID(idVal any) (gocql.UUID, error) {
-	idStr, ok := idVal.(string)
-	if !ok {
-		return gocql.UUID{}, fmt.Errorf("applyUserChange: 'id' must be a string value")
-	}
-	uuid, err := gocql.ParseUUID(idStr)
-	if err != nil {
-		return gocql.UUID{}, fmt.Errorf("applyUserChange: invalid UUID format: %w", err)
-	}
-	return uuid, nil
-}
-
-// parse debezium decimal number with given scale (encoded as a base64 string)
-// to inf.Dec type - the type required by CQL to accept a decimal column value
-func debeziumDecimalToInfDec(b64 string, scale int) *inf.Dec {
-	// Step 1: Base64 decode
-	raw, err := base64.StdEncoding.DecodeString(b64)
-	if err != nil {
-		panic(err)
-	}
-
-	// Step 2: Interpret bytes as two’s complement integer
-	i := new(big.Int)
-	if len(raw) > 0 && raw[0]&0x80 != 0 { // negative number
-		// two's complement conversion
-		tmp := make([]byte, len(raw))
-		for j := range raw {
-			tmp[j] = ^raw[j]
-		}
-		i.SetBytes(tmp)
-		i.Add(i, big.NewInt(1))
-		i.Neg(i)
-	} else {
-		i.SetBytes(raw)
-	}
-
-	// Step 3: create inf.Dec with scale
-	return inf.NewDecBig(i, inf.Scale(scale))
-}
@@ -1,10 +1,15 @@
 package sink
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
 	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
 	"github.com/gocql/gocql"
 	"gopkg.in/inf.v0"
 )
@@ -27,21 +32,52 @@ func parseUUID(id string) gocql.UUID {
 type mockSession struct {
 	executedQueries []string
 	args            [][]interface{}
+	consistency     []gocql.Consistency       // consistency set on the queries (0 if not set)
+	serial          []gocql.SerialConsistency // serial consistency set on the queries (0 if not set)
+
+	casNotApplied bool  // lightweight transactions aren't applied (if casErr is nil)
+	casErr        error // error of lightweight transactions
+	execErr       func(stmt string) error
 }
 
 // Implement CassandraSession interface
 func (m *mockSession) Query(stmt string, values ...interface{}) CassandraQuery {
 	m.executedQueries = append(m.executedQueries, stmt)
 	m.args = append(m.args, values)
-	return &mockQuery{}
+	m.consistency = append(m.consistency, 0)
+	m.serial = append(m.serial, 0)
+	return &mockQuery{session: m, index: len(m.executedQueries) - 1}
 }
 
-type mockQuery struct{}
+type mockQuery struct {
+	session *mockSession
+	index   int // of the query in the session's executedQueries
+}
 
 // Implement CassandraQuery interface
-func (q *mockQuery) Exec() error                               { return nil }
+func (q *mockQuery) Exec() error {
+	if q.session.execErr != nil {
+		return q.session.execErr(q.session.executedQueries[q.index])
+	}
+	return nil
+}
+
 func (q *mockQuery) MapScan(dest map[string]interface{}) error { return nil }
 
+func (q *mockQuery) MapScanCAS(dest map[string]interface{}) (bool, error) {
+	return !q.session.casNotApplied, q.session.casErr
+}
+
+func (q *mockQuery) Consistency(c gocql.Consistency) CassandraQuery {
+	q.session.consistency[q.index] = c
+	return q
+}
+
+func (q *mockQuery) SerialConsistency(c gocql.SerialConsistency) CassandraQuery {
+	q.session.serial[q.index] = c
+	return q
+}
+
 func TestApplyUserChange_Insert(t *testing.T) {
 	client := &CassandraClient{session: &mockSession{}}
 
@@ -391,3 +427,386 @@ func TestApplyOrderChange_InvalidOp(t *testing.T) {
 		t.Fatalf("expected no error for unsupported op, got %v", err)
 	}
 }
+
+func TestApplyChange_PoisonRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(c *CassandraClient, ev *model.ChangeEvent) error
+		row   map[string]interface{}
+		want  error
+	}{
+		{
+			name:  "user without name",
+			apply: (*CassandraClient).applyUserChange,
+			row:   map[string]interface{}{"id": testUserID, "dob": 11172, "created_at": "2025-08-28T16:02:58.281604Z"},
+			want:  parser.ErrMissingField,
+		},
+		{
+			name:  "user with string dob",
+			apply: (*CassandraClient).applyUserChange,
+			row:   map[string]interface{}{"id": testUserID, "name": "Alice", "dob": "2000-08-03", "created_at": "2025-08-28T16:02:58.281604Z"},
+			want:  parser.ErrWrongType,
+		},
+		{
+			name:  "order with invalid decimal",
+			apply: (*CassandraClient).applyOrderChange,
+			row: map[string]interface{}{"id": testOrderID, "user_id": testUserID, "status": "PLACED", "quantity": 2,
+				"total_amount": "not base64!", "placed_at": "2025-08-28T16:02:58.281604Z"},
+			want: parser.ErrInvalidValue,
+		},
+		{
+			name:  "order with null status",
+			apply: (*CassandraClient).applyOrderChange,
+			row:   map[string]interface{}{"id": testOrderID, "user_id": testUserID, "status": nil},
+			want:  parser.ErrNullField,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockSession{}
+			err := tt.apply(&CassandraClient{session: session}, &model.ChangeEvent{Op: "c", Row: tt.row})
+			if !errors.Is(err, tt.want) || !parser.IsPoison(err) {
+				t.Fatalf("expected a poison %v error, got %v", tt.want, err)
+			}
+			if len(session.executedQueries) != 0 {
+				t.Errorf("expected no queries, got %v", session.executedQueries)
+			}
+		})
+	}
+}
+
+func TestCassandraClientConfig_ClusterConfig(t *testing.T) {
+	cfg, err := DefaultCassandraClientConfig()
+	if err != nil {
+		t.Fatalf("DefaultCassandraClientConfig failed: %v", err)
+	}
+	if cfg.WriteConsistency != gocql.Quorum || cfg.ReadConsistency != gocql.Quorum || cfg.SerialConsistency != gocql.Serial {
+		t.Errorf("unexpected default consistency: %v, %v, %v", cfg.WriteConsistency, cfg.ReadConsistency, cfg.SerialConsistency)
+	}
+
+	cfg.WriteConsistency, cfg.SerialConsistency = gocql.LocalQuorum, gocql.LocalSerial
+	cfg.LocalDC = "dc1"
+	cfg.MaxRetries = 5
+	cfg.Compression = true
+	cfg.Username, cfg.Password = "cdc", "secret"
+	cfg.TLSCAFile = "/certs/ca.pem"
+	cfg.MaxPreparedStmts = 50
+	cluster := cfg.ClusterConfig()
+
+	if cluster.Consistency != gocql.LocalQuorum || cluster.SerialConsistency != gocql.LocalSerial || !cluster.DefaultIdempotence {
+		t.Errorf("unexpected cluster consistency: %v, %v", cluster.Consistency, cluster.SerialConsistency)
+	}
+	if retry, ok := cluster.RetryPolicy.(*gocql.ExponentialBackoffRetryPolicy); !ok || retry.NumRetries != 5 {
+		t.Errorf("unexpected retry policy: %#v", cluster.RetryPolicy)
+	}
+	if _, ok := cluster.Compressor.(gocql.SnappyCompressor); !ok {
+		t.Errorf("expected snappy compression, got %#v", cluster.Compressor)
+	}
+	if auth, ok := cluster.Authenticator.(gocql.PasswordAuthenticator); !ok || auth.Username != "cdc" {
+		t.Errorf("unexpected authenticator: %#v", cluster.Authenticator)
+	}
+	if cluster.SslOpts == nil || cluster.SslOpts.CaPath != "/certs/ca.pem" || cluster.MaxPreparedStmts != 50 {
+		t.Errorf("unexpected TLS options or prepared statement cache: %#v, %d", cluster.SslOpts, cluster.MaxPreparedStmts)
+	}
+	if cluster.PoolConfig.HostSelectionPolicy == nil {
+		t.Errorf("expected a token aware host selection policy")
+	}
+}
+
+func TestIsLWT(t *testing.T) {
+	tests := []struct {
+		stmt string
+		lwt  bool
+	}{
+		{"SELECT name from users where id = ? LIMIT 1", false},
+		{"INSERT INTO processed_events (event_id) VALUES (?) IF NOT EXISTS", true},
+		{"UPDATE users SET name = ? WHERE id = ? IF name = ?", true},
+		{"DELETE FROM users WHERE id = ? IF EXISTS", true},
+		{"UPDATE orders SET status = ?, is_deleted = ? WHERE order_id = ?", false},
+		{"INSERT INTO users (id, name, notified) VALUES (?, ?, ?)", false},
+	}
+	for _, tt := range tests {
+		if isLWT(tt.stmt) != tt.lwt {
+			t.Errorf("%q: expected lwt=%t", tt.stmt, tt.lwt)
+		}
+	}
+}
+
+func testUserInsertEvent() *model.ChangeEvent {
+	return &model.ChangeEvent{
+		Op:      "c",
+		EventID: "users-100",
+		TsMs:    1756396978300,
+		Row: map[string]interface{}{
+			"id":         testUserID,
+			"name":       "Alice",
+			"dob":        11172,
+			"created_at": "2025-08-28T16:02:58.281604Z",
+		},
+	}
+}
+
+func TestApplyChange_Dedup(t *testing.T) {
+	const insertEvent = "INSERT INTO processed_events (event_id, topic, ts_ms, processed_at) VALUES (?, ?, ?, ?) IF NOT EXISTS"
+	const deleteEvent = "DELETE FROM processed_events WHERE event_id = ?"
+	const insertUser = "INSERT INTO users (id, name, dob, created_at, is_deleted) VALUES (?, ?, ?, ?, ?)"
+
+	tests := []struct {
+		name          string
+		casNotApplied bool
+		casErr        error
+		execErr       error
+		wantErr       bool
+		wantQueries   []string
+	}{
+		{name: "new event", wantQueries: []string{insertEvent, insertUser}},
+		{name: "duplicate event", casNotApplied: true, wantQueries: []string{insertEvent}},
+		{name: "lightweight transaction error", casErr: errors.New("timeout"), wantErr: true, wantQueries: []string{insertEvent}},
+		{name: "apply error", execErr: errors.New("unavailable"), wantErr: true,
+			wantQueries: []string{insertEvent, insertUser, deleteEvent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockSession{casNotApplied: tt.casNotApplied, casErr: tt.casErr}
+			if tt.execErr != nil {
+				session.execErr = func(stmt string) error {
+					if stmt == insertUser {
+						return tt.execErr
+					}
+					return nil
+				}
+			}
+			client := &CassandraClient{session: session, serialCons: gocql.LocalSerial}
+
+			err := client.ApplyChange(config.DebeziumUsersTopic, testUserInsertEvent())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(session.executedQueries, tt.wantQueries) {
+				t.Fatalf("unexpected queries: %q", session.executedQueries)
+			}
+			if session.args[0][0] != "users-100" || session.serial[0] != gocql.LocalSerial {
+				t.Errorf("unexpected lightweight transaction: event id %v, serial consistency %v", session.args[0][0], session.serial[0])
+			}
+			if tt.execErr != nil && session.args[2][0] != "users-100" {
+				t.Errorf("expected the processed event to be removed, got %v", session.args[2])
+			}
+		})
+	}
+}
+
+func TestApplyChange_DedupModes(t *testing.T) {
+	session := &mockSession{}
+	client := &CassandraClient{session: session}
+
+	client.SetDedupMode(DedupMode{Scope: "replay-7"})
+	if err := client.ApplyChange(config.DebeziumUsersTopic, testUserInsertEvent()); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if session.args[0][0] != "replay-7/users-100" {
+		t.Errorf("expected a scoped event id, got %v", session.args[0][0])
+	}
+
+	session.executedQueries, session.args = nil, nil
+	client.SetDedupMode(DedupMode{Bypass: true})
+	if err := client.ApplyChange(config.DebeziumUsersTopic, testUserInsertEvent()); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if len(session.executedQueries) != 1 || isLWT(session.executedQueries[0]) {
+		t.Errorf("expected only the insert of the user, got %q", session.executedQueries)
+	}
+}
+
+func TestApplyUserChange_ReadConsistency(t *testing.T) {
+	session := &mockSession{}
+	client := &CassandraClient{session: session, readCons: gocql.LocalOne}
+
+	ev := testUserInsertEvent()
+	ev.Op = "u"
+	ev.Row["modified_at"] = "2025-08-29T10:00:00Z"
+	if err := client.applyUserChange(ev); err != nil {
+		t.Fatalf("applyUserChange failed: %v", err)
+	}
+	if session.executedQueries[0] != "SELECT name from users where id = ? LIMIT 1" || session.consistency[0] != gocql.LocalOne {
+		t.Errorf("expected the select to use the read consistency, got %q with %v", session.executedQueries[0], session.consistency[0])
+	}
+	if session.consistency[1] != 0 {
+		t.Errorf("expected the writes to use the session's consistency, got %v", session.consistency[1])
+	}
+}
+
+// apply change events of the users table to a client on an in-memory session
+func applyUserEvents(t *testing.T, client *CassandraClient, events ...*model.ChangeEvent) {
+	t.Helper()
+	for _, ev := range events {
+		if err := client.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange of %s failed: %v", ev.EventID, err)
+		}
+	}
+}
+
+func userEvent(eventID string, op string, name string, isDeleted bool) *model.ChangeEvent {
+	return &model.ChangeEvent{
+		Op:      op,
+		EventID: eventID,
+		TsMs:    1756396978300,
+		Row: map[string]interface{}{
+			"id":          testUserID,
+			"name":        name,
+			"dob":         11172,
+			"created_at":  "2025-08-28T16:02:58.281604Z",
+			"modified_at": "2025-08-29T10:00:00Z",
+			"is_deleted":  isDeleted,
+		},
+	}
+}
+
+func TestCassandraClient_UserLifecycle(t *testing.T) {
+	session := newMemorySession()
+	client := &CassandraClient{session: session}
+
+	applyUserEvents(t, client,
+		userEvent("e1", "c", "Alice", false),
+		userEvent("e2", "u", "Alicia", false), // rename: the row with the old clustering key is removed
+		userEvent("e3", "u", "Alicia", true),  // soft delete
+	)
+
+	rows := session.rows(t, "users", "id", testUserUUID)
+	if len(rows) != 1 || rows[0]["name"] != "Alicia" || rows[0]["is_deleted"] != true {
+		t.Fatalf("unexpected users: %v", rows)
+	}
+	if dob := rows[0]["dob"].(time.Time).Format("2006-01-02"); dob != "2000-08-03" {
+		t.Errorf("unexpected dob: %s", dob)
+	}
+	if events := session.rows(t, "processed_events"); len(events) != 3 {
+		t.Errorf("expected 3 processed events, got %d", len(events))
+	}
+
+	// redelivered events are skipped, so the old name isn't restored
+	applyUserEvents(t, client, userEvent("e1", "c", "Alice", false), userEvent("e2", "u", "Alicia", false))
+	rows = session.rows(t, "users", "id", testUserUUID)
+	if len(rows) != 1 || rows[0]["name"] != "Alicia" || rows[0]["is_deleted"] != true {
+		t.Errorf("expected the duplicate events to be skipped, got %v", rows)
+	}
+}
+
+func TestCassandraClient_OrderLifecycle(t *testing.T) {
+	session := newMemorySession()
+	client := &CassandraClient{session: session}
+
+	order := func(eventID string, op string, status string) *model.ChangeEvent {
+		return &model.ChangeEvent{Op: op, EventID: eventID, Row: map[string]interface{}{
+			"id": testOrderID, "user_id": testUserID, "status": status, "quantity": 2, "total_amount": "J0Q=",
+			"placed_at": "2025-08-28T16:02:58.281604Z", "modified_at": "2025-08-29T10:00:00Z", "is_deleted": false,
+		}}
+	}
+	for _, ev := range []*model.ChangeEvent{order("o1", "c", "PLACED"), order("o2", "u", "SHIPPED")} {
+		if err := client.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+
+	for _, table := range []string{"orders", "orders_by_user"} {
+		rows := session.rows(t, table, "order_id", testOrderUUID, "user_id", testUserUUID)
+		if len(rows) != 1 || rows[0]["status"] != "SHIPPED" || rows[0]["quantity"] != 2 || rows[0]["total_amount"].(*inf.Dec).Cmp(inf.NewDec(10052, 2)) != 0 {
+			t.Errorf("unexpected %s: %v", table, rows)
+		}
+	}
+	if rows := session.rows(t, "orders_by_user", "user_id", testUserUUID); len(rows) != 1 {
+		t.Errorf("expected the order in the user's partition, got %v", rows)
+	}
+}
+
+func TestCassandraClient_ApplySnapshotRow(t *testing.T) {
+	session := newMemorySession()
+	client := &CassandraClient{session: session}
+
+	user := func(name string, modifiedAt interface{}, isDeleted bool) model.JsonMap {
+		return model.JsonMap{"id": testUserID, "name": name, "dob": 11172, "created_at": "2025-08-28T16:02:58.281604Z",
+			"modified_at": modifiedAt, "is_deleted": isDeleted}
+	}
+	order := func(id string, status string, modifiedAt interface{}) model.JsonMap {
+		return model.JsonMap{"id": id, "user_id": testUserID, "status": status, "quantity": 2, "total_amount": "J0Q=",
+			"placed_at": "2025-08-28T16:02:58.281604Z", "modified_at": modifiedAt, "is_deleted": false}
+	}
+	const otherOrderID = "0d5a3ac1-4b8e-4c47-9d0c-5f1ee8f1b2a4"
+	snapshot := []struct {
+		topic string
+		row   model.JsonMap
+	}{
+		{config.DebeziumUsersTopic, user("Alice", "2025-08-28T16:02:58.281604Z", false)}, // created
+		{config.DebeziumUsersTopic, user("Bob", nil, true)},                              // soft deleted, never modified
+		{config.DebeziumOrdersTopic, order(testOrderID, "SHIPPED", "2025-08-29T10:00:00Z")},
+		{config.DebeziumOrdersTopic, order(otherOrderID, "PLACED", nil)}, // a null modified_at is unmodified
+	}
+	for _, r := range snapshot {
+		if err := client.ApplySnapshotRow(r.topic, r.row); err != nil {
+			t.Fatalf("ApplySnapshotRow of %v failed: %v", r.row, err)
+		}
+	}
+
+	createdAt := time.Date(2025, 8, 28, 16, 2, 58, 281000000, time.UTC)
+	if rows := session.rows(t, "users", "id", testUserUUID, "name", "Alice"); len(rows) != 1 ||
+		rows[0]["is_deleted"] != false || !rows[0]["modified_at"].(time.Time).IsZero() {
+		t.Errorf("expected the created user without a modification, got %v", rows)
+	}
+	// the soft deleted user is deleted as of its creation
+	if rows := session.rows(t, "users", "id", testUserUUID, "name", "Bob"); len(rows) != 1 ||
+		rows[0]["is_deleted"] != true || !rows[0]["modified_at"].(time.Time).Equal(createdAt) {
+		t.Errorf("expected the soft deleted user, got %v", rows)
+	}
+
+	for _, table := range []string{"orders", "orders_by_user"} {
+		if rows := session.rows(t, table, "order_id", testOrderUUID, "user_id", testUserUUID); len(rows) != 1 ||
+			rows[0]["status"] != "SHIPPED" || !rows[0]["modified_at"].(time.Time).Equal(time.Date(2025, 8, 29, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected the modified order in %s, got %v", table, rows)
+		}
+		if rows := session.rows(t, table, "order_id", parseUUID(otherOrderID), "user_id", testUserUUID); len(rows) != 1 ||
+			rows[0]["status"] != "PLACED" || !rows[0]["modified_at"].(time.Time).IsZero() {
+			t.Errorf("expected the unmodified order in %s, got %v", table, rows)
+		}
+	}
+}
+
+func TestCassandraClient_RetryAfterFailure(t *testing.T) {
+	session := newMemorySession()
+	client := &CassandraClient{session: session}
+
+	// the write of the user fails once (e.g. a timeout), so the event must not be recorded as processed
+	failures := 1
+	session.fail = func(stmt string) error {
+		if strings.HasPrefix(stmt, "INSERT INTO users") && failures > 0 {
+			failures--
+			return errors.New("write timeout")
+		}
+		return nil
+	}
+
+	if err := client.ApplyChange(config.DebeziumUsersTopic, userEvent("e1", "c", "Alice", false)); err == nil {
+		t.Fatalf("expected the first attempt to fail")
+	}
+	if events := session.rows(t, "processed_events"); len(events) != 0 {
+		t.Fatalf("expected no processed events, got %v", events)
+	}
+
+	applyUserEvents(t, client, userEvent("e1", "c", "Alice", false))
+	if rows := session.rows(t, "users"); len(rows) != 1 || rows[0]["name"] != "Alice" {
+		t.Errorf("expected the retried event to be applied, got %v", rows)
+	}
+}
+
+func TestCassandraClient_Ping(t *testing.T) {
+	session := &mockSession{}
+	client := &CassandraClient{session: session}
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if session.executedQueries[0] != "SELECT release_version FROM system.local" || session.consistency[0] != gocql.One {
+		t.Errorf("unexpected ping query: %s (consistency %v)", session.executedQueries[0], session.consistency[0])
+	}
+
+	session.execErr = func(stmt string) error { return errors.New("no hosts available") }
+	if err := client.Ping(); err == nil {
+		t.Errorf("expected the ping to fail")
+	}
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"strings"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// ChangeSink is implemented by every destination the CDC consumer can apply change events to.
+// `topic` is the Debezium topic the event was read from (e.g. "cdc.public.users")
+type ChangeSink interface {
+	ApplyChange(topic string, ev *model.ChangeEvent) error
+	Close()
+}
+
+// BufferedSink is a ChangeSink that buffers change events before persisting them.
+// Kafka offsets of applied events must only be committed after Flush has returned without an error.
+type BufferedSink interface {
+	ChangeSink
+	Flush() error
+}
+
+// DedupSink is a ChangeSink deduplicating change events by their event id, which can be bypassed or scoped
+// (e.g. to apply already processed events again when replaying a topic)
+type DedupSink interface {
+	ChangeSink
+	SetDedupMode(m DedupMode)
+}
+
+// PingableSink is a ChangeSink whose destination can be checked to be reachable (e.g. for readiness probes)
+type PingableSink interface {
+	ChangeSink
+	Ping() error
+}
+
+// check that existing sinks satisfy the interfaces
+var (
+	_ ChangeSink   = (*CassandraClient)(nil)
+	_ ChangeSink   = (*PostgresReplicaSink)(nil)
+	_ ChangeSink   = (*SQLiteSink)(nil)
+	_ DedupSink    = (*CassandraClient)(nil)
+	_ PingableSink = (*CassandraClient)(nil)
+	_ PingableSink = (*PostgresReplicaSink)(nil)
+	_ DedupSink    = (*SQLiteSink)(nil)
+	_ ChangeSink   = (*RedisCacheSink)(nil)
+	_ BufferedSink = (*FileSink)(nil)
+	_ BufferedSink = (*ParquetSink)(nil)
+	_ BufferedSink = (*WebhookSink)(nil)
+	_ BufferedSink = (*SearchIndexSink)(nil)
+)
+
+// extract the source table name from a Debezium topic name (i.e. <prefix>.<schema>.<table>)
+func tableFromTopic(topic string) string {
+	return topic[strings.LastIndex(topic, ".")+1:]
+}
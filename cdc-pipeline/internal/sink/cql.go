@@ -0,0 +1,260 @@
+package sink
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cqlTable describes a table of the Cassandra schema (see sql/migrations/cassandra)
+type cqlTable struct {
+	name          string
+	partitionKey  []string
+	clusteringKey []string
+	columns       []cqlColumn
+}
+
+type cqlColumn struct {
+	name    string
+	cqlType string // "uuid", "text", "date", "timestamp", "int", "bigint", "decimal" or "boolean"
+}
+
+// tables of the cdc keyspace, for sessions emulating Cassandra
+var cassandraTables = []cqlTable{
+	{
+		name:          "users",
+		partitionKey:  []string{"id"},
+		clusteringKey: []string{"name"},
+		columns: []cqlColumn{
+			{"id", "uuid"}, {"name", "text"}, {"dob", "date"}, {"created_at", "timestamp"},
+			{"modified_at", "timestamp"}, {"is_deleted", "boolean"},
+		},
+	},
+	{
+		name:          "orders",
+		partitionKey:  []string{"order_id"},
+		clusteringKey: []string{"user_id"},
+		columns: []cqlColumn{
+			{"order_id", "uuid"}, {"user_id", "uuid"}, {"status", "text"}, {"quantity", "int"},
+			{"total_amount", "decimal"}, {"placed_at", "timestamp"}, {"modified_at", "timestamp"}, {"is_deleted", "boolean"},
+		},
+	},
+	{
+		name:          "orders_by_user",
+		partitionKey:  []string{"user_id"},
+		clusteringKey: []string{"order_id"},
+		columns: []cqlColumn{
+			{"user_id", "uuid"}, {"order_id", "uuid"}, {"status", "text"}, {"quantity", "int"},
+			{"total_amount", "decimal"}, {"placed_at", "timestamp"}, {"modified_at", "timestamp"}, {"is_deleted", "boolean"},
+		},
+	},
+	{
+		name:         "processed_events",
+		partitionKey: []string{"event_id"},
+		columns: []cqlColumn{
+			{"event_id", "text"}, {"topic", "text"}, {"ts_ms", "bigint"}, {"processed_at", "timestamp"},
+		},
+	},
+}
+
+func lookupCQLTable(name string) (*cqlTable, error) {
+	for i := range cassandraTables {
+		if cassandraTables[i].name == name {
+			return &cassandraTables[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown table: %s", name)
+}
+
+// primary key columns (partition key followed by clustering key)
+func (t *cqlTable) primaryKey() []string {
+	return append(append([]string{}, t.partitionKey...), t.clusteringKey...)
+}
+
+func (t *cqlTable) columnType(name string) (string, error) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c.cqlType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown column '%s' in table %s", name, t.name)
+}
+
+// cqlStatement is a parsed CQL statement of one of the forms used by the cassandra sink.
+// Only bind markers (`?`) are supported as values, and WHERE clauses may only contain equality conditions.
+type cqlStatement struct {
+	kind        string // "INSERT", "UPDATE", "DELETE" or "SELECT"
+	table       string
+	columns     []string // INSERT: inserted columns, UPDATE: assigned columns, SELECT: selected columns (nil for `*`)
+	where       []string // columns of the WHERE clause conditions
+	ifNotExists bool
+	limit       int // 0 = no limit
+}
+
+var (
+	cqlInsertRegex = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+(\w+)\s*\(([^)]*)\)\s*VALUES\s*\(([^)]*)\)\s*(IF\s+NOT\s+EXISTS)?\s*;?\s*$`)
+	cqlUpdateRegex = regexp.MustCompile(`(?is)^\s*UPDATE\s+(\w+)\s+SET\s+(.+?)\s+WHERE\s+(.+?)\s*;?\s*$`)
+	cqlDeleteRegex = regexp.MustCompile(`(?is)^\s*DELETE\s+FROM\s+(\w+)\s+WHERE\s+(.+?)\s*;?\s*$`)
+	cqlSelectRegex = regexp.MustCompile(`(?is)^\s*SELECT\s+(.+?)\s+FROM\s+(\w+)(?:\s+WHERE\s+(.+?))?(?:\s+LIMIT\s+(\d+))?\s*;?\s*$`)
+	cqlAndRegex    = regexp.MustCompile(`(?i)\s+AND\s+`)
+	cqlAssignRegex = regexp.MustCompile(`^(\w+)\s*=\s*\?$`)
+)
+
+func parseCQL(stmt string) (*cqlStatement, error) {
+	if m := cqlInsertRegex.FindStringSubmatch(stmt); m != nil {
+		columns := splitCQLList(m[2])
+		values := splitCQLList(m[3])
+		if len(columns) != len(values) {
+			return nil, fmt.Errorf("number of columns and values don't match: %s", stmt)
+		}
+		for _, v := range values {
+			if v != "?" {
+				return nil, fmt.Errorf("only bind markers are supported as values: %s", stmt)
+			}
+		}
+		return &cqlStatement{kind: "INSERT", table: m[1], columns: columns, ifNotExists: m[4] != ""}, nil
+	}
+
+	if m := cqlUpdateRegex.FindStringSubmatch(stmt); m != nil {
+		columns, err := parseCQLConditions(splitCQLList(m[2]))
+		if err != nil {
+			return nil, err
+		}
+		where, err := parseCQLConditions(cqlAndRegex.Split(m[3], -1))
+		if err != nil {
+			return nil, err
+		}
+		return &cqlStatement{kind: "UPDATE", table: m[1], columns: columns, where: where}, nil
+	}
+
+	if m := cqlDeleteRegex.FindStringSubmatch(stmt); m != nil {
+		where, err := parseCQLConditions(cqlAndRegex.Split(m[2], -1))
+		if err != nil {
+			return nil, err
+		}
+		return &cqlStatement{kind: "DELETE", table: m[1], where: where}, nil
+	}
+
+	if m := cqlSelectRegex.FindStringSubmatch(stmt); m != nil {
+		st := &cqlStatement{kind: "SELECT", table: m[2]}
+		if strings.TrimSpace(m[1]) != "*" {
+			st.columns = splitCQLList(m[1])
+		}
+		if m[3] != "" {
+			where, err := parseCQLConditions(cqlAndRegex.Split(m[3], -1))
+			if err != nil {
+				return nil, err
+			}
+			st.where = where
+		}
+		if m[4] != "" {
+			st.limit, _ = strconv.Atoi(m[4])
+		}
+		return st, nil
+	}
+
+	return nil, fmt.Errorf("unsupported CQL statement: %s", stmt)
+}
+
+// split a comma separated list and trim its items
+func splitCQLList(s string) []string {
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// parse `column = ?` conditions (or assignments) and return their column names
+func parseCQLConditions(conds []string) ([]string, error) {
+	columns := make([]string, 0, len(conds))
+	for _, c := range conds {
+		m := cqlAssignRegex.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			return nil, fmt.Errorf("unsupported condition: %s", c)
+		}
+		columns = append(columns, m[1])
+	}
+	return columns, nil
+}
+
+// parse a statement and convert its values to the values stored for their columns (see sqliteValue)
+func prepareCQL(stmt string, values []interface{}) (*cqlStatement, *cqlTable, []interface{}, error) {
+	st, err := parseCQL(stmt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	t, err := lookupCQLTable(st.table)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	args := make([]interface{}, len(values))
+	bound := st.boundColumns()
+	if len(bound) != len(values) {
+		return nil, nil, nil, fmt.Errorf("expected %d values, got %d: %s", len(bound), len(values), stmt)
+	}
+	for i, c := range bound {
+		typ, err := t.columnType(c)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if args[i], err = sqliteValue(typ, values[i]); err != nil {
+			return nil, nil, nil, fmt.Errorf("column '%s': %w", c, err)
+		}
+	}
+	return st, t, args, nil
+}
+
+// columns the bind markers belong to (in order)
+func (st *cqlStatement) boundColumns() []string {
+	switch st.kind {
+	case "INSERT":
+		return st.columns
+	case "UPDATE":
+		return append(append([]string{}, st.columns...), st.where...)
+	default:
+		return st.where
+	}
+}
+
+// checkCQLWrite rejects the writes Cassandra rejects,
+// returns the columns written by an INSERT or UPDATE (in the order of the bound values)
+func checkCQLWrite(st *cqlStatement, t *cqlTable, args []interface{}) ([]string, error) {
+	switch st.kind {
+	case "INSERT":
+		return st.columns, checkPrimaryKey(t, st.columns, args)
+	case "UPDATE":
+		for _, c := range st.columns {
+			if slices.Contains(t.primaryKey(), c) {
+				return nil, fmt.Errorf("primary key column '%s' can not be updated", c)
+			}
+		}
+		if len(st.where) != len(t.primaryKey()) {
+			return nil, fmt.Errorf("some primary key parts are missing in the WHERE clause")
+		}
+		cols := st.boundColumns()
+		return cols, checkPrimaryKey(t, cols, args)
+	case "DELETE":
+		for _, pk := range t.partitionKey {
+			if !slices.Contains(st.where, pk) {
+				return nil, fmt.Errorf("partition key column '%s' is missing in the WHERE clause", pk)
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("not a write statement")
+}
+
+// Cassandra rejects writes without a (non null) value for every primary key column
+func checkPrimaryKey(t *cqlTable, cols []string, args []interface{}) error {
+	for _, pk := range t.primaryKey() {
+		i := slices.Index(cols, pk)
+		if i < 0 || args[i] == nil {
+			return fmt.Errorf("missing value for primary key column '%s' of table %s", pk, t.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,541 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+const (
+	manifestFileName = "manifest.jsonl"
+	partFileSuffix   = ".part"   // suffix of a file that is still being written
+	syncedFileSuffix = ".synced" // suffix of the sync marker of a file that is still being written
+)
+
+// syncMarker records how many records of a file that is still being written were synced by the last Flush
+// (a crash may leave records that were written to the file later, whose offsets weren't committed)
+type syncMarker struct {
+	Records int `json:"records"`
+}
+
+// FileSinkConfig configures a FileSink
+type FileSinkConfig struct {
+	Dir          string        // root directory (one sub-directory is created per source table)
+	MaxFileBytes int64         // rotate a file once this many (uncompressed) bytes are written to it, 0 = no limit
+	MaxFileAge   time.Duration // rotate a file once it has been open for this long, 0 = no limit
+	Gzip         bool          // gzip compress the files
+}
+
+// FileRecord is a single line of a change file
+type FileRecord struct {
+	Topic     string        `json:"topic"`
+	Partition int           `json:"partition"`
+	Offset    int64         `json:"offset"`
+	Op        string        `json:"op"`
+	TsMs      int64         `json:"ts_ms"`
+	EventID   string        `json:"event_id"`
+	Row       model.JsonMap `json:"row"`
+	Before    model.JsonMap `json:"before,omitempty"` // the row before the change (only its key for deletes by default)
+}
+
+// OffsetRange is the (inclusive) range of kafka offsets of a partition written to a file
+type OffsetRange struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+}
+
+// ManifestEntry describes a closed change file.
+// Entries are appended to the table's manifest in the order the files were closed.
+type ManifestEntry struct {
+	File     string              `json:"file"` // file name (relative to the table directory)
+	Topic    string              `json:"topic"`
+	Records  int                 `json:"records"`
+	Offsets  map[int]OffsetRange `json:"offsets"` // offset range per partition
+	OpenedAt time.Time           `json:"opened_at"`
+	ClosedAt time.Time           `json:"closed_at"`
+}
+
+// FileSink writes change events to rotating newline-delimited JSON files, one directory per source table
+type FileSink struct {
+	cfg   FileSinkConfig
+	mu    sync.Mutex
+	files map[string]*changeFile // open file per table
+	seq   int                    // file sequence number (makes file names unique within a process)
+	now   func() time.Time
+}
+
+// an open change file
+type changeFile struct {
+	dir   string // table directory
+	f     *os.File
+	gz    *gzip.Writer
+	w     *bufio.Writer
+	bytes int64
+	entry ManifestEntry
+}
+
+// NewFileSink creates the root directory (if needed) and returns a new FileSink.
+// Files left open by a previous run are recovered (see Flush).
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create file sink directory: %w", err)
+	}
+	s := &FileSink{cfg: cfg, files: make(map[string]*changeFile), now: time.Now}
+	if err := s.recoverPartFiles(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ApplyChange appends the change event to the current file of its source table
+// (rotating the file first if it has reached its maximum age)
+func (s *FileSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+
+	line, err := json.Marshal(FileRecord{
+		Topic:     topic,
+		Partition: ev.Partition,
+		Offset:    ev.Offset,
+		Op:        ev.Op,
+		TsMs:      ev.TsMs,
+		EventID:   ev.EventID,
+		Row:       ev.Row,
+		Before:    ev.Before,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal file record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	table := tableFromTopic(topic)
+	cf := s.files[table]
+
+	if cf != nil && s.cfg.MaxFileAge > 0 && s.now().Sub(cf.entry.OpenedAt) >= s.cfg.MaxFileAge {
+		if err := s.rotate(table); err != nil {
+			return err
+		}
+		cf = nil
+	}
+	if cf == nil {
+		if cf, err = s.open(table, topic); err != nil {
+			return err
+		}
+	}
+
+	if _, err := cf.w.Write(line); err != nil {
+		return fmt.Errorf("write to %s: %w", cf.f.Name(), err)
+	}
+	cf.bytes += int64(len(line))
+	cf.entry.Records++
+	r, ok := cf.entry.Offsets[ev.Partition]
+	if !ok {
+		r.First = ev.Offset
+	}
+	r.Last = ev.Offset
+	cf.entry.Offsets[ev.Partition] = r
+
+	if s.cfg.MaxFileBytes > 0 && cf.bytes >= s.cfg.MaxFileBytes {
+		return s.rotate(table)
+	}
+	return nil
+}
+
+// Flush syncs all open files to disk (and rotates the files that have reached their maximum age).
+// Every event applied before Flush returns without an error is durably stored:
+// the files left open by a crash are closed and recorded in their manifests when the sink is created again.
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for table, cf := range s.files {
+		if s.cfg.MaxFileAge > 0 && s.now().Sub(cf.entry.OpenedAt) >= s.cfg.MaxFileAge {
+			if err := s.rotate(table); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cf.sync(); err != nil {
+			return fmt.Errorf("sync %s: %w", cf.f.Name(), err)
+		}
+		if err := writeSyncMarker(cf.f.Name(), syncMarker{Records: cf.entry.Records}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all open files (and records them in their manifests)
+func (s *FileSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for table := range s.files {
+		if err := s.rotate(table); err != nil {
+			logger.ErrorLogger.Printf("file sink: error while closing files: %v\n", err)
+		}
+	}
+}
+
+// open a new change file for the table
+func (s *FileSink) open(table string, topic string) (*changeFile, error) {
+	dir := filepath.Join(s.cfg.Dir, table)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create table directory: %w", err)
+	}
+
+	// the sequence number restarts with the process, so it's bumped while the name is taken by a file of a previous run
+	// (e.g. a file being recovered right after a crash)
+	openedAt := s.now().UTC()
+	var name string
+	var f *os.File
+	for {
+		s.seq++
+		name = fmt.Sprintf("%s-%s-%06d.jsonl", table, openedAt.Format("20060102T150405.000"), s.seq)
+		if s.cfg.Gzip {
+			name += ".gz"
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			continue
+		}
+
+		var err error
+		f, err = os.OpenFile(filepath.Join(dir, name+partFileSuffix), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create change file: %w", err)
+		}
+		break
+	}
+
+	cf := &changeFile{
+		dir: dir,
+		f:   f,
+		entry: ManifestEntry{
+			File:     name,
+			Topic:    topic,
+			Offsets:  make(map[int]OffsetRange),
+			OpenedAt: openedAt,
+		},
+	}
+	if s.cfg.Gzip {
+		cf.gz = gzip.NewWriter(f)
+		cf.w = bufio.NewWriter(cf.gz)
+	} else {
+		cf.w = bufio.NewWriter(f)
+	}
+	s.files[table] = cf
+
+	logger.DebugLogger.Printf("file sink: opened %s\n", filepath.Join(dir, name))
+	return cf, nil
+}
+
+// close the table's current file, move it to its final name and append it to the manifest
+func (s *FileSink) rotate(table string) error {
+	cf := s.files[table]
+	if cf == nil {
+		return nil
+	}
+	delete(s.files, table)
+
+	partPath := cf.f.Name()
+	if err := cf.close(); err != nil {
+		return fmt.Errorf("close %s: %w", partPath, err)
+	}
+
+	// the closed file no longer needs its sync marker
+	if err := removeIfExists(partPath + syncedFileSuffix); err != nil {
+		return err
+	}
+
+	// an empty file has no offsets to record
+	if cf.entry.Records == 0 {
+		return os.Remove(partPath)
+	}
+
+	if err := os.Rename(partPath, filepath.Join(cf.dir, cf.entry.File)); err != nil {
+		return fmt.Errorf("rename change file: %w", err)
+	}
+	if err := syncDir(cf.dir); err != nil {
+		return err
+	}
+
+	cf.entry.ClosedAt = s.now().UTC()
+	if err := appendManifestEntry(cf.dir, &cf.entry); err != nil {
+		return err
+	}
+
+	logger.DebugLogger.Printf("file sink: closed %s with %d records\n", cf.entry.File, cf.entry.Records)
+	return nil
+}
+
+// flush buffered data and sync it to disk (keeping the file open)
+func (cf *changeFile) sync() error {
+	if err := cf.w.Flush(); err != nil {
+		return err
+	}
+	if cf.gz != nil {
+		if err := cf.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return cf.f.Sync()
+}
+
+// flush buffered data, sync it to disk and close the file
+func (cf *changeFile) close() error {
+	err := cf.w.Flush()
+	if cf.gz != nil && err == nil {
+		err = cf.gz.Close()
+	}
+	if err == nil {
+		err = cf.f.Sync()
+	}
+	if closeErr := cf.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// close the files left open by a previous run: their records synced by the last Flush are written to new files,
+// which are recorded in the manifests as usual (the later records weren't committed, so they're consumed again)
+func (s *FileSink) recoverPartFiles() error {
+	partPaths, err := filepath.Glob(filepath.Join(s.cfg.Dir, "*", "*"+partFileSuffix))
+	if err != nil {
+		return err
+	}
+
+	for _, partPath := range partPaths {
+		marker, err := readSyncMarker(partPath)
+		if err != nil {
+			return fmt.Errorf("recover %s: %w", partPath, err)
+		}
+
+		var records []FileRecord
+		err = readRecords(partPath, func(rec FileRecord) error {
+			if len(records) == marker.Records {
+				return errStopReading
+			}
+			records = append(records, rec)
+			return nil
+		})
+		// the records after the synced ones may be truncated
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return fmt.Errorf("recover %s: %w", partPath, err)
+		}
+		if len(records) < marker.Records {
+			return fmt.Errorf("recover %s: expected %d synced records, found %d (%v)", partPath, marker.Records, len(records), err)
+		}
+
+		for _, rec := range records {
+			if err := s.ApplyChange(rec.Topic, rec.changeEvent()); err != nil {
+				return fmt.Errorf("recover %s: %w", partPath, err)
+			}
+		}
+		if len(records) > 0 {
+			if err := s.rotate(tableFromTopic(records[0].Topic)); err != nil {
+				return fmt.Errorf("recover %s: %w", partPath, err)
+			}
+		}
+		if err := os.Remove(partPath); err != nil {
+			return fmt.Errorf("recover %s: %w", partPath, err)
+		}
+		for _, path := range []string{partPath + syncedFileSuffix, partPath + syncedFileSuffix + ".tmp"} {
+			if err := removeIfExists(path); err != nil {
+				return fmt.Errorf("recover %s: %w", partPath, err)
+			}
+		}
+		logger.InfoLogger.Printf("file sink: recovered %d synced records of %s\n", len(records), partPath)
+	}
+	return nil
+}
+
+// errStopReading stops reading the records of a change file
+var errStopReading = errors.New("stop reading")
+
+// atomically replace the sync marker of a file that is still being written
+func writeSyncMarker(partPath string, marker syncMarker) error {
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return fmt.Errorf("marshal sync marker: %w", err)
+	}
+
+	tmpPath := partPath + syncedFileSuffix + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("create sync marker: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write sync marker: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, partPath+syncedFileSuffix); err != nil {
+		return fmt.Errorf("rename sync marker: %w", err)
+	}
+	return syncDir(filepath.Dir(partPath))
+}
+
+// read the sync marker of a file that is still being written (nothing was synced if it has none)
+func readSyncMarker(partPath string) (syncMarker, error) {
+	var marker syncMarker
+	data, err := os.ReadFile(partPath + syncedFileSuffix)
+	if os.IsNotExist(err) {
+		return marker, nil
+	} else if err != nil {
+		return marker, err
+	}
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return marker, fmt.Errorf("decode sync marker: %w", err)
+	}
+	return marker, nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sync a directory to make a rename within it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func appendManifestEntry(dir string, entry *ManifestEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal manifest entry: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, manifestFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return f.Sync()
+}
+
+// ReadManifest returns the manifest entries of a table (in the order its files were closed)
+func ReadManifest(dir string, table string) ([]ManifestEntry, error) {
+	f, err := os.Open(filepath.Join(dir, table, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ManifestEntry
+	dec := json.NewDecoder(f)
+	for {
+		var entry ManifestEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode manifest entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ReplayFiles reads back the change events of every file listed in the table's manifest
+// (in manifest order) and passes each of them to fn along with its topic.
+// Files not (yet) in the manifest are ignored, so a replay always sees the same sequence of events.
+func ReplayFiles(dir string, table string, fn func(topic string, ev *model.ChangeEvent) error) error {
+	entries, err := ReadManifest(dir, table)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := readRecords(filepath.Join(dir, table, entry.File), func(rec FileRecord) error {
+			return fn(rec.Topic, rec.changeEvent())
+		})
+		if err != nil {
+			return fmt.Errorf("replay %s: %w", entry.File, err)
+		}
+	}
+	return nil
+}
+
+// read the records of a (possibly gzip compressed) change file and pass each of them to fn
+func readRecords(path string, fn func(rec FileRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if filepath.Ext(strings.TrimSuffix(path, partFileSuffix)) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err == io.EOF { // nothing was written to the file yet
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var rec FileRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// the change event the record was written for
+func (rec *FileRecord) changeEvent() *model.ChangeEvent {
+	return &model.ChangeEvent{
+		Op:        rec.Op,
+		Row:       rec.Row,
+		Before:    rec.Before,
+		TsMs:      rec.TsMs,
+		EventID:   rec.EventID,
+		Partition: rec.Partition,
+		Offset:    rec.Offset,
+	}
+}
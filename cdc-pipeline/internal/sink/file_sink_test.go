@@ -0,0 +1,278 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+func newTestUserEvent(partition int, offset int64) *model.ChangeEvent {
+	return &model.ChangeEvent{
+		Op: "c",
+		Row: map[string]interface{}{
+			"id":   testUserID,
+			"name": "Alice",
+		},
+		TsMs:      1690000000000 + offset,
+		EventID:   fmt.Sprintf("[%d]", offset),
+		Partition: partition,
+		Offset:    offset,
+	}
+}
+
+func TestFileSink_RotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileSink(FileSinkConfig{Dir: dir, MaxFileBytes: 1})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+
+	// every record exceeds the size limit, so each one gets its own file
+	for i := range 3 {
+		if err := fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	fs.Close()
+
+	entries, err := ReadManifest(dir, "users")
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 manifest entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		r := entry.Offsets[0]
+		if r.First != int64(i) || r.Last != int64(i) || entry.Records != 1 {
+			t.Errorf("unexpected manifest entry %d: %+v", i, entry)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "users", entry.File)); err != nil {
+			t.Errorf("file %s listed in manifest not found: %v", entry.File, err)
+		}
+	}
+}
+
+func TestFileSink_RotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileSink(FileSinkConfig{Dir: dir, MaxFileAge: time.Minute})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	now := time.Date(2025, 8, 28, 16, 0, 0, 0, time.UTC)
+	fs.now = func() time.Time { return now }
+
+	fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 10))
+	fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(1, 20))
+	now = now.Add(time.Minute)
+	fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 11))
+
+	// first file is closed by the age check, second file is still open
+	entries, err := ReadManifest(dir, "users")
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 manifest entry, got %d", len(entries))
+	}
+	expected := map[int]OffsetRange{0: {10, 10}, 1: {20, 20}}
+	for p, r := range expected {
+		if entries[0].Offsets[p] != r {
+			t.Errorf("unexpected offset range for partition %d: got %+v, want %+v", p, entries[0].Offsets[p], r)
+		}
+	}
+
+	// the open file is only visible with the part suffix
+	matches, _ := filepath.Glob(filepath.Join(dir, "users", "*"+partFileSuffix))
+	if len(matches) != 1 {
+		t.Errorf("expected 1 open file, got %d", len(matches))
+	}
+
+	// flush only syncs the open file until it reaches its maximum age
+	if err := fs.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	entries, _ = ReadManifest(dir, "users")
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 manifest entry after flush, got %d", len(entries))
+	}
+	now = now.Add(time.Minute)
+	if err := fs.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	entries, _ = ReadManifest(dir, "users")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 manifest entries after flushing an aged file, got %d", len(entries))
+	}
+}
+
+func TestFileSink_RecoverAfterCrash(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		dir := t.TempDir()
+		fs, err := NewFileSink(FileSinkConfig{Dir: dir, Gzip: gz})
+		if err != nil {
+			t.Fatalf("NewFileSink failed: %v", err)
+		}
+		for i := range 3 {
+			if err := fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+				t.Fatalf("ApplyChange failed: %v", err)
+			}
+		}
+		if err := fs.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+
+		// crash after two more events were written to the file but not synced (so their offsets weren't committed),
+		// while a third one is only partially written
+		for i := 3; i < 5; i++ {
+			if err := fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+				t.Fatalf("ApplyChange failed: %v", err)
+			}
+		}
+		cf := fs.files["users"]
+		cf.w.Flush()
+		if cf.gz != nil {
+			cf.gz.Flush()
+		}
+		cf.f.Close()
+		partPath := cf.f.Name()
+		f, err := os.OpenFile(partPath, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("open part file: %v", err)
+		}
+		f.WriteString(`{"topic":"` + config.DebeziumUsersTopic + `","offs`)
+		f.Close()
+
+		if _, err := NewFileSink(FileSinkConfig{Dir: dir, Gzip: gz}); err != nil {
+			t.Fatalf("NewFileSink failed to recover (gzip: %v): %v", gz, err)
+		}
+		for _, path := range []string{partPath, partPath + syncedFileSuffix} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed after recovery (gzip: %v), got %v", path, gz, err)
+			}
+		}
+		entries, err := ReadManifest(dir, "users")
+		if err != nil || len(entries) != 1 || entries[0].Offsets[0] != (OffsetRange{0, 2}) {
+			t.Fatalf("expected a manifest entry for the synced events only (gzip: %v), got %+v (err: %v)", gz, entries, err)
+		}
+		var replayed int
+		if err := ReplayFiles(dir, "users", func(string, *model.ChangeEvent) error { replayed++; return nil }); err != nil {
+			t.Fatalf("ReplayFiles failed (gzip: %v): %v", gz, err)
+		}
+		if replayed != 3 {
+			t.Errorf("expected 3 replayed events (gzip: %v), got %d", gz, replayed)
+		}
+	}
+}
+
+func TestFileSink_RecoverBeforeFirstFlush(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileSink(FileSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	if err := fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	// crash before the written event is synced
+	cf := fs.files["users"]
+	cf.w.Flush()
+	cf.f.Close()
+
+	if _, err := NewFileSink(FileSinkConfig{Dir: dir}); err != nil {
+		t.Fatalf("NewFileSink failed to recover: %v", err)
+	}
+	if _, err := os.Stat(cf.f.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected the part file to be removed after recovery, got %v", err)
+	}
+	if entries, err := ReadManifest(dir, "users"); !os.IsNotExist(err) {
+		t.Errorf("expected no manifest without synced events, got %+v (err: %v)", entries, err)
+	}
+}
+
+func TestFileSink_ReplaysDeletedKey(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileSink(FileSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	del := newTestUserEvent(0, 1)
+	del.Op, del.Row, del.Before = "d", nil, model.JsonMap{"id": testUserID}
+	if err := fs.ApplyChange(config.DebeziumUsersTopic, del); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	fs.Close()
+
+	var replayed []*model.ChangeEvent
+	if err := ReplayFiles(dir, "users", func(_ string, ev *model.ChangeEvent) error {
+		replayed = append(replayed, ev)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReplayFiles failed: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].Op != "d" || replayed[0].Row != nil || replayed[0].Before["id"] != testUserID {
+		t.Errorf("expected the delete with the deleted key, got %+v", replayed)
+	}
+}
+
+func TestFileSink_GzipReplay(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileSink(FileSinkConfig{Dir: dir, MaxFileBytes: 200, Gzip: true})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+
+	for i := range 5 {
+		if err := fs.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(i%2, int64(i))); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if err := fs.ApplyChange(config.DebeziumOrdersTopic, newTestUserEvent(0, 100)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	fs.Close()
+
+	entries, _ := ReadManifest(dir, "users")
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.File, ".jsonl.gz") {
+			t.Errorf("expected a gzip file, got %s", entry.File)
+		}
+	}
+
+	// replay returns the events in the order they were written
+	var offsets []int64
+	err = ReplayFiles(dir, "users", func(topic string, ev *model.ChangeEvent) error {
+		if topic != config.DebeziumUsersTopic {
+			t.Errorf("unexpected topic: %s", topic)
+		}
+		if ev.Row["id"] != testUserID || ev.Op != "c" {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+		offsets = append(offsets, ev.Offset)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReplayFiles failed: %v", err)
+	}
+	if len(offsets) != 5 {
+		t.Fatalf("expected 5 replayed events, got %d", len(offsets))
+	}
+	for i, off := range offsets {
+		if off != int64(i) {
+			t.Errorf("unexpected offset at position %d: got %d, want %d", i, off, i)
+		}
+	}
+
+	// orders have their own directory
+	entries, err = ReadManifest(dir, "orders")
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected 1 manifest entry for orders, got %d (err: %v)", len(entries), err)
+	}
+}
@@ -0,0 +1,281 @@
+package sink
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+// memorySession is an in-memory CassandraSession emulating the tables of the cdc keyspace (see cql.go), so tests can
+// assert on the table contents after applying change events. Values are stored like by the SQLite sink (see sqliteValue)
+// and read back as the Go values gocql returns.
+type memorySession struct {
+	mu     sync.Mutex
+	tables map[string][]map[string]interface{} // rows of the tables, ordered by primary key
+	fail   func(stmt string) error             // injects errors of the statements (if set)
+}
+
+func newMemorySession() *memorySession {
+	return &memorySession{tables: make(map[string][]map[string]interface{})}
+}
+
+func (s *memorySession) Query(stmt string, values ...interface{}) CassandraQuery {
+	return &memoryQuery{s: s, stmt: stmt, values: values}
+}
+
+// rows returns the rows of a table matching the conditions (column, value pairs), ordered by primary key
+func (s *memorySession) rows(t *testing.T, table string, conds ...interface{}) []map[string]interface{} {
+	t.Helper()
+	stmt := "SELECT * FROM " + table
+	for i := 0; i < len(conds); i += 2 {
+		if i == 0 {
+			stmt += " WHERE "
+		} else {
+			stmt += " AND "
+		}
+		stmt += fmt.Sprintf("%s = ?", conds[i])
+	}
+	var values []interface{}
+	for i := 1; i < len(conds); i += 2 {
+		values = append(values, conds[i])
+	}
+
+	rows, err := (&memoryQuery{s: s, stmt: stmt, values: values}).scan()
+	if err != nil {
+		t.Fatalf("select %s: %v", table, err)
+	}
+	return rows
+}
+
+type memoryQuery struct {
+	s      *memorySession
+	stmt   string
+	values []interface{}
+}
+
+func (q *memoryQuery) Consistency(gocql.Consistency) CassandraQuery             { return q }
+func (q *memoryQuery) SerialConsistency(gocql.SerialConsistency) CassandraQuery { return q }
+
+func (q *memoryQuery) Exec() error {
+	_, err := q.exec(make(map[string]interface{}))
+	return err
+}
+
+// MapScan reads the first row of a SELECT statement, returns gocql.ErrNotFound if there is none
+func (q *memoryQuery) MapScan(dest map[string]interface{}) error {
+	rows, err := q.scan()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return gocql.ErrNotFound
+	}
+	for k, v := range rows[0] {
+		dest[k] = v
+	}
+	return nil
+}
+
+// MapScanCAS executes an `INSERT ... IF NOT EXISTS` statement, reports whether the row was inserted
+// (if not, dest has the existing row)
+func (q *memoryQuery) MapScanCAS(dest map[string]interface{}) (bool, error) {
+	st, err := parseCQL(q.stmt)
+	if err != nil {
+		return false, err
+	}
+	if !st.ifNotExists {
+		return false, fmt.Errorf("not a lightweight transaction: %s", q.stmt)
+	}
+	return q.exec(dest)
+}
+
+// execute an INSERT, UPDATE or DELETE statement, reports whether it was applied (only an `IF NOT EXISTS` isn't)
+func (q *memoryQuery) exec(existing map[string]interface{}) (bool, error) {
+	if q.s.fail != nil {
+		if err := q.s.fail(q.stmt); err != nil {
+			return false, err
+		}
+	}
+	st, t, args, err := prepareCQL(q.stmt, q.values)
+	if err != nil {
+		return false, err
+	}
+	if st.kind == "SELECT" {
+		_, err := q.scan()
+		return true, err
+	}
+	cols, err := checkCQLWrite(st, t, args)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", err, q.stmt)
+	}
+
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	rows := q.s.tables[t.name]
+
+	if st.kind == "DELETE" {
+		q.s.tables[t.name] = slices.DeleteFunc(rows, func(row map[string]interface{}) bool {
+			return matchesConditions(row, st.where, args)
+		})
+		return true, nil
+	}
+
+	// INSERT and UPDATE write the columns of the row with the primary key, which is created if it doesn't exist
+	pk := t.primaryKey()
+	pkValues := make([]interface{}, len(pk))
+	for i, c := range pk {
+		pkValues[i] = args[slices.Index(cols, c)]
+	}
+	i := slices.IndexFunc(rows, func(row map[string]interface{}) bool { return matchesConditions(row, pk, pkValues) })
+	if i >= 0 && st.ifNotExists {
+		for c, v := range rows[i] {
+			typ, _ := t.columnType(c)
+			if existing[c], err = cqlValue(typ, v); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	if i < 0 {
+		rows = append(rows, make(map[string]interface{}))
+		i = len(rows) - 1
+	}
+	for j, c := range cols {
+		rows[i][c] = args[j]
+	}
+	slices.SortFunc(rows, func(a, b map[string]interface{}) int { return comparePrimaryKeys(pk, a, b) })
+	q.s.tables[t.name] = rows
+	return true, nil
+}
+
+// run a SELECT statement, returns its rows ordered by primary key
+func (q *memoryQuery) scan() ([]map[string]interface{}, error) {
+	if q.s.fail != nil {
+		if err := q.s.fail(q.stmt); err != nil {
+			return nil, err
+		}
+	}
+	st, t, args, err := prepareCQL(q.stmt, q.values)
+	if err != nil {
+		return nil, err
+	}
+	if st.kind != "SELECT" {
+		return nil, fmt.Errorf("not a SELECT statement: %s", q.stmt)
+	}
+
+	cols := st.columns
+	if cols == nil {
+		for _, c := range t.columns {
+			cols = append(cols, c.name)
+		}
+	}
+
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var result []map[string]interface{}
+	for _, stored := range q.s.tables[t.name] {
+		if !matchesConditions(stored, st.where, args) {
+			continue
+		}
+		row := make(map[string]interface{}, len(cols))
+		for _, c := range cols {
+			typ, err := t.columnType(c)
+			if err != nil {
+				return nil, err
+			}
+			if row[c], err = cqlValue(typ, stored[c]); err != nil {
+				return nil, fmt.Errorf("column '%s': %w", c, err)
+			}
+		}
+		result = append(result, row)
+		if st.limit > 0 && len(result) == st.limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func matchesConditions(row map[string]interface{}, cols []string, values []interface{}) bool {
+	for i, c := range cols {
+		if row[c] != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// order of rows by primary key (stored values are strings or int64, see sqliteValue)
+func comparePrimaryKeys(pk []string, a, b map[string]interface{}) int {
+	for _, c := range pk {
+		var n int
+		switch v := a[c].(type) {
+		case string:
+			n = cmp.Compare(v, b[c].(string))
+		case int64:
+			n = cmp.Compare(v, b[c].(int64))
+		}
+		if n != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func TestMemorySession(t *testing.T) {
+	s := newMemorySession()
+
+	for _, name := range []string{"Carol", "Alice", "Bob"} {
+		if err := s.Query("INSERT INTO users (id, name) VALUES (?, ?)", testUserUUID, name).Exec(); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+	}
+
+	// rows are ordered by clustering key and a LIMIT returns the first ones
+	row := map[string]interface{}{}
+	if err := s.Query("SELECT name from users where id = ? LIMIT 1", testUserUUID).MapScan(row); err != nil || row["name"] != "Alice" {
+		t.Fatalf("expected the first row in clustering order, got %v, %v", row, err)
+	}
+	if err := s.Query("SELECT name from users where id = ?", gocql.TimeUUID()).MapScan(row); err != gocql.ErrNotFound {
+		t.Errorf("expected gocql.ErrNotFound, got %v", err)
+	}
+
+	// an UPDATE only writes the assigned columns (and creates the row if it doesn't exist)
+	if err := s.Query("UPDATE users SET is_deleted = ? WHERE id = ? and name = ?", true, testUserUUID, "Bob").Exec(); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if err := s.Query("UPDATE users SET is_deleted = ? WHERE id = ?", true, testUserUUID).Exec(); err == nil {
+		t.Errorf("expected an error for an UPDATE without the clustering key")
+	}
+	rows := s.rows(t, "users", "id", testUserUUID)
+	if len(rows) != 3 || rows[1]["name"] != "Bob" || rows[1]["is_deleted"] != true || rows[0]["is_deleted"] != false {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+
+	// a DELETE of a partition removes all its rows
+	if err := s.Query("DELETE FROM users WHERE id = ? and name = ?", testUserUUID, "Carol").Exec(); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if rows := s.rows(t, "users"); len(rows) != 2 {
+		t.Errorf("expected 2 rows, got %v", rows)
+	}
+	if err := s.Query("DELETE FROM users WHERE id = ?", testUserUUID).Exec(); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if rows := s.rows(t, "users"); len(rows) != 0 {
+		t.Errorf("expected no rows, got %v", rows)
+	}
+
+	// lightweight transactions
+	const insertEvent = "INSERT INTO processed_events (event_id, topic) VALUES (?, ?) IF NOT EXISTS"
+	if applied, err := s.Query(insertEvent, "e1", "users").MapScanCAS(map[string]interface{}{}); err != nil || !applied {
+		t.Fatalf("expected the first insert to be applied, got %t, %v", applied, err)
+	}
+	existing := map[string]interface{}{}
+	if applied, err := s.Query(insertEvent, "e1", "orders").MapScanCAS(existing); err != nil || applied || existing["topic"] != "users" {
+		t.Errorf("expected the second insert not to be applied, got %t, %v (existing %v)", applied, err, existing)
+	}
+}
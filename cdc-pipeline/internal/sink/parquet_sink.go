@@ -0,0 +1,452 @@
+package sink
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/parquet-go/parquet-go"
+)
+
+// names of the change event metadata columns added to every parquet file
+const (
+	parquetOpColumn   = "__op"
+	parquetTsMsColumn = "__ts_ms"
+	parquetLSNColumn  = "__lsn"
+)
+
+// largest decimal precision that fits in an int64 unscaled value
+const maxInt64DecimalPrecision = 18
+
+// ParquetSinkConfig configures a ParquetSink
+type ParquetSinkConfig struct {
+	Dir string // root directory of the data lake
+}
+
+// ParquetSink buffers change events and writes them to parquet files
+// partitioned as `table=<t>/date=<yyyy-mm-dd>/` (date of the event timestamp in UTC).
+// The parquet schema of a file is derived from the Debezium schema of its first event.
+// A parquet file can only be read once its footer is written, so the files are closed on every Flush
+// (i.e. the consumer's flush settings determine the size of the files).
+type ParquetSink struct {
+	cfg   ParquetSinkConfig
+	mu    sync.Mutex
+	files map[string]*parquetFile // open file per partition directory
+	seq   int                     // file sequence number (makes file names unique within a process)
+	now   func() time.Time
+}
+
+// an open parquet file
+type parquetFile struct {
+	path      string // final path of the file
+	f         *os.File
+	w         *parquet.Writer
+	columns   []parquetColumn // in schema order
+	signature string          // signature of the Debezium columns the schema was derived from
+	rows      int
+}
+
+// parquetColumn maps a change event field to a parquet column
+type parquetColumn struct {
+	name     string
+	node     parquet.Node
+	optional bool
+	value    func(ev *model.ChangeEvent) any    // extract the field value from the event (nil for null)
+	convert  func(v any) (parquet.Value, error) // convert a non-null field value to a parquet value
+}
+
+// NewParquetSink creates the root directory (if needed) and returns a new ParquetSink
+func NewParquetSink(cfg ParquetSinkConfig) (*ParquetSink, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create parquet sink directory: %w", err)
+	}
+	return &ParquetSink{cfg: cfg, files: make(map[string]*parquetFile), now: time.Now}, nil
+}
+
+// ApplyChange buffers the change event in the file of its table and date partition
+func (s *ParquetSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+	if ev.Columns == nil {
+		return fmt.Errorf("parquet sink: change event %s has no schema (schemas must be enabled in the connector's JSON converter)", ev.EventID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	table := tableFromTopic(topic)
+	date := time.UnixMilli(ev.TsMs).UTC().Format("2006-01-02")
+	partition := filepath.Join(fmt.Sprintf("table=%s", table), fmt.Sprintf("date=%s", date))
+	signature := columnsSignature(ev.Columns)
+
+	pf := s.files[partition]
+
+	// a schema change starts a new file
+	if pf != nil && pf.signature != signature {
+		if err := s.closeFile(partition); err != nil {
+			return err
+		}
+		pf = nil
+	}
+	if pf == nil {
+		var err error
+		if pf, err = s.open(partition, ev.Columns); err != nil {
+			return err
+		}
+	}
+
+	row := make(parquet.Row, len(pf.columns))
+	for i, col := range pf.columns {
+		v := col.value(ev)
+		if v == nil {
+			if !col.optional {
+				return parser.Poison(fmt.Errorf("parquet sink: missing value for required column '%s'", col.name))
+			}
+			row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+
+		pv, err := col.convert(v)
+		if err != nil {
+			return parser.Poison(fmt.Errorf("parquet sink: column '%s': %w", col.name, err))
+		}
+		defLevel := 0
+		if col.optional {
+			defLevel = 1
+		}
+		row[i] = pv.Level(0, defLevel, i)
+	}
+
+	if _, err := pf.w.WriteRows([]parquet.Row{row}); err != nil {
+		return fmt.Errorf("parquet sink: write row to %s: %w", pf.path, err)
+	}
+	pf.rows++
+	return nil
+}
+
+// Flush closes all open files.
+// Every event applied before Flush returns without an error is durably stored.
+func (s *ParquetSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for partition := range s.files {
+		if err := s.closeFile(partition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes all open files
+func (s *ParquetSink) Close() {
+	if err := s.Flush(); err != nil {
+		logger.ErrorLogger.Printf("parquet sink: error while closing files: %v\n", err)
+	}
+}
+
+// open a new parquet file in the partition directory
+func (s *ParquetSink) open(partition string, cols []model.Column) (*parquetFile, error) {
+	dir := filepath.Join(s.cfg.Dir, partition)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create partition directory: %w", err)
+	}
+
+	columns, err := parquetColumns(cols)
+	if err != nil {
+		return nil, err
+	}
+
+	// parquet groups order their fields by name, so the columns are sorted the same way
+	group := make(parquet.Group, len(columns))
+	for _, col := range columns {
+		group[col.name] = col.node
+	}
+	schema := parquet.NewSchema(tableFromPartition(partition), group)
+
+	byName := make(map[string]parquetColumn, len(columns))
+	for _, col := range columns {
+		byName[col.name] = col
+	}
+	ordered := make([]parquetColumn, 0, len(columns))
+	for _, field := range schema.Fields() {
+		ordered = append(ordered, byName[field.Name()])
+	}
+
+	s.seq++
+	openedAt := s.now().UTC()
+	path := filepath.Join(dir, fmt.Sprintf("part-%s-%06d.parquet", openedAt.Format("20060102T150405.000"), s.seq))
+
+	f, err := os.OpenFile(path+partFileSuffix, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("create parquet file: %w", err)
+	}
+
+	pf := &parquetFile{
+		path:      path,
+		f:         f,
+		w:         parquet.NewWriter(f, schema),
+		columns:   ordered,
+		signature: columnsSignature(cols),
+	}
+	s.files[partition] = pf
+
+	logger.DebugLogger.Printf("parquet sink: opened %s\n", path)
+	return pf, nil
+}
+
+// write the file footer, sync the file to disk and move it to its final name
+func (s *ParquetSink) closeFile(partition string) error {
+	pf := s.files[partition]
+	if pf == nil {
+		return nil
+	}
+	delete(s.files, partition)
+
+	partPath := pf.f.Name()
+	err := pf.w.Close()
+	if err == nil {
+		err = pf.f.Sync()
+	}
+	if closeErr := pf.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("close %s: %w", partPath, err)
+	}
+
+	if pf.rows == 0 {
+		return os.Remove(partPath)
+	}
+	if err := os.Rename(partPath, pf.path); err != nil {
+		return fmt.Errorf("rename parquet file: %w", err)
+	}
+	if err := syncDir(filepath.Dir(pf.path)); err != nil {
+		return err
+	}
+
+	logger.DebugLogger.Printf("parquet sink: closed %s with %d rows\n", pf.path, pf.rows)
+	return nil
+}
+
+// build the parquet columns for the Debezium row columns (plus the event metadata columns)
+func parquetColumns(cols []model.Column) ([]parquetColumn, error) {
+	columns := []parquetColumn{
+		{
+			name:    parquetOpColumn,
+			node:    parquet.String(),
+			value:   func(ev *model.ChangeEvent) any { return ev.Op },
+			convert: func(v any) (parquet.Value, error) { return parquet.ByteArrayValue([]byte(v.(string))), nil },
+		},
+		{
+			name:    parquetTsMsColumn,
+			node:    parquet.Timestamp(parquet.Millisecond),
+			value:   func(ev *model.ChangeEvent) any { return ev.TsMs },
+			convert: func(v any) (parquet.Value, error) { return parquet.Int64Value(v.(int64)), nil },
+		},
+		{
+			name:     parquetLSNColumn,
+			node:     parquet.Optional(parquet.Int(64)),
+			optional: true,
+			value: func(ev *model.ChangeEvent) any {
+				if ev.LSN == 0 {
+					return nil
+				}
+				return ev.LSN
+			},
+			convert: func(v any) (parquet.Value, error) { return parquet.Int64Value(v.(int64)), nil },
+		},
+	}
+
+	for _, c := range cols {
+		if strings.HasPrefix(c.Name, "__") {
+			return nil, fmt.Errorf("parquet sink: column name '%s' clashes with the metadata columns", c.Name)
+		}
+
+		node, convert, err := parquetType(c)
+		if err != nil {
+			return nil, err
+		}
+
+		name := c.Name
+		columns = append(columns, parquetColumn{
+			name: name,
+			// row columns are always optional as delete events have no row
+			node:     parquet.Optional(node),
+			optional: true,
+			value: func(ev *model.ChangeEvent) any {
+				// a delete is written with the row before it (only its key with the default replica identity)
+				if ev.Op == "d" {
+					return ev.Before[name]
+				}
+				return ev.Row[name]
+			},
+			convert: convert,
+		})
+	}
+	return columns, nil
+}
+
+// map a Debezium column type to a parquet type (and a function converting a JSON value of the column to it)
+func parquetType(c model.Column) (parquet.Node, func(v any) (parquet.Value, error), error) {
+	switch c.Type {
+	case "int8", "int16", "int32":
+		node := parquet.Int(32)
+		if c.Logical == "io.debezium.time.Date" { // days since epoch
+			node = parquet.Date()
+		}
+		return node, func(v any) (parquet.Value, error) {
+			i, err := parser.ParseDebeziumNumber[int64](v)
+			return parquet.Int32Value(int32(i)), err
+		}, nil
+
+	case "int64":
+		node := parquet.Int(64)
+		switch c.Logical {
+		case "io.debezium.time.Timestamp":
+			node = parquet.Timestamp(parquet.Millisecond)
+		case "io.debezium.time.MicroTimestamp":
+			node = parquet.Timestamp(parquet.Microsecond)
+		}
+		return node, func(v any) (parquet.Value, error) {
+			i, err := parser.ParseDebeziumNumber[int64](v)
+			return parquet.Int64Value(i), err
+		}, nil
+
+	case "float", "double":
+		node := parquet.Leaf(parquet.DoubleType)
+		if c.Type == "float" {
+			node = parquet.Leaf(parquet.FloatType)
+		}
+		return node, func(v any) (parquet.Value, error) {
+			f, ok := v.(float64)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a number, got %T", v)
+			}
+			if c.Type == "float" {
+				return parquet.FloatValue(float32(f)), nil
+			}
+			return parquet.DoubleValue(f), nil
+		}, nil
+
+	case "boolean":
+		return parquet.Leaf(parquet.BooleanType), func(v any) (parquet.Value, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a boolean, got %T", v)
+			}
+			return parquet.BooleanValue(b), nil
+		}, nil
+
+	case "string":
+		if c.Logical == "io.debezium.time.ZonedTimestamp" { // RFC3339 formatted timestamp
+			return parquet.Timestamp(parquet.Microsecond), func(v any) (parquet.Value, error) {
+				s, ok := v.(string)
+				if !ok {
+					return parquet.Value{}, fmt.Errorf("expected a timestamp string, got %T", v)
+				}
+				t, err := time.Parse(time.RFC3339Nano, s)
+				if err != nil {
+					return parquet.Value{}, err
+				}
+				return parquet.Int64Value(t.UnixMicro()), nil
+			}, nil
+		}
+		return parquet.String(), func(v any) (parquet.Value, error) {
+			s, ok := v.(string)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a string, got %T", v)
+			}
+			return parquet.ByteArrayValue([]byte(s)), nil
+		}, nil
+
+	case "bytes":
+		if c.Logical == "org.apache.kafka.connect.data.Decimal" {
+			return parquetDecimalType(c)
+		}
+		return parquet.Leaf(parquet.ByteArrayType), func(v any) (parquet.Value, error) {
+			s, ok := v.(string)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a base64 string, got %T", v)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			return parquet.ByteArrayValue(b), err
+		}, nil
+	}
+
+	return nil, nil, fmt.Errorf("parquet sink: unsupported type '%s' of column '%s'", c.Type, c.Name)
+}
+
+// decimals are stored with their unscaled value in an int64 (or as a string if they don't fit one)
+func parquetDecimalType(c model.Column) (parquet.Node, func(v any) (parquet.Value, error), error) {
+	scale, err := strconv.Atoi(c.Params["scale"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("parquet sink: invalid scale of decimal column '%s': %w", c.Name, err)
+	}
+	precision, err := strconv.Atoi(c.Params["connect.decimal.precision"])
+	if err != nil || precision > maxInt64DecimalPrecision {
+		return parquet.String(), func(v any) (parquet.Value, error) {
+			s, ok := v.(string)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a base64 string, got %T", v)
+			}
+			unscaled, err := parser.DebeziumDecimalUnscaled(s)
+			if err != nil {
+				return parquet.Value{}, err
+			}
+			return parquet.ByteArrayValue([]byte(decimalString(unscaled.String(), scale))), nil
+		}, nil
+	}
+
+	return parquet.Decimal(scale, precision, parquet.Int64Type), func(v any) (parquet.Value, error) {
+		s, ok := v.(string)
+		if !ok {
+			return parquet.Value{}, fmt.Errorf("expected a base64 string, got %T", v)
+		}
+		unscaled, err := parser.DebeziumDecimalUnscaled(s)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(unscaled.Int64()), nil
+	}, nil
+}
+
+// format an unscaled decimal number (in base 10) with the given scale
+func decimalString(unscaled string, scale int) string {
+	if scale <= 0 {
+		return unscaled
+	}
+	sign := ""
+	if strings.HasPrefix(unscaled, "-") {
+		sign, unscaled = "-", unscaled[1:]
+	}
+	if len(unscaled) <= scale {
+		unscaled = strings.Repeat("0", scale-len(unscaled)+1) + unscaled
+	}
+	return sign + unscaled[:len(unscaled)-scale] + "." + unscaled[len(unscaled)-scale:]
+}
+
+// signature of a set of columns, used to detect schema changes
+func columnsSignature(cols []model.Column) string {
+	var sb strings.Builder
+	for _, c := range cols {
+		fmt.Fprintf(&sb, "%s:%s:%s:%t:%v;", c.Name, c.Type, c.Logical, c.Optional, c.Params)
+	}
+	return sb.String()
+}
+
+// extract the table name from a `table=<t>/date=<d>` partition path
+func tableFromPartition(partition string) string {
+	dir, _, _ := strings.Cut(partition, string(filepath.Separator))
+	return strings.TrimPrefix(dir, "table=")
+}
@@ -0,0 +1,296 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Debezium orders change event (with schema) as produced by the JSON converter
+const testOrderEventTemplate = `{
+	"schema": {
+		"type": "struct",
+		"fields": [
+			{"type": "struct", "optional": true, "field": "before", "fields": []},
+			{
+				"type": "struct", "optional": true, "name": "cdc.public.orders.Value", "field": "after",
+				"fields": [
+					{"type": "string", "optional": false, "name": "io.debezium.data.Uuid", "version": 1, "field": "id"},
+					{"type": "string", "optional": false, "field": "status"},
+					{"type": "string", "optional": false, "name": "io.debezium.data.Uuid", "version": 1, "field": "user_id"},
+					{"type": "int32", "optional": false, "field": "quantity"},
+					{"type": "bytes", "optional": false, "name": "org.apache.kafka.connect.data.Decimal", "version": 1,
+					 "parameters": {"scale": "2", "connect.decimal.precision": "10"}, "field": "total_amount"},
+					{"type": "string", "optional": true, "name": "io.debezium.time.ZonedTimestamp", "version": 1, "field": "placed_at"},
+					{"type": "string", "optional": true, "name": "io.debezium.time.ZonedTimestamp", "version": 1, "field": "modified_at"},
+					{"type": "boolean", "optional": true, "default": false, "field": "is_deleted"}
+				]
+			}
+		]
+	},
+	"payload": {
+		"op": "%s",
+		"before": null,
+		"after": {
+			"id": "%s",
+			"status": "PLACED",
+			"user_id": "` + testUserID + `",
+			"quantity": 2,
+			"total_amount": "J0Q=",
+			"placed_at": "2025-08-28T16:02:58.281604Z",
+			"modified_at": null,
+			"is_deleted": false
+		},
+		"ts_ms": %d,
+		"source": {"txId": 780, "lsn": %d, "ts_us": 1756396978281604}
+	}
+}`
+
+// read all rows of a parquet file as maps of column name to value
+func readParquetRows(t *testing.T, path string) []map[string]parquet.Value {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open parquet file: %v", err)
+	}
+	defer f.Close()
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+
+	var rows []map[string]parquet.Value
+	fields := r.Schema().Fields()
+	buf := make([]parquet.Row, 10)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			m := make(map[string]parquet.Value)
+			for _, v := range row {
+				m[fields[v.Column()].Name()] = v
+			}
+			rows = append(rows, m)
+		}
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("read parquet rows: %v", err)
+		}
+	}
+}
+
+func TestParquetSink_WritesPartitionedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ps, err := NewParquetSink(ParquetSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewParquetSink failed: %v", err)
+	}
+
+	day1 := time.Date(2025, 8, 28, 16, 2, 58, 0, time.UTC).UnixMilli()
+	day2 := time.Date(2025, 8, 29, 9, 0, 0, 0, time.UTC).UnixMilli()
+	messages := []string{
+		fmt.Sprintf(testOrderEventTemplate, "c", testOrderID, day1, 1001),
+		fmt.Sprintf(testOrderEventTemplate, "c", "6b0ad5b0-8f47-4cbe-8f50-92f4b1a4d6f1", day1, 1002),
+		fmt.Sprintf(testOrderEventTemplate, "u", testOrderID, day2, 1003),
+	}
+	for _, m := range messages {
+		ev, err := parser.ParseDebeziumEvent([]byte(m))
+		if err != nil {
+			t.Fatalf("ParseDebeziumEvent failed: %v", err)
+		}
+		if err := ps.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+
+	// nothing is visible before the files are closed
+	files, _ := filepath.Glob(filepath.Join(dir, "table=orders", "*", "*.parquet"))
+	if len(files) != 0 {
+		t.Fatalf("expected no closed files before flush, got %d", len(files))
+	}
+
+	if err := ps.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	files1, _ := filepath.Glob(filepath.Join(dir, "table=orders", "date=2025-08-28", "*.parquet"))
+	files2, _ := filepath.Glob(filepath.Join(dir, "table=orders", "date=2025-08-29", "*.parquet"))
+	if len(files1) != 1 || len(files2) != 1 {
+		t.Fatalf("expected 1 file per date partition, got %d and %d", len(files1), len(files2))
+	}
+
+	rows := readParquetRows(t, files1[0])
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	row := rows[0]
+	if got := string(row["id"].ByteArray()); got != testOrderID {
+		t.Errorf("unexpected id: got %s, want %s", got, testOrderID)
+	}
+	if got := string(row[parquetOpColumn].ByteArray()); got != "c" {
+		t.Errorf("unexpected op: got %s, want c", got)
+	}
+	if got := row[parquetTsMsColumn].Int64(); got != day1 {
+		t.Errorf("unexpected ts_ms: got %d, want %d", got, day1)
+	}
+	if got := row[parquetLSNColumn].Int64(); got != 1001 {
+		t.Errorf("unexpected lsn: got %d, want 1001", got)
+	}
+	if got := row["quantity"].Int32(); got != 2 {
+		t.Errorf("unexpected quantity: got %d, want 2", got)
+	}
+	if got := row["total_amount"].Int64(); got != 10052 { // unscaled value of 100.52
+		t.Errorf("unexpected total_amount: got %d, want 10052", got)
+	}
+	placedAt, _ := time.Parse(time.RFC3339, "2025-08-28T16:02:58.281604Z")
+	if got := row["placed_at"].Int64(); got != placedAt.UnixMicro() {
+		t.Errorf("unexpected placed_at: got %d, want %d", got, placedAt.UnixMicro())
+	}
+	if !row["modified_at"].IsNull() {
+		t.Errorf("expected null modified_at, got %v", row["modified_at"])
+	}
+	if row["is_deleted"].Boolean() {
+		t.Errorf("expected is_deleted to be false")
+	}
+}
+
+func TestParquetSink_NewFileOnSchemaChange(t *testing.T) {
+	dir := t.TempDir()
+	ps, err := NewParquetSink(ParquetSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewParquetSink failed: %v", err)
+	}
+
+	// the second event's schema no longer has the is_deleted column
+	ts := time.Date(2025, 8, 28, 16, 2, 58, 0, time.UTC).UnixMilli()
+	messages := []string{
+		fmt.Sprintf(testOrderEventTemplate, "c", testOrderID, ts, 1001),
+		strings.Replace(fmt.Sprintf(testOrderEventTemplate, "u", testOrderID, ts, 1002),
+			`,
+					{"type": "boolean", "optional": true, "default": false, "field": "is_deleted"}`, "", 1),
+	}
+	for _, m := range messages {
+		ev, err := parser.ParseDebeziumEvent([]byte(m))
+		if err != nil {
+			t.Fatalf("ParseDebeziumEvent failed: %v", err)
+		}
+		if err := ps.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+
+	// the file of the old schema is closed when the schema changes
+	files, _ := filepath.Glob(filepath.Join(dir, "table=orders", "date=2025-08-28", "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 closed file, got %d", len(files))
+	}
+
+	ps.Close()
+	files, _ = filepath.Glob(filepath.Join(dir, "table=orders", "date=2025-08-28", "*.parquet"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 closed files after close, got %d", len(files))
+	}
+	if rows := readParquetRows(t, files[1]); len(rows) != 1 || rows[0]["__op"].String() != "u" {
+		t.Errorf("expected the update in the second file, got %v", rows)
+	}
+}
+
+func TestParquetSink_DeleteHasDeletedKey(t *testing.T) {
+	dir := t.TempDir()
+	ps, err := NewParquetSink(ParquetSinkConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewParquetSink failed: %v", err)
+	}
+
+	// a delete with the default replica identity only has the key of the deleted row
+	ts := time.Date(2025, 8, 28, 16, 2, 58, 0, time.UTC).UnixMilli()
+	ev, err := parser.ParseDebeziumEvent([]byte(fmt.Sprintf(testOrderEventTemplate, "d", testOrderID, ts, 1001)))
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	ev.Row, ev.Before = nil, model.JsonMap{"id": testOrderID}
+	if err := ps.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	ps.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "table=orders", "date=2025-08-28", "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 closed file, got %d", len(files))
+	}
+	rows := readParquetRows(t, files[0])
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0]["__op"].String() != "d" || rows[0]["id"].String() != testOrderID {
+		t.Errorf("expected the delete with the deleted key, got %v", rows[0])
+	}
+	if !rows[0]["status"].IsNull() {
+		t.Errorf("expected null for a column missing from the deleted key, got %v", rows[0]["status"])
+	}
+}
+
+func TestParquetSink_RequiresSchema(t *testing.T) {
+	ps, err := NewParquetSink(ParquetSinkConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewParquetSink failed: %v", err)
+	}
+	defer ps.Close()
+
+	if err := ps.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err == nil {
+		t.Errorf("expected an error for an event without schema")
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		unscaled string
+		scale    int
+		want     string
+	}{
+		{"10052", 2, "100.52"},
+		{"-5", 2, "-0.05"},
+		{"42", 0, "42"},
+	}
+	for _, tt := range tests {
+		if got := decimalString(tt.unscaled, tt.scale); got != tt.want {
+			t.Errorf("decimalString(%s, %d) = %s, want %s", tt.unscaled, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestParquetType_FloatingPoint(t *testing.T) {
+	tests := []struct {
+		connectType string
+		want        parquet.Value
+	}{
+		{"float", parquet.FloatValue(1.5)},
+		{"double", parquet.DoubleValue(1.5)},
+	}
+	for _, tt := range tests {
+		node, convert, err := parquetType(model.Column{Name: "price", Type: tt.connectType})
+		if err != nil {
+			t.Fatalf("parquetType(%s) failed: %v", tt.connectType, err)
+		}
+		if node.Type().Kind() != tt.want.Kind() {
+			t.Errorf("parquetType(%s) kind = %v, want %v", tt.connectType, node.Type().Kind(), tt.want.Kind())
+		}
+		got, err := convert(1.5)
+		if err != nil {
+			t.Fatalf("convert %s value failed: %v", tt.connectType, err)
+		}
+		if !parquet.Equal(got, tt.want) {
+			t.Errorf("convert %s value = %v, want %v", tt.connectType, got, tt.want)
+		}
+	}
+}
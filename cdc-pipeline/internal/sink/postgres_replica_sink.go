@@ -0,0 +1,324 @@
+package sink
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+)
+
+// ReplicaDB is a database the replica sink writes to (an interface for testability)
+type ReplicaDB interface {
+	Begin() (ReplicaTx, error)
+	Close() error
+}
+
+// ReplicaTx is a transaction of a ReplicaDB
+type ReplicaTx interface {
+	Exec(query string, args ...any) (int64, error) // returns the number of affected rows
+	Commit() error
+	Rollback() error
+}
+
+// real implementation of the ReplicaDB interface
+type realReplicaDB struct {
+	db *sql.DB
+}
+
+func (r *realReplicaDB) Begin() (ReplicaTx, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &realReplicaTx{tx: tx}, nil
+}
+
+func (r *realReplicaDB) Close() error {
+	return r.db.Close()
+}
+
+type realReplicaTx struct {
+	tx *sql.Tx
+}
+
+func (t *realReplicaTx) Exec(query string, args ...any) (int64, error) {
+	res, err := t.tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (t *realReplicaTx) Commit() error   { return t.tx.Commit() }
+func (t *realReplicaTx) Rollback() error { return t.tx.Rollback() }
+
+// ReplicaColumn maps a source column to a column of the replica table
+type ReplicaColumn struct {
+	Source string
+	Target string
+}
+
+// ReplicaTable maps a source table to a table of the replica database.
+// Source columns without a mapping are not replicated.
+type ReplicaTable struct {
+	Source     string          // source table name (e.g. "users")
+	Target     string          // replica table name
+	PrimaryKey []string        // primary key columns (source names)
+	Columns    []ReplicaColumn // replicated columns (must include the primary key columns)
+}
+
+// PostgresReplicaSink replicates change events into a second Postgres database with upserts keyed on the primary key.
+// The LSN of the last change applied to every row is stored in the replica's replica_row_lsn table,
+// so duplicate and out of order (older) changes are skipped.
+type PostgresReplicaSink struct {
+	db     ReplicaDB
+	tables map[string]*replicaTable // by source table name
+}
+
+// a ReplicaTable with its pre-built statements
+type replicaTable struct {
+	ReplicaTable
+	target     map[string]string // source column -> target column
+	upsertStmt string
+	deleteStmt string
+}
+
+// NewPostgresReplicaSink connects to the replica database and returns a new PostgresReplicaSink
+func NewPostgresReplicaSink(addr string, user string, password string, dbName string, tables []ReplicaTable) (*PostgresReplicaSink, error) {
+	db, err := connectToPostgres(addr, user, password, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("connect to replica database: %w", err)
+	}
+	return newPostgresReplicaSink(&realReplicaDB{db: db}, tables)
+}
+
+func newPostgresReplicaSink(db ReplicaDB, tables []ReplicaTable) (*PostgresReplicaSink, error) {
+	s := &PostgresReplicaSink{db: db, tables: make(map[string]*replicaTable)}
+	for _, t := range tables {
+		rt, err := newReplicaTable(t)
+		if err != nil {
+			return nil, err
+		}
+		s.tables[t.Source] = rt
+	}
+	return s, nil
+}
+
+func newReplicaTable(t ReplicaTable) (*replicaTable, error) {
+	rt := &replicaTable{ReplicaTable: t, target: make(map[string]string)}
+	for _, c := range t.Columns {
+		rt.target[c.Source] = c.Target
+	}
+
+	var pkCols, pkConds []string
+	for i, pk := range t.PrimaryKey {
+		col, ok := rt.target[pk]
+		if !ok {
+			return nil, fmt.Errorf("replica table %s: primary key column '%s' is not mapped", t.Target, pk)
+		}
+		pkCols = append(pkCols, col)
+		pkConds = append(pkConds, fmt.Sprintf("%s = $%d", col, i+1))
+	}
+
+	var cols, params, updates []string
+	for i, c := range t.Columns {
+		cols = append(cols, c.Target)
+		params = append(params, fmt.Sprintf("$%d", i+1))
+		if !slices.Contains(t.PrimaryKey, c.Source) {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", c.Target, c.Target))
+		}
+	}
+
+	onConflict := "DO NOTHING"
+	if len(updates) > 0 {
+		onConflict = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	rt.upsertStmt = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		t.Target, strings.Join(cols, ", "), strings.Join(params, ", "), strings.Join(pkCols, ", "), onConflict)
+	rt.deleteStmt = fmt.Sprintf("DELETE FROM %s WHERE %s", t.Target, strings.Join(pkConds, " AND "))
+	return rt, nil
+}
+
+// Ping checks that the replica database is reachable by beginning (and rolling back) a transaction
+func (s *PostgresReplicaSink) Ping() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Rollback()
+}
+
+// Close closes the replica database connection
+func (s *PostgresReplicaSink) Close() {
+	if err := s.db.Close(); err != nil {
+		logger.ErrorLogger.Printf("replica sink: error while closing database: %v\n", err)
+	}
+}
+
+// ApplyChange upserts (or deletes) the changed row in the replica table of its source table
+func (s *PostgresReplicaSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+
+	rt, ok := s.tables[tableFromTopic(topic)]
+	if !ok {
+		return fmt.Errorf("replica sink: no replica table configured for topic %s", topic)
+	}
+
+	var stmt string
+	var args []any
+	var err error
+
+	switch ev.Op {
+	case "c", "u", "r":
+		stmt = rt.upsertStmt
+		args, err = rt.values(ev.Row, ev.Columns, rt.Columns)
+	case "d":
+		stmt = rt.deleteStmt
+		args, err = rt.values(ev.Before, ev.Columns, rt.primaryKeyColumns())
+	default:
+		logger.InfoLogger.Printf("replica sink: unexpected op '%s', nothing to do.\n", ev.Op)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("replica sink: %s: %w", rt.Target, err)
+	}
+
+	rowKey, err := rt.rowKey(ev)
+	if err != nil {
+		return fmt.Errorf("replica sink: %s: %w", rt.Target, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("replica sink: begin transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op after commit
+
+	// record the row's LSN first, only newer changes than the recorded one are applied
+	if ev.LSN != 0 {
+		n, err := tx.Exec(`INSERT INTO replica_row_lsn (table_name, row_key, lsn) VALUES ($1, $2, $3)
+ON CONFLICT (table_name, row_key) DO UPDATE SET lsn = EXCLUDED.lsn WHERE replica_row_lsn.lsn < EXCLUDED.lsn`,
+			rt.Target, rowKey, ev.LSN)
+		if err != nil {
+			return fmt.Errorf("replica sink: update row lsn: %w", err)
+		}
+		if n == 0 {
+			logger.DebugLogger.Printf("replica sink: skipping change with lsn %d for %s %s (already applied)\n", ev.LSN, rt.Target, rowKey)
+			return nil
+		}
+	}
+
+	if _, err := tx.Exec(stmt, args...); err != nil {
+		return fmt.Errorf("replica sink: apply change to %s: %w", rt.Target, err)
+	}
+	return tx.Commit()
+}
+
+func (rt *replicaTable) primaryKeyColumns() []ReplicaColumn {
+	cols := make([]ReplicaColumn, 0, len(rt.PrimaryKey))
+	for _, pk := range rt.PrimaryKey {
+		cols = append(cols, ReplicaColumn{Source: pk, Target: rt.target[pk]})
+	}
+	return cols
+}
+
+// extract the values of the given columns from the row (converted to values accepted by Postgres)
+func (rt *replicaTable) values(row model.JsonMap, schema []model.Column, cols []ReplicaColumn) ([]any, error) {
+	if row == nil {
+		return nil, parser.Poison(fmt.Errorf("missing row data"))
+	}
+
+	columnsByName := make(map[string]*model.Column, len(schema))
+	for i := range schema {
+		columnsByName[schema[i].Name] = &schema[i]
+	}
+
+	args := make([]any, 0, len(cols))
+	for _, c := range cols {
+		v, err := postgresValue(columnsByName[c.Source], row[c.Source])
+		if err != nil {
+			return nil, parser.Poison(fmt.Errorf("column '%s': %w", c.Source, err))
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// the key of a row in the replica_row_lsn table (JSON array of its primary key values)
+func (rt *replicaTable) rowKey(ev *model.ChangeEvent) (string, error) {
+	row := ev.Row
+	if ev.Op == "d" {
+		row = ev.Before
+	}
+
+	key := make([]any, 0, len(rt.PrimaryKey))
+	for _, pk := range rt.PrimaryKey {
+		v, ok := row[pk]
+		if !ok || v == nil {
+			return "", fmt.Errorf("missing primary key column '%s'", pk)
+		}
+		key = append(key, v)
+	}
+	b, err := json.Marshal(key)
+	return string(b), err
+}
+
+// convert a Debezium JSON value to a value accepted by Postgres, based on the column's logical type.
+// Values of columns without schema are passed as is.
+func postgresValue(col *model.Column, v any) (any, error) {
+	if v == nil || col == nil {
+		return v, nil
+	}
+
+	switch col.Logical {
+	case "io.debezium.time.Date": // days since epoch
+		days, err := parser.ParseDebeziumNumber[int64](v)
+		if err != nil {
+			return nil, err
+		}
+		return parser.DebeziumDate(int32(days)).Format("2006-01-02"), nil
+	case "io.debezium.time.Timestamp":
+		ms, err := parser.ParseDebeziumNumber[int64](v)
+		return time.UnixMilli(ms).UTC(), err
+	case "io.debezium.time.MicroTimestamp":
+		us, err := parser.ParseDebeziumNumber[int64](v)
+		return time.UnixMicro(us).UTC(), err
+	case "org.apache.kafka.connect.data.Decimal":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a base64 string, got %T", v)
+		}
+		scale, err := strconv.Atoi(col.Params["scale"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal scale: %w", err)
+		}
+		unscaled, err := parser.DebeziumDecimalUnscaled(s)
+		if err != nil {
+			return nil, err
+		}
+		return decimalString(unscaled.String(), scale), nil
+	}
+
+	switch col.Type {
+	case "int8", "int16", "int32", "int64":
+		return parser.ParseDebeziumNumber[int64](v)
+	case "bytes":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a base64 string, got %T", v)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return v, nil
+}
@@ -0,0 +1,192 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// mockReplicaDB records the statements executed in its transactions
+type mockReplicaDB struct {
+	executedQueries []string
+	queryParams     [][]any
+	lsnRowsAffected int64 // rows affected by the replica_row_lsn upsert
+	commits         int
+}
+
+func (db *mockReplicaDB) Begin() (ReplicaTx, error) { return &mockReplicaTx{db: db}, nil }
+func (db *mockReplicaDB) Close() error              { return nil }
+
+type mockReplicaTx struct {
+	db *mockReplicaDB
+}
+
+func (tx *mockReplicaTx) Exec(query string, args ...any) (int64, error) {
+	tx.db.executedQueries = append(tx.db.executedQueries, query)
+	tx.db.queryParams = append(tx.db.queryParams, args)
+	if strings.Contains(query, "replica_row_lsn") {
+		return tx.db.lsnRowsAffected, nil
+	}
+	return 1, nil
+}
+
+func (tx *mockReplicaTx) Commit() error {
+	tx.db.commits++
+	return nil
+}
+
+func (tx *mockReplicaTx) Rollback() error { return nil }
+
+var testReplicaTables = []ReplicaTable{
+	{
+		Source:     "orders",
+		Target:     "report_orders",
+		PrimaryKey: []string{"id"},
+		Columns: []ReplicaColumn{
+			{"id", "order_id"},
+			{"status", "order_status"},
+			{"total_amount", "total_amount"},
+			{"placed_at", "placed_at"},
+		},
+	},
+	{
+		Source:     "users",
+		Target:     "report_users",
+		PrimaryKey: []string{"id"},
+		Columns: []ReplicaColumn{
+			{"id", "user_id"},
+			{"name", "full_name"},
+			{"dob", "date_of_birth"},
+		},
+	},
+}
+
+var testOrderColumns = []model.Column{
+	{Name: "id", Type: "string", Logical: "io.debezium.data.Uuid"},
+	{Name: "status", Type: "string"},
+	{Name: "total_amount", Type: "bytes", Logical: "org.apache.kafka.connect.data.Decimal", Params: map[string]string{"scale": "2"}},
+	{Name: "placed_at", Type: "string", Logical: "io.debezium.time.ZonedTimestamp", Optional: true},
+}
+
+func newTestReplicaSink(t *testing.T, db *mockReplicaDB) *PostgresReplicaSink {
+	t.Helper()
+	s, err := newPostgresReplicaSink(db, testReplicaTables)
+	if err != nil {
+		t.Fatalf("newPostgresReplicaSink failed: %v", err)
+	}
+	return s
+}
+
+func TestPostgresReplicaSink_Upsert(t *testing.T) {
+	db := &mockReplicaDB{lsnRowsAffected: 1}
+	s := newTestReplicaSink(t, db)
+
+	ev := &model.ChangeEvent{
+		Op: "c",
+		Row: model.JsonMap{
+			"id":           testOrderID,
+			"status":       "PLACED",
+			"user_id":      testUserID, // not mapped
+			"total_amount": "J0Q=",
+			"placed_at":    "2025-08-28T16:02:58.281604Z",
+		},
+		LSN:     1001,
+		Columns: testOrderColumns,
+	}
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	if len(db.executedQueries) != 2 || db.commits != 1 {
+		t.Fatalf("expected 2 queries in a committed transaction, got %d queries and %d commits", len(db.executedQueries), db.commits)
+	}
+
+	lsnParams := db.queryParams[0]
+	if lsnParams[0] != "report_orders" || lsnParams[1] != `["`+testOrderID+`"]` || lsnParams[2] != int64(1001) {
+		t.Errorf("unexpected replica_row_lsn params: %v", lsnParams)
+	}
+
+	expectedQuery := "INSERT INTO report_orders (order_id, order_status, total_amount, placed_at) VALUES ($1, $2, $3, $4) " +
+		"ON CONFLICT (order_id) DO UPDATE SET order_status = EXCLUDED.order_status, total_amount = EXCLUDED.total_amount, placed_at = EXCLUDED.placed_at"
+	if db.executedQueries[1] != expectedQuery {
+		t.Errorf("unexpected query: got %q, want %q", db.executedQueries[1], expectedQuery)
+	}
+
+	expectedParams := []any{testOrderID, "PLACED", "100.52", "2025-08-28T16:02:58.281604Z"}
+	for i, val := range expectedParams {
+		if db.queryParams[1][i] != val {
+			t.Errorf("unexpected param at position %d: got %v, want %v", i, db.queryParams[1][i], val)
+		}
+	}
+}
+
+func TestPostgresReplicaSink_SkipsAppliedLSN(t *testing.T) {
+	db := &mockReplicaDB{lsnRowsAffected: 0} // row already has the same or a newer lsn
+	s := newTestReplicaSink(t, db)
+
+	ev := &model.ChangeEvent{
+		Op:      "u",
+		Row:     model.JsonMap{"id": testUserID, "name": "Alice", "dob": float64(11172)},
+		LSN:     1001,
+		Columns: []model.Column{{Name: "id", Type: "string"}, {Name: "name", Type: "string"}, {Name: "dob", Type: "int32", Logical: "io.debezium.time.Date"}},
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	if len(db.executedQueries) != 1 || db.commits != 0 {
+		t.Errorf("expected only the lsn check without commit, got %d queries and %d commits", len(db.executedQueries), db.commits)
+	}
+}
+
+func TestPostgresReplicaSink_Delete(t *testing.T) {
+	db := &mockReplicaDB{lsnRowsAffected: 1}
+	s := newTestReplicaSink(t, db)
+
+	ev := &model.ChangeEvent{
+		Op:      "d",
+		Before:  model.JsonMap{"id": testUserID}, // default replica identity only has the primary key
+		LSN:     1002,
+		Columns: []model.Column{{Name: "id", Type: "string"}},
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	expectedQuery := "DELETE FROM report_users WHERE user_id = $1"
+	if len(db.executedQueries) != 2 || db.executedQueries[1] != expectedQuery {
+		t.Fatalf("unexpected queries: %v", db.executedQueries)
+	}
+	if db.queryParams[1][0] != testUserID {
+		t.Errorf("unexpected delete param: got %v, want %v", db.queryParams[1][0], testUserID)
+	}
+}
+
+func TestPostgresReplicaSink_DateConversion(t *testing.T) {
+	db := &mockReplicaDB{lsnRowsAffected: 1}
+	s := newTestReplicaSink(t, db)
+
+	ev := &model.ChangeEvent{
+		Op:      "c",
+		Row:     model.JsonMap{"id": testUserID, "name": "Alice", "dob": float64(11172)},
+		LSN:     1003,
+		Columns: []model.Column{{Name: "id", Type: "string"}, {Name: "name", Type: "string"}, {Name: "dob", Type: "int32", Logical: "io.debezium.time.Date"}},
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if got := db.queryParams[1][2]; got != "2000-08-03" {
+		t.Errorf("unexpected dob param: got %v, want 2000-08-03", got)
+	}
+}
+
+func TestPostgresReplicaSink_UnmappedPrimaryKey(t *testing.T) {
+	_, err := newPostgresReplicaSink(&mockReplicaDB{}, []ReplicaTable{
+		{Source: "users", Target: "users", PrimaryKey: []string{"id"}, Columns: []ReplicaColumn{{"name", "name"}}},
+	})
+	if err == nil {
+		t.Errorf("expected an error for an unmapped primary key column")
+	}
+}
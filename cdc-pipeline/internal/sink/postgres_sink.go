@@ -39,7 +39,11 @@ func NewDBClient() (*realDBClient, error) {
 }
 
 func connectToDB() (*sql.DB, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s", config.PGUser, config.PGPassword, config.PGAddr, config.PGDBName)
+	return connectToPostgres(config.PGAddr, config.PGUser, config.PGPassword, config.PGDBName)
+}
+
+func connectToPostgres(addr string, user string, password string, dbName string) (*sql.DB, error) {
+	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s", user, password, addr, dbName)
 
 	var db *sql.DB
 	var err error
@@ -52,7 +56,7 @@ func connectToDB() (*sql.DB, error) {
 	if err != nil {
 		return db, err
 	}
-	logger.DebugLogger.Printf("Successfully connected to PostgreSQL on %s\n", config.PGAddr)
+	logger.DebugLogger.Printf("Successfully connected to PostgreSQL on %s\n", addr)
 
 	return db, nil
 }
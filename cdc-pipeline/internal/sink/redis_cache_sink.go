@@ -0,0 +1,240 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/redis/go-redis/v9"
+)
+
+// hash field with the LSN of the change an entry was last written by
+const redisLSNField = "__lsn"
+
+// attempts of a change whose key was modified concurrently (optimistic locking with WATCH)
+const redisMaxTxAttempts = 3
+
+// RedisCacheSinkConfig configures a RedisCacheSink
+type RedisCacheSinkConfig struct {
+	Addr       string
+	Password   string
+	DB         int
+	TTL        time.Duration // expiry of the cached entries, refreshed by every change (0 = no expiry)
+	DeletedTTL time.Duration // expiry of soft deleted entries (0 = removed right away)
+}
+
+// RedisCacheSink maintains the latest state of users and orders in a Redis compatible cache:
+//   - `user:<id>` and `order:<id>` hashes with the columns of the row (null columns are left out)
+//   - `user:<id>:orders` sets with the ids of the (not deleted) orders of a user
+//
+// Changes older than the one an entry was last written by (by LSN) are skipped.
+type RedisCacheSink struct {
+	cfg    RedisCacheSinkConfig
+	client *redis.Client
+}
+
+// NewRedisCacheSink connects to the cache and returns a new RedisCacheSink
+func NewRedisCacheSink(cfg RedisCacheSinkConfig) (*RedisCacheSink, error) {
+	client := redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis cache sink: connect to %s: %w", cfg.Addr, err)
+	}
+	return &RedisCacheSink{cfg: cfg, client: client}, nil
+}
+
+// Close closes the connections to the cache
+func (s *RedisCacheSink) Close() {
+	if err := s.client.Close(); err != nil {
+		logger.ErrorLogger.Printf("redis cache sink: error while closing client: %v\n", err)
+	}
+}
+
+// ApplyChange updates the cache entries of the changed user or order
+func (s *RedisCacheSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+
+	row := ev.Row
+	switch ev.Op {
+	case "c", "u", "r":
+	case "d":
+		row = ev.Before // default replica identity only has the primary key
+	default:
+		logger.InfoLogger.Printf("redis cache sink: unexpected op '%s', nothing to do.\n", ev.Op)
+		return nil
+	}
+	if row == nil {
+		return parser.Poison(fmt.Errorf("redis cache sink: missing row data"))
+	}
+
+	id, ok := row["id"].(string)
+	if !ok {
+		return parser.Poison(fmt.Errorf("redis cache sink: 'id' must be a string value"))
+	}
+
+	var key string
+	table := tableFromTopic(topic)
+	switch table {
+	case "users":
+		key = "user:" + id
+	case "orders":
+		key = "order:" + id
+	default:
+		return fmt.Errorf("redis cache sink: unsupported table: %s", table)
+	}
+
+	var err error
+	for range redisMaxTxAttempts {
+		err = s.client.Watch(context.Background(), func(tx *redis.Tx) error {
+			return s.applyChange(tx, key, table, id, ev, row)
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+		logger.DebugLogger.Printf("redis cache sink: %s was modified concurrently, trying again\n", key)
+	}
+	if err != nil {
+		return fmt.Errorf("redis cache sink: update %s: %w", key, err)
+	}
+	return nil
+}
+
+// update the entries of a row in a transaction (the row's key is watched)
+func (s *RedisCacheSink) applyChange(tx *redis.Tx, key string, table string, id string, ev *model.ChangeEvent, row model.JsonMap) error {
+	ctx := context.Background()
+
+	if ev.LSN != 0 {
+		lsn, err := tx.HGet(ctx, key, redisLSNField).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil && lsn >= ev.LSN {
+			logger.DebugLogger.Printf("redis cache sink: skipping change with lsn %d for %s (already at lsn %d)\n", ev.LSN, key, lsn)
+			return nil
+		}
+	}
+
+	// key of the user's orders set
+	var ordersKey string
+	if table == "orders" {
+		userID, _ := row["user_id"].(string)
+		if userID == "" {
+			// delete events only have the primary key, take the user from the cached order
+			var err error
+			if userID, err = tx.HGet(ctx, key, "user_id").Result(); err != nil && err != redis.Nil {
+				return err
+			}
+		}
+		if userID != "" {
+			ordersKey = "user:" + userID + ":orders"
+		}
+	}
+
+	fields, nulls, err := redisHashFields(row, ev.Columns)
+	if err != nil {
+		return err
+	}
+	if ev.LSN != 0 {
+		fields[redisLSNField] = ev.LSN
+	}
+	isDeleted, _ := row["is_deleted"].(bool)
+
+	_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		switch {
+		case ev.Op == "d" || (isDeleted && s.cfg.DeletedTTL <= 0):
+			p.Del(ctx, key)
+		case isDeleted:
+			// keep soft deleted entries for a while, so readers can tell them from unknown ones
+			writeHash(ctx, p, key, fields, nulls)
+			p.Expire(ctx, key, s.cfg.DeletedTTL)
+		default:
+			writeHash(ctx, p, key, fields, nulls)
+			expire(ctx, p, key, s.cfg.TTL)
+		}
+
+		if ordersKey != "" {
+			if ev.Op == "d" || isDeleted {
+				p.SRem(ctx, ordersKey, id)
+			} else {
+				p.SAdd(ctx, ordersKey, id)
+				expire(ctx, p, ordersKey, s.cfg.TTL)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func writeHash(ctx context.Context, p redis.Pipeliner, key string, fields map[string]any, nulls []string) {
+	p.HSet(ctx, key, fields)
+	if len(nulls) > 0 {
+		p.HDel(ctx, key, nulls...)
+	}
+}
+
+// set the key's expiry (or remove it if ttl is 0)
+func expire(ctx context.Context, p redis.Pipeliner, key string, ttl time.Duration) {
+	if ttl > 0 {
+		p.Expire(ctx, key, ttl)
+	} else {
+		p.Persist(ctx, key)
+	}
+}
+
+// convert a row to hash fields (and the names of its null columns)
+func redisHashFields(row model.JsonMap, schema []model.Column) (map[string]any, []string, error) {
+	columnsByName := make(map[string]*model.Column, len(schema))
+	for i := range schema {
+		columnsByName[schema[i].Name] = &schema[i]
+	}
+
+	fields := make(map[string]any, len(row))
+	var nulls []string
+	for name, v := range row {
+		if v == nil {
+			nulls = append(nulls, name)
+			continue
+		}
+		val, err := postgresValue(columnsByName[name], v)
+		if err != nil {
+			return nil, nil, parser.Poison(fmt.Errorf("column '%s': %w", name, err))
+		}
+		if fields[name], err = redisValue(val); err != nil {
+			return nil, nil, fmt.Errorf("column '%s': %w", name, err)
+		}
+	}
+	return fields, nulls, nil
+}
+
+// string representation of a (converted) column value
+func redisValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return string(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
@@ -0,0 +1,151 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// start an in-process Redis stand-in and return a sink connected to it
+func newTestRedisCacheSink(t *testing.T, cfg RedisCacheSinkConfig) (*RedisCacheSink, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg.Addr = mr.Addr()
+	s, err := NewRedisCacheSink(cfg)
+	if err != nil {
+		t.Fatalf("NewRedisCacheSink failed: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s, mr
+}
+
+func newTestOrderEvent(op string, lsn int64, status string, isDeleted bool) *model.ChangeEvent {
+	return &model.ChangeEvent{
+		Op: op,
+		Row: model.JsonMap{
+			"id":           testOrderID,
+			"user_id":      testUserID,
+			"status":       status,
+			"total_amount": "J0Q=",
+			"placed_at":    "2025-08-28T16:02:58.281604Z",
+			"is_deleted":   isDeleted,
+		},
+		LSN:     lsn,
+		Columns: testOrderColumns,
+	}
+}
+
+func TestRedisCacheSink_User(t *testing.T) {
+	s, mr := newTestRedisCacheSink(t, RedisCacheSinkConfig{TTL: time.Hour})
+
+	ev := &model.ChangeEvent{
+		Op:      "c",
+		Row:     model.JsonMap{"id": testUserID, "name": "Alice", "dob": float64(11172), "modified_at": nil, "is_deleted": false},
+		LSN:     100,
+		Columns: []model.Column{{Name: "dob", Type: "int32", Logical: "io.debezium.time.Date"}},
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	key := "user:" + testUserID
+	expected := map[string]string{"id": testUserID, "name": "Alice", "dob": "2000-08-03", "is_deleted": "false", redisLSNField: "100"}
+	for field, want := range expected {
+		if got := mr.HGet(key, field); got != want {
+			t.Errorf("unexpected value of field %s: got %q, want %q", field, got, want)
+		}
+	}
+	if fields, _ := mr.HKeys(key); len(fields) != len(expected) {
+		t.Errorf("expected null columns to be left out, got fields %v", fields)
+	}
+	if ttl := mr.TTL(key); ttl != time.Hour {
+		t.Errorf("unexpected ttl: got %v, want %v", ttl, time.Hour)
+	}
+}
+
+func TestRedisCacheSink_OrderLifecycle(t *testing.T) {
+	s, mr := newTestRedisCacheSink(t, RedisCacheSinkConfig{TTL: time.Hour, DeletedTTL: time.Minute})
+
+	orderKey, ordersKey := "order:"+testOrderID, "user:"+testUserID+":orders"
+
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, newTestOrderEvent("c", 200, "PLACED", false)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if got := mr.HGet(orderKey, "total_amount"); got != "100.52" {
+		t.Errorf("unexpected total amount: got %q, want 100.52", got)
+	}
+	if got := mr.HGet(orderKey, "placed_at"); got != "2025-08-28T16:02:58.281604Z" {
+		t.Errorf("unexpected placed_at: got %q", got)
+	}
+	if ok, _ := mr.SIsMember(ordersKey, testOrderID); !ok {
+		t.Fatalf("expected the order in the user's orders set")
+	}
+
+	// soft delete removes the order from the set and keeps the entry for DeletedTTL
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, newTestOrderEvent("u", 201, "CANCELLED", true)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if ok, _ := mr.SIsMember(ordersKey, testOrderID); ok {
+		t.Errorf("expected the soft deleted order to be removed from the user's orders set")
+	}
+	if got := mr.HGet(orderKey, "is_deleted"); got != "true" {
+		t.Errorf("expected the soft deleted entry, got is_deleted = %q", got)
+	}
+	if ttl := mr.TTL(orderKey); ttl != time.Minute {
+		t.Errorf("unexpected ttl of the soft deleted entry: got %v, want %v", ttl, time.Minute)
+	}
+
+	mr.FastForward(time.Minute)
+	if mr.Exists(orderKey) {
+		t.Errorf("expected the soft deleted entry to expire")
+	}
+}
+
+func TestRedisCacheSink_SoftDeleteWithoutDeletedTTL(t *testing.T) {
+	s, mr := newTestRedisCacheSink(t, RedisCacheSinkConfig{})
+
+	for _, ev := range []*model.ChangeEvent{newTestOrderEvent("c", 200, "PLACED", false), newTestOrderEvent("u", 201, "CANCELLED", true)} {
+		if err := s.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if mr.Exists("order:" + testOrderID) {
+		t.Errorf("expected the soft deleted order to be removed")
+	}
+}
+
+func TestRedisCacheSink_SkipsOlderChange(t *testing.T) {
+	s, mr := newTestRedisCacheSink(t, RedisCacheSinkConfig{})
+
+	for _, ev := range []*model.ChangeEvent{newTestOrderEvent("u", 201, "SHIPPED", false), newTestOrderEvent("c", 200, "PLACED", false)} {
+		if err := s.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if got := mr.HGet("order:"+testOrderID, "status"); got != "SHIPPED" {
+		t.Errorf("expected the older change to be skipped, got status %q", got)
+	}
+}
+
+func TestRedisCacheSink_Delete(t *testing.T) {
+	s, mr := newTestRedisCacheSink(t, RedisCacheSinkConfig{})
+
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, newTestOrderEvent("c", 200, "PLACED", false)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	// delete events only have the primary key, the user is taken from the cached order
+	ev := &model.ChangeEvent{Op: "d", Before: model.JsonMap{"id": testOrderID}, LSN: 201}
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if mr.Exists("order:" + testOrderID) {
+		t.Errorf("expected the order to be removed")
+	}
+	if ok, _ := mr.SIsMember("user:"+testUserID+":orders", testOrderID); ok {
+		t.Errorf("expected the order to be removed from the user's orders set")
+	}
+}
@@ -0,0 +1,30 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// UserRow is a row of the Postgres users table in a change event (decoded with parser.DecodeRow)
+type UserRow struct {
+	ID         gocql.UUID `debezium:"id"`
+	Name       string     `debezium:"name"`
+	DOB        time.Time  `debezium:"dob,date"`
+	CreatedAt  time.Time  `debezium:"created_at"`
+	ModifiedAt *time.Time `debezium:"modified_at"`
+	IsDeleted  *bool      `debezium:"is_deleted"`
+}
+
+// OrderRow is a row of the Postgres orders table in a change event (decoded with parser.DecodeRow)
+type OrderRow struct {
+	ID          gocql.UUID `debezium:"id"`
+	UserID      gocql.UUID `debezium:"user_id"`
+	Status      string     `debezium:"status"`
+	Quantity    int        `debezium:"quantity"`
+	TotalAmount *inf.Dec   `debezium:"total_amount,scale=2,required"`
+	PlacedAt    time.Time  `debezium:"placed_at"`
+	ModifiedAt  *time.Time `debezium:"modified_at"`
+	IsDeleted   *bool      `debezium:"is_deleted"`
+}
@@ -0,0 +1,265 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+)
+
+// SearchIndexSinkConfig configures a SearchIndexSink
+type SearchIndexSinkConfig struct {
+	URL             string // base URL of the OpenSearch/Elasticsearch cluster
+	Username        string // basic auth credentials (optional)
+	Password        string
+	IndexPrefix     string              // documents of a table are indexed in `<IndexPrefix><table>`
+	PrimaryKeys     map[string][]string // primary key columns by table (document ids are built from their values)
+	MaxBatchActions int                 // actions per bulk request
+	MaxBatchBytes   int                 // max size of a bulk request body (a batch is sent once exceeded)
+	MaxBatchAge     time.Duration       // a batch is sent by the next change once it is older than this (0 = only by Flush)
+	Timeout         time.Duration       // timeout of a bulk request
+}
+
+// bulk action metadata (see the `_bulk` API)
+type bulkActionMeta struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"version,omitempty"`
+	VersionType string `json:"version_type,omitempty"`
+}
+
+// response of a bulk request (only the fields needed to find failed actions)
+type bulkResponse struct {
+	Errors bool                                `json:"errors"`
+	Items  []map[string]bulkResponseItemResult `json:"items"`
+}
+
+type bulkResponseItemResult struct {
+	ID     string          `json:"_id"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// SearchIndexSink indexes the changed rows in OpenSearch/Elasticsearch with `_bulk` requests.
+// Created and updated rows are indexed as documents with ids built from their primary key, deleted rows are deleted.
+// The LSN of a change is used as external document version, so stale and duplicate changes are rejected by the cluster
+// and a failed batch can safely be sent again.
+type SearchIndexSink struct {
+	cfg    SearchIndexSinkConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex // guards below fields
+	batch    bytes.Buffer
+	actions  int
+	openedAt time.Time // time the first action of the batch was added
+}
+
+// NewSearchIndexSink returns a new SearchIndexSink
+func NewSearchIndexSink(cfg SearchIndexSinkConfig) (*SearchIndexSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("search index sink: no URL configured")
+	}
+	if cfg.MaxBatchActions < 1 {
+		cfg.MaxBatchActions = 1
+	}
+	return &SearchIndexSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+	}, nil
+}
+
+// ApplyChange adds an index (or delete) action for the changed row to the batch
+// and sends the batch once it is full or too old
+func (s *SearchIndexSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+
+	table := tableFromTopic(topic)
+	pk, ok := s.cfg.PrimaryKeys[table]
+	if !ok {
+		return fmt.Errorf("search index sink: no primary key configured for table %s", table)
+	}
+
+	var action string
+	var row model.JsonMap
+	switch ev.Op {
+	case "c", "u", "r":
+		action, row = "index", ev.Row
+	case "d":
+		action, row = "delete", ev.Before
+	default:
+		logger.InfoLogger.Printf("search index sink: unexpected op '%s', nothing to do.\n", ev.Op)
+		return nil
+	}
+
+	id, err := documentID(row, pk)
+	if err != nil {
+		return fmt.Errorf("search index sink: %s: %w", table, err)
+	}
+
+	meta := bulkActionMeta{Index: s.cfg.IndexPrefix + table, ID: id}
+	if ev.LSN != 0 {
+		meta.Version, meta.VersionType = ev.LSN, "external"
+	}
+
+	lines, err := json.Marshal(map[string]bulkActionMeta{action: meta})
+	if err != nil {
+		return fmt.Errorf("search index sink: %w", err)
+	}
+	lines = append(lines, '\n')
+	if action == "index" {
+		doc, err := searchDocument(row, ev.Columns)
+		if err != nil {
+			return fmt.Errorf("search index sink: %s: %w", table, err)
+		}
+		lines = append(append(lines, doc...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// send the batch first if the action doesn't fit into it anymore
+	if s.cfg.MaxBatchBytes > 0 && s.actions > 0 && s.batch.Len()+len(lines) > s.cfg.MaxBatchBytes {
+		if err := s.sendBatch(); err != nil {
+			return err
+		}
+	}
+
+	if s.actions == 0 {
+		s.openedAt = s.now()
+	}
+	s.batch.Write(lines)
+	s.actions++
+
+	if s.actions >= s.cfg.MaxBatchActions || (s.cfg.MaxBatchAge > 0 && s.now().Sub(s.openedAt) >= s.cfg.MaxBatchAge) {
+		return s.sendBatch()
+	}
+	return nil
+}
+
+// Flush sends the buffered actions
+func (s *SearchIndexSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendBatch()
+}
+
+// Close sends the buffered actions
+func (s *SearchIndexSink) Close() {
+	if err := s.Flush(); err != nil {
+		logger.ErrorLogger.Printf("search index sink: error while sending buffered actions: %v\n", err)
+	}
+}
+
+// send the batch in a bulk request (the caller must hold the lock).
+// The batch is kept if the request fails, so it is sent again with the next one.
+func (s *SearchIndexSink) sendBatch() error {
+	if s.actions == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+		strings.TrimSuffix(s.cfg.URL, "/")+"/_bulk", bytes.NewReader(s.batch.Bytes()))
+	if err != nil {
+		return fmt.Errorf("search index sink: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("search index sink: bulk request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("search index sink: read bulk response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("search index sink: bulk request: unexpected status: %s: %s", resp.Status, body)
+	}
+
+	var br bulkResponse
+	if err := json.Unmarshal(body, &br); err != nil {
+		return fmt.Errorf("search index sink: decode bulk response: %w", err)
+	}
+	if br.Errors {
+		if err := bulkItemsError(br.Items); err != nil {
+			return fmt.Errorf("search index sink: %w", err)
+		}
+	}
+
+	logger.DebugLogger.Printf("search index sink: sent %d actions (%d bytes)\n", s.actions, s.batch.Len())
+	s.batch.Reset()
+	s.actions = 0
+	return nil
+}
+
+// error of the failed actions of a bulk response (nil if all succeeded).
+// Version conflicts (the document already has the same or a newer version)
+// and deletes of missing documents are no failures.
+func bulkItemsError(items []map[string]bulkResponseItemResult) error {
+	var failed []string
+	for _, item := range items {
+		for action, res := range item {
+			if res.Status < 300 || res.Status == http.StatusConflict || (action == "delete" && res.Status == http.StatusNotFound) {
+				continue
+			}
+			failed = append(failed, fmt.Sprintf("%s %s: status %d: %s", action, res.ID, res.Status, res.Error))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d failed bulk actions: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// document id of a row (the primary key values joined by ':')
+func documentID(row model.JsonMap, pk []string) (string, error) {
+	if row == nil {
+		return "", parser.Poison(fmt.Errorf("missing row data"))
+	}
+	parts := make([]string, 0, len(pk))
+	for _, col := range pk {
+		v, ok := row[col]
+		if !ok || v == nil {
+			return "", parser.Poison(fmt.Errorf("missing primary key column '%s'", col))
+		}
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// JSON document of a row (with its values converted based on the column types, e.g. decimals and dates)
+func searchDocument(row model.JsonMap, schema []model.Column) ([]byte, error) {
+	columnsByName := make(map[string]*model.Column, len(schema))
+	for i := range schema {
+		columnsByName[schema[i].Name] = &schema[i]
+	}
+
+	doc := make(map[string]any, len(row))
+	for name, v := range row {
+		val, err := postgresValue(columnsByName[name], v)
+		if err != nil {
+			return nil, parser.Poison(fmt.Errorf("column '%s': %w", name, err))
+		}
+		doc[name] = val
+	}
+	return json.Marshal(doc)
+}
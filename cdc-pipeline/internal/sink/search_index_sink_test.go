@@ -0,0 +1,184 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// bulkRecorder is a httptest stand-in for the `_bulk` API recording the request payloads
+type bulkRecorder struct {
+	mu       sync.Mutex
+	payloads [][]map[string]any // NDJSON lines of every request
+	items    []map[string]bulkResponseItemResult
+	badReqs  int // requests to another path or with another content type
+}
+
+func (br *bulkRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		br.badReqs++
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var lines []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		var line map[string]any
+		json.Unmarshal(sc.Bytes(), &line)
+		lines = append(lines, line)
+	}
+	br.payloads = append(br.payloads, lines)
+
+	json.NewEncoder(w).Encode(bulkResponse{Errors: len(br.items) > 0, Items: br.items})
+}
+
+func newTestSearchIndexSink(t *testing.T, br *bulkRecorder, cfg SearchIndexSinkConfig) *SearchIndexSink {
+	t.Helper()
+	srv := httptest.NewServer(br)
+	t.Cleanup(srv.Close)
+
+	cfg.URL = srv.URL
+	cfg.IndexPrefix = "cdc-"
+	cfg.PrimaryKeys = map[string][]string{"users": {"id"}, "orders": {"id"}}
+	s, err := NewSearchIndexSink(cfg)
+	if err != nil {
+		t.Fatalf("NewSearchIndexSink failed: %v", err)
+	}
+	return s
+}
+
+func TestSearchIndexSink_BulkActions(t *testing.T) {
+	br := &bulkRecorder{}
+	s := newTestSearchIndexSink(t, br, SearchIndexSinkConfig{MaxBatchActions: 10})
+
+	order := &model.ChangeEvent{
+		Op:      "u",
+		Row:     model.JsonMap{"id": testOrderID, "status": "SHIPPED", "total_amount": "J0Q=", "placed_at": "2025-08-28T16:02:58.281604Z"},
+		LSN:     1001,
+		Columns: testOrderColumns,
+	}
+	if err := s.ApplyChange(config.DebeziumOrdersTopic, order); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	user := &model.ChangeEvent{Op: "d", Before: model.JsonMap{"id": testUserID}, LSN: 1002}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, user); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if len(br.payloads) != 0 {
+		t.Fatalf("expected the actions to be buffered until Flush")
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(br.payloads) != 1 || br.badReqs != 0 {
+		t.Fatalf("expected 1 valid bulk request, got %d (%d invalid)", len(br.payloads), br.badReqs)
+	}
+
+	lines := br.payloads[0]
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 NDJSON lines (index action, document, delete action), got %d", len(lines))
+	}
+
+	index := lines[0]["index"].(map[string]any)
+	if index["_index"] != "cdc-orders" || index["_id"] != testOrderID || index["version"] != float64(1001) || index["version_type"] != "external" {
+		t.Errorf("unexpected index action: %v", index)
+	}
+	if doc := lines[1]; doc["status"] != "SHIPPED" || doc["total_amount"] != "100.52" {
+		t.Errorf("unexpected document: %v", doc)
+	}
+	del := lines[2]["delete"].(map[string]any)
+	if del["_index"] != "cdc-users" || del["_id"] != testUserID || del["version"] != float64(1002) {
+		t.Errorf("unexpected delete action: %v", del)
+	}
+}
+
+func TestSearchIndexSink_BatchBySize(t *testing.T) {
+	br := &bulkRecorder{}
+	s := newTestSearchIndexSink(t, br, SearchIndexSinkConfig{MaxBatchActions: 2})
+
+	for i := range 5 {
+		if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if len(br.payloads) != 2 {
+		t.Fatalf("expected 2 full batches, got %d requests", len(br.payloads))
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(br.payloads) != 3 || len(br.payloads[2]) != 2 {
+		t.Errorf("expected the remaining action to be sent by Flush, got %d requests", len(br.payloads))
+	}
+}
+
+func TestSearchIndexSink_BatchByBytes(t *testing.T) {
+	br := &bulkRecorder{}
+	s := newTestSearchIndexSink(t, br, SearchIndexSinkConfig{MaxBatchActions: 100, MaxBatchBytes: 1})
+
+	for i := range 3 {
+		if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if len(br.payloads) != 2 {
+		// the batch is sent before an action not fitting into it is added
+		t.Errorf("expected every action to exceed the batch size, got %d requests", len(br.payloads))
+	}
+}
+
+func TestSearchIndexSink_BatchByAge(t *testing.T) {
+	br := &bulkRecorder{}
+	s := newTestSearchIndexSink(t, br, SearchIndexSinkConfig{MaxBatchActions: 100, MaxBatchAge: time.Second})
+	now := time.Date(2025, 8, 28, 16, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	now = now.Add(time.Second)
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 2)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if len(br.payloads) != 1 || len(br.payloads[0]) != 4 {
+		t.Errorf("expected both actions in a batch sent once it got too old, got %v", br.payloads)
+	}
+}
+
+func TestSearchIndexSink_FailedActions(t *testing.T) {
+	br := &bulkRecorder{items: []map[string]bulkResponseItemResult{
+		{"index": {ID: "a", Status: http.StatusConflict}}, // stale version, ignored
+		{"delete": {ID: "b", Status: http.StatusNotFound}},
+		{"index": {ID: "c", Status: http.StatusBadRequest, Error: json.RawMessage(`{"type":"mapper_parsing_exception"}`)}},
+	}}
+	s := newTestSearchIndexSink(t, br, SearchIndexSinkConfig{MaxBatchActions: 10})
+
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if err := s.Flush(); err == nil {
+		t.Fatalf("expected an error for the failed action")
+	}
+
+	// the batch is kept and sent again
+	br.items = nil
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(br.payloads) != 2 {
+		t.Errorf("expected the failed batch to be sent again, got %d requests", len(br.payloads))
+	}
+}
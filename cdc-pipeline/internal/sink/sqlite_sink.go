@@ -0,0 +1,362 @@
+package sink
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+	_ "modernc.org/sqlite" // pure Go SQLite driver
+)
+
+// SQLiteSink applies change events to an embedded SQLite database with the tables of the Cassandra schema
+// (users, orders, orders_by_user and processed_events).
+// It runs the cassandra sink's own logic on a session translating its CQL statements to SQL with Cassandra's semantics
+// (INSERT and UPDATE are upserts, `IF NOT EXISTS` inserts are lightweight transactions and rows are returned in clustering order),
+// so it can serve as a lightweight local replica and to test the cassandra sink end-to-end.
+type SQLiteSink struct {
+	*CassandraClient
+	db *sql.DB
+}
+
+// NewSQLiteSink opens (or creates) the SQLite database at path (":memory:" for an in-memory database),
+// creates the tables if they don't exist and returns a new SQLiteSink
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite sink: open database: %w", err)
+	}
+	// SQLite has a single writer anyway, and an in-memory database only lives as long as its connection
+	db.SetMaxOpenConns(1)
+
+	for _, t := range cassandraTables {
+		if _, err := db.Exec(sqliteCreateTableStmt(&t)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("sqlite sink: create table %s: %w", t.name, err)
+		}
+	}
+
+	return &SQLiteSink{CassandraClient: &CassandraClient{session: &sqliteSession{db: db}}, db: db}, nil
+}
+
+// Select runs a CQL SELECT statement and returns all its rows
+// (with the Go types gocql returns for the column types, e.g. gocql.UUID, time.Time and *inf.Dec)
+func (s *SQLiteSink) Select(stmt string, values ...interface{}) ([]map[string]interface{}, error) {
+	q := &sqliteQuery{db: s.db, stmt: stmt, values: values}
+	return q.scan()
+}
+
+func sqliteCreateTableStmt(t *cqlTable) string {
+	var cols []string
+	for _, c := range t.columns {
+		cols = append(cols, c.name+" "+sqliteColumnType(c.cqlType))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (%s))",
+		t.name, strings.Join(cols, ", "), strings.Join(t.primaryKey(), ", "))
+}
+
+func sqliteColumnType(cqlType string) string {
+	switch cqlType {
+	case "timestamp", "int", "bigint", "boolean":
+		return "INTEGER" // timestamps are stored as ms since epoch (Cassandra's precision)
+	default:
+		return "TEXT" // uuid, text, date (yyyy-mm-dd) and decimal (exact string representation)
+	}
+}
+
+// sqliteSession implements the CassandraSession interface on a SQLite database
+type sqliteSession struct {
+	db *sql.DB
+}
+
+func (s *sqliteSession) Query(stmt string, values ...interface{}) CassandraQuery {
+	return &sqliteQuery{db: s.db, stmt: stmt, values: values}
+}
+
+func (s *sqliteSession) Close() {
+	if err := s.db.Close(); err != nil {
+		logger.ErrorLogger.Printf("sqlite sink: error while closing database: %v\n", err)
+	}
+}
+
+type sqliteQuery struct {
+	db     *sql.DB
+	stmt   string
+	values []interface{}
+}
+
+func (q *sqliteQuery) Exec() error {
+	_, err := q.exec()
+	return err
+}
+
+// MapScan reads the first row of a SELECT statement, returns gocql.ErrNotFound if there is none
+func (q *sqliteQuery) MapScan(dest map[string]interface{}) error {
+	rows, err := q.scan()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return gocql.ErrNotFound
+	}
+	for k, v := range rows[0] {
+		dest[k] = v
+	}
+	return nil
+}
+
+// MapScanCAS executes an `INSERT ... IF NOT EXISTS` statement, reports whether the row was inserted
+func (q *sqliteQuery) MapScanCAS(dest map[string]interface{}) (bool, error) {
+	st, err := parseCQL(q.stmt)
+	if err != nil {
+		return false, err
+	}
+	if !st.ifNotExists {
+		return false, fmt.Errorf("not a lightweight transaction: %s", q.stmt)
+	}
+	n, err := q.exec()
+	return n == 1, err
+}
+
+// SQLite has a single node, the consistency levels are ignored
+func (q *sqliteQuery) Consistency(gocql.Consistency) CassandraQuery             { return q }
+func (q *sqliteQuery) SerialConsistency(gocql.SerialConsistency) CassandraQuery { return q }
+
+// translate and execute an INSERT, UPDATE or DELETE statement, returns the number of affected rows
+func (q *sqliteQuery) exec() (int64, error) {
+	st, t, args, err := prepareCQL(q.stmt, q.values)
+	if err != nil {
+		return 0, err
+	}
+	if st.kind == "SELECT" {
+		_, err := q.scan()
+		return 0, err
+	}
+	cols, err := checkCQLWrite(st, t, args)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", err, q.stmt)
+	}
+
+	var stmt string
+	switch st.kind {
+	case "INSERT":
+		stmt = sqliteUpsertStmt(t, cols, st.ifNotExists)
+	case "UPDATE":
+		// an UPDATE creates the row if it doesn't exist (like an INSERT of the assigned columns)
+		stmt = sqliteUpsertStmt(t, cols, false)
+	case "DELETE":
+		stmt = fmt.Sprintf("DELETE FROM %s WHERE %s", t.name, sqliteConditions(st.where))
+	}
+
+	res, err := q.db.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// translate and run a SELECT statement, returns its rows ordered by primary key
+func (q *sqliteQuery) scan() ([]map[string]interface{}, error) {
+	st, t, args, err := prepareCQL(q.stmt, q.values)
+	if err != nil {
+		return nil, err
+	}
+	if st.kind != "SELECT" {
+		return nil, fmt.Errorf("not a SELECT statement: %s", q.stmt)
+	}
+
+	cols := st.columns
+	if cols == nil {
+		for _, c := range t.columns {
+			cols = append(cols, c.name)
+		}
+	}
+	types := make([]string, len(cols))
+	for i, c := range cols {
+		if types[i], err = t.columnType(c); err != nil {
+			return nil, err
+		}
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), t.name)
+	if len(st.where) > 0 {
+		stmt += " WHERE " + sqliteConditions(st.where)
+	}
+	stmt += " ORDER BY " + strings.Join(t.primaryKey(), ", ")
+	if st.limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", st.limit)
+	}
+
+	rows, err := q.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		raw := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range raw {
+			ptrs[i] = &raw[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			if row[c], err = cqlValue(types[i], raw[i]); err != nil {
+				return nil, fmt.Errorf("column '%s': %w", c, err)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// INSERT statement only writing the given columns of an existing row (like Cassandra's INSERT)
+func sqliteUpsertStmt(t *cqlTable, cols []string, ifNotExists bool) string {
+	params := make([]string, len(cols))
+	var updates []string
+	for i, c := range cols {
+		params[i] = "?"
+		if !slices.Contains(t.primaryKey(), c) {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", c, c))
+		}
+	}
+
+	onConflict := "DO NOTHING"
+	if !ifNotExists && len(updates) > 0 {
+		onConflict = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		t.name, strings.Join(cols, ", "), strings.Join(params, ", "), strings.Join(t.primaryKey(), ", "), onConflict)
+}
+
+func sqliteConditions(cols []string) string {
+	conds := make([]string, len(cols))
+	for i, c := range cols {
+		conds[i] = c + " = ?"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// convert a value bound to a CQL column to the value stored in SQLite
+func sqliteValue(cqlType string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch cqlType {
+	case "uuid":
+		switch v := v.(type) {
+		case gocql.UUID:
+			return v.String(), nil
+		case string:
+			id, err := gocql.ParseUUID(v)
+			if err != nil {
+				return nil, err
+			}
+			return id.String(), nil
+		}
+	case "text":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "date":
+		switch v := v.(type) {
+		case string:
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		case time.Time:
+			return v.UTC().Format("2006-01-02"), nil
+		}
+	case "timestamp":
+		switch v := v.(type) {
+		case time.Time:
+			return v.UnixMilli(), nil
+		case int64:
+			return v, nil
+		}
+	case "int", "bigint":
+		switch v := v.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		}
+	case "decimal":
+		if d, ok := v.(*inf.Dec); ok {
+			return d.String(), nil
+		}
+	case "boolean":
+		if b, ok := v.(bool); ok {
+			if b {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	}
+	return nil, fmt.Errorf("can not marshal %T into %s", v, cqlType)
+}
+
+// convert a value stored in SQLite to the Go value gocql returns for the CQL type
+// (null values are returned as the type's zero value, like gocql's MapScan does)
+func cqlValue(cqlType string, v interface{}) (interface{}, error) {
+	switch cqlType {
+	case "uuid":
+		if v == nil {
+			return gocql.UUID{}, nil
+		}
+		return gocql.ParseUUID(v.(string))
+	case "text":
+		if v == nil {
+			return "", nil
+		}
+		return v.(string), nil
+	case "date":
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return time.Parse("2006-01-02", v.(string))
+	case "timestamp":
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return time.UnixMilli(v.(int64)).UTC(), nil
+	case "int":
+		if v == nil {
+			return 0, nil
+		}
+		return int(v.(int64)), nil
+	case "bigint":
+		if v == nil {
+			return int64(0), nil
+		}
+		return v.(int64), nil
+	case "decimal":
+		if v == nil {
+			return (*inf.Dec)(nil), nil
+		}
+		d, ok := new(inf.Dec).SetString(v.(string))
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal: %v", v)
+		}
+		return d, nil
+	case "boolean":
+		if v == nil {
+			return false, nil
+		}
+		return v.(int64) != 0, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %s", cqlType)
+}
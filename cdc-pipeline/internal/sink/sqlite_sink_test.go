@@ -0,0 +1,321 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/gocql/gocql"
+)
+
+func newTestSQLiteSink(t *testing.T) *SQLiteSink {
+	t.Helper()
+	s, err := NewSQLiteSink(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteSink failed: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// parse a Debezium message (without schema) and apply it to the sink
+func applyDebeziumMessage(t *testing.T, s *SQLiteSink, topic string, lsn int64, op string, after string) {
+	t.Helper()
+	msg := fmt.Sprintf(`{"payload": {"op": %q, "before": null, "after": %s, "ts_ms": 1756396978300,
+		"source": {"txId": %d, "lsn": %d, "ts_us": 1756396978281604}}}`, op, after, lsn, lsn)
+
+	ev, err := parser.ParseDebeziumEvent([]byte(msg))
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	if err := s.ApplyChange(topic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+}
+
+func selectRows(t *testing.T, s *SQLiteSink, stmt string, values ...interface{}) []map[string]interface{} {
+	t.Helper()
+	rows, err := s.Select(stmt, values...)
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	return rows
+}
+
+func testUserJSON(name string, isDeleted bool) string {
+	return fmt.Sprintf(`{"id": %q, "name": %q, "dob": 11172, "created_at": "2025-08-28T16:02:58.281604Z",
+		"modified_at": "2025-08-29T10:00:00.123456Z", "is_deleted": %t}`, testUserID, name, isDeleted)
+}
+
+func testOrderJSON(status string, isDeleted bool) string {
+	return fmt.Sprintf(`{"id": %q, "user_id": %q, "status": %q, "quantity": 2, "total_amount": "J0Q=",
+		"placed_at": "2025-08-28T16:02:58.281604Z", "modified_at": "2025-08-29T10:00:00.123456Z", "is_deleted": %t}`,
+		testOrderID, testUserID, status, isDeleted)
+}
+
+func TestSQLiteSink_UserLifecycle(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+
+	rows := selectRows(t, s, "SELECT * FROM users WHERE id = ?", testUserUUID)
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(rows))
+	}
+	got := rows[0]
+	createdAt := time.Date(2025, 8, 28, 16, 2, 58, 281000000, time.UTC) // truncated to ms like in Cassandra
+	if got["id"] != testUserUUID || got["name"] != "Alice" || got["is_deleted"] != false ||
+		!got["dob"].(time.Time).Equal(time.Date(2000, 8, 3, 0, 0, 0, 0, time.UTC)) ||
+		!got["created_at"].(time.Time).Equal(createdAt) || !got["modified_at"].(time.Time).IsZero() {
+		t.Errorf("unexpected user after insert: %v", got)
+	}
+
+	// renaming the user replaces the row (name is a clustering key)
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 101, "u", testUserJSON("Alicia", false))
+
+	rows = selectRows(t, s, "SELECT name, created_at, modified_at FROM users WHERE id = ?", testUserUUID)
+	if len(rows) != 1 || rows[0]["name"] != "Alicia" {
+		t.Fatalf("expected only the renamed user, got %v", rows)
+	}
+	if !rows[0]["created_at"].(time.Time).Equal(createdAt) || rows[0]["modified_at"].(time.Time).IsZero() {
+		t.Errorf("unexpected timestamps after update: %v", rows[0])
+	}
+
+	// soft delete
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 102, "u", testUserJSON("Alicia", true))
+
+	rows = selectRows(t, s, "SELECT name, is_deleted FROM users WHERE id = ?", testUserUUID)
+	if len(rows) != 1 || rows[0]["name"] != "Alicia" || rows[0]["is_deleted"] != true {
+		t.Errorf("expected the user to be soft deleted, got %v", rows)
+	}
+}
+
+func TestSQLiteSink_OrderLifecycle(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	applyDebeziumMessage(t, s, config.DebeziumOrdersTopic, 200, "c", testOrderJSON("PLACED", false))
+	applyDebeziumMessage(t, s, config.DebeziumOrdersTopic, 201, "u", testOrderJSON("SHIPPED", false))
+
+	orders := selectRows(t, s, "SELECT * FROM orders WHERE order_id = ?", testOrderUUID)
+	byUser := selectRows(t, s, "SELECT * FROM orders_by_user WHERE user_id = ?", testUserUUID)
+	if len(orders) != 1 || len(byUser) != 1 {
+		t.Fatalf("expected the order in both tables, got %d and %d rows", len(orders), len(byUser))
+	}
+
+	for _, row := range []map[string]interface{}{orders[0], byUser[0]} {
+		if row["order_id"] != testOrderUUID || row["user_id"] != testUserUUID || row["status"] != "SHIPPED" ||
+			row["quantity"] != 2 || row["is_deleted"] != false || row["modified_at"].(time.Time).IsZero() {
+			t.Errorf("unexpected order row: %v", row)
+		}
+		if total := fmt.Sprint(row["total_amount"]); total != "100.52" {
+			t.Errorf("unexpected total amount: got %s, want 100.52", total)
+		}
+	}
+}
+
+func TestSQLiteSink_DuplicateEvent(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 101, "u", testUserJSON("Alicia", false))
+
+	// redelivery of the (already processed) insert must not re-create the old row
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+
+	rows := selectRows(t, s, "SELECT name FROM users WHERE id = ?", testUserUUID)
+	if len(rows) != 1 || rows[0]["name"] != "Alicia" {
+		t.Errorf("expected the duplicate event to be skipped, got %v", rows)
+	}
+	if events := selectRows(t, s, "SELECT event_id FROM processed_events"); len(events) != 2 {
+		t.Errorf("expected 2 processed events, got %d", len(events))
+	}
+}
+
+func TestSQLiteSink_DedupMode(t *testing.T) {
+	s := newTestSQLiteSink(t)
+	countEvents := func() int { return len(selectRows(t, s, "SELECT event_id FROM processed_events")) }
+
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 101, "u", testUserJSON("Alicia", false))
+
+	// a replay applies the processed events again, but only once within its scope
+	s.SetDedupMode(DedupMode{Scope: "replay-1"})
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 101, "u", testUserJSON("Alicia", false))
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 101, "u", testUserJSON("Alicia", false))
+	if n := countEvents(); n != 3 {
+		t.Errorf("expected 3 processed events, got %d", n)
+	}
+
+	// bypassing the dedup applies (and doesn't record) every event
+	s.SetDedupMode(DedupMode{Bypass: true})
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+
+	rows := selectRows(t, s, "SELECT name FROM users WHERE id = ?", testUserUUID)
+	if len(rows) != 2 {
+		t.Errorf("expected the insert to be applied again, got %v", rows)
+	}
+	if n := countEvents(); n != 3 {
+		t.Errorf("expected no new processed events, got %d", n)
+	}
+}
+
+func TestSQLiteSink_UpdateOfUnknownUser(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	msg := fmt.Sprintf(`{"payload": {"op": "u", "after": %s, "ts_ms": 1, "source": {"txId": 1, "lsn": 1, "ts_us": 1000}}}`,
+		testUserJSON("Alice", false))
+	ev, err := parser.ParseDebeziumEvent([]byte(msg))
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+
+	// like with Cassandra, reading the existing record fails
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err == nil {
+		t.Errorf("expected an error for an update of an unknown user")
+	}
+}
+
+func TestSQLiteSession_UpdateIsUpsert(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	id := gocql.TimeUUID()
+	modifiedAt := time.Date(2025, 8, 28, 16, 0, 0, 0, time.UTC)
+	if err := s.session.Query("UPDATE users SET modified_at = ?, is_deleted = ? WHERE id = ? and name = ?", modifiedAt, true, id, "Bob").Exec(); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	rows := selectRows(t, s, "SELECT * FROM users WHERE id = ?", id)
+	if len(rows) != 1 || rows[0]["name"] != "Bob" || rows[0]["is_deleted"] != true || !rows[0]["modified_at"].(time.Time).Equal(modifiedAt) {
+		t.Errorf("expected UPDATE to create the row, got %v", rows)
+	}
+}
+
+func TestSQLiteSession_SelectInClusteringOrder(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	for _, name := range []string{"Carol", "Alice", "Bob"} {
+		if err := s.session.Query("INSERT INTO users (id, name) VALUES (?, ?)", testUserUUID, name).Exec(); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+	}
+
+	row := map[string]interface{}{}
+	if err := s.session.Query("SELECT name from users where id = ? LIMIT 1", testUserUUID).MapScan(row); err != nil {
+		t.Fatalf("MapScan failed: %v", err)
+	}
+	if row["name"] != "Alice" {
+		t.Errorf("expected the first row in clustering order, got %v", row["name"])
+	}
+
+	if err := s.session.Query("SELECT name from users where id = ?", gocql.TimeUUID()).MapScan(row); err != gocql.ErrNotFound {
+		t.Errorf("expected gocql.ErrNotFound, got %v", err)
+	}
+}
+
+func TestParseCQL(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want cqlStatement
+	}{
+		{
+			"INSERT INTO processed_events (event_id, topic) VALUES (?, ?) IF NOT EXISTS",
+			cqlStatement{kind: "INSERT", table: "processed_events", columns: []string{"event_id", "topic"}, ifNotExists: true},
+		},
+		{
+			"UPDATE orders SET status = ?, is_deleted = ? WHERE order_id = ? AND user_id = ?",
+			cqlStatement{kind: "UPDATE", table: "orders", columns: []string{"status", "is_deleted"}, where: []string{"order_id", "user_id"}},
+		},
+		{
+			"DELETE FROM users WHERE id = ? and name = ?",
+			cqlStatement{kind: "DELETE", table: "users", where: []string{"id", "name"}},
+		},
+		{
+			"SELECT name from users where id = ? LIMIT 1",
+			cqlStatement{kind: "SELECT", table: "users", columns: []string{"name"}, where: []string{"id"}, limit: 1},
+		},
+		{
+			"SELECT * FROM orders",
+			cqlStatement{kind: "SELECT", table: "orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCQL(tt.stmt)
+		if err != nil {
+			t.Errorf("parseCQL(%q) failed: %v", tt.stmt, err)
+			continue
+		}
+		if fmt.Sprint(*got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseCQL(%q) = %+v, want %+v", tt.stmt, *got, tt.want)
+		}
+	}
+
+	if _, err := parseCQL("UPDATE users SET name = 'x' WHERE id = ?"); err == nil {
+		t.Errorf("expected an error for a literal value")
+	}
+}
+
+func TestSQLiteSink_ApplySnapshotRow(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	snapshotRow := func(js string) model.JsonMap {
+		var row model.JsonMap
+		if err := json.Unmarshal([]byte(js), &row); err != nil {
+			t.Fatalf("invalid row: %v", err)
+		}
+		return row
+	}
+
+	// a soft deleted user is created and then updated (like by its change events)
+	if err := s.ApplySnapshotRow(config.DebeziumUsersTopic, snapshotRow(testUserJSON("Alice", true))); err != nil {
+		t.Fatalf("ApplySnapshotRow failed: %v", err)
+	}
+	users := selectRows(t, s, "SELECT * FROM users WHERE id = ?", testUserUUID)
+	if len(users) != 1 || users[0]["is_deleted"] != true || users[0]["dob"].(time.Time).IsZero() || users[0]["modified_at"].(time.Time).IsZero() {
+		t.Errorf("unexpected user: %v", users)
+	}
+
+	// an order that wasn't modified since it was placed is only created
+	order := snapshotRow(testOrderJSON("PLACED", false))
+	order["modified_at"] = order["placed_at"]
+	if err := s.ApplySnapshotRow(config.DebeziumOrdersTopic, order); err != nil {
+		t.Fatalf("ApplySnapshotRow failed: %v", err)
+	}
+	for _, table := range []string{"orders", "orders_by_user"} {
+		rows := selectRows(t, s, "SELECT * FROM "+table+" WHERE user_id = ? AND order_id = ?", testUserUUID, testOrderUUID)
+		if len(rows) != 1 || rows[0]["status"] != "PLACED" || !rows[0]["modified_at"].(time.Time).IsZero() {
+			t.Errorf("unexpected %s: %v", table, rows)
+		}
+	}
+
+	if err := s.ApplySnapshotRow("cdc.public.unknown", order); err == nil {
+		t.Errorf("expected an error for an unknown topic")
+	}
+}
+
+func TestSQLiteSink_PoisonEvent(t *testing.T) {
+	s := newTestSQLiteSink(t)
+
+	msg := fmt.Sprintf(`{"payload": {"op": "c", "after": {"id": %q, "name": "Alice", "dob": "2000-08-03"}, "ts_ms": 1,
+		"source": {"txId": 100, "lsn": 100, "ts_us": 1000}}}`, testUserID)
+	ev, err := parser.ParseDebeziumEvent([]byte(msg))
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); !parser.IsPoison(err) {
+		t.Fatalf("expected a poison message error, got %v", err)
+	}
+
+	// the failed event isn't recorded as processed, so it's applied when it's fixed (or retried)
+	if events := selectRows(t, s, "SELECT event_id FROM processed_events"); len(events) != 0 {
+		t.Errorf("expected no processed events, got %v", events)
+	}
+	applyDebeziumMessage(t, s, config.DebeziumUsersTopic, 100, "c", testUserJSON("Alice", false))
+	if rows := selectRows(t, s, "SELECT name FROM users WHERE id = ?", testUserUUID); len(rows) != 1 {
+		t.Errorf("expected the event to be applied, got %v", rows)
+	}
+}
@@ -0,0 +1,306 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/google/uuid"
+)
+
+// headers set on every webhook request
+const (
+	WebhookSignatureHeader = "X-CDC-Signature" // "sha256=<hex encoded HMAC-SHA256 of the body>"
+	WebhookDeliveryHeader  = "X-CDC-Delivery"  // unique id of the delivery (identical for all its attempts)
+)
+
+// ErrCircuitOpen is returned for deliveries to an endpoint whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// WebhookEndpoint is a destination of the webhook sink
+type WebhookEndpoint struct {
+	Name   string
+	URL    string
+	Secret string   // key used to sign the request bodies
+	Tables []string // only changes of these tables are delivered (all tables if empty)
+}
+
+// WebhookSinkConfig configures a WebhookSink
+type WebhookSinkConfig struct {
+	Endpoints        []WebhookEndpoint
+	BatchSize        int           // number of events per request, events are buffered until Flush if > 1
+	MaxAttempts      int           // attempts per delivery
+	InitialBackOff   time.Duration // wait time after the first failed attempt (doubled after every attempt)
+	Timeout          time.Duration // timeout of a single request
+	BreakerThreshold int           // consecutive failed deliveries that open an endpoint's circuit (0 = never)
+	BreakerCooldown  time.Duration // time a circuit stays open before a trial delivery is let through
+}
+
+// WebhookEvent is the JSON representation of a change event delivered to the endpoints
+type WebhookEvent struct {
+	Table   string        `json:"table"`
+	Op      string        `json:"op"`
+	TsMs    int64         `json:"ts_ms"`
+	EventID string        `json:"event_id"`
+	LSN     int64         `json:"lsn,omitempty"`
+	Row     model.JsonMap `json:"row"`
+	Before  model.JsonMap `json:"before,omitempty"` // the row before the change (only its key for deletes by default)
+}
+
+// WebhookPayload is the body of a webhook request
+type WebhookPayload struct {
+	Events []WebhookEvent `json:"events"`
+}
+
+// WebhookSink POSTs change events to the configured HTTP endpoints.
+// Deliveries are at least once, so receivers should de-duplicate events using their event_id.
+type WebhookSink struct {
+	cfg       WebhookSinkConfig
+	client    *http.Client
+	endpoints []*webhookEndpoint
+	sleep     func(time.Duration)
+	now       func() time.Time
+}
+
+// per endpoint state
+type webhookEndpoint struct {
+	WebhookEndpoint
+	mu        sync.Mutex // serialises deliveries (and guards below fields)
+	pending   []WebhookEvent
+	delivered map[string]bool // ids of the events of the last successful delivery
+	breaker   circuitBreaker
+}
+
+// circuitBreaker opens after a number of consecutive failures and lets a trial request through after a cooldown
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewWebhookSink returns a new WebhookSink
+func NewWebhookSink(cfg WebhookSinkConfig) (*WebhookSink, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, fmt.Errorf("webhook sink: no endpoints configured")
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+
+	s := &WebhookSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		sleep:  time.Sleep,
+		now:    time.Now,
+	}
+	for _, e := range cfg.Endpoints {
+		s.endpoints = append(s.endpoints, &webhookEndpoint{WebhookEndpoint: e})
+	}
+	return s, nil
+}
+
+// ApplyChange delivers the change event to every endpoint interested in its table
+// (or buffers it when batching is enabled). When the delivery to an endpoint fails, applying the event again retries
+// the delivery to that endpoint only: the event isn't buffered again by the endpoints it's pending for or was
+// delivered to.
+func (s *WebhookSink) ApplyChange(topic string, ev *model.ChangeEvent) error {
+	if ev == nil {
+		logger.DebugLogger.Println("nil change event")
+		return nil
+	}
+
+	table := tableFromTopic(topic)
+	event := WebhookEvent{
+		Table:   table,
+		Op:      ev.Op,
+		TsMs:    ev.TsMs,
+		EventID: ev.EventID,
+		LSN:     ev.LSN,
+		Row:     ev.Row,
+		Before:  ev.Before,
+	}
+
+	var errs []error
+	for _, e := range s.endpoints {
+		if len(e.Tables) > 0 && !slices.Contains(e.Tables, table) {
+			continue
+		}
+
+		e.mu.Lock()
+		if !e.hasEvent(event.EventID) {
+			e.pending = append(e.pending, event)
+		}
+		var err error
+		if len(e.pending) >= s.cfg.BatchSize {
+			err = s.deliverPending(e)
+		}
+		e.mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush delivers all buffered events
+func (s *WebhookSink) Flush() error {
+	var errs []error
+	for _, e := range s.endpoints {
+		e.mu.Lock()
+		if err := s.deliverPending(e); err != nil {
+			errs = append(errs, err)
+		}
+		e.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Close delivers all buffered events
+func (s *WebhookSink) Close() {
+	if err := s.Flush(); err != nil {
+		logger.ErrorLogger.Printf("webhook sink: error while delivering buffered events: %v\n", err)
+	}
+}
+
+// deliver the pending events of an endpoint (the caller must hold the endpoint's lock).
+// Events stay pending if the delivery fails, so they are retried with the next delivery.
+func (s *WebhookSink) deliverPending(e *webhookEndpoint) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+
+	now := s.now()
+	if e.breaker.isOpen(now) {
+		return fmt.Errorf("webhook %s: %w", e.Name, ErrCircuitOpen)
+	}
+
+	body, err := json.Marshal(WebhookPayload{Events: e.pending})
+	if err != nil {
+		return fmt.Errorf("webhook %s: marshal payload: %w", e.Name, err)
+	}
+
+	deliveryID := uuid.NewString()
+	start := now
+	attempts, status, err := s.post(e, deliveryID, body)
+	if err != nil {
+		if e.breaker.recordFailure(s.now(), s.cfg.BreakerThreshold, s.cfg.BreakerCooldown) {
+			logger.ErrorLogger.Printf("webhook %s: circuit breaker opened for %v after %d consecutive failures\n", e.Name, s.cfg.BreakerCooldown, e.breaker.failures)
+		}
+		logger.ErrorLogger.Printf("webhook delivery failed: endpoint=%s delivery=%s events=%d attempts=%d status=%d: %v\n",
+			e.Name, deliveryID, len(e.pending), attempts, status, err)
+		return fmt.Errorf("webhook %s: %w", e.Name, err)
+	}
+
+	e.breaker.recordSuccess()
+	logger.InfoLogger.Printf("webhook delivered: endpoint=%s delivery=%s events=%d attempts=%d status=%d duration=%v\n",
+		e.Name, deliveryID, len(e.pending), attempts, status, s.now().Sub(start))
+	e.delivered = make(map[string]bool, len(e.pending))
+	for _, ev := range e.pending {
+		e.delivered[ev.EventID] = true
+	}
+	e.pending = e.pending[:0]
+	return nil
+}
+
+// reports whether an event is pending for the endpoint or was in its last delivery, i.e. it's applied again after
+// failing for another endpoint (the caller must hold the endpoint's lock)
+func (e *webhookEndpoint) hasEvent(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	return e.delivered[eventID] || slices.ContainsFunc(e.pending, func(ev WebhookEvent) bool { return ev.EventID == eventID })
+}
+
+// POST the body to the endpoint with a backoff retry strategy.
+// Returns the number of attempts and the status code of the last response.
+func (s *WebhookSink) post(e *webhookEndpoint, deliveryID string, body []byte) (int, int, error) {
+	var status int
+	var err error
+
+	for i := 0; i < s.cfg.MaxAttempts; i++ {
+		var retry bool
+		status, retry, err = s.postOnce(e, deliveryID, body)
+		if err == nil || !retry {
+			return i + 1, status, err
+		}
+		if i < s.cfg.MaxAttempts-1 {
+			delay := s.cfg.InitialBackOff << i
+			logger.DebugLogger.Printf("[Attempt %d/%d] webhook %s failed, trying again in %v: %v\n", i+1, s.cfg.MaxAttempts, e.Name, delay, err)
+			s.sleep(delay)
+		}
+	}
+	return s.cfg.MaxAttempts, status, err
+}
+
+// send a single request, reports whether a failed request may be retried
+func (s *WebhookSink) postOnce(e *webhookEndpoint, deliveryID string, body []byte) (int, bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(e.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, err // network errors are retried
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // drain the body to reuse the connection
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.StatusCode, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return resp.StatusCode, true, fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		// other client errors won't succeed on retry
+		return resp.StatusCode, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// SignWebhookPayload returns the signature header value of a request body
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether the signature header value matches the request body
+// (to be used by webhook receivers)
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, body)), []byte(signature))
+}
+
+func (b *circuitBreaker) isOpen(now time.Time) bool {
+	// once the cooldown has passed, the circuit is half-open and lets a trial delivery through
+	return now.Before(b.openUntil)
+}
+
+// record a failed delivery, reports whether the circuit was opened
+func (b *circuitBreaker) recordFailure(now time.Time, threshold int, cooldown time.Duration) bool {
+	b.failures++
+	if threshold > 0 && b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+		return true
+	}
+	return false
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
@@ -0,0 +1,274 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/config"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+const testWebhookSecret = "s3cr3t"
+
+// webhookReceiver is a httptest stand-in for a webhook endpoint
+type webhookReceiver struct {
+	mu        sync.Mutex
+	statuses  []int // status codes to reply with (in order), 200 once exhausted
+	requests  int
+	payloads  []WebhookPayload
+	badSigned int // requests with an invalid signature
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if !VerifyWebhookSignature(testWebhookSecret, body, r.Header.Get(WebhookSignatureHeader)) {
+		wr.badSigned++
+	}
+
+	status := http.StatusOK
+	if wr.requests < len(wr.statuses) {
+		status = wr.statuses[wr.requests]
+	}
+	wr.requests++
+
+	if status == http.StatusOK {
+		var p WebhookPayload
+		json.Unmarshal(body, &p)
+		wr.payloads = append(wr.payloads, p)
+	}
+	w.WriteHeader(status)
+}
+
+func newTestWebhookSink(t *testing.T, cfg WebhookSinkConfig) *WebhookSink {
+	t.Helper()
+	s, err := NewWebhookSink(cfg)
+	if err != nil {
+		t.Fatalf("NewWebhookSink failed: %v", err)
+	}
+	s.sleep = func(time.Duration) {} // no need to wait between retries
+	return s
+}
+
+func TestWebhookSink_SignedDelivery(t *testing.T) {
+	users, orders := &webhookReceiver{}, &webhookReceiver{}
+	usersSrv, ordersSrv := httptest.NewServer(users), httptest.NewServer(orders)
+	defer usersSrv.Close()
+	defer ordersSrv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints: []WebhookEndpoint{
+			{Name: "users", URL: usersSrv.URL, Secret: testWebhookSecret, Tables: []string{"users"}},
+			{Name: "orders", URL: ordersSrv.URL, Secret: testWebhookSecret, Tables: []string{"orders"}},
+		},
+		MaxAttempts: 1,
+	})
+
+	ev := newTestUserEvent(0, 7)
+	ev.LSN = 1234
+	if err := s.ApplyChange(config.DebeziumUsersTopic, ev); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	if users.requests != 1 || orders.requests != 0 {
+		t.Fatalf("expected 1 request to users and none to orders, got %d and %d", users.requests, orders.requests)
+	}
+	if users.badSigned != 0 {
+		t.Errorf("expected a valid signature")
+	}
+
+	events := users.payloads[0].Events
+	if len(events) != 1 {
+		t.Fatalf("expected 1 delivered event, got %d", len(events))
+	}
+	got := events[0]
+	if got.Table != "users" || got.Op != "c" || got.EventID != ev.EventID || got.LSN != 1234 || got.Row["id"] != testUserID {
+		t.Errorf("unexpected delivered event: %+v", got)
+	}
+}
+
+func TestWebhookSink_DeleteHasDeletedKey(t *testing.T) {
+	wr := &webhookReceiver{}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints: []WebhookEndpoint{{Name: "test", URL: srv.URL, Secret: testWebhookSecret}},
+	})
+	del := newTestUserEvent(0, 1)
+	del.Op, del.Row, del.Before = "d", nil, model.JsonMap{"id": testUserID}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, del); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+
+	if len(wr.payloads) != 1 || len(wr.payloads[0].Events) != 1 {
+		t.Fatalf("expected a single delivered event, got %+v", wr.payloads)
+	}
+	ev := wr.payloads[0].Events[0]
+	if ev.Op != "d" || ev.Row != nil || ev.Before["id"] != testUserID {
+		t.Errorf("expected the delete with the deleted key, got %+v", ev)
+	}
+}
+
+func TestWebhookSink_RetryWithBackoff(t *testing.T) {
+	wr := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints:      []WebhookEndpoint{{Name: "test", URL: srv.URL, Secret: testWebhookSecret}},
+		MaxAttempts:    3,
+		InitialBackOff: time.Second,
+	})
+	var delays []time.Duration
+	s.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if wr.requests != 3 {
+		t.Errorf("expected 3 attempts, got %d", wr.requests)
+	}
+	if len(delays) != 2 || delays[0] != time.Second || delays[1] != 2*time.Second {
+		t.Errorf("unexpected backoff delays: %v", delays)
+	}
+}
+
+func TestWebhookSink_NoRetryOnClientError(t *testing.T) {
+	wr := &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints:   []WebhookEndpoint{{Name: "test", URL: srv.URL, Secret: testWebhookSecret}},
+		MaxAttempts: 3,
+	})
+
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err == nil {
+		t.Fatalf("expected an error for a rejected delivery")
+	}
+	if wr.requests != 1 {
+		t.Errorf("expected a single attempt, got %d", wr.requests)
+	}
+}
+
+func TestWebhookSink_CircuitBreaker(t *testing.T) {
+	wr := &webhookReceiver{statuses: []int{500, 500, 500}}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints:        []WebhookEndpoint{{Name: "test", URL: srv.URL, Secret: testWebhookSecret}},
+		MaxAttempts:      1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+	now := time.Date(2025, 8, 28, 16, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// two failed deliveries open the circuit
+	for i := range 2 {
+		if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err == nil {
+			t.Fatalf("expected delivery to fail")
+		}
+	}
+	err := s.Flush()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if wr.requests != 2 {
+		t.Errorf("expected no request while the circuit is open, got %d requests", wr.requests)
+	}
+
+	// trial delivery after the cooldown fails again and re-opens the circuit
+	now = now.Add(time.Minute)
+	if err := s.Flush(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the trial delivery to fail, got %v", err)
+	}
+	if err := s.Flush(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// next trial succeeds and delivers all events that failed before
+	now = now.Add(time.Minute)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("expected the trial delivery to succeed, got %v", err)
+	}
+	if len(wr.payloads) != 1 || len(wr.payloads[0].Events) != 2 {
+		t.Errorf("expected both failed events in a single delivery, got %+v", wr.payloads)
+	}
+}
+
+func TestWebhookSink_Batching(t *testing.T) {
+	wr := &webhookReceiver{}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints:   []WebhookEndpoint{{Name: "test", URL: srv.URL, Secret: testWebhookSecret}},
+		BatchSize:   2,
+		MaxAttempts: 1,
+	})
+
+	for i := range 3 {
+		if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, int64(i))); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	if wr.requests != 1 {
+		t.Fatalf("expected 1 request for a full batch, got %d", wr.requests)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if wr.requests != 2 || len(wr.payloads[0].Events) != 2 || len(wr.payloads[1].Events) != 1 {
+		t.Errorf("unexpected batches: %+v", wr.payloads)
+	}
+}
+
+func TestWebhookSink_RetryAfterEndpointFailure(t *testing.T) {
+	ok, failing := &webhookReceiver{}, &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	okSrv, failingSrv := httptest.NewServer(ok), httptest.NewServer(failing)
+	defer okSrv.Close()
+	defer failingSrv.Close()
+
+	s := newTestWebhookSink(t, WebhookSinkConfig{
+		Endpoints: []WebhookEndpoint{
+			{Name: "ok", URL: okSrv.URL, Secret: testWebhookSecret},
+			{Name: "failing", URL: failingSrv.URL, Secret: testWebhookSecret},
+		},
+		BatchSize:   2,
+		MaxAttempts: 1,
+	})
+
+	// the batch is delivered to one endpoint only
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 0)); err != nil {
+		t.Fatalf("ApplyChange failed: %v", err)
+	}
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err == nil {
+		t.Fatalf("expected an error for the failed endpoint")
+	}
+
+	// applying the failed event again only retries the failed endpoint, without duplicating the event in its batch
+	if err := s.ApplyChange(config.DebeziumUsersTopic, newTestUserEvent(0, 1)); err != nil {
+		t.Fatalf("retried ApplyChange failed: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if ok.requests != 1 || len(ok.payloads[0].Events) != 2 {
+		t.Errorf("expected a single delivery of 2 events to the ok endpoint, got %d requests: %+v", ok.requests, ok.payloads)
+	}
+	if failing.requests != 2 || len(failing.payloads) != 1 || len(failing.payloads[0].Events) != 2 {
+		t.Errorf("expected the retry to deliver 2 events to the failed endpoint, got %d requests: %+v", failing.requests, failing.payloads)
+	}
+}
@@ -0,0 +1,126 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+)
+
+// connector metadata in the `source` field of the messages
+const (
+	sourceConnector = "postgresql"
+	sourceVersion   = "go-pgoutput"
+)
+
+// DebeziumTopic returns the topic of a table's changes (`<prefix>.<schema>.<table>`, like Debezium names it)
+func DebeziumTopic(prefix string, schema string, table string) string {
+	return fmt.Sprintf("%s.%s.%s", prefix, schema, table)
+}
+
+// EncodeDebeziumKey returns the message key of a change in Debezium's JSON format (schema and payload)
+func EncodeDebeziumKey(prefix string, ch *Change) ([]byte, error) {
+	name := DebeziumTopic(prefix, ch.Schema, ch.Table)
+	return json.Marshal(model.JsonMap{
+		"schema":  structSchema(name+".Key", ch.KeyColumns, false, ""),
+		"payload": ch.Key,
+	})
+}
+
+// EncodeDebeziumValue returns the message value of a change in Debezium's JSON format (schema and envelope payload).
+// processedAt is the time the change was processed (ts_ms of the envelope).
+func EncodeDebeziumValue(prefix string, db string, ch *Change, processedAt int64) ([]byte, error) {
+	name := DebeziumTopic(prefix, ch.Schema, ch.Table)
+	ev := ch.Event
+
+	schema := model.JsonMap{
+		"type": "struct",
+		"fields": []model.JsonMap{
+			structSchema(name+".Value", ev.Columns, true, "before"),
+			structSchema(name+".Value", ev.Columns, true, "after"),
+			sourceSchema(),
+			{"type": "string", "optional": false, "field": "op"},
+			{"type": "int64", "optional": true, "field": "ts_ms"},
+		},
+		"optional": false,
+		"name":     name + ".Envelope",
+		"version":  1,
+	}
+
+	payload := model.JsonMap{
+		"before": rowOrNil(ev.Before),
+		"after":  rowOrNil(ev.Row),
+		"source": model.JsonMap{
+			"version":   sourceVersion,
+			"connector": sourceConnector,
+			"name":      prefix,
+			"ts_ms":     ch.CommitTime.UnixMilli(),
+			"ts_us":     ch.CommitTime.UnixMicro(),
+			"snapshot":  "false",
+			"db":        db,
+			"schema":    ch.Schema,
+			"table":     ch.Table,
+			"txId":      ch.TxID,
+			"lsn":       ev.LSN,
+		},
+		"op":    ev.Op,
+		"ts_ms": processedAt,
+	}
+
+	return json.Marshal(model.JsonMap{"schema": schema, "payload": payload})
+}
+
+// a nil row is encoded as JSON null (not as an empty object)
+func rowOrNil(row model.JsonMap) any {
+	if row == nil {
+		return nil
+	}
+	return row
+}
+
+// schema of a struct with the given columns (field is empty for the top level struct of a key)
+func structSchema(name string, columns []model.Column, optional bool, field string) model.JsonMap {
+	fields := make([]model.JsonMap, 0, len(columns))
+	for _, c := range columns {
+		f := model.JsonMap{"type": c.Type, "optional": c.Optional, "field": c.Name}
+		if c.Logical != "" {
+			f["name"] = c.Logical
+			f["version"] = 1
+		}
+		if len(c.Params) > 0 {
+			f["parameters"] = c.Params
+		}
+		fields = append(fields, f)
+	}
+
+	s := model.JsonMap{"type": "struct", "fields": fields, "optional": optional, "name": name}
+	if field != "" {
+		s["field"] = field
+	}
+	return s
+}
+
+func sourceSchema() model.JsonMap {
+	field := func(typ string, name string, optional bool) model.JsonMap {
+		return model.JsonMap{"type": typ, "optional": optional, "field": name}
+	}
+	return model.JsonMap{
+		"type": "struct",
+		"fields": []model.JsonMap{
+			field("string", "version", false),
+			field("string", "connector", false),
+			field("string", "name", false),
+			field("int64", "ts_ms", false),
+			field("int64", "ts_us", true),
+			field("string", "snapshot", true),
+			field("string", "db", false),
+			field("string", "schema", false),
+			field("string", "table", false),
+			field("int64", "txId", true),
+			field("int64", "lsn", true),
+		},
+		"optional": false,
+		"name":     "io.debezium.connector.postgresql.Source",
+		"field":    "source",
+	}
+}
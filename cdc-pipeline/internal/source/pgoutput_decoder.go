@@ -0,0 +1,290 @@
+package source
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/model"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gopkg.in/inf.v0"
+)
+
+// value of columns whose (unchanged TOASTed) value is not sent by Postgres (same placeholder as Debezium)
+const UnavailableValuePlaceholder = "__debezium_unavailable_value"
+
+// Change is a decoded row change of a table
+type Change struct {
+	Schema     string // e.g. "public"
+	Table      string // e.g. "users"
+	TxID       uint32
+	CommitTime time.Time
+	Key        model.JsonMap  // values of the replica identity (primary key) columns
+	KeyColumns []model.Column // schema of the key
+	Event      *model.ChangeEvent
+}
+
+// relation (table) metadata sent by pgoutput before the first change of a table (and after its schema changed)
+type relation struct {
+	schema  string
+	table   string
+	columns []model.Column
+	key     []int // indexes of the replica identity columns
+}
+
+// Decoder decodes pgoutput messages into row changes.
+// Messages must be passed in the order they were received, as changes refer to relations and transactions sent before.
+type Decoder struct {
+	relations  map[uint32]*relation
+	txID       uint32
+	commitTime time.Time
+}
+
+// NewDecoder returns a new Decoder
+func NewDecoder() *Decoder {
+	return &Decoder{relations: make(map[uint32]*relation)}
+}
+
+// Decode decodes a pgoutput message received at the given LSN.
+// Returns the row change, or nil for messages without a change (e.g. relation, begin and commit messages).
+func (d *Decoder) Decode(lsn pglogrepl.LSN, msg pglogrepl.Message) (*Change, error) {
+	switch m := msg.(type) {
+	case *pglogrepl.RelationMessage:
+		d.relations[m.RelationID] = newRelation(m)
+		return nil, nil
+	case *pglogrepl.BeginMessage:
+		d.txID, d.commitTime = m.Xid, m.CommitTime
+		return nil, nil
+	case *pglogrepl.InsertMessage:
+		return d.change(lsn, m.RelationID, "c", nil, m.Tuple)
+	case *pglogrepl.UpdateMessage:
+		// the old tuple is only sent if the key changed (or for replica identity FULL)
+		return d.change(lsn, m.RelationID, "u", m.OldTuple, m.NewTuple)
+	case *pglogrepl.DeleteMessage:
+		return d.change(lsn, m.RelationID, "d", m.OldTuple, nil)
+	default:
+		// commit, origin, type and truncate messages
+		return nil, nil
+	}
+}
+
+func (d *Decoder) change(lsn pglogrepl.LSN, relationID uint32, op string, oldTuple, newTuple *pglogrepl.TupleData) (*Change, error) {
+	rel, ok := d.relations[relationID]
+	if !ok {
+		return nil, fmt.Errorf("unknown relation id %d", relationID)
+	}
+
+	ev := &model.ChangeEvent{
+		Op:      op,
+		TsMs:    d.commitTime.UnixMilli(),
+		TxID:    int64(d.txID),
+		LSN:     int64(lsn),
+		Columns: rel.columns,
+	}
+
+	var err error
+	if oldTuple != nil {
+		if ev.Before, err = rel.decodeTuple(oldTuple); err != nil {
+			return nil, fmt.Errorf("%s.%s: old tuple: %w", rel.schema, rel.table, err)
+		}
+	}
+	if newTuple != nil {
+		if ev.Row, err = rel.decodeTuple(newTuple); err != nil {
+			return nil, fmt.Errorf("%s.%s: new tuple: %w", rel.schema, rel.table, err)
+		}
+	}
+
+	ch := &Change{
+		Schema:     rel.schema,
+		Table:      rel.table,
+		TxID:       d.txID,
+		CommitTime: d.commitTime,
+		Event:      ev,
+		Key:        model.JsonMap{},
+	}
+	keyRow := ev.Row
+	if op == "d" {
+		keyRow = ev.Before
+	}
+	for _, i := range rel.key {
+		col := rel.columns[i]
+		ch.KeyColumns = append(ch.KeyColumns, col)
+		ch.Key[col.Name] = keyRow[col.Name]
+	}
+	return ch, nil
+}
+
+func newRelation(m *pglogrepl.RelationMessage) *relation {
+	rel := &relation{schema: m.Namespace, table: m.RelationName}
+	for i, c := range m.Columns {
+		isKey := c.Flags == 1
+		col := ColumnSchema(c.Name, c.DataType, c.TypeModifier)
+		// pgoutput doesn't send the columns' nullability, only key columns are known to be not null
+		col.Optional = !isKey
+		rel.columns = append(rel.columns, col)
+		if isKey {
+			rel.key = append(rel.key, i)
+		}
+	}
+	return rel
+}
+
+// ColumnSchema returns the Debezium schema of a column of the given type (as the Postgres connector's default settings produce it)
+func ColumnSchema(name string, oid uint32, typmod int32) model.Column {
+	col := model.Column{Name: name}
+	switch oid {
+	case pgtype.BoolOID:
+		col.Type = "boolean"
+	case pgtype.Int2OID:
+		col.Type = "int16"
+	case pgtype.Int4OID:
+		col.Type = "int32"
+	case pgtype.Int8OID:
+		col.Type = "int64"
+	case pgtype.Float4OID:
+		col.Type = "float"
+	case pgtype.Float8OID:
+		col.Type = "double"
+	case pgtype.NumericOID:
+		if typmod < 0 {
+			// numeric without precision and scale has no fixed scale, so it's sent as string
+			col.Type = "string"
+			break
+		}
+		col.Type = "bytes"
+		col.Logical = "org.apache.kafka.connect.data.Decimal"
+		col.Params = map[string]string{
+			"scale":                     strconv.Itoa(int((typmod - 4) & 0xffff)),
+			"connect.decimal.precision": strconv.Itoa(int(((typmod - 4) >> 16) & 0xffff)),
+		}
+	case pgtype.UUIDOID:
+		col.Type, col.Logical = "string", "io.debezium.data.Uuid"
+	case pgtype.DateOID:
+		col.Type, col.Logical = "int32", "io.debezium.time.Date"
+	case pgtype.TimestamptzOID:
+		col.Type, col.Logical = "string", "io.debezium.time.ZonedTimestamp"
+	case pgtype.TimestampOID:
+		col.Type, col.Logical = "int64", "io.debezium.time.MicroTimestamp"
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		col.Type, col.Logical = "string", "io.debezium.data.Json"
+	case pgtype.ByteaOID:
+		col.Type = "bytes"
+	default:
+		col.Type = "string" // text, varchar and all other types in their text representation
+	}
+	return col
+}
+
+func (rel *relation) decodeTuple(tuple *pglogrepl.TupleData) (model.JsonMap, error) {
+	if len(tuple.Columns) != len(rel.columns) {
+		return nil, fmt.Errorf("expected %d columns, got %d", len(rel.columns), len(tuple.Columns))
+	}
+
+	row := make(model.JsonMap, len(rel.columns))
+	for i, tc := range tuple.Columns {
+		col := rel.columns[i]
+		switch tc.DataType {
+		case pglogrepl.TupleDataTypeNull:
+			row[col.Name] = nil
+		case pglogrepl.TupleDataTypeToast:
+			row[col.Name] = UnavailableValuePlaceholder
+		case pglogrepl.TupleDataTypeText:
+			v, err := DecodeTextValue(&col, string(tc.Data))
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %w", col.Name, err)
+			}
+			row[col.Name] = v
+		default:
+			return nil, fmt.Errorf("column '%s': unsupported tuple data type '%c'", col.Name, tc.DataType)
+		}
+	}
+	return row, nil
+}
+
+// DecodeTextValue converts the text representation of a value (as Postgres outputs it) to its Debezium JSON value
+func DecodeTextValue(col *model.Column, text string) (any, error) {
+	switch col.Logical {
+	case "io.debezium.time.Date": // days since epoch
+		t, err := time.Parse("2006-01-02", text)
+		if err != nil {
+			return nil, err
+		}
+		return int32(t.Unix() / 86400), nil
+	case "io.debezium.time.ZonedTimestamp":
+		t, err := parseTimestamp(text, true)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	case "io.debezium.time.MicroTimestamp":
+		t, err := parseTimestamp(text, false)
+		if err != nil {
+			return nil, err
+		}
+		return t.UnixMicro(), nil
+	case "org.apache.kafka.connect.data.Decimal":
+		scale, _ := strconv.Atoi(col.Params["scale"])
+		return encodeDecimal(text, scale)
+	}
+
+	switch col.Type {
+	case "boolean":
+		return text == "t", nil
+	case "int16", "int32", "int64":
+		return strconv.ParseInt(text, 10, 64)
+	case "float", "double":
+		return strconv.ParseFloat(text, 64)
+	case "bytes": // bytea in hex format (\x...)
+		b, err := hex.DecodeString(strings.TrimPrefix(text, `\x`))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return text, nil
+}
+
+// parse a timestamp in Postgres' output format (e.g. "2025-08-28 16:02:58.281604+00")
+func parseTimestamp(text string, withZone bool) (time.Time, error) {
+	if !withZone {
+		return time.Parse("2006-01-02 15:04:05.999999999", text)
+	}
+	t, err := time.Parse("2006-01-02 15:04:05.999999999Z07", text)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05.999999999Z07:00", text)
+	}
+	return t, err
+}
+
+// encode a decimal number as Debezium does:
+// the unscaled value as big-endian two's complement bytes (encoded as a base64 string)
+func encodeDecimal(text string, scale int) (string, error) {
+	d, ok := new(inf.Dec).SetString(text)
+	if !ok {
+		return "", fmt.Errorf("invalid decimal: %s", text)
+	}
+	d.Round(d, inf.Scale(scale), inf.RoundHalfEven)
+	return base64.StdEncoding.EncodeToString(twosComplementBytes(d.UnscaledBig())), nil
+}
+
+// minimal big-endian two's complement representation of an integer
+func twosComplementBytes(x *big.Int) []byte {
+	if x.Sign() >= 0 {
+		b := x.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...) // keep the sign bit clear
+		}
+		return b
+	}
+
+	// 2^(8n) + x for the smallest n whose range includes x
+	n := (new(big.Int).Not(x).BitLen())/8 + 1
+	v := new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+	v.Add(v, x)
+	return v.Bytes()
+}
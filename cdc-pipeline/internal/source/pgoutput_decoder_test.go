@@ -0,0 +1,163 @@
+package source
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	testOrderID = "eed38f7e-fea3-46b4-9536-89a3b1cba1f8"
+	testUserID  = "da0859fb-8eeb-44cd-97f5-df0db4f7a2c3"
+)
+
+var testCommitTime = time.Date(2025, 8, 28, 16, 2, 58, 281604000, time.UTC)
+
+// relation message of the orders table (see sql/migrations/postgres)
+func testOrdersRelation() *pglogrepl.RelationMessage {
+	return &pglogrepl.RelationMessage{
+		RelationID:   16390,
+		Namespace:    "public",
+		RelationName: "orders",
+		Columns: []*pglogrepl.RelationMessageColumn{
+			{Flags: 1, Name: "id", DataType: pgtype.UUIDOID, TypeModifier: -1},
+			{Name: "status", DataType: pgtype.VarcharOID, TypeModifier: 14},
+			{Name: "user_id", DataType: pgtype.UUIDOID, TypeModifier: -1},
+			{Name: "quantity", DataType: pgtype.Int4OID, TypeModifier: -1},
+			{Name: "total_amount", DataType: pgtype.NumericOID, TypeModifier: 10<<16 | 2 + 4}, // numeric(10, 2)
+			{Name: "placed_at", DataType: pgtype.TimestamptzOID, TypeModifier: -1},
+			{Name: "is_deleted", DataType: pgtype.BoolOID, TypeModifier: -1},
+		},
+	}
+}
+
+func textTuple(values ...string) *pglogrepl.TupleData {
+	t := &pglogrepl.TupleData{}
+	for _, v := range values {
+		col := &pglogrepl.TupleDataColumn{DataType: pglogrepl.TupleDataTypeText, Data: []byte(v)}
+		if v == "" {
+			col = &pglogrepl.TupleDataColumn{DataType: pglogrepl.TupleDataTypeNull}
+		}
+		t.Columns = append(t.Columns, col)
+	}
+	return t
+}
+
+func testOrderTuple(status string) *pglogrepl.TupleData {
+	return textTuple(testOrderID, status, testUserID, "2", "100.52", "2025-08-28 16:02:58.281604+00", "f")
+}
+
+func newTestDecoder(t *testing.T) *Decoder {
+	t.Helper()
+	d := NewDecoder()
+	for _, msg := range []pglogrepl.Message{testOrdersRelation(), &pglogrepl.BeginMessage{Xid: 780, CommitTime: testCommitTime}} {
+		if ch, err := d.Decode(0, msg); err != nil || ch != nil {
+			t.Fatalf("unexpected result for %T: %v, %v", msg, ch, err)
+		}
+	}
+	return d
+}
+
+func TestDecoder_Insert(t *testing.T) {
+	d := newTestDecoder(t)
+
+	ch, err := d.Decode(27034688, &pglogrepl.InsertMessage{RelationID: 16390, Tuple: testOrderTuple("PLACED")})
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if ch.Schema != "public" || ch.Table != "orders" || ch.TxID != 780 || ch.Key["id"] != testOrderID {
+		t.Errorf("unexpected change: %+v", ch)
+	}
+
+	ev := ch.Event
+	if ev.Op != "c" || ev.LSN != 27034688 || ev.TsMs != testCommitTime.UnixMilli() || ev.Before != nil {
+		t.Errorf("unexpected change event: %+v", ev)
+	}
+
+	expected := map[string]any{
+		"id":           testOrderID,
+		"status":       "PLACED",
+		"quantity":     int64(2),
+		"total_amount": "J0Q=", // 10052 (unscaled) as two's complement bytes
+		"placed_at":    "2025-08-28T16:02:58.281604Z",
+		"is_deleted":   false,
+	}
+	for col, want := range expected {
+		if got := ev.Row[col]; got != want {
+			t.Errorf("unexpected value of column %s: got %v (%T), want %v", col, got, got, want)
+		}
+	}
+
+	amount := ev.Columns[4]
+	if amount.Logical != "org.apache.kafka.connect.data.Decimal" || amount.Params["scale"] != "2" || amount.Params["connect.decimal.precision"] != "10" {
+		t.Errorf("unexpected decimal column schema: %+v", amount)
+	}
+	if ev.Columns[0].Optional || !ev.Columns[1].Optional {
+		t.Errorf("expected only the key column to be required")
+	}
+}
+
+func TestDecoder_UpdateAndDelete(t *testing.T) {
+	d := newTestDecoder(t)
+
+	ch, err := d.Decode(100, &pglogrepl.UpdateMessage{RelationID: 16390, NewTuple: testOrderTuple("SHIPPED")})
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if ch.Event.Op != "u" || ch.Event.Row["status"] != "SHIPPED" || ch.Event.Before != nil {
+		t.Errorf("unexpected update event: %+v", ch.Event)
+	}
+
+	// delete events only have the replica identity (key) columns
+	oldKey := textTuple(testOrderID, "", "", "", "", "", "")
+	ch, err = d.Decode(101, &pglogrepl.DeleteMessage{RelationID: 16390, OldTupleType: pglogrepl.DeleteMessageTupleTypeKey, OldTuple: oldKey})
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if ch.Event.Op != "d" || ch.Event.Row != nil || ch.Event.Before["id"] != testOrderID || ch.Key["id"] != testOrderID {
+		t.Errorf("unexpected delete event: %+v", ch.Event)
+	}
+}
+
+func TestDecoder_UnknownRelation(t *testing.T) {
+	if _, err := NewDecoder().Decode(1, &pglogrepl.InsertMessage{RelationID: 1, Tuple: textTuple("x")}); err == nil {
+		t.Errorf("expected an error for a change of an unknown relation")
+	}
+}
+
+func TestDecodeTextValue(t *testing.T) {
+	tests := []struct {
+		oid    uint32
+		typmod int32
+		text   string
+		want   any
+	}{
+		{pgtype.DateOID, -1, "2000-08-03", int32(11172)},
+		{pgtype.DateOID, -1, "1969-12-31", int32(-1)},
+		{pgtype.TimestamptzOID, -1, "2025-08-28 18:02:58.5+02", "2025-08-28T16:02:58.5Z"},
+		{pgtype.TimestamptzOID, -1, "2025-08-28 21:32:58+05:30", "2025-08-28T16:02:58Z"},
+		{pgtype.TimestampOID, -1, "1970-01-01 00:00:01.000002", int64(1000002)},
+		{pgtype.NumericOID, 10<<16 | 2 + 4, "-1.28", "gA=="},
+		{pgtype.NumericOID, 10<<16 | 2 + 4, "-1.29", "/38="},
+		{pgtype.NumericOID, 10<<16 | 2 + 4, "1.28", "AIA="},
+		{pgtype.NumericOID, -1, "3.14159", "3.14159"},
+		{pgtype.ByteaOID, -1, `\x0102`, "AQI="},
+		{pgtype.BoolOID, -1, "t", true},
+		{pgtype.Float8OID, -1, "1.5", 1.5},
+	}
+
+	for _, tt := range tests {
+		col := ColumnSchema("c", tt.oid, tt.typmod)
+		got, err := DecodeTextValue(&col, tt.text)
+		if err != nil {
+			t.Errorf("DecodeTextValue(%d, %q) failed: %v", tt.oid, tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("DecodeTextValue(%d, %q) = %v (%T), want %v (%T)", tt.oid, tt.text, got, got, tt.want, tt.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/kafkautils"
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/logger"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/segmentio/kafka-go"
+)
+
+// PostgresSourceConfig configures a PostgresSource
+type PostgresSourceConfig struct {
+	Addr               string
+	User               string
+	Password           string
+	Database           string
+	Publication        string
+	Tables             []string // tables of the publication, if it doesn't exist yet (e.g. "public.users")
+	SlotName           string
+	TopicPrefix        string        // changes of a table are published to `<TopicPrefix>.<schema>.<table>`
+	StandbyTimeout     time.Duration // interval of the status updates sent to Postgres
+	MaxPendingMessages int           // messages of a transaction are written before its commit once this many are pending
+	Tombstones         bool          // publish a tombstone (null value) after every delete, like Debezium
+}
+
+// PostgresSource streams the changes of the tables of a publication from Postgres with the logical replication protocol
+// (pgoutput plugin) and publishes them to Kafka in Debezium's JSON format.
+// The LSN of a transaction is only acknowledged to Postgres after all its changes were written to Kafka,
+// so no change is lost if the source stops (but changes of a transaction may be published again).
+type PostgresSource struct {
+	cfg      PostgresSourceConfig
+	writer   kafkautils.MessageWriter
+	decoder  *Decoder
+	pending  []kafka.Message
+	ackedLSN pglogrepl.LSN // all changes up to this LSN are written to Kafka
+	now      func() time.Time
+
+	streaming atomic.Bool // the replication is started (and not stopped)
+}
+
+// NewPostgresSource returns a new PostgresSource writing to the given writer
+// (which must not have a Topic set, the topic is set on every message)
+func NewPostgresSource(cfg PostgresSourceConfig, writer kafkautils.MessageWriter) *PostgresSource {
+	if cfg.MaxPendingMessages < 1 {
+		cfg.MaxPendingMessages = 1
+	}
+	return &PostgresSource{cfg: cfg, writer: writer, decoder: NewDecoder(), now: time.Now}
+}
+
+// AckedLSN returns the LSN up to which all changes are written to Kafka
+func (s *PostgresSource) AckedLSN() pglogrepl.LSN {
+	return s.ackedLSN
+}
+
+// Streaming returns whether changes are streamed from Postgres, i.e. Run started the replication and didn't return
+func (s *PostgresSource) Streaming() bool {
+	return s.streaming.Load()
+}
+
+// Run connects to Postgres (creating the publication and replication slot if they don't exist)
+// and streams changes until the context is cancelled
+func (s *PostgresSource) Run(ctx context.Context) error {
+	connString := fmt.Sprintf("postgres://%s:%s@%s/%s?replication=database", s.cfg.User, s.cfg.Password, s.cfg.Addr, s.cfg.Database)
+	conn, err := pgconn.Connect(ctx, connString)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if err := s.ensurePublication(ctx, conn); err != nil {
+		return err
+	}
+	if err := s.ensureSlot(ctx, conn); err != nil {
+		return err
+	}
+
+	// start at the slot's confirmed LSN
+	err = pglogrepl.StartReplication(ctx, conn, s.cfg.SlotName, 0, pglogrepl.StartReplicationOptions{
+		PluginArgs: []string{"proto_version '1'", fmt.Sprintf("publication_names '%s'", s.cfg.Publication)},
+	})
+	if err != nil {
+		return fmt.Errorf("start replication: %w", err)
+	}
+	logger.InfoLogger.Printf("postgres source: replication started (slot=%s, publication=%s)\n", s.cfg.SlotName, s.cfg.Publication)
+	s.streaming.Store(true)
+	defer s.streaming.Store(false)
+
+	return s.stream(ctx, conn)
+}
+
+func (s *PostgresSource) ensurePublication(ctx context.Context, conn *pgconn.PgConn) error {
+	res, err := conn.Exec(ctx, fmt.Sprintf("SELECT 1 FROM pg_publication WHERE pubname = '%s'",
+		strings.ReplaceAll(s.cfg.Publication, "'", "''"))).ReadAll()
+	if err != nil {
+		return fmt.Errorf("check publication %s: %w", s.cfg.Publication, err)
+	}
+	if len(res) > 0 && len(res[0].Rows) > 0 {
+		return nil
+	}
+
+	tables := make([]string, len(s.cfg.Tables))
+	for i, t := range s.cfg.Tables {
+		tables[i] = pgx.Identifier(strings.Split(t, ".")).Sanitize()
+	}
+	stmt := fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", pgx.Identifier{s.cfg.Publication}.Sanitize(), strings.Join(tables, ", "))
+	if _, err := conn.Exec(ctx, stmt).ReadAll(); err != nil {
+		return fmt.Errorf("create publication %s: %w", s.cfg.Publication, err)
+	}
+	logger.InfoLogger.Printf("postgres source: created publication %s for %v\n", s.cfg.Publication, s.cfg.Tables)
+	return nil
+}
+
+func (s *PostgresSource) ensureSlot(ctx context.Context, conn *pgconn.PgConn) error {
+	_, err := pglogrepl.CreateReplicationSlot(ctx, conn, s.cfg.SlotName, "pgoutput", pglogrepl.CreateReplicationSlotOptions{})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42710" { // duplicate_object
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("create replication slot %s: %w", s.cfg.SlotName, err)
+	}
+	logger.InfoLogger.Printf("postgres source: created replication slot %s\n", s.cfg.SlotName)
+	return nil
+}
+
+// receive and handle replication messages until the context is cancelled
+func (s *PostgresSource) stream(ctx context.Context, conn *pgconn.PgConn) error {
+	defer func() {
+		// report the final position (the context may already be cancelled)
+		if err := s.sendStatus(context.Background(), conn); err != nil {
+			logger.ErrorLogger.Printf("postgres source: failed to send final status update: %v\n", err)
+		}
+	}()
+
+	nextStatus := s.now().Add(s.cfg.StandbyTimeout)
+	for {
+		if !s.now().Before(nextStatus) {
+			if err := s.sendStatus(ctx, conn); err != nil {
+				return err
+			}
+			nextStatus = s.now().Add(s.cfg.StandbyTimeout)
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		raw, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return nil // stopped
+		}
+		if pgconn.Timeout(err) {
+			continue // time for a status update
+		}
+		if err != nil {
+			return fmt.Errorf("receive replication message: %w", err)
+		}
+
+		switch msg := raw.(type) {
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("replication error: %s (%s)", msg.Message, msg.Code)
+		case *pgproto3.CopyData:
+			switch msg.Data[0] {
+			case pglogrepl.PrimaryKeepaliveMessageByteID:
+				pkm, err := pglogrepl.ParsePrimaryKeepaliveMessage(msg.Data[1:])
+				if err != nil {
+					return fmt.Errorf("parse keepalive message: %w", err)
+				}
+				if pkm.ReplyRequested {
+					nextStatus = time.Time{}
+				}
+			case pglogrepl.XLogDataByteID:
+				xld, err := pglogrepl.ParseXLogData(msg.Data[1:])
+				if err != nil {
+					return fmt.Errorf("parse xlog data: %w", err)
+				}
+				m, err := pglogrepl.Parse(xld.WALData)
+				if err != nil {
+					return fmt.Errorf("parse pgoutput message: %w", err)
+				}
+				if err := s.handleMessage(ctx, xld.WALStart, m); err != nil {
+					return err
+				}
+			}
+		default:
+			logger.DebugLogger.Printf("postgres source: unexpected message %T\n", raw)
+		}
+	}
+}
+
+// acknowledge the written changes to Postgres (so it can recycle the WAL up to that position)
+func (s *PostgresSource) sendStatus(ctx context.Context, conn *pgconn.PgConn) error {
+	err := pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: s.ackedLSN})
+	if err != nil {
+		return fmt.Errorf("send status update: %w", err)
+	}
+	logger.DebugLogger.Printf("postgres source: acknowledged lsn %s\n", s.ackedLSN)
+	return nil
+}
+
+// handle a pgoutput message received at the given LSN
+func (s *PostgresSource) handleMessage(ctx context.Context, lsn pglogrepl.LSN, msg pglogrepl.Message) error {
+	ch, err := s.decoder.Decode(lsn, msg)
+	if err != nil {
+		return fmt.Errorf("decode pgoutput message: %w", err)
+	}
+
+	if ch != nil {
+		msgs, err := s.messages(ch)
+		if err != nil {
+			return err
+		}
+		s.pending = append(s.pending, msgs...)
+		// don't buffer large transactions entirely (their LSN is still only acknowledged after the commit)
+		if len(s.pending) >= s.cfg.MaxPendingMessages {
+			return s.writePending(ctx)
+		}
+		return nil
+	}
+
+	if commit, ok := msg.(*pglogrepl.CommitMessage); ok {
+		if err := s.writePending(ctx); err != nil {
+			return err
+		}
+		s.ackedLSN = commit.TransactionEndLSN
+	}
+	return nil
+}
+
+func (s *PostgresSource) writePending(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.writer.WriteMessages(ctx, s.pending...); err != nil {
+		return fmt.Errorf("write %d messages to kafka: %w", len(s.pending), err)
+	}
+	logger.DebugLogger.Printf("postgres source: wrote %d messages\n", len(s.pending))
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// kafka messages of a change (the change event and a tombstone for deletes)
+func (s *PostgresSource) messages(ch *Change) ([]kafka.Message, error) {
+	topic := DebeziumTopic(s.cfg.TopicPrefix, ch.Schema, ch.Table)
+	key, err := EncodeDebeziumKey(s.cfg.TopicPrefix, ch)
+	if err != nil {
+		return nil, fmt.Errorf("encode key: %w", err)
+	}
+	value, err := EncodeDebeziumValue(s.cfg.TopicPrefix, s.cfg.Database, ch, s.now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+
+	msgs := []kafka.Message{{Topic: topic, Key: key, Value: value}}
+	if ch.Event.Op == "d" && s.cfg.Tombstones {
+		msgs = append(msgs, kafka.Message{Topic: topic, Key: key})
+	}
+	return msgs, nil
+}
@@ -0,0 +1,137 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faizan2786/event-driven-cdc-pipeline/cdc-pipeline/internal/parser"
+	"github.com/jackc/pglogrepl"
+	"github.com/segmentio/kafka-go"
+)
+
+// mockWriter records the written messages (or fails)
+type mockWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *mockWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func newTestPostgresSource(w *mockWriter) *PostgresSource {
+	s := NewPostgresSource(PostgresSourceConfig{
+		Database:           "cdc_db",
+		TopicPrefix:        "cdc",
+		MaxPendingMessages: 100,
+		Tombstones:         true,
+	}, w)
+	s.now = func() time.Time { return testCommitTime.Add(time.Second) }
+	return s
+}
+
+// handle the messages of a transaction with a single insert into orders
+func handleTestTransaction(s *PostgresSource, commitLSN pglogrepl.LSN) error {
+	msgs := []pglogrepl.Message{
+		testOrdersRelation(),
+		&pglogrepl.BeginMessage{Xid: 780, CommitTime: testCommitTime},
+		&pglogrepl.InsertMessage{RelationID: 16390, Tuple: testOrderTuple("PLACED")},
+		&pglogrepl.CommitMessage{CommitLSN: commitLSN - 1, TransactionEndLSN: commitLSN, CommitTime: testCommitTime},
+	}
+	for i, m := range msgs {
+		if err := s.handleMessage(context.Background(), commitLSN-pglogrepl.LSN(len(msgs)-i), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPostgresSource_AcksAfterWrite(t *testing.T) {
+	w := &mockWriter{}
+	s := newTestPostgresSource(w)
+
+	if err := handleTestTransaction(s, 2000); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if len(w.msgs) != 1 || w.msgs[0].Topic != "cdc.public.orders" {
+		t.Fatalf("expected 1 message to cdc.public.orders, got %+v", w.msgs)
+	}
+	if s.AckedLSN() != 2000 {
+		t.Errorf("expected the transaction's end LSN to be acknowledged, got %s", s.AckedLSN())
+	}
+}
+
+func TestPostgresSource_NoAckWhenWriteFails(t *testing.T) {
+	w := &mockWriter{err: errors.New("kafka unavailable")}
+	s := newTestPostgresSource(w)
+
+	if err := handleTestTransaction(s, 2000); err == nil {
+		t.Fatalf("expected an error when the write fails")
+	}
+	if s.AckedLSN() != 0 {
+		t.Errorf("expected no LSN to be acknowledged, got %s", s.AckedLSN())
+	}
+}
+
+func TestPostgresSource_DebeziumCompatibleMessage(t *testing.T) {
+	w := &mockWriter{}
+	s := newTestPostgresSource(w)
+
+	if err := handleTestTransaction(s, 2000); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+
+	// the consumer's parser must read the message like one produced by Debezium
+	ev, err := parser.ParseDebeziumEvent(w.msgs[0].Value)
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	if ev.Op != "c" || ev.LSN != 1998 || ev.TsMs != testCommitTime.Add(time.Second).UnixMilli() || ev.EventID == "" {
+		t.Errorf("unexpected change event: %+v", ev)
+	}
+	if ev.Row["id"] != testOrderID || ev.Row["total_amount"] != "J0Q=" || ev.Row["quantity"] != float64(2) ||
+		ev.Row["placed_at"] != "2025-08-28T16:02:58.281604Z" || ev.Row["is_deleted"] != false {
+		t.Errorf("unexpected row: %v", ev.Row)
+	}
+	if len(ev.Columns) != 7 || ev.Columns[4].Params["scale"] != "2" {
+		t.Errorf("unexpected columns: %+v", ev.Columns)
+	}
+
+	if string(w.msgs[0].Key) != `{"payload":{"id":"`+testOrderID+`"},"schema":{"fields":[{"field":"id","name":"io.debezium.data.Uuid","optional":false,"type":"string","version":1}],"name":"cdc.public.orders.Key","optional":false,"type":"struct"}}` {
+		t.Errorf("unexpected key: %s", w.msgs[0].Key)
+	}
+}
+
+func TestPostgresSource_DeleteTombstone(t *testing.T) {
+	w := &mockWriter{}
+	s := newTestPostgresSource(w)
+
+	msgs := []pglogrepl.Message{
+		testOrdersRelation(),
+		&pglogrepl.BeginMessage{Xid: 781, CommitTime: testCommitTime},
+		&pglogrepl.DeleteMessage{RelationID: 16390, OldTupleType: pglogrepl.DeleteMessageTupleTypeKey, OldTuple: textTuple(testOrderID, "", "", "", "", "", "")},
+		&pglogrepl.CommitMessage{TransactionEndLSN: 3000},
+	}
+	for _, m := range msgs {
+		if err := s.handleMessage(context.Background(), 2999, m); err != nil {
+			t.Fatalf("handleMessage failed: %v", err)
+		}
+	}
+
+	if len(w.msgs) != 2 || w.msgs[1].Value != nil || string(w.msgs[1].Key) != string(w.msgs[0].Key) {
+		t.Fatalf("expected the delete event followed by a tombstone, got %d messages", len(w.msgs))
+	}
+	ev, err := parser.ParseDebeziumEvent(w.msgs[0].Value)
+	if err != nil {
+		t.Fatalf("ParseDebeziumEvent failed: %v", err)
+	}
+	if ev.Op != "d" || ev.Before["id"] != testOrderID {
+		t.Errorf("unexpected delete event: %+v", ev)
+	}
+}
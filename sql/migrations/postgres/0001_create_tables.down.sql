@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS user_is_deleted_trigger ON users;
+DROP FUNCTION IF EXISTS update_order_is_deleted();
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS users;
@@ -32,7 +32,7 @@ END;
 $$ LANGUAGE plpgsql;
 
 -- create a trigger to call above function when is_deleted is set to TRUE in USERS table
-CREATE TRIGGER user_is_deleted_trigger
+CREATE OR REPLACE TRIGGER user_is_deleted_trigger
 AFTER UPDATE OF is_deleted ON users
 FOR EACH ROW
 WHEN (OLD.is_deleted IS FALSE AND NEW.is_deleted IS TRUE)
@@ -0,0 +1,32 @@
+-- replica (reporting) database schema, populated by the postgres replica sink of the CDC consumer
+-- (column names differ from the source tables, see internal/config/postgres_replica_config.go for the mapping)
+
+-- no foreign key from orders to users, as the two tables are replicated independently (from different topics)
+CREATE TABLE IF NOT EXISTS users (
+    user_id UUID PRIMARY KEY,
+    full_name VARCHAR(50) NOT NULL,
+    date_of_birth DATE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    order_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    order_status VARCHAR(10) NOT NULL,
+    quantity INTEGER NOT NULL,
+    total_amount DECIMAL(10, 2) NOT NULL,
+    placed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+
+-- LSN of the last change applied to each replicated row (for idempotency)
+-- `row_key` -> JSON array of the row's primary key values
+CREATE TABLE IF NOT EXISTS replica_row_lsn (
+    table_name TEXT NOT NULL,
+    row_key TEXT NOT NULL,
+    lsn BIGINT NOT NULL,
+    PRIMARY KEY (table_name, row_key)
+);